@@ -139,9 +139,7 @@ func main() {
 	
 	// Start a temporary HTTP server to test endpoints
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", healthManager.HTTPHandler())
-	mux.HandleFunc("/health/ready", healthManager.ReadinessHandler())
-	mux.HandleFunc("/health/live", healthManager.LivenessHandler())
+	healthManager.RegisterRoutes(mux)
 	
 	server := &http.Server{
 		Addr:    ":8888",
@@ -163,6 +161,9 @@ func main() {
 		"http://localhost:8888/health",
 		"http://localhost:8888/health/ready",
 		"http://localhost:8888/health/live",
+		"http://localhost:8888/health?format=ietf",
+		"http://localhost:8888/health/ready?verbose",
+		"http://localhost:8888/health/ready/custom_check",
 	}
 	
 	client := &http.Client{Timeout: 5 * time.Second}
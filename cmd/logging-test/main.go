@@ -156,7 +156,7 @@ func main() {
 		appLogger.Error("Failed to change log level", zap.Error(err))
 	}
 	
-	// These should not appear if level change worked (but it requires restart)
+	// The info message is suppressed now that the level is error
 	logger.Info("This info message should be suppressed if level changed to error")
 	logger.Error("This error message should still appear")
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	_ "github.com/lib/pq"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+// runAPIKey dispatches the apikey subcommands
+func runAPIKey(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: phonic apikey <create|list|revoke> [flags]")
+	}
+
+	fs := flag.NewFlagSet("apikey "+args[0], flag.ExitOnError)
+	env := fs.String("env", "dev", "environment whose configuration is loaded")
+	configPath := fs.String("config", "", "path to a configuration file")
+
+	switch args[0] {
+	case "create":
+		user := fs.String("user", "", "owning user ID (required)")
+		tenant := fs.String("tenant", "", "tenant ID (required)")
+		name := fs.String("name", "", "human-readable key name (required)")
+		scopes := fs.String("scopes", "", "comma-separated scopes")
+		expires := fs.Duration("expires", 0, "key lifetime, e.g. 2160h; 0 never expires")
+		fs.Parse(args[1:])
+
+		if *user == "" || *tenant == "" || *name == "" {
+			return fmt.Errorf("-user, -tenant and -name are required")
+		}
+
+		return withAPIKeys(*env, *configPath, func(ctx context.Context, keys *auth.APIKeyVerifier) error {
+			template := auth.APIKey{
+				UserID:   *user,
+				TenantID: *tenant,
+				Name:     *name,
+				Scopes:   splitScopes(*scopes),
+			}
+			if *expires > 0 {
+				expiresAt := time.Now().Add(*expires)
+				template.ExpiresAt = &expiresAt
+			}
+
+			plaintext, created, err := keys.Create(ctx, template)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Created API key %s (%s). Store it now; it cannot be shown again.\n", created.ID, created.Prefix)
+			fmt.Println(plaintext)
+			return nil
+		})
+
+	case "list":
+		user := fs.String("user", "", "user ID whose keys are listed (required)")
+		fs.Parse(args[1:])
+
+		if *user == "" {
+			return fmt.Errorf("-user is required")
+		}
+
+		return withAPIKeys(*env, *configPath, func(ctx context.Context, keys *auth.APIKeyVerifier) error {
+			list, err := keys.List(ctx, *user)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tPREFIX\tNAME\tTENANT\tSCOPES\tEXPIRES\tLAST USED\tSTATUS")
+			for _, key := range list {
+				status := "active"
+				if err := key.Valid(time.Now()); err != nil {
+					status = strings.TrimPrefix(err.Error(), "api key ")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					key.ID, key.Prefix, key.Name, key.TenantID, strings.Join(key.Scopes, ","),
+					formatTime(key.ExpiresAt), formatTime(key.LastUsedAt), status)
+			}
+			return w.Flush()
+		})
+
+	case "revoke":
+		id := fs.String("id", "", "API key ID (required)")
+		fs.Parse(args[1:])
+
+		if *id == "" {
+			return fmt.Errorf("-id is required")
+		}
+
+		return withAPIKeys(*env, *configPath, func(ctx context.Context, keys *auth.APIKeyVerifier) error {
+			if err := keys.Revoke(ctx, *id); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Revoked API key %s\n", *id)
+			return nil
+		})
+	}
+
+	return fmt.Errorf("unknown apikey command %q", args[0])
+}
+
+// withAPIKeys connects to Postgres and Redis and runs fn with a verifier
+func withAPIKeys(env, configPath string, fn func(ctx context.Context, keys *auth.APIKeyVerifier) error) error {
+	cfg, err := loadConfig(env, configPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := openDatabase(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	cache := redis.NewClient(&redis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.Database,
+	})
+	defer cache.Close()
+
+	return fn(ctx, auth.NewAPIKeyVerifier(auth.NewPostgresAPIKeyStore(db), cache, nil))
+}
+
+// openDatabase opens and pings the configured Postgres database
+func openDatabase(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.GetDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
+}
+
+// formatTime renders an optional timestamp for listings
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
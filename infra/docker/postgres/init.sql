@@ -61,6 +61,22 @@ CREATE TABLE IF NOT EXISTS audio_files (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Create API keys table (keys are stored as SHA-256 hashes only)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(32) NOT NULL, -- 'phk_' + key id, safe to display
+    key_hash CHAR(64) UNIQUE NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- Create indexes for performance
 CREATE INDEX IF NOT EXISTS idx_call_sessions_user_id ON call_sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_call_sessions_status ON call_sessions(status);
@@ -69,6 +85,8 @@ CREATE INDEX IF NOT EXISTS idx_call_events_session_id ON analytics.call_events(s
 CREATE INDEX IF NOT EXISTS idx_call_events_timestamp ON analytics.call_events(timestamp);
 CREATE INDEX IF NOT EXISTS idx_call_events_event_type ON analytics.call_events(event_type);
 CREATE INDEX IF NOT EXISTS idx_audio_files_session_id ON audio_files(session_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys(prefix);
 
 -- Create updated_at trigger function
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -86,6 +104,9 @@ CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users
 CREATE TRIGGER update_call_sessions_updated_at BEFORE UPDATE ON call_sessions
     FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER update_api_keys_updated_at BEFORE UPDATE ON api_keys
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
 -- Grant permissions
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO phonic;
 GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA analytics TO phonic;
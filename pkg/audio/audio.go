@@ -0,0 +1,107 @@
+// Package audio converts between the audio formats on a call's path:
+// G.711 from phone networks at 8kHz, 16kHz PCM for Moshi STT, 24kHz PCM
+// from Moshi TTS and 48kHz for WebRTC. Samples are 16-bit signed PCM,
+// interleaved when there is more than one channel.
+//
+// Functions on the hot path append to a caller-supplied slice and return
+// it, so buffers can be reused across frames without allocating.
+package audio
+
+import (
+	"fmt"
+	"time"
+)
+
+// Sample rates used by the telephony, Moshi and WebRTC legs
+const (
+	Rate8k  = 8000
+	Rate16k = 16000
+	Rate24k = 24000
+	Rate48k = 48000
+)
+
+// Format describes PCM16 audio
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// Validate reports whether the format can describe audio
+func (f Format) Validate() error {
+	if f.SampleRate <= 0 {
+		return fmt.Errorf("invalid sample rate %d", f.SampleRate)
+	}
+	if f.Channels <= 0 {
+		return fmt.Errorf("invalid channel count %d", f.Channels)
+	}
+	return nil
+}
+
+// Samples returns the number of samples, across all channels, in d
+func (f Format) Samples(d time.Duration) int {
+	return int(d*time.Duration(f.SampleRate)/time.Second) * f.Channels
+}
+
+// Duration returns the length of samples interleaved samples
+func (f Format) Duration(samples int) time.Duration {
+	if f.SampleRate <= 0 || f.Channels <= 0 {
+		return 0
+	}
+	return time.Duration(samples/f.Channels) * time.Second / time.Duration(f.SampleRate)
+}
+
+// Frame is a block of PCM16 audio
+type Frame struct {
+	Format
+	// PCM holds interleaved samples
+	PCM []int16
+}
+
+// NewFrame allocates a silent frame of duration d
+func NewFrame(format Format, d time.Duration) Frame {
+	return Frame{Format: format, PCM: make([]int16, format.Samples(d))}
+}
+
+// Frames returns the number of samples per channel
+func (f Frame) Frames() int {
+	if f.Channels <= 0 {
+		return 0
+	}
+	return len(f.PCM) / f.Channels
+}
+
+// Duration returns the length of the frame
+func (f Frame) Duration() time.Duration {
+	return f.Format.Duration(len(f.PCM))
+}
+
+// AppendPCM16 appends pcm to dst as little-endian bytes
+func AppendPCM16(dst []byte, pcm []int16) []byte {
+	for _, s := range pcm {
+		dst = append(dst, byte(s), byte(uint16(s)>>8))
+	}
+	return dst
+}
+
+// AppendSamples appends the little-endian samples in data to dst; a
+// trailing odd byte is ignored
+func AppendSamples(dst []int16, data []byte) []int16 {
+	for i := 0; i+1 < len(data); i += 2 {
+		dst = append(dst, int16(uint16(data[i])|uint16(data[i+1])<<8))
+	}
+	return dst
+}
+
+// clamp16 rounds v and saturates it to the int16 range
+func clamp16(v float64) int16 {
+	switch {
+	case v >= 32767:
+		return 32767
+	case v <= -32768:
+		return -32768
+	case v >= 0:
+		return int16(v + 0.5)
+	default:
+		return int16(v - 0.5)
+	}
+}
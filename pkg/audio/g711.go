@@ -0,0 +1,129 @@
+package audio
+
+// G.711 companding as specified by ITU-T G.711. Decoding uses lookup
+// tables built at init; encoding works on the segment of the sample.
+
+const (
+	muLawBias = 0x84
+	muLawClip = 32635
+)
+
+var (
+	muLawTable [256]int16
+	aLawTable  [256]int16
+)
+
+func init() {
+	for i := range 256 {
+		muLawTable[i] = decodeMuLaw(byte(i))
+		aLawTable[i] = decodeALaw(byte(i))
+	}
+}
+
+// EncodeMuLaw appends the μ-law encoding of pcm to dst
+func EncodeMuLaw(dst []byte, pcm []int16) []byte {
+	for _, s := range pcm {
+		dst = append(dst, MuLaw(s))
+	}
+	return dst
+}
+
+// DecodeMuLaw appends the samples of μ-law data to dst
+func DecodeMuLaw(dst []int16, data []byte) []int16 {
+	for _, b := range data {
+		dst = append(dst, muLawTable[b])
+	}
+	return dst
+}
+
+// EncodeALaw appends the A-law encoding of pcm to dst
+func EncodeALaw(dst []byte, pcm []int16) []byte {
+	for _, s := range pcm {
+		dst = append(dst, ALaw(s))
+	}
+	return dst
+}
+
+// DecodeALaw appends the samples of A-law data to dst
+func DecodeALaw(dst []int16, data []byte) []int16 {
+	for _, b := range data {
+		dst = append(dst, aLawTable[b])
+	}
+	return dst
+}
+
+// MuLaw encodes one sample
+func MuLaw(s int16) byte {
+	v := int(s)
+	sign := 0
+	if v < 0 {
+		// One's complement, as in the ITU-T G.191 reference encoder
+		v = -v - 1
+		sign = 0x80
+	}
+	if v > muLawClip {
+		v = muLawClip
+	}
+	v += muLawBias
+
+	exponent := 7
+	for mask := 0x4000; v&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (v >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+// ALaw encodes one sample
+func ALaw(s int16) byte {
+	v := int(s) >> 3
+	sign := 0x80
+	if v < 0 {
+		v = -v - 1
+		sign = 0
+	}
+
+	var b int
+	if v < 32 {
+		b = v >> 1
+	} else {
+		exponent := 1
+		for v >= 64 && exponent < 7 {
+			v >>= 1
+			exponent++
+		}
+		if v >= 64 {
+			v = 63
+		}
+		b = exponent<<4 | (v>>1)&0x0F
+	}
+	return byte((sign | b) ^ 0x55)
+}
+
+// decodeMuLaw expands one μ-law byte
+func decodeMuLaw(b byte) int16 {
+	b = ^b
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b) & 0x0F
+	v := ((mantissa << 3) + muLawBias) << exponent
+	v -= muLawBias
+	if b&0x80 != 0 {
+		return int16(-v)
+	}
+	return int16(v)
+}
+
+// decodeALaw expands one A-law byte
+func decodeALaw(b byte) int16 {
+	b ^= 0x55
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b) & 0x0F
+	v := mantissa<<4 + 8
+	if exponent > 0 {
+		v = (v + 0x100) << (exponent - 1)
+	}
+	if b&0x80 == 0 {
+		return int16(-v)
+	}
+	return int16(v)
+}
@@ -0,0 +1,175 @@
+package audio
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestG711KnownValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(int16) byte
+		sample int16
+		want   byte
+	}{
+		{"mu-law zero", MuLaw, 0, 0xFF},
+		{"mu-law minus one", MuLaw, -1, 0x7F},
+		{"mu-law max", MuLaw, 32767, 0x80},
+		{"mu-law min", MuLaw, -32768, 0x00},
+		{"a-law zero", ALaw, 0, 0xD5},
+		{"a-law minus one", ALaw, -1, 0x55},
+		{"a-law max", ALaw, 32767, 0xAA},
+		{"a-law min", ALaw, -32768, 0x2A},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.encode(tt.sample); got != tt.want {
+				t.Errorf("encode(%d) = %#02x, want %#02x", tt.sample, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestG711RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(dst []byte, pcm []int16) []byte
+		decode func(dst []int16, data []byte) []int16
+		// peak is the largest magnitude the law can represent
+		peak int16
+	}{
+		{"mu-law", EncodeMuLaw, DecodeMuLaw, 32124},
+		{"a-law", EncodeALaw, DecodeALaw, 32256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := make([]int16, 0, 1<<16)
+			for s := -32768; s <= 32767; s++ {
+				pcm = append(pcm, int16(s))
+			}
+			decoded := tt.decode(nil, tt.encode(nil, pcm))
+			if len(decoded) != len(pcm) {
+				t.Fatalf("decoded %d samples, want %d", len(decoded), len(pcm))
+			}
+
+			// The quantization step grows with the segment, so the error
+			// stays within an eighth of the magnitude
+			for i, s := range pcm {
+				want := min(max(int(s), -int(tt.peak)), int(tt.peak))
+				diff := abs(int(decoded[i]) - want)
+				if diff*8 > abs(int(s))+64 {
+					t.Fatalf("sample %d decoded as %d", s, decoded[i])
+				}
+			}
+
+			// Every code decodes to a value that encodes back to it; μ-law
+			// has a negative zero, which encodes as positive zero
+			for b := range 256 {
+				data := []byte{byte(b)}
+				got := tt.encode(nil, tt.decode(nil, data))[0]
+				if got != byte(b) && !(tt.name == "mu-law" && b == 0x7F && got == 0xFF) {
+					t.Errorf("code %#02x round-trips to %#02x", b, got)
+				}
+			}
+		})
+	}
+}
+
+// TestG711Golden checks the codecs against testdata written by Python's
+// audioop (see testdata/gen_vectors.py)
+func TestG711Golden(t *testing.T) {
+	pcm := readTestWAV(t, "sweep_8k.wav").PCM
+
+	tests := []struct {
+		file   string
+		encode func(dst []byte, pcm []int16) []byte
+		decode func(dst []int16, data []byte) []int16
+	}{
+		{"sweep_8k.ulaw", EncodeMuLaw, DecodeMuLaw},
+		{"sweep_8k.alaw", EncodeALaw, DecodeALaw},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			want, err := os.ReadFile("testdata/" + tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := tt.encode(nil, pcm)
+			if len(got) != len(want) {
+				t.Fatalf("encoded %d bytes, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("sample %d (%d) encoded as %#02x, want %#02x", i, pcm[i], got[i], want[i])
+				}
+			}
+			if again := tt.encode(nil, tt.decode(nil, want)); !bytes.Equal(again, want) {
+				t.Error("decoding and re-encoding the golden data changed it")
+			}
+		})
+	}
+}
+
+func TestG711Appends(t *testing.T) {
+	dst := EncodeMuLaw([]byte{1, 2}, []int16{0, 0})
+	if len(dst) != 4 || dst[0] != 1 || dst[1] != 2 {
+		t.Errorf("EncodeMuLaw did not append: %v", dst)
+	}
+	pcm := DecodeALaw([]int16{7}, []byte{0xD5})
+	if len(pcm) != 2 || pcm[0] != 7 || pcm[1] != 8 {
+		t.Errorf("DecodeALaw did not append: %v", pcm)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// benchmarkFrame is 20ms of 8kHz telephony audio
+func benchmarkFrame() []int16 {
+	pcm := make([]int16, 160)
+	for i := range pcm {
+		pcm[i] = int16(i*409 - 32768)
+	}
+	return pcm
+}
+
+func BenchmarkEncodeMuLaw(b *testing.B) {
+	pcm := benchmarkFrame()
+	dst := make([]byte, 0, len(pcm))
+	b.SetBytes(int64(len(pcm) * 2))
+	for b.Loop() {
+		dst = EncodeMuLaw(dst[:0], pcm)
+	}
+}
+
+func BenchmarkDecodeMuLaw(b *testing.B) {
+	data := EncodeMuLaw(nil, benchmarkFrame())
+	dst := make([]int16, 0, len(data))
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		dst = DecodeMuLaw(dst[:0], data)
+	}
+}
+
+func BenchmarkEncodeALaw(b *testing.B) {
+	pcm := benchmarkFrame()
+	dst := make([]byte, 0, len(pcm))
+	b.SetBytes(int64(len(pcm) * 2))
+	for b.Loop() {
+		dst = EncodeALaw(dst[:0], pcm)
+	}
+}
+
+func BenchmarkDecodeALaw(b *testing.B) {
+	data := EncodeALaw(nil, benchmarkFrame())
+	dst := make([]int16, 0, len(data))
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		dst = DecodeALaw(dst[:0], data)
+	}
+}
@@ -0,0 +1,552 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// ErrInvalidPacket is returned for packets the jitter buffer can't place
+var ErrInvalidPacket = errors.New("invalid audio packet")
+
+// Concealment selects how the jitter buffer fills missing audio
+type Concealment int
+
+// Concealment modes
+const (
+	// ConcealRepeat repeats the previous frame, fading out, then falls
+	// back to silence
+	ConcealRepeat Concealment = iota
+	// ConcealSilence fills gaps with silence
+	ConcealSilence
+)
+
+// concealFade is the gain applied to each repeated frame
+const concealFade = 0.7
+
+// lateBoostDecay is how long it takes the delay added after a late packet
+// to decay by one frame
+const lateBoostDecay = 5 * time.Second
+
+// resyncPackets is how many packets in a row must land more than MaxDelay
+// ahead of playout before the buffer follows them to a new position
+const resyncPackets = 3
+
+// Packet is a decoded RTP-style audio packet
+type Packet struct {
+	// Sequence increments by one per packet and wraps
+	Sequence uint16
+	// Timestamp is the position of the first sample in ClockRate units
+	// and wraps
+	Timestamp uint32
+	// PCM holds the decoded interleaved samples; the jitter buffer keeps
+	// the slice until it is played
+	PCM []int16
+}
+
+// JitterOptions configures a JitterBuffer
+type JitterOptions struct {
+	Format
+	// ClockRate is the RTP timestamp rate; it defaults to SampleRate, but
+	// is 48000 for Opus whatever the decoded rate
+	ClockRate int
+	// FrameDuration is the playout frame length; default 20ms
+	FrameDuration time.Duration
+	// MinDelay and MaxDelay bound the adaptive playout delay; defaults
+	// 20ms and 300ms
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// Concealment fills lost packets; default ConcealRepeat
+	Concealment Concealment
+	// MaxRepeats is the number of frames ConcealRepeat repeats before
+	// silence; default 3
+	MaxRepeats int
+}
+
+// withDefaults fills in unset options
+func (o JitterOptions) withDefaults() JitterOptions {
+	if o.SampleRate <= 0 {
+		o.SampleRate = Rate16k
+	}
+	if o.Channels <= 0 {
+		o.Channels = 1
+	}
+	if o.ClockRate <= 0 {
+		o.ClockRate = o.SampleRate
+	}
+	if o.FrameDuration <= 0 {
+		o.FrameDuration = 20 * time.Millisecond
+	}
+	if o.MinDelay <= 0 {
+		o.MinDelay = o.FrameDuration
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = 300 * time.Millisecond
+	}
+	if o.MaxDelay < o.MinDelay {
+		o.MaxDelay = o.MinDelay
+	}
+	if o.MaxRepeats <= 0 {
+		o.MaxRepeats = 3
+	}
+	return o
+}
+
+// JitterStats describes a jitter buffer's stream
+type JitterStats struct {
+	// Received counts packets, including late ones; Lost is estimated
+	// from sequence numbers as in RFC 3550. Discarded counts packets
+	// dropped for landing too far ahead of playout.
+	Received  int
+	Lost      int
+	Late      int
+	Duplicate int
+	Discarded int
+	// Concealed counts frames with filled gaps, Dropped frames skipped to
+	// cut latency and Underruns frames played with nothing buffered
+	Concealed int
+	Dropped   int
+	Underruns int
+	// Jitter is the RFC 3550 interarrival jitter
+	Jitter time.Duration
+	// Depth is the audio buffered ahead of playout; TargetDelay is what
+	// the buffer adapts it towards
+	Depth       time.Duration
+	TargetDelay time.Duration
+	// Played is the length of audio played out
+	Played time.Duration
+}
+
+// LossRate returns the fraction of expected packets that were lost
+func (s JitterStats) LossRate() float64 {
+	expected := s.Received + s.Lost
+	if expected == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(expected)
+}
+
+// Fields returns the stats as log fields
+func (s JitterStats) Fields() logger.Fields {
+	return logger.Fields{
+		"packets_received":  s.Received,
+		"packets_lost":      s.Lost,
+		"packets_late":      s.Late,
+		"packets_duplicate": s.Duplicate,
+		"packets_discarded": s.Discarded,
+		"loss_rate":         s.LossRate(),
+		"frames_concealed":  s.Concealed,
+		"frames_dropped":    s.Dropped,
+		"underruns":         s.Underruns,
+		"jitter":            s.Jitter.String(),
+		"buffer_depth":      s.Depth.String(),
+		"target_delay":      s.TargetDelay.String(),
+	}
+}
+
+// bufferedPacket is a packet placed on the sample timeline
+type bufferedPacket struct {
+	start int64
+	pcm   []int16
+}
+
+// aheadPacket is a packet held back for landing far ahead of playout
+type aheadPacket struct {
+	bufferedPacket
+	seq int64
+}
+
+// end returns the position after the packet's last frame
+func (p bufferedPacket) end(channels int) int64 {
+	return p.start + int64(len(p.pcm)/channels)
+}
+
+// JitterBuffer reorders network audio and plays it out at a steady rate.
+// Packets are pushed as they arrive and Pop is called once per frame by
+// the playout clock. The playout delay adapts to the measured jitter and
+// to late packets, lost audio is concealed and packets arriving after
+// their playout time are discarded. It is not safe for concurrent use.
+type JitterBuffer struct {
+	opts      JitterOptions
+	frameSize int
+	// packets are ordered by start, in frames at SampleRate
+	packets []bufferedPacket
+	last    []int16
+
+	// Extended timestamp and sequence state
+	haveFirst  bool
+	refTS      uint32
+	refExt     int64
+	firstSeq   int64
+	refSeq     uint16
+	highestSeq int64
+
+	// Jitter state, in seconds
+	firstArrival time.Time
+	firstTS      int64
+	transit      float64
+	jitter       float64
+
+	started   bool
+	next      int64
+	latestEnd int64
+	// boost is extra delay after late packets, in frames
+	boost       float64
+	concealRun  int
+	underrunRun int
+	// ahead holds consecutive packets too far ahead of playout
+	ahead []aheadPacket
+
+	stats JitterStats
+}
+
+// NewJitterBuffer creates a jitter buffer
+func NewJitterBuffer(opts JitterOptions) (*JitterBuffer, error) {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	frames := int(opts.FrameDuration * time.Duration(opts.SampleRate) / time.Second)
+	if frames <= 0 {
+		return nil, fmt.Errorf("invalid jitter buffer frame duration %v", opts.FrameDuration)
+	}
+	return &JitterBuffer{
+		opts:      opts,
+		frameSize: frames * opts.Channels,
+		last:      make([]int16, frames*opts.Channels),
+	}, nil
+}
+
+// FrameSize returns the number of samples Pop appends
+func (j *JitterBuffer) FrameSize() int {
+	return j.frameSize
+}
+
+// Push adds a packet that arrived at arrival
+func (j *JitterBuffer) Push(p Packet, arrival time.Time) error {
+	if len(p.PCM) == 0 || len(p.PCM)%j.opts.Channels != 0 {
+		return fmt.Errorf("%w: %d samples for %d channels", ErrInvalidPacket, len(p.PCM), j.opts.Channels)
+	}
+
+	refTS, refExt, refSeq := j.refTS, j.refExt, j.refSeq
+	ts, seq := j.extend(p.Timestamp, p.Sequence)
+	packet := bufferedPacket{start: ts * int64(j.opts.SampleRate) / int64(j.opts.ClockRate), pcm: p.PCM}
+
+	// A lone packet far ahead of the audio being played is more likely a
+	// corrupt or stray timestamp than the stream moving; buffering it
+	// would make the buffer look deep and skip real audio to reach it, so
+	// it is held back. If the stream keeps arriving there, e.g. after the
+	// sender restarted, the old audio is dropped and playout follows.
+	if len(j.ahead) > 0 && !j.nearAhead(packet.start) {
+		j.ahead = j.ahead[:0]
+	}
+	far := j.farAhead(packet.start)
+	if far && len(j.ahead) < resyncPackets-1 {
+		j.refTS, j.refExt, j.refSeq = refTS, refExt, refSeq
+		j.ahead = append(j.ahead, aheadPacket{packet, seq})
+		j.stats.Discarded++
+		return nil
+	}
+	if len(j.ahead) > 0 {
+		// The held packets were the stream after all
+		if far {
+			j.resync(packet.start, ts, arrival)
+		}
+		j.admitAhead()
+	}
+	j.updateJitter(ts, arrival)
+
+	channels := j.opts.Channels
+	if j.started && packet.end(channels) <= j.next {
+		j.received(seq)
+		j.stats.Late++
+		// Play further behind so the next late packet is in time
+		j.boost = min(j.boost+1, float64(j.maxFrames()))
+		return nil
+	}
+
+	i := sort.Search(len(j.packets), func(i int) bool { return j.packets[i].start >= packet.start })
+	if i < len(j.packets) && j.packets[i].start == packet.start {
+		j.stats.Duplicate++
+		return nil
+	}
+	j.received(seq)
+
+	j.packets = append(j.packets, bufferedPacket{})
+	copy(j.packets[i+1:], j.packets[i:])
+	j.packets[i] = packet
+	j.latestEnd = max(j.latestEnd, packet.end(channels))
+	return nil
+}
+
+// farAhead reports whether a packet starting at start is more than
+// MaxDelay ahead of playout while audio is buffered
+func (j *JitterBuffer) farAhead(start int64) bool {
+	if len(j.packets) == 0 {
+		return false
+	}
+	from := j.packets[0].start
+	if j.started {
+		from = j.next
+	}
+	return start-from > int64(j.maxFrames())*int64(j.frameSize/j.opts.Channels)
+}
+
+// nearAhead reports whether a packet starting at start is within MaxDelay
+// of the held packets
+func (j *JitterBuffer) nearAhead(start int64) bool {
+	d := start - j.ahead[0].start
+	return max(d, -d) <= int64(j.maxFrames())*int64(j.frameSize/j.opts.Channels)
+}
+
+// resync drops the buffered audio and restarts playout from the held
+// packets or the packet at start, whichever is first
+func (j *JitterBuffer) resync(start, ts int64, arrival time.Time) {
+	j.packets = j.packets[:0]
+	j.latestEnd = 0
+	if j.started {
+		j.next = start
+		for _, p := range j.ahead {
+			j.next = min(j.next, p.start)
+		}
+	}
+	// Measure jitter from here, not across the jump
+	j.firstArrival, j.firstTS, j.transit = arrival, ts, 0
+}
+
+// admitAhead buffers the held packets that are still in time
+func (j *JitterBuffer) admitAhead() {
+	channels := j.opts.Channels
+	for _, p := range j.ahead {
+		i := sort.Search(len(j.packets), func(i int) bool { return j.packets[i].start >= p.start })
+		if (j.started && p.end(channels) <= j.next) || (i < len(j.packets) && j.packets[i].start == p.start) {
+			continue
+		}
+		j.received(p.seq)
+		j.stats.Discarded--
+		j.packets = slices.Insert(j.packets, i, p.bufferedPacket)
+		j.latestEnd = max(j.latestEnd, p.end(channels))
+	}
+	j.ahead = j.ahead[:0]
+}
+
+// received counts a packet and updates the loss estimate
+func (j *JitterBuffer) received(seq int64) {
+	j.stats.Received++
+	j.firstSeq = min(j.firstSeq, seq)
+	j.highestSeq = max(j.highestSeq, seq)
+	j.stats.Lost = max(0, int(j.highestSeq-j.firstSeq+1)-j.stats.Received)
+}
+
+// extend unwraps a timestamp and sequence number against the newest seen
+func (j *JitterBuffer) extend(ts uint32, seq uint16) (int64, int64) {
+	if !j.haveFirst {
+		j.haveFirst = true
+		j.refTS, j.refExt = ts, int64(ts)
+		j.refSeq, j.firstSeq, j.highestSeq = seq, int64(seq), int64(seq)
+		return j.refExt, int64(seq)
+	}
+
+	ext := j.refExt + int64(int32(ts-j.refTS))
+	if ext > j.refExt {
+		j.refTS, j.refExt = ts, ext
+	}
+	extSeq := j.highestSeq + int64(int16(seq-j.refSeq))
+	if extSeq > j.highestSeq {
+		j.refSeq = seq
+	}
+	return ext, extSeq
+}
+
+// updateJitter applies the RFC 3550 interarrival jitter estimator
+func (j *JitterBuffer) updateJitter(ts int64, arrival time.Time) {
+	if j.stats.Received+j.stats.Duplicate == 0 {
+		j.firstArrival, j.firstTS = arrival, ts
+	}
+	// Relative to the first packet, so the float math stays exact
+	transit := arrival.Sub(j.firstArrival).Seconds() - float64(ts-j.firstTS)/float64(j.opts.ClockRate)
+	if j.stats.Received+j.stats.Duplicate > 0 {
+		d := math.Abs(transit - j.transit)
+		j.jitter += (d - j.jitter) / 16
+	}
+	j.transit = transit
+}
+
+// target returns the playout delay to buffer, in frames at SampleRate
+func (j *JitterBuffer) target() int64 {
+	frame := j.opts.FrameDuration.Seconds()
+	delay := frame + 4*j.jitter + j.boost*frame
+	delay = min(max(delay, j.opts.MinDelay.Seconds()), j.opts.MaxDelay.Seconds())
+	// Round up to whole frames
+	frames := math.Ceil(delay/frame-1e-9) * frame
+	return int64(frames * float64(j.opts.SampleRate))
+}
+
+// maxFrames returns MaxDelay in playout frames
+func (j *JitterBuffer) maxFrames() int {
+	return max(1, int(j.opts.MaxDelay/j.opts.FrameDuration))
+}
+
+// depth returns the buffered audio ahead of playout, in frames
+func (j *JitterBuffer) depth() int64 {
+	if len(j.packets) == 0 {
+		return 0
+	}
+	from := j.packets[0].start
+	if j.started {
+		from = j.next
+	}
+	return max(0, j.latestEnd-from)
+}
+
+// Pop appends the next FrameSize samples of audio to dst. Before enough
+// audio is buffered, and after the stream stops, it plays silence.
+func (j *JitterBuffer) Pop(dst []int16) []int16 {
+	channels := j.opts.Channels
+	frames := int64(j.frameSize / channels)
+	j.stats.Played += j.opts.FrameDuration
+
+	// Decay the late-packet delay once per lateBoostDecay of playout
+	j.boost = max(0, j.boost-float64(j.opts.FrameDuration)/float64(lateBoostDecay))
+
+	if !j.started {
+		if len(j.packets) == 0 || j.depth() < j.target() {
+			return appendSilence(dst, j.frameSize)
+		}
+		j.started = true
+		j.next = j.packets[0].start
+	}
+
+	// Resynchronize when the stream jumps far ahead, e.g. after the
+	// sender restarts, and skip a frame when the buffer has grown well
+	// past the target
+	if len(j.packets) > 0 && j.packets[0].start-j.next > int64(j.maxFrames())*frames {
+		j.next = j.packets[0].start
+	}
+	if j.depth() > j.target()+2*frames {
+		j.next += frames
+		j.stats.Dropped++
+	}
+
+	start := len(dst)
+	dst = appendSilence(dst, j.frameSize)
+	out := dst[start:]
+
+	// When the next audio hasn't arrived and the buffer is a frame short
+	// of the target, hold playout for a frame rather than skip it; the
+	// delay grows after late packets and the audio may still arrive
+	waiting := len(j.packets) == 0 || j.packets[0].start > j.next
+	if waiting && j.depth()+frames <= j.target() {
+		j.conceal(out, 0, j.frameSize)
+		j.concealed(true)
+		j.underrun(len(j.packets) == 0)
+		copy(j.last, out)
+		return dst
+	}
+	end := j.next + frames
+	pos := j.next
+	copied, concealed := false, false
+	for _, p := range j.packets {
+		if p.start >= end {
+			break
+		}
+		pEnd := p.end(channels)
+		if pEnd <= pos {
+			continue
+		}
+		if p.start > pos {
+			j.conceal(out, int(pos-j.next)*channels, int(p.start-j.next)*channels)
+			concealed = true
+			pos = p.start
+		}
+		n := min(pEnd, end) - pos
+		copy(out[int(pos-j.next)*channels:], p.pcm[int(pos-p.start)*channels:int(pos-p.start+n)*channels])
+		pos += n
+		copied = true
+	}
+	if pos < end {
+		j.conceal(out, int(pos-j.next)*channels, j.frameSize)
+		concealed = true
+	}
+
+	// Release played packets
+	played := 0
+	for played < len(j.packets) && j.packets[played].end(channels) <= end {
+		played++
+	}
+	j.packets = append(j.packets[:0], j.packets[played:]...)
+	j.next = end
+
+	j.concealed(concealed)
+	j.underrun(!copied)
+	copy(j.last, out)
+	return dst
+}
+
+// concealed records whether a frame needed concealment
+func (j *JitterBuffer) concealed(concealed bool) {
+	if !concealed {
+		j.concealRun = 0
+		return
+	}
+	j.stats.Concealed++
+	j.concealRun++
+}
+
+// underrun records whether a frame was played with no audio
+func (j *JitterBuffer) underrun(underrun bool) {
+	if !underrun {
+		j.underrunRun = 0
+		return
+	}
+	j.stats.Underruns++
+	j.underrunRun++
+	// The stream paused; buffer up again before resuming
+	if len(j.packets) == 0 && j.underrunRun > j.maxFrames() {
+		j.started = false
+		j.underrunRun = 0
+	}
+}
+
+// appendSilence appends n zero samples to dst
+func appendSilence(dst []int16, n int) []int16 {
+	start := len(dst)
+	dst = slices.Grow(dst, n)[:start+n]
+	clear(dst[start:])
+	return dst
+}
+
+// conceal fills out[from:to] for missing audio
+func (j *JitterBuffer) conceal(out []int16, from, to int) {
+	if j.opts.Concealment == ConcealSilence || j.concealRun >= j.opts.MaxRepeats {
+		clear(out[from:to])
+		return
+	}
+	for i := from; i < to; i++ {
+		out[i] = int16(float64(j.last[i]) * concealFade)
+	}
+}
+
+// Stats returns the stream's stats so far
+func (j *JitterBuffer) Stats() JitterStats {
+	stats := j.stats
+	stats.Jitter = time.Duration(j.jitter * float64(time.Second))
+	stats.Depth = time.Duration(j.depth()) * time.Second / time.Duration(j.opts.SampleRate)
+	stats.TargetDelay = time.Duration(j.target()) * time.Second / time.Duration(j.opts.SampleRate)
+	return stats
+}
+
+// LogStats logs the stats as audio processing, with the buffer depth as
+// the processing delay and the audio played as the audio length
+func (j *JitterBuffer) LogStats(log *logger.Logger) {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	stats := j.Stats()
+	log.WithFields(stats.Fields()).LogAudioProcessing("jitter_buffer", stats.Depth, stats.Played, j.opts.SampleRate, j.opts.Channels)
+}
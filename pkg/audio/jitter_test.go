@@ -0,0 +1,350 @@
+package audio
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// jitterFrame is 20ms at 8kHz
+const jitterFrame = 160
+
+// arrival is packet n of a stream arriving at a time after the stream
+// started
+type arrival struct {
+	n  int
+	at time.Duration
+}
+
+// onTime returns count packets arriving latency after they were sent,
+// except those in skip
+func onTime(count int, latency time.Duration, skip ...int) []arrival {
+	var out []arrival
+	for n := range count {
+		if !slices.Contains(skip, n) {
+			out = append(out, arrival{n, time.Duration(n)*20*time.Millisecond + latency})
+		}
+	}
+	return out
+}
+
+// delay moves the arrival of packet n later by d
+func delay(arrivals []arrival, n int, d time.Duration) []arrival {
+	for i := range arrivals {
+		if arrivals[i].n == n {
+			arrivals[i].at += d
+		}
+	}
+	return arrivals
+}
+
+// restart returns count packets on time, then count more sent straight
+// after them but numbered from count+skip, as when a sender jumps ahead
+func restart(count, skip int, latency time.Duration) []arrival {
+	out := onTime(count, latency)
+	for n := range count {
+		out = append(out, arrival{count + skip + n, time.Duration(count+n)*20*time.Millisecond + latency})
+	}
+	return out
+}
+
+// marker is the constant sample value of packet n
+func marker(n int) int16 {
+	return int16(1000 * (n + 1))
+}
+
+// playout pushes arrivals as they arrive and pops a frame every 20ms,
+// labelling each frame with the packet it played, "~" for concealment or
+// "-" for silence. Sequence numbers and timestamps start at seq and ts.
+func playout(t *testing.T, j *JitterBuffer, arrivals []arrival, frames int, seq uint16, ts uint32) []string {
+	t.Helper()
+	sort.SliceStable(arrivals, func(a, b int) bool { return arrivals[a].at < arrivals[b].at })
+	start := time.Unix(1700000000, 0)
+
+	var out []string
+	next := 0
+	for f := range frames {
+		now := time.Duration(f) * 20 * time.Millisecond
+		for ; next < len(arrivals) && arrivals[next].at <= now; next++ {
+			a := arrivals[next]
+			pcm := make([]int16, jitterFrame)
+			for i := range pcm {
+				pcm[i] = marker(a.n)
+			}
+			p := Packet{Sequence: seq + uint16(a.n), Timestamp: ts + uint32(a.n*jitterFrame), PCM: pcm}
+			if err := j.Push(p, start.Add(a.at)); err != nil {
+				t.Fatalf("Push packet %d: %v", a.n, err)
+			}
+		}
+
+		frame := j.Pop(nil)
+		if len(frame) != j.FrameSize() {
+			t.Fatalf("Pop returned %d samples, want %d", len(frame), j.FrameSize())
+		}
+		switch v := frame[0]; {
+		case v == 0:
+			out = append(out, "-")
+		case v%1000 == 0:
+			out = append(out, strconv.Itoa(int(v)/1000-1))
+		default:
+			out = append(out, "~")
+		}
+	}
+	return out
+}
+
+// played labels packets from..to-1 as played in order
+func played(from, to int) []string {
+	var out []string
+	for n := from; n < to; n++ {
+		out = append(out, strconv.Itoa(n))
+	}
+	return out
+}
+
+func labels(s ...string) []string {
+	return s
+}
+
+func TestJitterBufferPlayout(t *testing.T) {
+	format := Format{SampleRate: Rate8k, Channels: 1}
+	tests := []struct {
+		name      string
+		opts      JitterOptions
+		arrivals  []arrival
+		seq       uint16
+		ts        uint32
+		want      []string
+		wantStats JitterStats
+	}{
+		{
+			name:      "in order",
+			arrivals:  onTime(10, 5*time.Millisecond),
+			want:      slices.Concat(labels("-"), played(0, 10)),
+			wantStats: JitterStats{Received: 10},
+		},
+		{
+			name: "reordered within the delay",
+			opts: JitterOptions{MinDelay: 40 * time.Millisecond},
+			arrivals: []arrival{
+				{0, 5 * time.Millisecond}, {2, 35 * time.Millisecond}, {1, 38 * time.Millisecond},
+				{4, 62 * time.Millisecond}, {3, 65 * time.Millisecond}, {5, 105 * time.Millisecond},
+				{7, 142 * time.Millisecond}, {6, 145 * time.Millisecond}, {8, 165 * time.Millisecond},
+				{9, 185 * time.Millisecond},
+			},
+			want:      slices.Concat(labels("-", "-"), played(0, 10)),
+			wantStats: JitterStats{Received: 10},
+		},
+		{
+			name:      "late packet played within the delay",
+			opts:      JitterOptions{MinDelay: 40 * time.Millisecond},
+			arrivals:  delay(onTime(10, 5*time.Millisecond), 3, 30*time.Millisecond),
+			want:      slices.Concat(labels("-", "-"), played(0, 10)),
+			wantStats: JitterStats{Received: 10},
+		},
+		{
+			name:      "late packet discarded",
+			opts:      JitterOptions{MinDelay: 40 * time.Millisecond},
+			arrivals:  delay(onTime(10, 5*time.Millisecond), 3, 100*time.Millisecond),
+			want:      slices.Concat(labels("-", "-"), played(0, 3), labels("~"), played(4, 10)),
+			wantStats: JitterStats{Received: 10, Late: 1, Concealed: 1},
+		},
+		{
+			name:      "duplicate ignored",
+			arrivals:  append(onTime(10, 5*time.Millisecond), arrival{4, 90 * time.Millisecond}),
+			want:      slices.Concat(labels("-"), played(0, 10)),
+			wantStats: JitterStats{Received: 10, Duplicate: 1},
+		},
+		{
+			name:      "lost packet concealed",
+			opts:      JitterOptions{MinDelay: 60 * time.Millisecond},
+			arrivals:  onTime(10, 5*time.Millisecond, 4),
+			want:      slices.Concat(labels("-", "-", "-"), played(0, 4), labels("~"), played(5, 10)),
+			wantStats: JitterStats{Received: 9, Lost: 1, Concealed: 1},
+		},
+		{
+			name:      "lost packet filled with silence",
+			opts:      JitterOptions{MinDelay: 60 * time.Millisecond, Concealment: ConcealSilence},
+			arrivals:  onTime(10, 5*time.Millisecond, 4),
+			want:      slices.Concat(labels("-", "-", "-"), played(0, 4), labels("-"), played(5, 10)),
+			wantStats: JitterStats{Received: 9, Lost: 1, Concealed: 1},
+		},
+		{
+			// The buffer waits for the missing audio, so the burst stretches
+			// playout; repeats fade out after MaxRepeats
+			name:      "burst loss",
+			opts:      JitterOptions{MinDelay: 60 * time.Millisecond},
+			arrivals:  onTime(14, 5*time.Millisecond, 4, 5, 6, 7, 8),
+			want:      slices.Concat(labels("-", "-", "-"), played(0, 4), labels("~", "~", "~", "-", "-", "-", "-"), played(9, 14)),
+			wantStats: JitterStats{Received: 9, Lost: 5, Concealed: 7, Dropped: 1},
+		},
+		{
+			name:      "sequence and timestamp wrap",
+			arrivals:  onTime(10, 5*time.Millisecond, 5),
+			seq:       65532,
+			ts:        math.MaxUint32 - 3*jitterFrame + 1,
+			opts:      JitterOptions{MinDelay: 60 * time.Millisecond},
+			want:      slices.Concat(labels("-", "-", "-"), played(0, 5), labels("~"), played(6, 10)),
+			wantStats: JitterStats{Received: 9, Lost: 1, Concealed: 1},
+		},
+		{
+			// A lone packet far ahead must not drag playout forward
+			name:      "stray packet far ahead",
+			arrivals:  append(onTime(10, 5*time.Millisecond), arrival{30, 50 * time.Millisecond}),
+			want:      slices.Concat(labels("-"), played(0, 10)),
+			wantStats: JitterStats{Received: 10, Discarded: 1},
+		},
+		{
+			// The sender jumps ahead and stays there, so playout follows it
+			name:      "stream jumps ahead",
+			opts:      JitterOptions{MinDelay: 60 * time.Millisecond, MaxDelay: 100 * time.Millisecond},
+			arrivals:  restart(10, 10, 5*time.Millisecond),
+			want:      slices.Concat(labels("-", "-", "-"), played(0, 10), played(20, 30)),
+			wantStats: JitterStats{Received: 20, Lost: 10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Format = format
+			j, err := NewJitterBuffer(tt.opts)
+			if err != nil {
+				t.Fatalf("NewJitterBuffer: %v", err)
+			}
+			got := playout(t, j, tt.arrivals, len(tt.want), tt.seq, tt.ts)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("played %v, want %v", got, tt.want)
+			}
+
+			stats := j.Stats()
+			w := tt.wantStats
+			if stats.Received != w.Received || stats.Lost != w.Lost || stats.Late != w.Late ||
+				stats.Duplicate != w.Duplicate || stats.Concealed != w.Concealed || stats.Dropped != w.Dropped ||
+				stats.Discarded != w.Discarded {
+				t.Errorf("stats %+v, want %+v", stats, w)
+			}
+			if stats.Played != time.Duration(len(tt.want))*20*time.Millisecond {
+				t.Errorf("played %v of audio, want %d frames", stats.Played, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestJitterBufferConcealmentFades(t *testing.T) {
+	j, err := NewJitterBuffer(JitterOptions{Format: Format{SampleRate: Rate8k, Channels: 1}, MinDelay: 60 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	playout(t, j, onTime(5, 5*time.Millisecond), 8, 0, 0)
+
+	// Packet 4 was the last played; the next frames repeat it, fading
+	want := float64(marker(4))
+	for i := range j.opts.MaxRepeats {
+		want *= concealFade
+		frame := j.Pop(nil)
+		if got := frame[0]; got != int16(want) {
+			t.Errorf("repeat %d is %d, want %d", i+1, got, int16(want))
+		}
+	}
+	if frame := j.Pop(nil); Peak(frame) != 0 {
+		t.Errorf("frame after MaxRepeats peaks at %d, want silence", Peak(frame))
+	}
+}
+
+func TestJitterBufferAdaptsDelay(t *testing.T) {
+	format := Format{SampleRate: Rate8k, Channels: 1}
+	j, err := NewJitterBuffer(JitterOptions{Format: format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	playout(t, j, onTime(50, 5*time.Millisecond), 50, 0, 0)
+	steady := j.Stats()
+	if steady.Jitter != 0 || steady.TargetDelay != 20*time.Millisecond {
+		t.Errorf("steady stream has jitter %v and target %v, want 0 and 20ms", steady.Jitter, steady.TargetDelay)
+	}
+
+	// Packets alternating between 5ms and 35ms of latency
+	j, err = NewJitterBuffer(JitterOptions{Format: format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrivals := onTime(50, 5*time.Millisecond)
+	for i := range arrivals {
+		if i%2 == 1 {
+			arrivals[i].at += 30 * time.Millisecond
+		}
+	}
+	playout(t, j, arrivals, 50, 0, 0)
+	jittery := j.Stats()
+	if jittery.Jitter < 20*time.Millisecond || jittery.Jitter > 35*time.Millisecond {
+		t.Errorf("jitter %v, want about 30ms", jittery.Jitter)
+	}
+	if jittery.TargetDelay <= steady.TargetDelay || jittery.TargetDelay > 300*time.Millisecond {
+		t.Errorf("target delay %v, want above %v and within MaxDelay", jittery.TargetDelay, steady.TargetDelay)
+	}
+}
+
+func TestJitterBufferPush(t *testing.T) {
+	j, err := NewJitterBuffer(JitterOptions{Format: Format{SampleRate: Rate48k, Channels: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		pcm     []int16
+		wantErr error
+	}{
+		{"empty", nil, ErrInvalidPacket},
+		{"partial frame", make([]int16, 3), ErrInvalidPacket},
+		{"stereo", make([]int16, 1920), nil},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := j.Push(Packet{Sequence: uint16(i), Timestamp: uint32(i * 960), PCM: tt.pcm}, time.Now())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJitterStatsLossRate(t *testing.T) {
+	tests := []struct {
+		stats JitterStats
+		want  float64
+	}{
+		{JitterStats{}, 0},
+		{JitterStats{Received: 99, Lost: 1}, 0.01},
+		{JitterStats{Received: 3, Lost: 1}, 0.25},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.LossRate(); got != tt.want {
+			t.Errorf("LossRate of %+v = %v, want %v", tt.stats, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkJitterBuffer(b *testing.B) {
+	j, err := NewJitterBuffer(JitterOptions{Format: Format{SampleRate: Rate16k, Channels: 1}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	pcm := make([]int16, j.FrameSize())
+	dst := make([]int16, 0, j.FrameSize())
+	start := time.Now()
+	n := 0
+	b.SetBytes(int64(len(pcm) * 2))
+	for b.Loop() {
+		// Swap every other pair of packets
+		seq := n ^ 1
+		p := Packet{Sequence: uint16(seq), Timestamp: uint32(seq * len(pcm)), PCM: pcm}
+		if err := j.Push(p, start.Add(time.Duration(n)*20*time.Millisecond)); err != nil {
+			b.Fatal(err)
+		}
+		dst = j.Pop(dst[:0])
+		n++
+	}
+}
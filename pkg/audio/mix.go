@@ -0,0 +1,105 @@
+package audio
+
+import "math"
+
+// Downmix appends the average of each frame's channels in pcm to dst
+func Downmix(dst, pcm []int16, channels int) []int16 {
+	if channels <= 1 {
+		return append(dst, pcm...)
+	}
+	for i := 0; i+channels <= len(pcm); i += channels {
+		sum := 0
+		for _, s := range pcm[i : i+channels] {
+			sum += int(s)
+		}
+		dst = append(dst, int16(sum/channels))
+	}
+	return dst
+}
+
+// ToMono converts a frame to a single channel
+func ToMono(f Frame) Frame {
+	if f.Channels <= 1 {
+		return f
+	}
+	return Frame{
+		Format: Format{SampleRate: f.SampleRate, Channels: 1},
+		PCM:    Downmix(make([]int16, 0, f.Frames()), f.PCM, f.Channels),
+	}
+}
+
+// DecibelsToGain converts a level change in dB to a linear factor
+func DecibelsToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// GainToDecibels converts a linear factor to dB
+func GainToDecibels(gain float64) float64 {
+	return 20 * math.Log10(gain)
+}
+
+// ApplyGain scales pcm in place by a linear factor, saturating at the
+// int16 range
+func ApplyGain(pcm []int16, gain float64) {
+	if gain == 1 {
+		return
+	}
+	for i, s := range pcm {
+		pcm[i] = clamp16(float64(s) * gain)
+	}
+}
+
+// Peak returns the largest absolute sample value
+func Peak(pcm []int16) int {
+	peak := 0
+	for _, s := range pcm {
+		v := int(s)
+		if v < 0 {
+			v = -v
+		}
+		peak = max(peak, v)
+	}
+	return peak
+}
+
+// RMS returns the root mean square of the samples
+func RMS(pcm []int16) float64 {
+	if len(pcm) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range pcm {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(pcm)))
+}
+
+// Level returns the RMS level in dBFS, or -Inf for silence
+func Level(pcm []int16) float64 {
+	return GainToDecibels(RMS(pcm) / 32768)
+}
+
+// NormalizePeak scales pcm in place so its peak is at target dBFS and
+// returns the gain applied. Silence is left unchanged.
+func NormalizePeak(pcm []int16, target float64) float64 {
+	peak := Peak(pcm)
+	if peak == 0 {
+		return 1
+	}
+	gain := DecibelsToGain(target) * 32767 / float64(peak)
+	ApplyGain(pcm, gain)
+	return gain
+}
+
+// NormalizeRMS scales pcm in place towards an RMS level of target dBFS,
+// limiting the gain to maxGain dB so near-silence is not amplified into
+// noise. Samples saturate rather than wrap. It returns the gain applied.
+func NormalizeRMS(pcm []int16, target, maxGain float64) float64 {
+	rms := RMS(pcm)
+	if rms == 0 {
+		return 1
+	}
+	gain := min(DecibelsToGain(target)*32768/rms, DecibelsToGain(maxGain))
+	ApplyGain(pcm, gain)
+	return gain
+}
@@ -0,0 +1,248 @@
+package audio
+
+import (
+	"math"
+	"os"
+	"slices"
+	"testing"
+)
+
+// readTestWAV reads a WAV file from testdata
+func readTestWAV(t *testing.T, name string) Frame {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	frame, err := ReadWAV(f)
+	if err != nil {
+		t.Fatalf("ReadWAV(%s): %v", name, err)
+	}
+	return frame
+}
+
+func TestDownmix(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []int16
+		channels int
+		want     []int16
+	}{
+		{"mono is copied", []int16{1, -2, 3}, 1, []int16{1, -2, 3}},
+		{"stereo average", []int16{100, 300, -100, -300, 7, 8}, 2, []int16{200, -200, 7}},
+		// Integer division truncates towards zero
+		{"odd sums", []int16{1, 2, -1, -2}, 2, []int16{1, -1}},
+		{"full scale does not overflow", []int16{32767, 32767, -32768, -32768, 32767, -32768}, 2, []int16{32767, -32768, 0}},
+		{"three channels", []int16{3, 6, 9, -3, -6, -9}, 3, []int16{6, -6}},
+		{"partial frame dropped", []int16{10, 20, 30}, 2, []int16{15}},
+		{"silence", make([]int16, 4), 2, []int16{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := []int16{-1}
+			got := Downmix(slices.Clone(prefix), tt.pcm, tt.channels)
+			if !slices.Equal(got, append(prefix, tt.want...)) {
+				t.Errorf("Downmix = %v, want %v appended to %v", got, tt.want, prefix)
+			}
+		})
+	}
+}
+
+func TestToMono(t *testing.T) {
+	stereo := Frame{Format: Format{SampleRate: Rate8k, Channels: 2}, PCM: []int16{10, 20, 30, 40}}
+	mono := ToMono(stereo)
+	if mono.Channels != 1 || mono.SampleRate != Rate8k || !slices.Equal(mono.PCM, []int16{15, 35}) {
+		t.Errorf("ToMono = %+v", mono)
+	}
+	if mono.Duration() != stereo.Duration() {
+		t.Errorf("duration %s, want %s", mono.Duration(), stereo.Duration())
+	}
+
+	already := Frame{Format: Format{SampleRate: Rate8k, Channels: 1}, PCM: []int16{1, 2}}
+	if got := ToMono(already); &got.PCM[0] != &already.PCM[0] {
+		t.Error("ToMono copied a mono frame")
+	}
+}
+
+func TestDownmixGolden(t *testing.T) {
+	stereo := readTestWAV(t, "sweep_8k_stereo.wav")
+	want := readTestWAV(t, "sweep_8k_downmix.wav")
+	got := ToMono(stereo)
+	if got.Frames() != want.Frames() {
+		t.Fatalf("%d frames, want %d", got.Frames(), want.Frames())
+	}
+	// audioop averages in floating point and floors, so odd negative sums
+	// differ from integer division by one
+	for i, s := range got.PCM {
+		if diff := int(s) - int(want.PCM[i]); diff < 0 || diff > 1 {
+			t.Fatalf("sample %d = %d, want %d", i, s, want.PCM[i])
+		}
+	}
+}
+
+func TestDecibels(t *testing.T) {
+	tests := []struct {
+		db   float64
+		gain float64
+	}{
+		{0, 1},
+		{20, 10},
+		{-20, 0.1},
+		{-6.0206, 0.5},
+	}
+	for _, tt := range tests {
+		if got := DecibelsToGain(tt.db); math.Abs(got-tt.gain) > 1e-4 {
+			t.Errorf("DecibelsToGain(%v) = %v, want %v", tt.db, got, tt.gain)
+		}
+		if got := GainToDecibels(tt.gain); math.Abs(got-tt.db) > 1e-4 {
+			t.Errorf("GainToDecibels(%v) = %v, want %v", tt.gain, got, tt.db)
+		}
+	}
+}
+
+func TestApplyGain(t *testing.T) {
+	tests := []struct {
+		name string
+		pcm  []int16
+		gain float64
+		want []int16
+	}{
+		{"unity", []int16{1, -1, 32767}, 1, []int16{1, -1, 32767}},
+		{"double", []int16{100, -100, 0}, 2, []int16{200, -200, 0}},
+		{"rounds half away from zero", []int16{1, -1, 3}, 0.5, []int16{1, -1, 2}},
+		{"saturates", []int16{20000, -20000, 16384, -16385}, 2, []int16{32767, -32768, 32767, -32768}},
+		{"invert min saturates", []int16{-32768}, -1, []int16{32767}},
+		{"mute", []int16{123, -456}, 0, []int16{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := slices.Clone(tt.pcm)
+			ApplyGain(pcm, tt.gain)
+			if !slices.Equal(pcm, tt.want) {
+				t.Errorf("ApplyGain(%v, %v) = %v, want %v", tt.pcm, tt.gain, pcm, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeakRMSLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		pcm       []int16
+		wantPeak  int
+		wantRMS   float64
+		wantLevel float64
+	}{
+		{"empty", nil, 0, 0, math.Inf(-1)},
+		{"silence", make([]int16, 160), 0, 0, math.Inf(-1)},
+		// The most negative sample has a larger magnitude than the most
+		// positive one
+		{"min sample", []int16{-32768, 32767}, 32768, 32767.5, -0.0000133},
+		{"square wave", []int16{16384, -16384, 16384, -16384}, 16384, 16384, -6.0206},
+		{"dc", []int16{-100, -100}, 100, 100, -50.3090},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Peak(tt.pcm); got != tt.wantPeak {
+				t.Errorf("Peak = %d, want %d", got, tt.wantPeak)
+			}
+			if got := RMS(tt.pcm); math.Abs(got-tt.wantRMS) > 0.01 {
+				t.Errorf("RMS = %v, want %v", got, tt.wantRMS)
+			}
+			got := Level(tt.pcm)
+			if math.IsInf(tt.wantLevel, -1) {
+				if !math.IsInf(got, -1) {
+					t.Errorf("Level = %v, want -Inf", got)
+				}
+				return
+			}
+			if math.Abs(got-tt.wantLevel) > 1e-3 {
+				t.Errorf("Level = %v dBFS, want %v", got, tt.wantLevel)
+			}
+		})
+	}
+
+	// A full-scale sine is 3 dB below a full-scale square
+	sine := sine(1000, 32767, Rate16k, 1, Rate16k/10)
+	if got := Level(sine); math.Abs(got+3.01) > 0.05 {
+		t.Errorf("full-scale sine level %v dBFS, want -3.01", got)
+	}
+}
+
+func TestNormalizePeak(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []int16
+		target   float64
+		wantPeak int
+		wantGain float64
+	}{
+		{"boost", []int16{1000, -500, 250}, 0, 32767, 32.767},
+		{"cut", []int16{32767, -16384}, -6.0206, 16384, 0.5},
+		{"negative peak", []int16{100, -2000}, 0, 32767, 16.3835},
+		{"silence", make([]int16, 8), 0, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := slices.Clone(tt.pcm)
+			gain := NormalizePeak(pcm, tt.target)
+			if math.Abs(gain-tt.wantGain) > 1e-3 {
+				t.Errorf("gain %v, want %v", gain, tt.wantGain)
+			}
+			if got := Peak(pcm); abs(got-tt.wantPeak) > 1 {
+				t.Errorf("peak %d, want %d", got, tt.wantPeak)
+			}
+		})
+	}
+}
+
+func TestNormalizeRMS(t *testing.T) {
+	quiet := sine(440, 1000, Rate16k, 1, Rate16k/10)
+	loud := sine(440, 30000, Rate16k, 1, Rate16k/10)
+
+	tests := []struct {
+		name      string
+		pcm       []int16
+		target    float64
+		maxGain   float64
+		wantLevel float64
+		// wantClipped is whether samples saturate at full scale
+		wantClipped bool
+	}{
+		{name: "boost", pcm: quiet, target: -20, maxGain: 30, wantLevel: -20},
+		{name: "cut", pcm: loud, target: -20, maxGain: 30, wantLevel: -20},
+		// The gain limit stops quiet input from reaching the target
+		{name: "limited", pcm: quiet, target: -20, maxGain: 6, wantLevel: Level(quiet) + 6},
+		// A sine at -1 dBFS RMS would peak 2 dB above full scale; clipping
+		// costs about a dB of the target
+		{name: "saturates", pcm: quiet, target: -1, maxGain: 60, wantLevel: -2, wantClipped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := slices.Clone(tt.pcm)
+			gain := NormalizeRMS(pcm, tt.target, tt.maxGain)
+			if gain > DecibelsToGain(tt.maxGain)+1e-9 {
+				t.Errorf("gain %v exceeds the %v dB limit", gain, tt.maxGain)
+			}
+			if got := Level(pcm); math.Abs(got-tt.wantLevel) > 0.2 {
+				t.Errorf("level %.2f dBFS, want %.2f", got, tt.wantLevel)
+			}
+			if clipped := slices.Contains(pcm, 32767); clipped != tt.wantClipped {
+				t.Errorf("clipped = %v, want %v", clipped, tt.wantClipped)
+			}
+		})
+	}
+
+	silence := make([]int16, 8)
+	if gain := NormalizeRMS(silence, -20, 30); gain != 1 || Peak(silence) != 0 {
+		t.Errorf("silence got gain %v", gain)
+	}
+}
+
+func BenchmarkNormalizeRMS(b *testing.B) {
+	pcm := benchmarkFrame()
+	for b.Loop() {
+		NormalizeRMS(pcm, -20, 0)
+	}
+}
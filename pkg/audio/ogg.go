@@ -0,0 +1,226 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+)
+
+// Ogg page header flags
+const (
+	oggFirst = 0x02
+	oggLast  = 0x04
+)
+
+// oggPageSamples is the audio per page; RFC 7845 recommends pages of at
+// most one second so seeking stays cheap
+const oggPageSamples = OpusRate
+
+// oggCRC is the lookup table for the Ogg CRC-32: polynomial 0x04C11DB7,
+// unreflected, zero initial value and no final XOR
+var oggCRC = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04C11DB7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// OggOpusOptions configures an OggOpusWriter
+type OggOpusOptions struct {
+	// Channels of the encoded stream; default 1
+	Channels int
+	// InputSampleRate is the rate of the audio before encoding, recorded
+	// for players; default 48000
+	InputSampleRate int
+	// PreSkip is the encoder lookahead to discard on playback, in samples
+	// at 48kHz; see OpusEncoder.Lookahead. Default 312.
+	PreSkip int
+	// Serial identifies the logical stream; random by default
+	Serial uint32
+	// Vendor is recorded in the comment header; default "phonic"
+	Vendor string
+	// Comments are "KEY=value" tags, e.g. "TITLE=call 42"
+	Comments []string
+}
+
+// withDefaults fills in unset options
+func (o OggOpusOptions) withDefaults() OggOpusOptions {
+	if o.Channels <= 0 {
+		o.Channels = 1
+	}
+	if o.InputSampleRate <= 0 {
+		o.InputSampleRate = OpusRate
+	}
+	if o.PreSkip <= 0 {
+		o.PreSkip = 312
+	}
+	if o.Serial == 0 {
+		o.Serial = rand.Uint32()
+	}
+	if o.Vendor == "" {
+		o.Vendor = "phonic"
+	}
+	return o
+}
+
+// OggOpusWriter writes Opus packets to an Ogg/Opus file (RFC 7845), e.g.
+// for call recordings. It is not safe for concurrent use.
+type OggOpusWriter struct {
+	w        io.Writer
+	opts     OggOpusOptions
+	sequence uint32
+	// granule counts the samples written at 48kHz, including the
+	// pre-skip, as RFC 7845 defines granule positions
+	granule int64
+
+	// The page being built
+	segments    []byte
+	body        []byte
+	pageSamples int
+	page        []byte
+	closed      bool
+}
+
+// NewOggOpusWriter writes the Opus headers to w and returns a writer for
+// the packets
+func NewOggOpusWriter(w io.Writer, opts OggOpusOptions) (*OggOpusWriter, error) {
+	opts = opts.withDefaults()
+	if opts.Channels > 2 {
+		return nil, fmt.Errorf("unsupported ogg opus channel count %d", opts.Channels)
+	}
+	ow := &OggOpusWriter{w: w, opts: opts}
+
+	// Identification header
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = byte(opts.Channels)
+	binary.LittleEndian.PutUint16(head[10:12], uint16(opts.PreSkip))
+	binary.LittleEndian.PutUint32(head[12:16], uint32(opts.InputSampleRate))
+	if err := ow.writePage(oggFirst, 0, [][]byte{head}); err != nil {
+		return nil, err
+	}
+
+	// Comment header
+	tags := append([]byte("OpusTags"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(tags[8:12], uint32(len(opts.Vendor)))
+	tags = append(tags, opts.Vendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(opts.Comments)))
+	for _, c := range opts.Comments {
+		tags = binary.LittleEndian.AppendUint32(tags, uint32(len(c)))
+		tags = append(tags, c...)
+	}
+	if err := ow.writePage(0, 0, [][]byte{tags}); err != nil {
+		return nil, err
+	}
+
+	return ow, nil
+}
+
+// WritePacket adds an encoded packet; its duration is read from the
+// packet's TOC byte
+func (ow *OggOpusWriter) WritePacket(packet []byte) error {
+	if ow.closed {
+		return fmt.Errorf("ogg opus writer closed")
+	}
+	samples, err := OpusPacketSamples(packet)
+	if err != nil {
+		return err
+	}
+
+	// A packet of n bytes takes n/255+1 lacing values
+	lacing := len(packet)/255 + 1
+	if len(ow.segments)+lacing > 255 || ow.pageSamples >= oggPageSamples {
+		if err := ow.flush(0); err != nil {
+			return err
+		}
+	}
+	ow.segments = appendLacing(ow.segments, len(packet))
+	ow.body = append(ow.body, packet...)
+	ow.pageSamples += samples
+	ow.granule += int64(samples)
+	return nil
+}
+
+// Duration returns the audio written so far, excluding the pre-skip, in
+// samples at 48kHz
+func (ow *OggOpusWriter) Duration() int64 {
+	return max(0, ow.granule-int64(ow.opts.PreSkip))
+}
+
+// Close writes the last page, marked as the end of the stream; it does
+// not close the underlying writer
+func (ow *OggOpusWriter) Close() error {
+	if ow.closed {
+		return nil
+	}
+	ow.closed = true
+	return ow.flush(oggLast)
+}
+
+// flush writes the buffered packets as a page
+func (ow *OggOpusWriter) flush(flags byte) error {
+	if len(ow.segments) == 0 && flags&oggLast == 0 {
+		return nil
+	}
+	err := ow.writeRawPage(flags, ow.granule, ow.segments, ow.body)
+	ow.segments = ow.segments[:0]
+	ow.body = ow.body[:0]
+	ow.pageSamples = 0
+	return err
+}
+
+// writePage writes packets, each under 255*255 bytes, as one page
+func (ow *OggOpusWriter) writePage(flags byte, granule int64, packets [][]byte) error {
+	var segments, body []byte
+	for _, p := range packets {
+		segments = appendLacing(segments, len(p))
+		body = append(body, p...)
+	}
+	return ow.writeRawPage(flags, granule, segments, body)
+}
+
+// appendLacing appends the lacing values for a packet of n bytes: 255 for
+// each full segment and the remainder, which may be zero
+func appendLacing(segments []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		segments = append(segments, 255)
+	}
+	return append(segments, byte(n))
+}
+
+// writeRawPage frames a page body and writes it
+func (ow *OggOpusWriter) writeRawPage(flags byte, granule int64, segments, body []byte) error {
+	page := append(ow.page[:0], "OggS"...)
+	page = append(page, 0, flags)
+	page = binary.LittleEndian.AppendUint64(page, uint64(granule))
+	page = binary.LittleEndian.AppendUint32(page, ow.opts.Serial)
+	page = binary.LittleEndian.AppendUint32(page, ow.sequence)
+	page = append(page, 0, 0, 0, 0)
+	page = append(page, byte(len(segments)))
+	page = append(page, segments...)
+	page = append(page, body...)
+
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(page[22:26], crc)
+
+	ow.page = page
+	ow.sequence++
+	if _, err := ow.w.Write(page); err != nil {
+		return fmt.Errorf("failed to write ogg page: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// oggPage is a parsed Ogg page
+type oggPage struct {
+	flags    byte
+	granule  int64
+	serial   uint32
+	sequence uint32
+	segments []byte
+	body     []byte
+}
+
+// oggChecksum is the Ogg CRC-32 of data
+func oggChecksum(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// readOggPages splits data into pages, checking the framing and CRCs
+func readOggPages(t *testing.T, data []byte) []oggPage {
+	t.Helper()
+	var pages []oggPage
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" || data[4] != 0 {
+			t.Fatalf("page %d: bad header % x", len(pages), data[:min(len(data), 27)])
+		}
+		n := int(data[26])
+		if len(data) < 27+n {
+			t.Fatalf("page %d: truncated segment table", len(pages))
+		}
+		segments := data[27 : 27+n]
+		size := 27 + n
+		for _, s := range segments {
+			size += int(s)
+		}
+		if len(data) < size {
+			t.Fatalf("page %d: %d bytes, want %d", len(pages), len(data), size)
+		}
+		raw := slices.Clone(data[:size])
+		want := binary.LittleEndian.Uint32(raw[22:26])
+		clear(raw[22:26])
+		if got := oggChecksum(raw); got != want {
+			t.Fatalf("page %d: CRC %#08x, header says %#08x", len(pages), got, want)
+		}
+		pages = append(pages, oggPage{
+			flags:    data[5],
+			granule:  int64(binary.LittleEndian.Uint64(data[6:14])),
+			serial:   binary.LittleEndian.Uint32(data[14:18]),
+			sequence: binary.LittleEndian.Uint32(data[18:22]),
+			segments: segments,
+			body:     data[27+n : size],
+		})
+		data = data[size:]
+	}
+	return pages
+}
+
+// oggPackets joins the packets of a page by their lacing values; no
+// packet here spans pages
+func oggPackets(t *testing.T, p oggPage) [][]byte {
+	t.Helper()
+	var packets [][]byte
+	body, size := p.body, 0
+	for _, s := range p.segments {
+		size += int(s)
+		if s < 255 {
+			packets = append(packets, body[:size])
+			body, size = body[size:], 0
+		}
+	}
+	if size != 0 || len(body) != 0 {
+		t.Fatalf("page %d ends mid-packet", p.sequence)
+	}
+	return packets
+}
+
+// opusPacket returns a packet of size bytes with the given TOC byte
+func opusPacket(toc byte, size int) []byte {
+	p := make([]byte, size)
+	p[0] = toc
+	for i := 1; i < size; i++ {
+		p[i] = byte(i)
+	}
+	return p
+}
+
+func TestOggCRC(t *testing.T) {
+	// The check value for CRC-32 with polynomial 0x04C11DB7, unreflected,
+	// zero initial value and no final XOR
+	if got := oggChecksum([]byte("123456789")); got != 0x89A1897F {
+		t.Errorf("CRC = %#08x, want 0x89a1897f", got)
+	}
+}
+
+func TestAppendLacing(t *testing.T) {
+	tests := []struct {
+		size int
+		want []byte
+	}{
+		{0, []byte{0}},
+		{1, []byte{1}},
+		{254, []byte{254}},
+		// A multiple of 255 ends with a zero so the packet is terminated
+		{255, []byte{255, 0}},
+		{300, []byte{255, 45}},
+		{510, []byte{255, 255, 0}},
+	}
+	for _, tt := range tests {
+		if got := appendLacing([]byte{7}, tt.size); !slices.Equal(got, append([]byte{7}, tt.want...)) {
+			t.Errorf("appendLacing(%d) = %v, want %v after the prefix", tt.size, got[1:], tt.want)
+		}
+	}
+}
+
+func TestOggOpusWriterHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewOggOpusWriter(&buf, OggOpusOptions{
+		Channels:        2,
+		InputSampleRate: Rate16k,
+		PreSkip:         3840,
+		Serial:          0x12345678,
+		Comments:        []string{"TITLE=call 42"},
+	})
+	if err != nil {
+		t.Fatalf("NewOggOpusWriter: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	pages := readOggPages(t, buf.Bytes())
+	if len(pages) != 3 {
+		t.Fatalf("%d pages, want headers and the end of stream", len(pages))
+	}
+	for i, p := range pages {
+		if p.serial != 0x12345678 || p.sequence != uint32(i) || p.granule != 0 {
+			t.Errorf("page %d: serial %#x, sequence %d, granule %d", i, p.serial, p.sequence, p.granule)
+		}
+	}
+	if pages[0].flags != oggFirst || pages[1].flags != 0 || pages[2].flags != oggLast {
+		t.Errorf("flags %#x %#x %#x, want first, none, last", pages[0].flags, pages[1].flags, pages[2].flags)
+	}
+
+	// RFC 7845 section 5.1: magic, version 1, channels, pre-skip, input
+	// rate, zero output gain and channel mapping family 0
+	head := oggPackets(t, pages[0])
+	wantHead := []byte{'O', 'p', 'u', 's', 'H', 'e', 'a', 'd', 1, 2, 0x00, 0x0F, 0x80, 0x3E, 0, 0, 0, 0, 0}
+	if len(head) != 1 || !bytes.Equal(head[0], wantHead) {
+		t.Errorf("OpusHead = % x, want % x", head, wantHead)
+	}
+
+	// Section 5.2: magic, vendor and the comments, each length-prefixed
+	tags := oggPackets(t, pages[1])
+	wantTags := []byte("OpusTags\x06\x00\x00\x00phonic\x01\x00\x00\x00\x0d\x00\x00\x00TITLE=call 42")
+	if len(tags) != 1 || !bytes.Equal(tags[0], wantTags) {
+		t.Errorf("OpusTags = %q, want %q", tags, wantTags)
+	}
+
+	if len(pages[2].segments) != 0 {
+		t.Errorf("empty stream ended with %d segments", len(pages[2].segments))
+	}
+}
+
+func TestOggOpusWriterDefaults(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewOggOpusWriter(&buf, OggOpusOptions{}); err != nil {
+		t.Fatalf("NewOggOpusWriter: %v", err)
+	}
+	head := oggPackets(t, readOggPages(t, buf.Bytes())[0])[0]
+	if head[9] != 1 || binary.LittleEndian.Uint16(head[10:12]) != 312 ||
+		binary.LittleEndian.Uint32(head[12:16]) != OpusRate {
+		t.Errorf("default OpusHead % x", head)
+	}
+
+	if _, err := NewOggOpusWriter(&buf, OggOpusOptions{Channels: 3}); err == nil {
+		t.Error("3 channels accepted")
+	}
+}
+
+func TestOggOpusWriterPackets(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets [][]byte
+		// wantPages is the number of packets on each audio page
+		wantPages []int
+	}{
+		{
+			name:      "one page",
+			packets:   repeatPacket(opusPacket(0xF8, 40), 10),
+			wantPages: []int{10},
+		},
+		{
+			// 20ms packets fill a second after 50
+			name:      "paged by duration",
+			packets:   repeatPacket(opusPacket(0xF8, 40), 60),
+			wantPages: []int{50, 10},
+		},
+		{
+			// 2.5ms packets of 600 bytes take three lacing values each, so
+			// 85 fill the segment table
+			name:      "paged by segment count",
+			packets:   repeatPacket(opusPacket(0x80, 600), 100),
+			wantPages: []int{85, 15},
+		},
+		{
+			name:      "lacing boundaries",
+			packets:   [][]byte{opusPacket(0xF8, 255), opusPacket(0xF8, 1), opusPacket(0xF8, 510), opusPacket(0xF8, 300)},
+			wantPages: []int{4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewOggOpusWriter(&buf, OggOpusOptions{Serial: 1})
+			if err != nil {
+				t.Fatalf("NewOggOpusWriter: %v", err)
+			}
+			var samples int64
+			for _, p := range tt.packets {
+				if err := w.WritePacket(p); err != nil {
+					t.Fatalf("WritePacket: %v", err)
+				}
+				n, _ := OpusPacketSamples(p)
+				samples += int64(n)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if got, want := w.Duration(), samples-312; got != want {
+				t.Errorf("Duration = %d, want %d", got, want)
+			}
+
+			pages := readOggPages(t, buf.Bytes())[2:]
+			if len(pages) != len(tt.wantPages) {
+				t.Fatalf("%d audio pages, want %d", len(pages), len(tt.wantPages))
+			}
+			// Granule positions count every sample up to the end of the
+			// page, including the pre-skip
+			var got [][]byte
+			var granule int64
+			for i, p := range pages {
+				packets := oggPackets(t, p)
+				if len(packets) != tt.wantPages[i] {
+					t.Errorf("page %d has %d packets, want %d", i, len(packets), tt.wantPages[i])
+				}
+				for _, packet := range packets {
+					n, _ := OpusPacketSamples(packet)
+					granule += int64(n)
+				}
+				if p.granule != granule {
+					t.Errorf("page %d granule %d, want %d", i, p.granule, granule)
+				}
+				if last := i == len(pages)-1; (p.flags == oggLast) != last {
+					t.Errorf("page %d flags %#x", i, p.flags)
+				}
+				got = append(got, packets...)
+			}
+			if !slices.EqualFunc(got, tt.packets, bytes.Equal) {
+				t.Error("packets read back differ from those written")
+			}
+		})
+	}
+}
+
+func TestOggOpusWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewOggOpusWriter(&buf, OggOpusOptions{})
+	if err != nil {
+		t.Fatalf("NewOggOpusWriter: %v", err)
+	}
+	if err := w.WritePacket(nil); !errors.Is(err, ErrInvalidOpusPacket) {
+		t.Errorf("empty packet: %v, want ErrInvalidOpusPacket", err)
+	}
+	if got := w.Duration(); got != 0 {
+		t.Errorf("Duration before the pre-skip = %d, want 0", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := w.WritePacket(opusPacket(0xF8, 10)); err == nil {
+		t.Error("WritePacket after Close succeeded")
+	}
+
+	if _, err := NewOggOpusWriter(failWriter{}, OggOpusOptions{}); err == nil {
+		t.Error("write error not returned")
+	}
+}
+
+// repeatPacket returns n copies of p
+func repeatPacket(p []byte, n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = p
+	}
+	return out
+}
+
+// failWriter fails every write
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
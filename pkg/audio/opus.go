@@ -0,0 +1,315 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Opus support needs libopus and is built with the opus tag:
+//
+//	go build -tags opus ./...
+//
+// Without it the constructors return ErrOpusUnavailable, while the Ogg
+// writer and packet parsing still work.
+
+// ErrOpusUnavailable is returned when the binary was built without Opus
+var ErrOpusUnavailable = errors.New("opus support not built in; build with -tags opus and libopus installed")
+
+// ErrInvalidOpusPacket is returned for malformed Opus packets
+var ErrInvalidOpusPacket = errors.New("invalid opus packet")
+
+// OpusRate is the rate of Opus timestamps and Ogg granule positions,
+// whatever the encoded or decoded rate
+const OpusRate = Rate48k
+
+// OpusApplication tunes the encoder
+type OpusApplication int
+
+// Opus applications
+const (
+	// OpusVoIP favours speech intelligibility
+	OpusVoIP OpusApplication = iota
+	// OpusAudio favours fidelity for music and mixed content
+	OpusAudio
+	// OpusLowDelay disables speech modes for the lowest latency
+	OpusLowDelay
+)
+
+// OpusOptions configures an OpusEncoder or OpusDecoder
+type OpusOptions struct {
+	// SampleRate is the PCM rate: 8, 12, 16, 24 or 48kHz. A decoder
+	// resamples to it, so 48kHz WebRTC audio can be decoded straight to
+	// 16kHz for STT. Default 48000.
+	SampleRate int
+	// Channels is 1 or 2; a mono decoder down-mixes stereo streams.
+	// Default 1.
+	Channels int
+	// Bitrate is the encoder's target in bits per second; default 24000
+	Bitrate int
+	// Application tunes the encoder; default OpusVoIP
+	Application OpusApplication
+	// FrameDuration is the encoded frame length: 2.5, 5, 10, 20, 40 or
+	// 60ms; default 20ms
+	FrameDuration time.Duration
+}
+
+// withDefaults fills in unset options
+func (o OpusOptions) withDefaults() OpusOptions {
+	if o.SampleRate <= 0 {
+		o.SampleRate = OpusRate
+	}
+	if o.Channels <= 0 {
+		o.Channels = 1
+	}
+	if o.Bitrate <= 0 {
+		o.Bitrate = 24000
+	}
+	if o.FrameDuration <= 0 {
+		o.FrameDuration = 20 * time.Millisecond
+	}
+	return o
+}
+
+// validate checks options after defaults
+func (o OpusOptions) validate() error {
+	switch o.SampleRate {
+	case Rate8k, 12000, Rate16k, Rate24k, Rate48k:
+	default:
+		return fmt.Errorf("unsupported opus sample rate %d", o.SampleRate)
+	}
+	if o.Channels != 1 && o.Channels != 2 {
+		return fmt.Errorf("unsupported opus channel count %d", o.Channels)
+	}
+	if o.Bitrate < 6000 || o.Bitrate > 510000 {
+		return fmt.Errorf("opus bitrate %d outside 6000-510000", o.Bitrate)
+	}
+	switch o.FrameDuration {
+	case 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+		20 * time.Millisecond, 40 * time.Millisecond, 60 * time.Millisecond:
+	default:
+		return fmt.Errorf("unsupported opus frame duration %v", o.FrameDuration)
+	}
+	return nil
+}
+
+// frameSize returns the samples per channel in one frame
+func (o OpusOptions) frameSize() int {
+	return int(o.FrameDuration * time.Duration(o.SampleRate) / time.Second)
+}
+
+// maxOpusPacket is the largest packet the encoder produces
+const maxOpusPacket = 1275 * 3
+
+// maxOpusFrame is the longest packet, 120ms, in samples per channel at
+// 48kHz
+const maxOpusFrame = 5760
+
+// OpusEncoder encodes PCM16 into Opus packets. It is not safe for
+// concurrent use.
+type OpusEncoder struct {
+	opts    OpusOptions
+	codec   *opusEncoderCodec
+	pending []int16
+	packet  []byte
+}
+
+// NewOpusEncoder creates an encoder; Close frees it
+func NewOpusEncoder(opts OpusOptions) (*OpusEncoder, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	codec, err := newOpusEncoderCodec(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &OpusEncoder{
+		opts:    opts,
+		codec:   codec,
+		pending: make([]int16, 0, opts.frameSize()*opts.Channels),
+		packet:  make([]byte, maxOpusPacket),
+	}, nil
+}
+
+// FrameSize returns the number of interleaved samples in one frame
+func (e *OpusEncoder) FrameSize() int {
+	return e.opts.frameSize() * e.opts.Channels
+}
+
+// Lookahead returns the encoder delay in samples per channel at 48kHz,
+// which is the pre-skip of an Ogg/Opus stream
+func (e *OpusEncoder) Lookahead() int {
+	return e.codec.lookahead()
+}
+
+// SetBitrate changes the target bitrate, e.g. as network conditions change
+func (e *OpusEncoder) SetBitrate(bitrate int) error {
+	if bitrate < 6000 || bitrate > 510000 {
+		return fmt.Errorf("opus bitrate %d outside 6000-510000", bitrate)
+	}
+	if err := e.codec.setBitrate(bitrate); err != nil {
+		return fmt.Errorf("failed to set opus bitrate: %w", err)
+	}
+	e.opts.Bitrate = bitrate
+	return nil
+}
+
+// Encode appends the packet for exactly one frame of pcm to dst
+func (e *OpusEncoder) Encode(dst []byte, pcm []int16) ([]byte, error) {
+	if len(pcm) != e.FrameSize() {
+		return dst, fmt.Errorf("opus frame of %d samples, want %d", len(pcm), e.FrameSize())
+	}
+	packet, err := e.encode(pcm)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, packet...), nil
+}
+
+// encode returns the packet for one frame, in the encoder's buffer
+func (e *OpusEncoder) encode(pcm []int16) ([]byte, error) {
+	n, err := e.codec.encode(pcm, e.opts.frameSize(), e.packet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode opus frame: %w", err)
+	}
+	return e.packet[:n], nil
+}
+
+// Write encodes pcm of any length, buffering a partial frame, and calls
+// emit with each packet. The packet is only valid during the call.
+func (e *OpusEncoder) Write(pcm []int16, emit func(packet []byte) error) error {
+	size := e.FrameSize()
+	for len(pcm) > 0 {
+		var frame []int16
+		if len(e.pending) == 0 && len(pcm) >= size {
+			frame, pcm = pcm[:size], pcm[size:]
+		} else {
+			n := min(size-len(e.pending), len(pcm))
+			e.pending = append(e.pending, pcm[:n]...)
+			pcm = pcm[n:]
+			if len(e.pending) < size {
+				return nil
+			}
+			frame = e.pending
+		}
+		packet, err := e.encode(frame)
+		e.pending = e.pending[:0]
+		if err != nil {
+			return err
+		}
+		if err := emit(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush pads a buffered partial frame with silence and encodes it
+func (e *OpusEncoder) Flush(emit func(packet []byte) error) error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	return e.Write(make([]int16, e.FrameSize()-len(e.pending)), emit)
+}
+
+// Close frees the encoder
+func (e *OpusEncoder) Close() {
+	e.codec.destroy()
+}
+
+// OpusDecoder decodes Opus packets into PCM16. It is not safe for
+// concurrent use.
+type OpusDecoder struct {
+	opts  OpusOptions
+	codec *opusDecoderCodec
+	pcm   []int16
+}
+
+// NewOpusDecoder creates a decoder producing audio at opts.SampleRate;
+// Close frees it
+func NewOpusDecoder(opts OpusOptions) (*OpusDecoder, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	codec, err := newOpusDecoderCodec(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &OpusDecoder{
+		opts:  opts,
+		codec: codec,
+		pcm:   make([]int16, maxOpusFrame*opts.SampleRate/OpusRate*opts.Channels),
+	}, nil
+}
+
+// Decode appends the samples of packet to dst
+func (d *OpusDecoder) Decode(dst []int16, packet []byte) ([]int16, error) {
+	if len(packet) == 0 {
+		return dst, ErrInvalidOpusPacket
+	}
+	n, err := d.codec.decode(packet, d.pcm, d.opts.Channels)
+	if err != nil {
+		return dst, fmt.Errorf("failed to decode opus packet: %w", err)
+	}
+	return append(dst, d.pcm[:n*d.opts.Channels]...), nil
+}
+
+// DecodeLost appends d of concealment audio for a lost packet, e.g. when
+// a jitter buffer reports a gap
+func (d *OpusDecoder) DecodeLost(dst []int16, duration time.Duration) ([]int16, error) {
+	frames := int(duration * time.Duration(d.opts.SampleRate) / time.Second)
+	frames = min(frames, len(d.pcm)/d.opts.Channels)
+	n, err := d.codec.decode(nil, d.pcm[:frames*d.opts.Channels], d.opts.Channels)
+	if err != nil {
+		return dst, fmt.Errorf("failed to conceal opus loss: %w", err)
+	}
+	return append(dst, d.pcm[:n*d.opts.Channels]...), nil
+}
+
+// Close frees the decoder
+func (d *OpusDecoder) Close() {
+	d.codec.destroy()
+}
+
+// OpusPacketSamples returns the duration of a packet in samples per
+// channel at 48kHz, from its TOC byte as described in RFC 6716
+func OpusPacketSamples(packet []byte) (int, error) {
+	if len(packet) == 0 {
+		return 0, ErrInvalidOpusPacket
+	}
+	toc := packet[0]
+	config := int(toc >> 3)
+
+	var frame int
+	switch {
+	case config < 12:
+		// SILK: 10, 20, 40, 60ms
+		frame = []int{480, 960, 1920, 2880}[config%4]
+	case config < 16:
+		// Hybrid: 10, 20ms
+		frame = []int{480, 960}[config%2]
+	default:
+		// CELT: 2.5, 5, 10, 20ms
+		frame = []int{120, 240, 480, 960}[config%4]
+	}
+
+	var frames int
+	switch toc & 0x03 {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	default:
+		if len(packet) < 2 {
+			return 0, ErrInvalidOpusPacket
+		}
+		frames = int(packet[1] & 0x3F)
+	}
+	samples := frame * frames
+	if frames == 0 || samples > maxOpusFrame {
+		return 0, ErrInvalidOpusPacket
+	}
+	return samples, nil
+}
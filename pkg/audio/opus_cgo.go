@@ -0,0 +1,123 @@
+//go:build cgo && opus
+
+package audio
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+static int phonic_opus_set_bitrate(OpusEncoder *enc, opus_int32 bitrate) {
+	return opus_encoder_ctl(enc, OPUS_SET_BITRATE(bitrate));
+}
+
+static int phonic_opus_get_lookahead(OpusEncoder *enc, opus_int32 *lookahead) {
+	return opus_encoder_ctl(enc, OPUS_GET_LOOKAHEAD(lookahead));
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// opusError converts a libopus error code
+func opusError(code C.int) error {
+	return errors.New(C.GoString(C.opus_strerror(code)))
+}
+
+// opusEncoderCodec wraps a libopus encoder
+type opusEncoderCodec struct {
+	enc *C.OpusEncoder
+}
+
+// newOpusEncoderCodec creates a libopus encoder for opts
+func newOpusEncoderCodec(opts OpusOptions) (*opusEncoderCodec, error) {
+	application := C.int(C.OPUS_APPLICATION_VOIP)
+	switch opts.Application {
+	case OpusAudio:
+		application = C.OPUS_APPLICATION_AUDIO
+	case OpusLowDelay:
+		application = C.OPUS_APPLICATION_RESTRICTED_LOWDELAY
+	}
+
+	var code C.int
+	enc := C.opus_encoder_create(C.opus_int32(opts.SampleRate), C.int(opts.Channels), application, &code)
+	if code != C.OPUS_OK {
+		return nil, opusError(code)
+	}
+	c := &opusEncoderCodec{enc: enc}
+	if err := c.setBitrate(opts.Bitrate); err != nil {
+		c.destroy()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *opusEncoderCodec) encode(pcm []int16, frameSize int, packet []byte) (int, error) {
+	n := C.opus_encode(c.enc, (*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(frameSize),
+		(*C.uchar)(unsafe.Pointer(&packet[0])), C.opus_int32(len(packet)))
+	if n < 0 {
+		return 0, opusError(C.int(n))
+	}
+	return int(n), nil
+}
+
+func (c *opusEncoderCodec) setBitrate(bitrate int) error {
+	if code := C.phonic_opus_set_bitrate(c.enc, C.opus_int32(bitrate)); code != C.OPUS_OK {
+		return opusError(code)
+	}
+	return nil
+}
+
+func (c *opusEncoderCodec) lookahead() int {
+	var lookahead C.opus_int32
+	if C.phonic_opus_get_lookahead(c.enc, &lookahead) != C.OPUS_OK {
+		return 0
+	}
+	return int(lookahead)
+}
+
+func (c *opusEncoderCodec) destroy() {
+	if c.enc != nil {
+		C.opus_encoder_destroy(c.enc)
+		c.enc = nil
+	}
+}
+
+// opusDecoderCodec wraps a libopus decoder
+type opusDecoderCodec struct {
+	dec *C.OpusDecoder
+}
+
+// newOpusDecoderCodec creates a libopus decoder for opts
+func newOpusDecoderCodec(opts OpusOptions) (*opusDecoderCodec, error) {
+	var code C.int
+	dec := C.opus_decoder_create(C.opus_int32(opts.SampleRate), C.int(opts.Channels), &code)
+	if code != C.OPUS_OK {
+		return nil, opusError(code)
+	}
+	return &opusDecoderCodec{dec: dec}, nil
+}
+
+// decode decodes packet into pcm, or conceals a lost packet of len(pcm)
+// when packet is nil, returning samples per channel
+func (c *opusDecoderCodec) decode(packet []byte, pcm []int16, channels int) (int, error) {
+	var data *C.uchar
+	if len(packet) > 0 {
+		data = (*C.uchar)(unsafe.Pointer(&packet[0]))
+	}
+	n := C.opus_decode(c.dec, data, C.opus_int32(len(packet)),
+		(*C.opus_int16)(unsafe.Pointer(&pcm[0])), C.int(len(pcm)/channels), 0)
+	if n < 0 {
+		return 0, opusError(n)
+	}
+	return int(n), nil
+}
+
+func (c *opusDecoderCodec) destroy() {
+	if c.dec != nil {
+		C.opus_decoder_destroy(c.dec)
+		c.dec = nil
+	}
+}
@@ -0,0 +1,138 @@
+//go:build cgo && opus
+
+package audio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+// correlation returns the normalized cross-correlation of a and b with b
+// delayed by lag samples
+func correlation(a, b []int16, lag int) float64 {
+	var ab, aa, bb float64
+	for i := 0; i < len(a) && i+lag < len(b); i++ {
+		x, y := float64(a[i]), float64(b[i+lag])
+		ab += x * y
+		aa += x * x
+		bb += y * y
+	}
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+	return ab / math.Sqrt(aa*bb)
+}
+
+func TestOpusRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts OpusOptions
+	}{
+		{"16kHz mono voip", OpusOptions{SampleRate: Rate16k}},
+		{"8kHz mono 60ms", OpusOptions{SampleRate: Rate8k, FrameDuration: 60 * time.Millisecond}},
+		{"48kHz stereo audio", OpusOptions{Channels: 2, Bitrate: 64000, Application: OpusAudio}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts.withDefaults()
+			enc, err := NewOpusEncoder(opts)
+			if err != nil {
+				t.Fatalf("NewOpusEncoder: %v", err)
+			}
+			defer enc.Close()
+			dec, err := NewOpusDecoder(opts)
+			if err != nil {
+				t.Fatalf("NewOpusDecoder: %v", err)
+			}
+			defer dec.Close()
+
+			var ogg bytes.Buffer
+			w, err := NewOggOpusWriter(&ogg, OggOpusOptions{
+				Channels:        opts.Channels,
+				InputSampleRate: opts.SampleRate,
+				PreSkip:         enc.Lookahead(),
+			})
+			if err != nil {
+				t.Fatalf("NewOggOpusWriter: %v", err)
+			}
+
+			// A second of 440Hz, written in uneven chunks
+			in := sine(440, 8000, opts.SampleRate, opts.Channels, opts.SampleRate)
+			var out []int16
+			var packets int
+			emit := func(packet []byte) error {
+				packets++
+				if err := w.WritePacket(packet); err != nil {
+					return err
+				}
+				out, err = dec.Decode(out, packet)
+				return err
+			}
+			for chunk := in; len(chunk) > 0; {
+				n := min(len(chunk), 333*opts.Channels)
+				if err := enc.Write(chunk[:n], emit); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				chunk = chunk[n:]
+			}
+			if err := enc.Flush(emit); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			if want := packets * enc.FrameSize(); len(out) != want {
+				t.Fatalf("decoded %d samples, want %d", len(out), want)
+			}
+			if len(out) < len(in) {
+				t.Fatalf("decoded %d samples of %d", len(out), len(in))
+			}
+			if diff := math.Abs(Level(out[len(out)/4:len(in)]) - Level(in[len(in)/4:])); diff > 1 {
+				t.Errorf("level changed by %.2f dB", diff)
+			}
+			// The codec delays the audio by its lookahead
+			best := 0.0
+			for lag := 0; lag < opts.SampleRate/50*opts.Channels; lag += opts.Channels {
+				best = max(best, correlation(in[len(in)/4:len(in)*3/4], out[len(in)/4:], lag))
+			}
+			if best < 0.9 {
+				t.Errorf("decoded audio correlates %.2f with the input", best)
+			}
+
+			frameSamples := int64(opts.frameSize() * OpusRate / opts.SampleRate)
+			if got, want := w.Duration(), int64(packets)*frameSamples-int64(enc.Lookahead()); got != want {
+				t.Errorf("Duration = %d, want %d", got, want)
+			}
+			pages := readOggPages(t, ogg.Bytes())
+			if last := pages[len(pages)-1]; last.granule != int64(packets)*frameSamples || last.flags != oggLast {
+				t.Errorf("last page granule %d flags %#x", last.granule, last.flags)
+			}
+
+			lost, err := dec.DecodeLost(nil, opts.FrameDuration)
+			if err != nil {
+				t.Fatalf("DecodeLost: %v", err)
+			}
+			if len(lost) != enc.FrameSize() {
+				t.Errorf("concealed %d samples, want %d", len(lost), enc.FrameSize())
+			}
+		})
+	}
+}
+
+func BenchmarkOpusEncode(b *testing.B) {
+	enc, err := NewOpusEncoder(OpusOptions{SampleRate: Rate16k})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer enc.Close()
+	pcm := sine(440, 8000, Rate16k, 1, enc.FrameSize())
+	var packet []byte
+	for b.Loop() {
+		if packet, err = enc.Encode(packet[:0], pcm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
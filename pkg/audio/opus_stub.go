@@ -0,0 +1,25 @@
+//go:build !cgo || !opus
+
+package audio
+
+// opusEncoderCodec stands in for libopus when it isn't built in
+type opusEncoderCodec struct{}
+
+func newOpusEncoderCodec(OpusOptions) (*opusEncoderCodec, error) {
+	return nil, ErrOpusUnavailable
+}
+
+func (*opusEncoderCodec) encode([]int16, int, []byte) (int, error) { return 0, ErrOpusUnavailable }
+func (*opusEncoderCodec) setBitrate(int) error                     { return ErrOpusUnavailable }
+func (*opusEncoderCodec) lookahead() int                           { return 0 }
+func (*opusEncoderCodec) destroy()                                 {}
+
+// opusDecoderCodec stands in for libopus when it isn't built in
+type opusDecoderCodec struct{}
+
+func newOpusDecoderCodec(OpusOptions) (*opusDecoderCodec, error) {
+	return nil, ErrOpusUnavailable
+}
+
+func (*opusDecoderCodec) decode([]byte, []int16, int) (int, error) { return 0, ErrOpusUnavailable }
+func (*opusDecoderCodec) destroy()                                 {}
@@ -0,0 +1,89 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOpusPacketSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   int
+	}{
+		// The TOC byte is config<<3 | stereo<<2 | frame count code
+		{"silk 10ms", []byte{0 << 3}, 480},
+		{"silk 20ms", []byte{1 << 3}, 960},
+		{"silk 40ms", []byte{2 << 3}, 1920},
+		{"silk 60ms", []byte{11 << 3}, 2880},
+		{"hybrid 10ms", []byte{12 << 3}, 480},
+		{"hybrid 20ms", []byte{15 << 3}, 960},
+		{"celt 2.5ms", []byte{16 << 3}, 120},
+		{"celt 5ms", []byte{17 << 3}, 240},
+		{"celt 10ms", []byte{30 << 3}, 480},
+		{"celt 20ms", []byte{31 << 3}, 960},
+		{"stereo flag ignored", []byte{31<<3 | 0x04}, 960},
+		{"two equal frames", []byte{1<<3 | 1}, 1920},
+		{"two different frames", []byte{1<<3 | 2, 0}, 1920},
+		{"arbitrary frame count", []byte{16<<3 | 3, 5}, 600},
+		// The count byte's padding and VBR flags are not part of the count
+		{"count with flags", []byte{16<<3 | 3, 0xC0 | 5}, 600},
+		{"120ms", []byte{11<<3 | 1}, 5760},
+		{"120ms of 2.5ms frames", []byte{16<<3 | 3, 48}, 5760},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpusPacketSamples(tt.packet)
+			if err != nil || got != tt.want {
+				t.Errorf("OpusPacketSamples(% x) = %d, %v, want %d", tt.packet, got, err, tt.want)
+			}
+		})
+	}
+
+	invalid := map[string][]byte{
+		"empty":              nil,
+		"missing count byte": {16<<3 | 3},
+		"zero frames":        {16<<3 | 3, 0},
+		"over 120ms":         {11<<3 | 3, 3},
+	}
+	for name, packet := range invalid {
+		if _, err := OpusPacketSamples(packet); !errors.Is(err, ErrInvalidOpusPacket) {
+			t.Errorf("%s: %v, want ErrInvalidOpusPacket", name, err)
+		}
+	}
+}
+
+func TestOpusOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    OpusOptions
+		wantErr bool
+	}{
+		{name: "defaults", opts: OpusOptions{}},
+		{name: "8kHz stereo", opts: OpusOptions{SampleRate: Rate8k, Channels: 2}},
+		{name: "12kHz", opts: OpusOptions{SampleRate: 12000}},
+		{name: "2.5ms", opts: OpusOptions{FrameDuration: 2500 * time.Microsecond}},
+		{name: "60ms", opts: OpusOptions{FrameDuration: 60 * time.Millisecond}},
+		{name: "min bitrate", opts: OpusOptions{Bitrate: 6000}},
+		{name: "max bitrate", opts: OpusOptions{Bitrate: 510000}},
+		{name: "44.1kHz", opts: OpusOptions{SampleRate: 44100}, wantErr: true},
+		{name: "3 channels", opts: OpusOptions{Channels: 3}, wantErr: true},
+		{name: "low bitrate", opts: OpusOptions{Bitrate: 5999}, wantErr: true},
+		{name: "high bitrate", opts: OpusOptions{Bitrate: 510001}, wantErr: true},
+		{name: "30ms", opts: OpusOptions{FrameDuration: 30 * time.Millisecond}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.withDefaults().validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	opts := OpusOptions{SampleRate: Rate16k, FrameDuration: 20 * time.Millisecond}
+	if got := opts.frameSize(); got != 320 {
+		t.Errorf("frameSize = %d, want 320", got)
+	}
+}
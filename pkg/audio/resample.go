@@ -0,0 +1,242 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// Quality selects the length of the resampling filter; higher quality
+// attenuates aliasing more at the cost of CPU and latency
+type Quality int
+
+// Resampler qualities
+const (
+	QualityLow Quality = iota
+	QualityMedium
+	QualityHigh
+)
+
+// zeroCrossings returns the half-length of the filter in output periods
+func (q Quality) zeroCrossings() int {
+	switch q {
+	case QualityLow:
+		return 4
+	case QualityHigh:
+		return 16
+	default:
+		return 8
+	}
+}
+
+// kaiserBeta returns the window shape for the quality
+func (q Quality) kaiserBeta() float64 {
+	switch q {
+	case QualityLow:
+		return 5
+	case QualityHigh:
+		return 9
+	default:
+		return 7
+	}
+}
+
+// Resampler converts a stream between sample rates with a polyphase
+// windowed-sinc filter. It keeps filter history between calls, so a
+// stream can be fed in frames of any size. Output is time-aligned with
+// the input, but the last Delay output frames are held back until more
+// input arrives or Flush is called. A Resampler is not safe for
+// concurrent use.
+type Resampler struct {
+	from, to int
+	channels int
+	// up and down are the reduced ratio to/from
+	up, down int
+	// taps is the filter length per phase, in input samples
+	taps int
+	// phases[p] holds the taps for fractional position p/up, reversed so
+	// they line up with the input window
+	phases [][]float32
+
+	// history holds the last taps-1 input frames, followed by the
+	// current input while processing
+	history []int16
+	// position is the next output position, in 1/up input frames, from
+	// the start of history
+	position int
+	// in and out count the frames consumed and produced
+	in, out int64
+}
+
+// NewResampler creates a resampler for interleaved audio with channels
+// channels
+func NewResampler(from, to, channels int, quality Quality) (*Resampler, error) {
+	if from <= 0 || to <= 0 {
+		return nil, fmt.Errorf("invalid resampling rates %d to %d", from, to)
+	}
+	if channels <= 0 {
+		return nil, fmt.Errorf("invalid channel count %d", channels)
+	}
+
+	g := gcd(from, to)
+	r := &Resampler{
+		from:     from,
+		to:       to,
+		channels: channels,
+		up:       to / g,
+		down:     from / g,
+	}
+	if r.up == r.down {
+		return r, nil
+	}
+
+	// Cut off below the lower Nyquist frequency, in cycles per sample at
+	// the upsampled rate
+	cutoff := 0.5 / float64(max(r.up, r.down))
+	if r.down > r.up {
+		cutoff *= 0.95
+	}
+	ratio := max(1, (r.down+r.up-1)/r.up)
+	r.taps = 2 * quality.zeroCrossings() * ratio
+	length := r.taps * r.up
+	center := float64(length / 2)
+	beta := quality.kaiserBeta()
+
+	r.phases = make([][]float32, r.up)
+	for p := range r.up {
+		r.phases[p] = make([]float32, r.taps)
+		for k := range r.taps {
+			// Tap k of phase p multiplies input frame i-k for output at
+			// position i+p/up
+			j := p + k*r.up
+			x := float64(j) - center
+			h := 2 * cutoff * sinc(2*cutoff*x) * kaiser(float64(j)/float64(length), beta)
+			r.phases[p][r.taps-1-k] = float32(h * float64(r.up))
+		}
+	}
+	r.history = make([]int16, (r.taps-1)*channels)
+	r.Reset()
+	return r, nil
+}
+
+// Delay returns the number of output frames held back by the filter
+func (r *Resampler) Delay() int {
+	if r.taps == 0 {
+		return 0
+	}
+	return (r.taps*r.up/2 + r.down - 1) / r.down
+}
+
+// Reset clears the filter history for a new stream
+func (r *Resampler) Reset() {
+	r.in, r.out = 0, 0
+	if r.taps == 0 {
+		return
+	}
+	r.history = r.history[:(r.taps-1)*r.channels]
+	clear(r.history)
+	// Start half a filter in, so output n lines up with input n*down/up
+	r.position = (r.taps-1)*r.up + r.taps*r.up/2
+}
+
+// Process appends the resampled pcm to dst
+func (r *Resampler) Process(dst, pcm []int16) []int16 {
+	if r.up == r.down {
+		return append(dst, pcm...)
+	}
+	r.in += int64(len(pcm) / r.channels)
+	return r.process(dst, pcm, -1)
+}
+
+// Flush appends the held-back output, ending the stream at the length
+// that corresponds to the input, and resets the resampler
+func (r *Resampler) Flush(dst []int16) []int16 {
+	if r.up == r.down {
+		return dst
+	}
+	total := (r.in*int64(r.up) + int64(r.down) - 1) / int64(r.down)
+	padding := make([]int16, (r.taps/2+1)*r.channels)
+	dst = r.process(dst, padding, total)
+	r.Reset()
+	return dst
+}
+
+// process filters pcm into dst, stopping after limit output frames in
+// total when limit is not negative
+func (r *Resampler) process(dst, pcm []int16, limit int64) []int16 {
+	r.history = append(r.history, pcm...)
+	frames := len(r.history) / r.channels
+	ch := r.channels
+	for limit < 0 || r.out < limit {
+		i := r.position / r.up
+		if i >= frames {
+			break
+		}
+		taps := r.phases[r.position%r.up]
+		window := r.history[(i-r.taps+1)*ch : (i+1)*ch]
+		for c := range ch {
+			var sum float32
+			for k, t := range taps {
+				sum += t * float32(window[k*ch+c])
+			}
+			dst = append(dst, clamp16(float64(sum)))
+		}
+		r.position += r.down
+		r.out++
+	}
+
+	// Keep the frames the next output still needs
+	keep := r.taps - 1
+	consumed := frames - keep
+	if consumed > 0 {
+		n := copy(r.history, r.history[consumed*ch:])
+		r.history = r.history[:n]
+		r.position -= consumed * r.up
+	}
+	return dst
+}
+
+// Resample converts a complete clip between rates
+func Resample(pcm []int16, from, to, channels int, quality Quality) ([]int16, error) {
+	r, err := NewResampler(from, to, channels, quality)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int16, 0, (len(pcm)/channels+1)*r.up/r.down*channels+channels)
+	out = r.Process(out, pcm)
+	return r.Flush(out), nil
+}
+
+// sinc is the normalized sinc function
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser evaluates a Kaiser window at t in [0, 1]
+func kaiser(t, beta float64) float64 {
+	x := 2*t - 1
+	return bessel0(beta*math.Sqrt(max(0, 1-x*x))) / bessel0(beta)
+}
+
+// bessel0 is the zeroth order modified Bessel function of the first kind
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
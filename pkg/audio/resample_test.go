@@ -0,0 +1,174 @@
+package audio
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// sine returns frames of a tone at rate, interleaved over channels
+func sine(freq float64, amplitude float64, rate, channels, frames int) []int16 {
+	pcm := make([]int16, 0, frames*channels)
+	for i := range frames {
+		s := int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+		for range channels {
+			pcm = append(pcm, s)
+		}
+	}
+	return pcm
+}
+
+func TestResampleLength(t *testing.T) {
+	rates := [][2]int{
+		{Rate8k, Rate16k},
+		{Rate16k, Rate8k},
+		{Rate8k, Rate48k},
+		{Rate48k, Rate16k},
+		{Rate24k, Rate16k},
+		{Rate16k, Rate24k},
+		{44100, Rate16k},
+		{Rate16k, Rate16k},
+	}
+	for _, r := range rates {
+		for _, channels := range []int{1, 2} {
+			for _, frames := range []int{0, 1, 333, r[0]} {
+				in := sine(440, 8000, r[0], channels, frames)
+				out, err := Resample(in, r[0], r[1], channels, QualityMedium)
+				if err != nil {
+					t.Fatalf("%d to %d: %v", r[0], r[1], err)
+				}
+				want := (frames*r[1] + r[0] - 1) / r[0]
+				if got := len(out); got != want*channels {
+					t.Errorf("%d to %d, %d channels, %d frames: got %d samples, want %d",
+						r[0], r[1], channels, frames, got, want*channels)
+				}
+			}
+		}
+	}
+}
+
+func TestResamplerStreaming(t *testing.T) {
+	rates := [][2]int{{Rate8k, Rate16k}, {Rate48k, Rate16k}, {Rate16k, Rate24k}, {44100, Rate16k}}
+	for _, r := range rates {
+		for _, channels := range []int{1, 2} {
+			in := sine(1000, 8000, r[0], channels, r[0]/2)
+			want, err := Resample(in, r[0], r[1], channels, QualityMedium)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rs, err := NewResampler(r[0], r[1], channels, QualityMedium)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Feed frames of varying size; the output must not depend on
+			// how the stream is split
+			var got []int16
+			for i, size := 0, 1; i < len(in); i, size = i+size*channels, size%317+13 {
+				end := min(len(in), i+size*channels)
+				got = rs.Process(got, in[i:end])
+				if held := end/channels*r[1]/r[0] - len(got)/channels; held > rs.Delay()+1 {
+					t.Fatalf("%d to %d: %d frames held back, Delay is %d", r[0], r[1], held, rs.Delay())
+				}
+			}
+			got = rs.Flush(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("%d to %d, %d channels: streamed output differs from Resample", r[0], r[1], channels)
+			}
+		}
+	}
+}
+
+func TestResampleFrequencyResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		freq     float64
+		// pass means the tone is below the output Nyquist frequency and
+		// must come through unchanged; otherwise it must be filtered out
+		pass bool
+	}{
+		{"telephony up", Rate8k, Rate16k, 1000, true},
+		{"stt down", Rate16k, Rate8k, 1000, true},
+		{"webrtc up", Rate8k, Rate48k, 3000, true},
+		{"webrtc down", Rate48k, Rate16k, 1000, true},
+		{"tts down", Rate24k, Rate16k, 3000, true},
+		{"tts up", Rate16k, Rate24k, 3000, true},
+		{"cd down", 44100, Rate16k, 1000, true},
+		{"alias to telephony", Rate16k, Rate8k, 7000, false},
+		{"alias from webrtc", Rate48k, Rate16k, 12000, false},
+		{"alias from tts", Rate24k, Rate16k, 10000, false},
+		{"alias from cd", 44100, Rate16k, 15000, false},
+	}
+	const amplitude = 10000
+	for _, tt := range tests {
+		for _, quality := range []Quality{QualityLow, QualityMedium, QualityHigh} {
+			in := sine(tt.freq, amplitude, tt.from, 1, tt.from)
+			out, err := Resample(in, tt.from, tt.to, 1, quality)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Skip the edges, where the filter sees the silence around the
+			// clip
+			var errPower, power float64
+			edge := tt.to / 20
+			for i := edge; i < len(out)-edge; i++ {
+				want := amplitude * math.Sin(2*math.Pi*tt.freq*float64(i)/float64(tt.to))
+				errPower += (float64(out[i]) - want) * (float64(out[i]) - want)
+				power += float64(out[i]) * float64(out[i])
+			}
+			n := float64(len(out) - 2*edge)
+			if tt.pass {
+				// The output follows the input tone in time, not just in
+				// frequency. The low quality filter rolls off early, so
+				// only the others are held to this.
+				if rms := math.Sqrt(errPower/n) / amplitude; quality > QualityLow && rms > 0.01 {
+					t.Errorf("%s, quality %d: error %.4f of the tone", tt.name, quality, rms)
+				}
+				continue
+			}
+			level := 20 * math.Log10(math.Sqrt(power/n)/(amplitude/math.Sqrt2)+1e-9)
+			if level > -40 {
+				t.Errorf("%s, quality %d: tone above Nyquist at %.1f dB, want below -40 dB", tt.name, quality, level)
+			}
+		}
+	}
+}
+
+func TestNewResamplerErrors(t *testing.T) {
+	tests := []struct {
+		name               string
+		from, to, channels int
+	}{
+		{"zero from", 0, Rate16k, 1},
+		{"negative to", Rate16k, -1, 1},
+		{"no channels", Rate16k, Rate8k, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewResampler(tt.from, tt.to, tt.channels, QualityMedium); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func benchmarkResampler(b *testing.B, from, to int) {
+	r, err := NewResampler(from, to, 1, QualityMedium)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// 20ms frames, as they arrive from a call leg
+	in := sine(1000, 8000, from, 1, from/50)
+	out := make([]int16, 0, to/50+r.Delay())
+	b.SetBytes(int64(len(in) * 2))
+	for b.Loop() {
+		out = r.Process(out[:0], in)
+	}
+}
+
+func BenchmarkResample8kTo16k(b *testing.B)  { benchmarkResampler(b, Rate8k, Rate16k) }
+func BenchmarkResample24kTo16k(b *testing.B) { benchmarkResampler(b, Rate24k, Rate16k) }
+func BenchmarkResample24kTo48k(b *testing.B) { benchmarkResampler(b, Rate24k, Rate48k) }
+func BenchmarkResample48kTo16k(b *testing.B) { benchmarkResampler(b, Rate48k, Rate16k) }
@@ -0,0 +1,91 @@
+"""Generates the golden vectors in this directory.
+
+The WAV files are written with Python's wave module, the A-law data with
+audioop and the mu-law data with a port of the ITU-T G.191 reference
+encoder, so the Go codecs are checked against independent
+implementations rather than against themselves. audioop is not used for
+mu-law because it truncates negative samples to 14 bits before taking
+the magnitude, which puts a few values on the other side of a step than
+G.191 does. Run with Python 3.11 or 3.12 (audioop was removed in 3.13):
+
+    python3 gen_vectors.py
+"""
+
+import audioop
+import math
+import struct
+import wave
+
+RATE = 8000
+
+
+def sweep():
+    """A 125 ms logarithmic sweep from 100 Hz to 3.4 kHz at -1 dBFS"""
+    n = RATE // 8
+    amp = 32767 * 10 ** (-1 / 20)
+    k = math.log(3400 / 100)
+    out = []
+    for i in range(n):
+        t = i / RATE
+        phase = 2 * math.pi * 100 * (n / RATE) / k * (math.exp(k * t / (n / RATE)) - 1)
+        out.append(round(amp * math.sin(phase)))
+    return out
+
+
+def ramp():
+    """Every 33rd value of the int16 range, covering each G.711 segment"""
+    return list(range(-32768, 32768, 33))
+
+
+def ulaw_compress(samples):
+    """ulaw_compress from the ITU-T G.191 software tools (g711.c)"""
+    out = bytearray()
+    for x in samples:
+        absno = ((~x) >> 2) + 33 if x < 0 else (x >> 2) + 33
+        absno = min(absno, 0x1FFF)
+        i = absno >> 6
+        segno = 1
+        while i != 0:
+            segno += 1
+            i >>= 1
+        high_nibble = 0x0008 - segno
+        low_nibble = 0x000F - ((absno >> segno) & 0x000F)
+        code = (high_nibble << 4) | low_nibble
+        if x >= 0:
+            code |= 0x0080
+        out.append(code)
+    return bytes(out)
+
+
+def write_wav(name, channels, samples):
+    with wave.open(name, "wb") as w:
+        w.setnchannels(channels)
+        w.setsampwidth(2)
+        w.setframerate(RATE)
+        w.writeframes(struct.pack("<%dh" % len(samples), *samples))
+
+
+def main():
+    mono = sweep() + ramp()
+    pcm = struct.pack("<%dh" % len(mono), *mono)
+    write_wav("sweep_8k.wav", 1, mono)
+    with open("sweep_8k.ulaw", "wb") as f:
+        f.write(ulaw_compress(mono))
+    with open("sweep_8k.alaw", "wb") as f:
+        f.write(audioop.lin2alaw(pcm, 2))
+
+    # Stereo with a different signal per channel, and audioop's downmix
+    left, right = sweep(), [s // 3 for s in reversed(sweep())]
+    stereo = [s for pair in zip(left, right) for s in pair]
+    write_wav("sweep_8k_stereo.wav", 2, stereo)
+    spcm = struct.pack("<%dh" % len(stereo), *stereo)
+    downmix = audioop.tomono(spcm, 2, 0.5, 0.5)
+    with wave.open("sweep_8k_downmix.wav", "wb") as w:
+        w.setnchannels(1)
+        w.setsampwidth(2)
+        w.setframerate(RATE)
+        w.writeframes(downmix)
+
+
+if __name__ == "__main__":
+    main()
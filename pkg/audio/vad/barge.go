@@ -0,0 +1,241 @@
+package vad
+
+import (
+	"math"
+	"time"
+)
+
+// BargeInOptions configures a BargeInDetector
+type BargeInOptions struct {
+	// VAD configures speech detection on the microphone stream
+	VAD Options
+	// MinDuration is how long the caller must talk over playback before
+	// the detector fires; default 200ms
+	MinDuration time.Duration
+	// EchoDelay is the longest delay between playing audio and hearing
+	// its echo on the microphone; default 250ms
+	EchoDelay time.Duration
+	// EchoMargin is how far in dB the microphone must exceed the expected
+	// echo to count as the caller; default 3
+	EchoMargin float64
+	// InitialERL is the assumed echo return loss in dB, the attenuation
+	// from playback to microphone, until it has been measured; default 6
+	InitialERL float64
+}
+
+// withDefaults fills in unset options
+func (o BargeInOptions) withDefaults() BargeInOptions {
+	o.VAD = o.VAD.withDefaults()
+	if o.MinDuration <= 0 {
+		o.MinDuration = 200 * time.Millisecond
+	}
+	if o.EchoDelay <= 0 {
+		o.EchoDelay = 250 * time.Millisecond
+	}
+	if o.EchoMargin <= 0 {
+		o.EchoMargin = 3
+	}
+	if o.InitialERL <= 0 {
+		o.InitialERL = 6
+	}
+	return o
+}
+
+// Bounds on the echo return loss estimate, in dB
+const (
+	minERL = 0
+	maxERL = 40
+)
+
+// silentDB is the playback level below which nothing is playing
+const silentDB = -60
+
+// maxGap is the pause between syllables that doesn't interrupt barge-in
+// speech
+const maxGap = 100 * time.Millisecond
+
+// BargeInDetector fires when the caller starts talking while TTS audio
+// is playing. Played audio is passed to Playback as it is sent, and
+// microphone audio to Process as it arrives. A microphone frame only
+// counts as the caller if it is louder than the echo expected from the
+// audio played within EchoDelay; the echo path attenuation is learned
+// while the caller is silent. It is not safe for concurrent use.
+type BargeInDetector struct {
+	opts     BargeInOptions
+	detector *Detector
+
+	// playback holds the levels of played frames not yet matched to a
+	// microphone frame; recent holds those matched to the microphone
+	// frames of the last EchoDelay, newest last
+	playback []float64
+	recent   []float64
+	partial  playbackFrame
+
+	erl float64
+	// run counts caller frames, ended by a gap of more than maxGap other
+	// frames; quiet counts frames since the last caller frame and playing
+	// those since playback started
+	run      int
+	playing  int
+	gap      int
+	quiet    int
+	required int
+	maxGap   int
+	holdoff  int
+	fired    bool
+	pending  []int16
+}
+
+// playbackFrame accumulates played audio into frames
+type playbackFrame struct {
+	power   float64
+	samples int
+}
+
+// NewBargeInDetector creates a barge-in detector
+func NewBargeInDetector(opts BargeInOptions) (*BargeInDetector, error) {
+	opts = opts.withDefaults()
+	detector, err := NewDetector(opts.VAD)
+	if err != nil {
+		return nil, err
+	}
+	frame := opts.VAD.FrameDuration
+	b := &BargeInDetector{
+		opts:     opts,
+		detector: detector,
+		recent:   make([]float64, 0, frameCount(opts.EchoDelay, frame)+1),
+		required: frameCount(opts.MinDuration, frame),
+		maxGap:   frameCount(maxGap, frame),
+		holdoff:  frameCount(opts.VAD.Hangover, frame),
+		pending:  make([]int16, 0, detector.FrameSize()),
+	}
+	b.Reset()
+	return b, nil
+}
+
+// Reset rearms the detector and forgets playback, keeping the learned
+// echo return loss
+func (b *BargeInDetector) Reset() {
+	b.playback = b.playback[:0]
+	b.recent = b.recent[:0]
+	b.partial = playbackFrame{}
+	b.playing = 0
+	b.run = 0
+	b.gap = 0
+	b.quiet = b.holdoff
+	b.fired = false
+	if b.erl == 0 {
+		b.erl = b.opts.InitialERL
+	}
+}
+
+// Playing reports whether audio was played within EchoDelay
+func (b *BargeInDetector) Playing() bool {
+	return b.expectedEcho() > silentDB
+}
+
+// ERL returns the current echo return loss estimate in dB
+func (b *BargeInDetector) ERL() float64 {
+	return b.erl
+}
+
+// Playback records audio sent to the caller, at the VAD sample rate
+func (b *BargeInDetector) Playback(pcm []int16) {
+	size := b.detector.FrameSize()
+	for _, s := range pcm {
+		v := float64(s) / 32768
+		b.partial.power += v * v
+		b.partial.samples++
+		if b.partial.samples == size {
+			b.playback = append(b.playback, powerToDB(b.partial.power/float64(size)))
+			b.partial = playbackFrame{}
+		}
+	}
+	// Playback far ahead of the microphone can't echo yet; keep it bounded
+	if excess := len(b.playback) - 2*cap(b.recent); excess > 0 {
+		b.playback = append(b.playback[:0], b.playback[excess:]...)
+	}
+}
+
+// Process analyses microphone audio and reports whether the caller barged
+// in. It fires once per playback; Reset rearms it.
+func (b *BargeInDetector) Process(pcm []int16) bool {
+	fired := false
+	size := b.detector.FrameSize()
+	for len(pcm) > 0 {
+		n := min(size-len(b.pending), len(pcm))
+		b.pending = append(b.pending, pcm[:n]...)
+		pcm = pcm[n:]
+		if len(b.pending) == size {
+			fired = b.step(b.pending) || fired
+			b.pending = b.pending[:0]
+		}
+	}
+	return fired
+}
+
+// step handles one microphone frame
+func (b *BargeInDetector) step(frame []int16) bool {
+	// Match the frame with the audio played at the same time
+	played := math.Inf(-1)
+	if len(b.playback) > 0 {
+		played = b.playback[0]
+		b.playback = b.playback[1:]
+	}
+	if len(b.recent) == cap(b.recent) {
+		b.recent = append(b.recent[:0], b.recent[1:]...)
+	}
+	b.recent = append(b.recent, played)
+
+	speech, level := b.detector.classify(frame)
+	echo := b.expectedEcho()
+	if echo <= silentDB {
+		// Nothing to talk over; rearm for the next playback
+		b.playing = 0
+		b.run = 0
+		b.gap = 0
+		b.fired = false
+		return false
+	}
+	b.playing++
+
+	caller := speech && level > echo-b.erl+b.opts.EchoMargin
+	if !caller {
+		b.quiet++
+		if b.gap++; b.gap > b.maxGap {
+			b.run = 0
+		}
+		// Away from the caller's speech, once the echo has had time to
+		// arrive, the microphone hears only echo and noise, which
+		// measures the echo path. A louder echo than expected is learned
+		// quickly, a quieter one slowly.
+		if b.quiet >= b.holdoff && b.playing > cap(b.recent) && level > silentDB {
+			measured := min(max(echo-level, minERL), maxERL)
+			rate := 0.02
+			if measured < b.erl {
+				rate = 0.5
+			}
+			b.erl += rate * (measured - b.erl)
+		}
+		return false
+	}
+
+	b.quiet = 0
+	b.gap = 0
+	b.run++
+	if b.run >= b.required && !b.fired {
+		b.fired = true
+		return true
+	}
+	return false
+}
+
+// expectedEcho returns the loudest playback level that can still be
+// echoing, in dBFS
+func (b *BargeInDetector) expectedEcho() float64 {
+	loudest := math.Inf(-1)
+	for _, l := range b.recent {
+		loudest = max(loudest, l)
+	}
+	return loudest
+}
@@ -0,0 +1,178 @@
+package vad
+
+import (
+	"testing"
+	"time"
+)
+
+const chunk = 20 * time.Millisecond
+
+// echo is play as heard back on the microphone, delayed and attenuated
+// by loss dB
+func echo(play []int16, delay time.Duration, loss float64) []int16 {
+	gain := amplitude(-loss) / 32768
+	out := make([]int16, samples(delay)+len(play))
+	for i, s := range play {
+		out[samples(delay)+i] = int16(float64(s) * gain)
+	}
+	return out[:len(play)]
+}
+
+// converse plays play while feeding mic, chunk by chunk as a call would,
+// and returns when the detector fired
+func converse(b *BargeInDetector, play, mic []int16) []time.Duration {
+	return converseAt(b, play, mic, rate)
+}
+
+// converseAt is converse for audio at sampleRate
+func converseAt(b *BargeInDetector, play, mic []int16, sampleRate int) []time.Duration {
+	var fired []time.Duration
+	n := int(chunk * time.Duration(sampleRate) / time.Second)
+	for i := 0; i < len(mic); i += n {
+		if i < len(play) {
+			b.Playback(play[i:min(len(play), i+n)])
+		}
+		if b.Process(mic[i:min(len(mic), i+n)]) {
+			fired = append(fired, time.Duration(i/n+1)*chunk)
+		}
+	}
+	return fired
+}
+
+func TestBargeIn(t *testing.T) {
+	// The agent talks for four seconds and its echo comes back 120ms later
+	// at 15dB below
+	tts := voice(4*time.Second, -15, 140)
+	line := noise(5*time.Second, -60, 7)
+	heard := mix(line, echo(tts, 120*time.Millisecond, 15), 0)
+	short := tts[:samples(time.Second)]
+	heardShort := mix(line, echo(short, 120*time.Millisecond, 15), 0)
+
+	tests := []struct {
+		name string
+		play []int16
+		mic  []int16
+		// want is when the detector should fire, within 60ms; zero means
+		// never
+		want time.Duration
+	}{
+		{
+			name: "echo only",
+			play: tts,
+			mic:  heard,
+		},
+		{
+			name: "caller talks over playback",
+			play: tts,
+			mic:  mix(heard, voice(time.Second, -12, 220), 2*time.Second),
+			want: 2200 * time.Millisecond,
+		},
+		{
+			name: "caller talks over the start of playback",
+			play: tts,
+			mic:  mix(heard, voice(time.Second, -12, 220), 300*time.Millisecond),
+			want: 500 * time.Millisecond,
+		},
+		{
+			name: "cough shorter than MinDuration",
+			play: tts,
+			mic:  mix(heard, voice(120*time.Millisecond, -12, 220), 2*time.Second),
+		},
+		{
+			name: "caller quieter than the echo",
+			play: tts,
+			mic:  mix(heard, voice(time.Second, -40, 220), 2*time.Second),
+		},
+		{
+			name: "caller talks with nothing playing",
+			mic:  mix(line, voice(time.Second, -12, 220), 2*time.Second),
+		},
+		{
+			name: "caller talks after playback ends",
+			play: short,
+			mic:  mix(heardShort, voice(time.Second, -12, 220), 2*time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBargeInDetector(BargeInOptions{})
+			if err != nil {
+				t.Fatalf("NewBargeInDetector: %v", err)
+			}
+			fired := converse(b, tt.play, tt.mic)
+			if tt.want == 0 {
+				if len(fired) > 0 {
+					t.Errorf("fired at %v, want never", fired)
+				}
+				return
+			}
+			if len(fired) != 1 {
+				t.Fatalf("fired at %v, want once at %v", fired, tt.want)
+			}
+			if fired[0] < tt.want-60*time.Millisecond || fired[0] > tt.want+60*time.Millisecond {
+				t.Errorf("fired at %v, want %v", fired[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestBargeInFiresOncePerPlayback(t *testing.T) {
+	b, err := NewBargeInDetector(BargeInOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tts := voice(4*time.Second, -15, 140)
+	// The caller talks twice over the same playback
+	mic := mix(mix(echo(tts, 120*time.Millisecond, 15), voice(500*time.Millisecond, -12, 220), time.Second),
+		voice(500*time.Millisecond, -12, 220), 3*time.Second)
+	if fired := converse(b, tts, mic); len(fired) != 1 {
+		t.Fatalf("fired at %v, want once", fired)
+	}
+
+	// Reset rearms for the next playback
+	b.Reset()
+	if fired := converse(b, tts, mic); len(fired) != 1 {
+		t.Errorf("fired at %v after Reset, want once", fired)
+	}
+}
+
+func TestBargeInLearnsEchoReturnLoss(t *testing.T) {
+	b, err := NewBargeInDetector(BargeInOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tts := voice(4*time.Second, -15, 140)
+	converse(b, tts, echo(tts, 120*time.Millisecond, 20))
+	if erl := b.ERL(); erl < 14 || erl > 22 {
+		t.Errorf("ERL = %.1f dB after a 20dB echo, want it learned from the initial 6dB", erl)
+	}
+	if !b.Playing() {
+		t.Error("not playing right after playback")
+	}
+
+	// The estimate survives Reset, so the next playback starts from it
+	b.Reset()
+	if erl := b.ERL(); erl < 14 {
+		t.Errorf("ERL = %.1f dB after Reset, want it kept", erl)
+	}
+	if b.Playing() {
+		t.Error("still playing after Reset")
+	}
+}
+
+func BenchmarkBargeIn(b *testing.B) {
+	d, err := NewBargeInDetector(BargeInOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	tts := voice(time.Second, -15, 140)
+	mic := mix(echo(tts, 120*time.Millisecond, 15), voice(500*time.Millisecond, -12, 220), 250*time.Millisecond)
+	n := samples(chunk)
+	b.SetBytes(int64(len(mic) * 2))
+	for b.Loop() {
+		for i := 0; i < len(mic); i += n {
+			d.Playback(tts[i : i+n])
+			d.Process(mic[i : i+n])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package vad
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/audio"
+)
+
+// The fixtures in testdata are 8kHz μ-law telephone-channel clips; see
+// testdata/README.md for how they were made and how to add recordings
+
+// readFixture reads a mono 8kHz WAV fixture
+func readFixture(t *testing.T, name string) []int16 {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	frame, err := audio.ReadWAV(f)
+	if err != nil {
+		t.Fatalf("ReadWAV(%s): %v", name, err)
+	}
+	if frame.SampleRate != audio.Rate8k || frame.Channels != 1 {
+		t.Fatalf("%s is %+v, want 8kHz mono", name, frame.Format)
+	}
+	return frame.PCM
+}
+
+func TestDetectorFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		opts Options
+		// want is where the speech in the clip is, within a frame plus
+		// MinSpeech at the start and Hangover at the end
+		want []wantEvent
+	}{
+		{
+			file: "speech_babble.wav",
+			want: []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2600 * time.Millisecond}},
+		},
+		{
+			file: "speech_babble.wav",
+			opts: Options{Aggressiveness: VeryAggressive, FrameDuration: 30 * time.Millisecond},
+			want: []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2600 * time.Millisecond}},
+		},
+		{file: "noise_street.wav"},
+		{file: "noise_street.wav", opts: Options{Aggressiveness: Quality}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			tt.opts.SampleRate = audio.Rate8k
+			d, err := NewDetector(tt.opts)
+			if err != nil {
+				t.Fatalf("NewDetector: %v", err)
+			}
+			events := d.Flush(d.Process(nil, readFixture(t, tt.file)))
+			checkEvents(t, events, tt.want, d.opts.FrameDuration)
+		})
+	}
+}
+
+func TestBargeInFixtures(t *testing.T) {
+	play := readFixture(t, "echo_playback.wav")
+
+	tests := []struct {
+		file string
+		// want is when the detector should fire, within 60ms; zero means
+		// never
+		want time.Duration
+	}{
+		{file: "echo_only.wav"},
+		// The caller starts at 2s and must talk for MinDuration
+		{file: "echo_bargein.wav", want: 2200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := NewBargeInDetector(BargeInOptions{VAD: Options{SampleRate: audio.Rate8k}})
+			if err != nil {
+				t.Fatalf("NewBargeInDetector: %v", err)
+			}
+			fired := converseAt(b, play, readFixture(t, tt.file), audio.Rate8k)
+			if tt.want == 0 {
+				if len(fired) > 0 {
+					t.Errorf("fired at %v, want never", fired)
+				}
+				return
+			}
+			if len(fired) != 1 {
+				t.Fatalf("fired at %v, want once at %v", fired, tt.want)
+			}
+			if fired[0] < tt.want-60*time.Millisecond || fired[0] > tt.want+60*time.Millisecond {
+				t.Errorf("fired at %v, want %v", fired[0], tt.want)
+			}
+		})
+	}
+}
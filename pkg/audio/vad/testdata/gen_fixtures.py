@@ -0,0 +1,243 @@
+"""Generates the telephone-channel fixtures in this directory.
+
+These clips are synthesized, not recorded; see README.md. Speech is a
+source-filter model (a jittered glottal pulse train through vowel formant
+resonators, with fricative noise bursts and stop closures), every clip
+passes through a 300-3400 Hz telephone band and is stored as 8 kHz mu-law
+WAV, and echo goes through a synthetic room impulse response. Output is
+deterministic. Run with Python 3.11 or 3.12 (audioop was removed in 3.13):
+
+    python3 gen_fixtures.py
+"""
+
+import audioop
+import math
+import random
+import struct
+
+RATE = 8000
+
+# F1, F2, F3 in Hz for a few vowels
+VOWELS = {
+    "a": (730, 1090, 2440),
+    "e": (530, 1840, 2480),
+    "i": (270, 2290, 3010),
+    "o": (570, 840, 2410),
+    "u": (300, 870, 2240),
+}
+
+
+def seconds(s):
+    return int(round(s * RATE))
+
+
+class Biquad:
+    """A direct form I biquad (RBJ cookbook)"""
+
+    def __init__(self, b0, b1, b2, a0, a1, a2):
+        self.b = (b0 / a0, b1 / a0, b2 / a0)
+        self.a = (a1 / a0, a2 / a0)
+        self.x1 = self.x2 = self.y1 = self.y2 = 0.0
+
+    @classmethod
+    def lowpass(cls, f, q=0.707):
+        w = 2 * math.pi * f / RATE
+        alpha = math.sin(w) / (2 * q)
+        c = math.cos(w)
+        return cls((1 - c) / 2, 1 - c, (1 - c) / 2, 1 + alpha, -2 * c, 1 - alpha)
+
+    @classmethod
+    def highpass(cls, f, q=0.707):
+        w = 2 * math.pi * f / RATE
+        alpha = math.sin(w) / (2 * q)
+        c = math.cos(w)
+        return cls((1 + c) / 2, -(1 + c), (1 + c) / 2, 1 + alpha, -2 * c, 1 - alpha)
+
+    @classmethod
+    def bandpass(cls, f, bw):
+        q = f / bw
+        w = 2 * math.pi * f / RATE
+        alpha = math.sin(w) / (2 * q)
+        c = math.cos(w)
+        return cls(alpha, 0, -alpha, 1 + alpha, -2 * c, 1 - alpha)
+
+    def __call__(self, x):
+        y = (self.b[0] * x + self.b[1] * self.x1 + self.b[2] * self.x2
+             - self.a[0] * self.y1 - self.a[1] * self.y2)
+        self.x2, self.x1 = self.x1, x
+        self.y2, self.y1 = self.y1, y
+        return y
+
+
+def filt(signal, *filters):
+    out = []
+    for x in signal:
+        for f in filters:
+            x = f(x)
+        out.append(x)
+    return out
+
+
+def level(signal, db):
+    """Scales signal to an RMS of db dBFS"""
+    power = sum(x * x for x in signal) / max(1, len(signal))
+    if power == 0:
+        return signal
+    scale = 32768 * 10 ** (db / 20) / math.sqrt(power)
+    return [x * scale for x in signal]
+
+
+def vowel(rng, duration, pitch, name):
+    """A voiced syllable nucleus with a rise-fall envelope"""
+    n = seconds(duration)
+    f1, f2, f3 = VOWELS[name]
+    formants = [Biquad.bandpass(f1, 80), Biquad.bandpass(f2, 120), Biquad.bandpass(f3, 160)]
+    gains = (1.0, 0.5, 0.25)
+    out = []
+    phase = 0.0
+    for i in range(n):
+        # Pitch declines over the syllable, with a little jitter
+        f0 = pitch * (1.1 - 0.2 * i / n) * (1 + 0.01 * rng.gauss(0, 1))
+        phase += f0 / RATE
+        pulse = 0.0
+        if phase >= 1:
+            phase -= 1
+            pulse = 1.0
+        env = math.sin(math.pi * i / n) ** 0.5
+        out.append(env * sum(g * f(pulse) for g, f in zip(gains, formants)))
+    return out
+
+
+def fricative(rng, duration):
+    """An unvoiced hiss like /s/ or /f/"""
+    n = seconds(duration)
+    hp = Biquad.highpass(2500)
+    return [0.15 * math.sin(math.pi * i / n) * hp(rng.uniform(-1, 1)) for i in range(n)]
+
+
+def utterance(rng, duration, pitch):
+    """Syllables with fricatives and short stop closures, about 4 a second"""
+    out = []
+    while len(out) < seconds(duration):
+        if rng.random() < 0.3:
+            out += fricative(rng, rng.uniform(0.05, 0.09))
+        out += vowel(rng, rng.uniform(0.12, 0.22), pitch, rng.choice("aeiou"))
+        if rng.random() < 0.4:
+            # Stop closure, far shorter than the detector's hangover
+            out += [0.0] * seconds(rng.uniform(0.03, 0.06))
+    out = out[:seconds(duration)]
+    # Fade the edges so the cut is not a click
+    fade = seconds(0.02)
+    for i in range(fade):
+        out[i] *= i / fade
+        out[-1 - i] *= i / fade
+    return out
+
+
+def pink(rng, duration):
+    """Approximately pink noise (Paul Kellet's economy filter)"""
+    b0 = b1 = b2 = 0.0
+    out = []
+    for _ in range(seconds(duration)):
+        w = rng.uniform(-1, 1)
+        b0 = 0.99765 * b0 + w * 0.0990460
+        b1 = 0.96300 * b1 + w * 0.2965164
+        b2 = 0.57000 * b2 + w * 1.0526913
+        out.append(b0 + b1 + b2 + w * 0.1848)
+    return out
+
+
+def babble(rng, duration, talkers):
+    """Several overlapping distant talkers"""
+    out = [0.0] * seconds(duration)
+    for _ in range(talkers):
+        voice = utterance(rng, duration, rng.uniform(100, 220))
+        offset = rng.randrange(len(out))
+        for i in range(len(out)):
+            out[i] += voice[(i + offset) % len(voice)]
+    return out
+
+
+def room(rng, delay, loss, rt60):
+    """An echo path impulse response: bulk delay, then a decaying tail"""
+    n = seconds(delay + rt60)
+    ir = [0.0] * n
+    start = seconds(delay)
+    ir[start] = 1.0
+    decay = math.log(1000) / seconds(rt60)
+    for i in range(start + 1, n):
+        ir[i] = 0.3 * rng.gauss(0, 1) * math.exp(-decay * (i - start))
+    power = sum(x * x for x in ir)
+    gain = 10 ** (-loss / 20) / math.sqrt(power)
+    return [x * gain for x in ir]
+
+
+def convolve(signal, ir):
+    taps = [(i, h) for i, h in enumerate(ir) if abs(h) > 1e-4]
+    out = [0.0] * len(signal)
+    for i, h in taps:
+        for j in range(len(signal) - i):
+            out[i + j] += h * signal[j]
+    return out
+
+
+def mix(*signals):
+    n = max(len(s) for s in signals)
+    return [sum(s[i] for s in signals if i < len(s)) for i in range(n)]
+
+
+def place(signal, at, total):
+    out = [0.0] * seconds(total)
+    start = seconds(at)
+    for i, x in enumerate(signal):
+        if start + i < len(out):
+            out[start + i] = x
+    return out
+
+
+def phone(signal):
+    """The telephone band"""
+    return filt(signal, Biquad.highpass(300), Biquad.highpass(300), Biquad.lowpass(3400))
+
+
+def write_mulaw_wav(name, signal):
+    pcm = struct.pack("<%dh" % len(signal), *(max(-32768, min(32767, int(round(x)))) for x in signal))
+    data = audioop.lin2ulaw(pcm, 2)
+    fmt = struct.pack("<HHIIHHH", 7, 1, RATE, RATE, 1, 8, 0)
+    with open(name, "wb") as f:
+        f.write(b"RIFF")
+        f.write(struct.pack("<I", 4 + 8 + len(fmt) + 8 + len(data) + len(data) % 2))
+        f.write(b"WAVE")
+        f.write(b"fmt " + struct.pack("<I", len(fmt)) + fmt)
+        f.write(b"data" + struct.pack("<I", len(data)) + data)
+        if len(data) % 2:
+            f.write(b"\0")
+
+
+def main():
+    rng = random.Random(20251018)
+
+    # A caller speaking from 1.0s to 2.6s over cafe babble
+    speech = level(utterance(rng, 1.6, 125), -22)
+    background = level(babble(rng, 4, 6), -45)
+    write_mulaw_wav("speech_babble.wav", phone(mix(background, place(speech, 1.0, 4))))
+
+    # Street noise with a door slam, and no speech
+    street = level(pink(rng, 4), -42)
+    slam = level([rng.gauss(0, 1) * math.exp(-i / seconds(0.01)) for i in range(seconds(0.06))], -20)
+    write_mulaw_wav("noise_street.wav", phone(mix(street, place(slam, 2.0, 4))))
+
+    # The agent's TTS playback, and the microphone hearing its echo through
+    # a room 15dB down, with and without the caller talking over it from
+    # 2.0s to 3.2s
+    tts = phone(level(utterance(rng, 4, 210), -16))
+    echo = convolve(tts, room(rng, 0.03, 15, 0.2))
+    line = level(pink(rng, 4), -58)
+    caller = level(utterance(rng, 1.2, 115), -18)
+    write_mulaw_wav("echo_playback.wav", tts)
+    write_mulaw_wav("echo_only.wav", phone(mix(line, echo)))
+    write_mulaw_wav("echo_bargein.wav", phone(mix(line, echo, place(caller, 2.0, 4))))
+
+
+if __name__ == "__main__":
+    main()
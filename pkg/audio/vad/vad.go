@@ -0,0 +1,335 @@
+// Package vad detects speech in PCM16 audio with frame energy and zero
+// crossing rate against an adaptive noise floor, and detects callers
+// barging in over TTS playback without triggering on its echo.
+package vad
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/audio"
+)
+
+// Aggressiveness levels, from most to least likely to report speech
+const (
+	Quality = iota
+	LowBitrate
+	Aggressive
+	VeryAggressive
+)
+
+// thresholds are indexed by aggressiveness
+var (
+	// marginDB is how far above the noise floor a frame must be
+	marginDB = [...]float64{6, 9, 12, 15}
+	// floorDB is the minimum level of a speech frame in dBFS
+	floorDB = [...]float64{-55, -50, -45, -40}
+	// maxZCR is the zero crossing rate above which a frame only counts as
+	// speech if it is well above the margin, rejecting hiss
+	maxZCR = [...]float64{0.5, 0.45, 0.4, 0.35}
+)
+
+// zcrBoostDB is the extra level that lets a noisy-sounding frame count
+// as speech, so fricatives are not cut off
+const zcrBoostDB = 10
+
+// The noise floor is the minimum frame power over noiseWindows windows of
+// noiseWindow each, scaled by noiseBias since the minimum of a noisy
+// signal sits below its mean. Speech has pauses between syllables, so the
+// minimum tracks the background even while someone talks.
+const (
+	noiseWindows = 8
+	noiseWindow  = 200 * time.Millisecond
+	noiseBias    = 2
+)
+
+// Options configures a Detector
+type Options struct {
+	// SampleRate of the input; default 16000
+	SampleRate int
+	// FrameDuration is 10, 20 or 30ms; default 20ms
+	FrameDuration time.Duration
+	// Aggressiveness is Quality through VeryAggressive; higher values
+	// report less noise as speech but may clip quiet speech
+	Aggressiveness int
+	// MinSpeech is how long speech must last before SpeechStart; default
+	// 100ms
+	MinSpeech time.Duration
+	// Hangover is how long silence must last before SpeechEnd; default
+	// 300ms
+	Hangover time.Duration
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.SampleRate <= 0 {
+		o.SampleRate = audio.Rate16k
+	}
+	if o.FrameDuration <= 0 {
+		o.FrameDuration = 20 * time.Millisecond
+	}
+	if o.MinSpeech <= 0 {
+		o.MinSpeech = 100 * time.Millisecond
+	}
+	if o.Hangover <= 0 {
+		o.Hangover = 300 * time.Millisecond
+	}
+	return o
+}
+
+// validate checks options after defaults
+func (o Options) validate() error {
+	switch o.FrameDuration {
+	case 10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond:
+	default:
+		return fmt.Errorf("invalid vad frame duration %v", o.FrameDuration)
+	}
+	if o.Aggressiveness < Quality || o.Aggressiveness > VeryAggressive {
+		return fmt.Errorf("invalid vad aggressiveness %d", o.Aggressiveness)
+	}
+	return nil
+}
+
+// EventType distinguishes speech events
+type EventType int
+
+// Event types
+const (
+	SpeechStart EventType = iota + 1
+	SpeechEnd
+)
+
+// String returns the event name
+func (t EventType) String() string {
+	switch t {
+	case SpeechStart:
+		return "speech_start"
+	case SpeechEnd:
+		return "speech_end"
+	default:
+		return "unknown"
+	}
+}
+
+// Event marks the start or end of speech
+type Event struct {
+	Type EventType
+	// Time is the stream position where speech started or ended
+	Time time.Duration
+	// Duration is the length of the speech, on SpeechEnd
+	Duration time.Duration
+}
+
+// Detector turns a mono PCM16 stream into speech events. It is not safe
+// for concurrent use.
+type Detector struct {
+	opts      Options
+	frameSize int
+	pending   []int16
+
+	// minima holds the minimum power of the last noiseWindows windows;
+	// minimum is that of the current window, after window frames
+	minima  [noiseWindows]float64
+	next    int
+	minimum float64
+	window  int
+	frames  int
+
+	speaking bool
+	// run counts consecutive frames disagreeing with the current state
+	run int
+	// runStart is the first frame of the run; speechStart the first
+	// frame of the current speech
+	runStart    int
+	speechStart int
+	minSpeech   int
+	hangover    int
+}
+
+// NewDetector creates a detector
+func NewDetector(opts Options) (*Detector, error) {
+	opts = opts.withDefaults()
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	frameSize := int(opts.FrameDuration * time.Duration(opts.SampleRate) / time.Second)
+	d := &Detector{
+		opts:      opts,
+		frameSize: frameSize,
+		pending:   make([]int16, 0, frameSize),
+		minSpeech: frameCount(opts.MinSpeech, opts.FrameDuration),
+		hangover:  frameCount(opts.Hangover, opts.FrameDuration),
+	}
+	d.Reset()
+	return d, nil
+}
+
+// FrameSize returns the number of samples per analysis frame
+func (d *Detector) FrameSize() int {
+	return d.frameSize
+}
+
+// Speaking reports whether the detector is inside speech
+func (d *Detector) Speaking() bool {
+	return d.speaking
+}
+
+// Reset forgets the stream, including the noise estimate, which is
+// rebuilt from the quietest frames seen
+func (d *Detector) Reset() {
+	d.pending = d.pending[:0]
+	for i := range d.minima {
+		d.minima[i] = math.Inf(1)
+	}
+	d.minimum = math.Inf(1)
+	d.window = 0
+	d.frames = 0
+	d.speaking = false
+	d.run = 0
+}
+
+// Process analyses pcm, which may be any length, and appends the events
+// it completes to events
+func (d *Detector) Process(events []Event, pcm []int16) []Event {
+	if len(d.pending) > 0 {
+		n := min(d.frameSize-len(d.pending), len(pcm))
+		d.pending = append(d.pending, pcm[:n]...)
+		pcm = pcm[n:]
+		if len(d.pending) < d.frameSize {
+			return events
+		}
+		events = d.step(events, d.pending)
+		d.pending = d.pending[:0]
+	}
+	for len(pcm) >= d.frameSize {
+		events = d.step(events, pcm[:d.frameSize])
+		pcm = pcm[d.frameSize:]
+	}
+	d.pending = append(d.pending, pcm...)
+	return events
+}
+
+// Flush ends speech in progress at the current position, appending its
+// SpeechEnd to events
+func (d *Detector) Flush(events []Event) []Event {
+	if d.speaking {
+		events = append(events, d.end(d.frames))
+	}
+	d.speaking = false
+	d.run = 0
+	return events
+}
+
+// step classifies one frame and advances the state machine
+func (d *Detector) step(events []Event, frame []int16) []Event {
+	speech, _ := d.classify(frame)
+	index := d.frames
+	d.frames++
+
+	if speech == d.speaking {
+		d.run = 0
+		return events
+	}
+	if d.run == 0 {
+		d.runStart = index
+	}
+	d.run++
+
+	switch {
+	case !d.speaking && d.run >= d.minSpeech:
+		d.speaking = true
+		d.speechStart = d.runStart
+		d.run = 0
+		events = append(events, Event{Type: SpeechStart, Time: d.position(d.speechStart)})
+	case d.speaking && d.run >= d.hangover:
+		events = append(events, d.end(d.runStart))
+		d.speaking = false
+		d.run = 0
+	}
+	return events
+}
+
+// end builds the SpeechEnd event for speech ending before frame
+func (d *Detector) end(frame int) Event {
+	return Event{
+		Type:     SpeechEnd,
+		Time:     d.position(frame),
+		Duration: d.position(frame) - d.position(d.speechStart),
+	}
+}
+
+// classify decides whether a frame is speech and returns its level in
+// dBFS
+func (d *Detector) classify(frame []int16) (bool, float64) {
+	power, zcr := analyse(frame)
+	level := powerToDB(power)
+	noise := powerToDB(d.noiseFloor(power))
+	a := d.opts.Aggressiveness
+
+	threshold := max(noise+marginDB[a], floorDB[a])
+	speech := level > threshold
+	if speech && zcr > maxZCR[a] && level < threshold+zcrBoostDB {
+		speech = false
+	}
+	return speech, level
+}
+
+// noiseFloor records a frame's power and returns the noise estimate
+func (d *Detector) noiseFloor(power float64) float64 {
+	d.minimum = min(d.minimum, power)
+	d.window++
+	if d.window >= frameCount(noiseWindow, d.opts.FrameDuration) {
+		d.minima[d.next] = d.minimum
+		d.next = (d.next + 1) % noiseWindows
+		d.minimum = math.Inf(1)
+		d.window = 0
+	}
+
+	floor := d.minimum
+	for _, m := range d.minima {
+		floor = min(floor, m)
+	}
+	return floor * noiseBias
+}
+
+// position returns the stream time of a frame index
+func (d *Detector) position(frame int) time.Duration {
+	return time.Duration(frame) * d.opts.FrameDuration
+}
+
+// analyse returns the mean power of a frame, normalised to full scale,
+// and its zero crossing rate
+func analyse(frame []int16) (float64, float64) {
+	if len(frame) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	crossings := 0
+	for i, s := range frame {
+		v := float64(s) / 32768
+		sum += v * v
+		if i > 0 && (s >= 0) != (frame[i-1] >= 0) {
+			crossings++
+		}
+	}
+	return sum / float64(len(frame)), float64(crossings) / float64(len(frame))
+}
+
+// frameCount converts a duration to whole frames, at least one
+func frameCount(d, frame time.Duration) int {
+	return max(1, int((d+frame-1)/frame))
+}
+
+// powerToDB converts mean power to dBFS
+func powerToDB(p float64) float64 {
+	if p <= 1e-12 {
+		return -120
+	}
+	return 10 * math.Log10(p)
+}
+
+// dbToPower converts dBFS to mean power
+func dbToPower(db float64) float64 {
+	return math.Pow(10, db/10)
+}
@@ -0,0 +1,298 @@
+package vad
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+const rate = 16000
+
+// Fixture signals are generated from fixed seeds, so every run sees the
+// same samples
+
+// samples returns the number of samples in d
+func samples(d time.Duration) int {
+	return int(d * rate / time.Second)
+}
+
+// amplitude converts a level in dBFS to a linear RMS sample value
+func amplitude(db float64) float64 {
+	return 32768 * math.Pow(10, db/20)
+}
+
+// silence is digital silence
+func silence(d time.Duration) []int16 {
+	return make([]int16, samples(d))
+}
+
+// noise is white background noise at db
+func noise(d time.Duration, db float64, seed uint64) []int16 {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	// Uniform noise in [-a, a] has RMS a/√3
+	a := amplitude(db) * math.Sqrt(3)
+	pcm := make([]int16, samples(d))
+	for i := range pcm {
+		pcm[i] = int16(a * (2*rng.Float64() - 1))
+	}
+	return pcm
+}
+
+// hiss is noise with nearly every sample changing sign, like a fan or a
+// sibilant-free line hiss
+func hiss(d time.Duration, db float64, seed uint64) []int16 {
+	pcm := noise(d, db, seed)
+	for i, s := range pcm {
+		v := max(int16(100), s, -s)
+		if i%2 == 1 {
+			v = -v
+		}
+		pcm[i] = v
+	}
+	return pcm
+}
+
+// voice is a voiced, speech-like signal at db: a harmonic series on pitch
+// with a syllable-rate envelope
+func voice(d time.Duration, db, pitch float64) []int16 {
+	pcm := make([]float64, samples(d))
+	var power float64
+	for i := range pcm {
+		t := float64(i) / rate
+		var v float64
+		for h := 1.0; h <= 6; h++ {
+			v += math.Sin(2*math.Pi*pitch*h*t) / h
+		}
+		v *= 0.65 + 0.35*math.Sin(2*math.Pi*4*t)
+		pcm[i] = v
+		power += v * v
+	}
+	scale := amplitude(db) / math.Sqrt(power/float64(len(pcm)))
+	out := make([]int16, len(pcm))
+	for i, v := range pcm {
+		out[i] = int16(v * scale)
+	}
+	return out
+}
+
+// mix adds b to a, starting at offset
+func mix(a, b []int16, offset time.Duration) []int16 {
+	out := slices.Clone(a)
+	start := samples(offset)
+	for i, s := range b {
+		if start+i < len(out) {
+			out[start+i] = int16(max(-32768, min(32767, int(out[start+i])+int(s))))
+		}
+	}
+	return out
+}
+
+// join concatenates signals
+func join(parts ...[]int16) []int16 {
+	return slices.Concat(parts...)
+}
+
+// wantEvent is an expected event, matched within a tolerance
+type wantEvent struct {
+	typ EventType
+	at  time.Duration
+}
+
+func TestDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		signal []int16
+		want   []wantEvent
+	}{
+		{
+			name:   "silence",
+			signal: silence(2 * time.Second),
+		},
+		{
+			name:   "steady background noise",
+			signal: noise(3*time.Second, -50, 1),
+		},
+		{
+			name: "speech in silence",
+			signal: join(silence(time.Second), voice(time.Second, -25, 140),
+				silence(time.Second)),
+			want: []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2 * time.Second}},
+		},
+		{
+			name: "speech over noise",
+			signal: mix(noise(4*time.Second, -45, 2),
+				voice(1500*time.Millisecond, -20, 200), 2*time.Second),
+			want: []wantEvent{{SpeechStart, 2 * time.Second}, {SpeechEnd, 3500 * time.Millisecond}},
+		},
+		{
+			name: "quiet speech below the aggressive floor",
+			opts: Options{Aggressiveness: VeryAggressive},
+			signal: join(silence(time.Second), voice(time.Second, -45, 140),
+				silence(time.Second)),
+		},
+		{
+			name: "quiet speech at quality",
+			opts: Options{Aggressiveness: Quality},
+			signal: join(silence(time.Second), voice(time.Second, -45, 140),
+				silence(time.Second)),
+			want: []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2 * time.Second}},
+		},
+		{
+			name:   "click shorter than MinSpeech",
+			signal: join(silence(time.Second), voice(60*time.Millisecond, -20, 140), silence(time.Second)),
+		},
+		{
+			name: "pause shorter than Hangover",
+			signal: join(silence(time.Second), voice(500*time.Millisecond, -25, 140),
+				silence(200*time.Millisecond), voice(500*time.Millisecond, -25, 140), silence(time.Second)),
+			want: []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2200 * time.Millisecond}},
+		},
+		{
+			name: "pause longer than Hangover",
+			signal: join(silence(time.Second), voice(500*time.Millisecond, -25, 140),
+				silence(500*time.Millisecond), voice(500*time.Millisecond, -25, 140), silence(time.Second)),
+			want: []wantEvent{
+				{SpeechStart, time.Second}, {SpeechEnd, 1500 * time.Millisecond},
+				{SpeechStart, 2 * time.Second}, {SpeechEnd, 2500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "hiss rejected by zero crossing rate",
+			opts:   Options{Aggressiveness: VeryAggressive},
+			signal: join(silence(time.Second), hiss(time.Second, -35, 3), silence(time.Second)),
+		},
+		{
+			name:   "loud hiss still counts",
+			opts:   Options{Aggressiveness: VeryAggressive},
+			signal: join(silence(time.Second), hiss(time.Second, -20, 3), silence(time.Second)),
+			want:   []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2 * time.Second}},
+		},
+		{
+			name:   "10ms frames",
+			opts:   Options{FrameDuration: 10 * time.Millisecond},
+			signal: join(silence(time.Second), voice(time.Second, -25, 140), silence(time.Second)),
+			want:   []wantEvent{{SpeechStart, time.Second}, {SpeechEnd, 2 * time.Second}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDetector(tt.opts)
+			if err != nil {
+				t.Fatalf("NewDetector: %v", err)
+			}
+			events := d.Flush(d.Process(nil, tt.signal))
+			checkEvents(t, events, tt.want, d.opts.FrameDuration)
+		})
+	}
+}
+
+// checkEvents compares events with want, allowing a frame of error
+func checkEvents(t *testing.T, events []Event, want []wantEvent, frame time.Duration) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("got events %v, want %v", events, want)
+	}
+	var start time.Duration
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.typ || e.Time < w.at-frame || e.Time > w.at+frame {
+			t.Errorf("event %d is %v at %v, want %v at %v", i, e.Type, e.Time, w.typ, w.at)
+		}
+		switch e.Type {
+		case SpeechStart:
+			start = e.Time
+		case SpeechEnd:
+			if e.Duration != e.Time-start {
+				t.Errorf("event %d lasts %v, want %v", i, e.Duration, e.Time-start)
+			}
+		}
+	}
+}
+
+func TestDetectorChunking(t *testing.T) {
+	signal := mix(noise(5*time.Second, -50, 4), join(
+		silence(time.Second), voice(800*time.Millisecond, -25, 120),
+		silence(600*time.Millisecond), voice(1200*time.Millisecond, -22, 220)), 0)
+
+	d, err := NewDetector(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := d.Flush(d.Process(nil, signal))
+	if len(want) != 4 {
+		t.Fatalf("got %d events from the whole signal, want 4", len(want))
+	}
+
+	// Feeding the stream in pieces that don't line up with frames gives
+	// the same events
+	for _, size := range []int{1, 7, 160, 321, 4000} {
+		d.Reset()
+		var got []Event
+		for i := 0; i < len(signal); i += size {
+			got = d.Process(got, signal[i:min(len(signal), i+size)])
+		}
+		got = d.Flush(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("chunks of %d: got %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestDetectorFlush(t *testing.T) {
+	d, err := NewDetector(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := d.Process(nil, join(silence(time.Second), voice(time.Second, -25, 140)))
+	if len(events) != 1 || !d.Speaking() {
+		t.Fatalf("got %v, want speech in progress", events)
+	}
+	events = d.Flush(events[:0])
+	if len(events) != 1 || events[0].Type != SpeechEnd || events[0].Time != 2*time.Second {
+		t.Errorf("Flush gave %v, want speech_end at 2s", events)
+	}
+	if d.Speaking() {
+		t.Error("still speaking after Flush")
+	}
+	if events := d.Flush(nil); len(events) != 0 {
+		t.Errorf("second Flush gave %v", events)
+	}
+}
+
+func TestNewDetectorValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"defaults", Options{}, false},
+		{"30ms frames at 8kHz", Options{SampleRate: 8000, FrameDuration: 30 * time.Millisecond}, false},
+		{"15ms frames", Options{FrameDuration: 15 * time.Millisecond}, true},
+		{"negative aggressiveness", Options{Aggressiveness: -1}, true},
+		{"aggressiveness above range", Options{Aggressiveness: VeryAggressive + 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDetector(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func BenchmarkDetector(b *testing.B) {
+	d, err := NewDetector(Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	signal := mix(noise(time.Second, -50, 5), voice(500*time.Millisecond, -25, 140), 250*time.Millisecond)
+	var events []Event
+	b.SetBytes(int64(len(signal) * 2))
+	for b.Loop() {
+		events = d.Process(events[:0], signal)
+	}
+}
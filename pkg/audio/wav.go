@@ -0,0 +1,206 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ErrInvalidWAV is returned for data that is not a supported WAV file
+var ErrInvalidWAV = errors.New("invalid wav file")
+
+// WAV format codes
+const (
+	wavFormatPCM        = 1
+	wavFormatALaw       = 6
+	wavFormatMuLaw      = 7
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavHeaderSize is the size of the header WriteWAV and WAVWriter emit
+const wavHeaderSize = 44
+
+// ReadWAV reads a WAV file into a PCM16 frame. 8-bit and 16-bit PCM,
+// μ-law and A-law data are supported.
+func ReadWAV(r io.Reader) (Frame, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return Frame{}, fmt.Errorf("failed to read wav header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return Frame{}, fmt.Errorf("%w: missing RIFF/WAVE header", ErrInvalidWAV)
+	}
+
+	var (
+		format        Format
+		code          uint16
+		bitsPerSample uint16
+		haveFormat    bool
+	)
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return Frame{}, fmt.Errorf("%w: no data chunk: %v", ErrInvalidWAV, err)
+		}
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1024 {
+				return Frame{}, fmt.Errorf("%w: fmt chunk of %d bytes", ErrInvalidWAV, size)
+			}
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return Frame{}, fmt.Errorf("failed to read wav format: %w", err)
+			}
+			code = binary.LittleEndian.Uint16(data[0:2])
+			format.Channels = int(binary.LittleEndian.Uint16(data[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(data[4:8]))
+			bitsPerSample = binary.LittleEndian.Uint16(data[14:16])
+			if code == wavFormatExtensible && size >= 26 {
+				// The sub-format GUID starts with the format code
+				code = binary.LittleEndian.Uint16(data[24:26])
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return Frame{}, fmt.Errorf("%w: data before fmt chunk", ErrInvalidWAV)
+			}
+			if err := format.Validate(); err != nil {
+				return Frame{}, fmt.Errorf("%w: %v", ErrInvalidWAV, err)
+			}
+			// Streamed files may carry a placeholder size; read to EOF
+			var body io.Reader = r
+			if size != 0 && size != math.MaxUint32 {
+				body = io.LimitReader(r, int64(size))
+			}
+			data, err := io.ReadAll(body)
+			if err != nil {
+				return Frame{}, fmt.Errorf("failed to read wav data: %w", err)
+			}
+			pcm, err := wavSamples(code, bitsPerSample, data)
+			if err != nil {
+				return Frame{}, err
+			}
+			return Frame{Format: format, PCM: pcm}, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return Frame{}, fmt.Errorf("failed to skip wav chunk %q: %w", id, err)
+			}
+		}
+	}
+}
+
+// wavSamples converts WAV sample data to PCM16
+func wavSamples(code, bits uint16, data []byte) ([]int16, error) {
+	switch {
+	case code == wavFormatPCM && bits == 16:
+		return AppendSamples(make([]int16, 0, len(data)/2), data), nil
+	case code == wavFormatPCM && bits == 8:
+		pcm := make([]int16, len(data))
+		for i, b := range data {
+			pcm[i] = int16(int(b)-128) << 8
+		}
+		return pcm, nil
+	case code == wavFormatMuLaw && bits == 8:
+		return DecodeMuLaw(make([]int16, 0, len(data)), data), nil
+	case code == wavFormatALaw && bits == 8:
+		return DecodeALaw(make([]int16, 0, len(data)), data), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %d with %d bits per sample", ErrInvalidWAV, code, bits)
+	}
+}
+
+// WriteWAV writes f as a 16-bit PCM WAV file
+func WriteWAV(w io.Writer, f Frame) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	header := wavHeader(f.Format, len(f.PCM)*2)
+	if _, err := w.Write(header[:]); err != nil {
+		return fmt.Errorf("failed to write wav header: %w", err)
+	}
+	if _, err := w.Write(AppendPCM16(make([]byte, 0, len(f.PCM)*2), f.PCM)); err != nil {
+		return fmt.Errorf("failed to write wav data: %w", err)
+	}
+	return nil
+}
+
+// wavHeader builds a canonical PCM16 header for dataSize bytes of samples
+func wavHeader(format Format, dataSize int) [wavHeaderSize]byte {
+	var h [wavHeaderSize]byte
+	copy(h[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:8], uint32(36+dataSize))
+	copy(h[8:12], "WAVE")
+	copy(h[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:20], 16)
+	binary.LittleEndian.PutUint16(h[20:22], wavFormatPCM)
+	binary.LittleEndian.PutUint16(h[22:24], uint16(format.Channels))
+	binary.LittleEndian.PutUint32(h[24:28], uint32(format.SampleRate))
+	binary.LittleEndian.PutUint32(h[28:32], uint32(format.SampleRate*format.Channels*2))
+	binary.LittleEndian.PutUint16(h[32:34], uint16(format.Channels*2))
+	binary.LittleEndian.PutUint16(h[34:36], 16)
+	copy(h[36:40], "data")
+	binary.LittleEndian.PutUint32(h[40:44], uint32(dataSize))
+	return h
+}
+
+// WAVWriter streams PCM16 audio to a WAV file, e.g. a call recording.
+// The header sizes are filled in on Close.
+type WAVWriter struct {
+	w      io.WriteSeeker
+	format Format
+	size   int
+	buf    []byte
+}
+
+// NewWAVWriter writes a header for format to w and returns a writer for
+// the samples
+func NewWAVWriter(w io.WriteSeeker, format Format) (*WAVWriter, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	header := wavHeader(format, 0)
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, fmt.Errorf("failed to write wav header: %w", err)
+	}
+	return &WAVWriter{w: w, format: format}, nil
+}
+
+// Write appends interleaved samples
+func (ww *WAVWriter) Write(pcm []int16) error {
+	ww.buf = AppendPCM16(ww.buf[:0], pcm)
+	n, err := ww.w.Write(ww.buf)
+	ww.size += n
+	if err != nil {
+		return fmt.Errorf("failed to write wav data: %w", err)
+	}
+	return nil
+}
+
+// Duration returns the length of audio written so far
+func (ww *WAVWriter) Duration() time.Duration {
+	return ww.format.Duration(ww.size / 2)
+}
+
+// Close rewrites the header with the final sizes; it does not close the
+// underlying writer
+func (ww *WAVWriter) Close() error {
+	header := wavHeader(ww.format, ww.size)
+	if _, err := ww.w.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to wav header: %w", err)
+	}
+	if _, err := ww.w.Write(header[:]); err != nil {
+		return fmt.Errorf("failed to write wav header: %w", err)
+	}
+	if _, err := ww.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to seek to wav end: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// wavChunk is a RIFF chunk for building test files
+type wavChunk struct {
+	id   string
+	data []byte
+}
+
+// fmtChunk builds a fmt chunk
+func fmtChunk(code uint16, channels, rate int, bits uint16) wavChunk {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint16(data[0:2], code)
+	binary.LittleEndian.PutUint16(data[2:4], uint16(channels))
+	binary.LittleEndian.PutUint32(data[4:8], uint32(rate))
+	blockAlign := channels * int(bits) / 8
+	binary.LittleEndian.PutUint32(data[8:12], uint32(rate*blockAlign))
+	binary.LittleEndian.PutUint16(data[12:14], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(data[14:16], bits)
+	return wavChunk{"fmt ", data}
+}
+
+// buildWAV assembles a RIFF/WAVE file from chunks, padding odd sizes
+func buildWAV(chunks ...wavChunk) []byte {
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	for _, c := range chunks {
+		body.WriteString(c.id)
+		binary.Write(&body, binary.LittleEndian, uint32(len(c.data)))
+		body.Write(c.data)
+		if len(c.data)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+// withDataSize overwrites the size of the data chunk, which must be last
+func withDataSize(file []byte, dataLen int, size uint32) []byte {
+	binary.LittleEndian.PutUint32(file[len(file)-dataLen-4:], size)
+	return file
+}
+
+func TestWAVHeader(t *testing.T) {
+	f := Frame{Format: Format{SampleRate: Rate16k, Channels: 2}, PCM: []int16{1, -1, 2, -2}}
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, f); err != nil {
+		t.Fatalf("WriteWAV: %v", err)
+	}
+	data := buf.Bytes()
+	if len(data) != wavHeaderSize+8 {
+		t.Fatalf("wrote %d bytes, want %d", len(data), wavHeaderSize+8)
+	}
+
+	le := binary.LittleEndian
+	fields := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"riff", string(data[0:4]), "RIFF"},
+		{"riff size", le.Uint32(data[4:8]), uint32(36 + 8)},
+		{"wave", string(data[8:12]), "WAVE"},
+		{"fmt", string(data[12:16]), "fmt "},
+		{"fmt size", le.Uint32(data[16:20]), uint32(16)},
+		{"format", le.Uint16(data[20:22]), uint16(wavFormatPCM)},
+		{"channels", le.Uint16(data[22:24]), uint16(2)},
+		{"sample rate", le.Uint32(data[24:28]), uint32(Rate16k)},
+		{"byte rate", le.Uint32(data[28:32]), uint32(Rate16k * 4)},
+		{"block align", le.Uint16(data[32:34]), uint16(4)},
+		{"bits", le.Uint16(data[34:36]), uint16(16)},
+		{"data", string(data[36:40]), "data"},
+		{"data size", le.Uint32(data[40:44]), uint32(8)},
+	}
+	for _, field := range fields {
+		if field.got != field.want {
+			t.Errorf("%s = %v, want %v", field.name, field.got, field.want)
+		}
+	}
+	if got := AppendSamples(nil, data[44:]); !slices.Equal(got, f.PCM) {
+		t.Errorf("samples %v, want %v", got, f.PCM)
+	}
+}
+
+func TestWAVRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+	}{
+		{"empty", Frame{Format: Format{SampleRate: Rate8k, Channels: 1}}},
+		{"mono telephony", Frame{Format: Format{SampleRate: Rate8k, Channels: 1}, PCM: sine(440, 12000, Rate8k, 1, 800)}},
+		{"stereo webrtc", Frame{Format: Format{SampleRate: Rate48k, Channels: 2}, PCM: sine(1000, 30000, Rate48k, 2, 960)}},
+		{"extremes", Frame{Format: Format{SampleRate: Rate24k, Channels: 1}, PCM: []int16{math.MinInt16, -1, 0, 1, math.MaxInt16}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteWAV(&buf, tt.frame); err != nil {
+				t.Fatalf("WriteWAV: %v", err)
+			}
+			got, err := ReadWAV(&buf)
+			if err != nil {
+				t.Fatalf("ReadWAV: %v", err)
+			}
+			if got.Format != tt.frame.Format {
+				t.Errorf("format %+v, want %+v", got.Format, tt.frame.Format)
+			}
+			if !slices.Equal(got.PCM, tt.frame.PCM) {
+				t.Errorf("samples differ after round trip")
+			}
+		})
+	}
+}
+
+// TestWAVGolden reads files written by Python's wave module and checks
+// that WriteWAV reproduces them byte for byte
+func TestWAVGolden(t *testing.T) {
+	tests := []struct {
+		file     string
+		channels int
+		frames   int
+	}{
+		{"sweep_8k.wav", 1, 2986},
+		{"sweep_8k_stereo.wav", 2, 1000},
+		{"sweep_8k_downmix.wav", 1, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			frame, err := ReadWAV(bytes.NewReader(want))
+			if err != nil {
+				t.Fatalf("ReadWAV: %v", err)
+			}
+			if frame.SampleRate != Rate8k || frame.Channels != tt.channels || frame.Frames() != tt.frames {
+				t.Errorf("read %d frames of %+v, want %d at 8kHz with %d channels", frame.Frames(), frame.Format, tt.frames, tt.channels)
+			}
+
+			var buf bytes.Buffer
+			if err := WriteWAV(&buf, frame); err != nil {
+				t.Fatalf("WriteWAV: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Error("WriteWAV output differs from the golden file")
+			}
+		})
+	}
+
+	// The sweep starts at zero phase and the ramp at the int16 minimum
+	pcm := readTestWAV(t, "sweep_8k.wav").PCM
+	if pcm[0] != 0 || pcm[1000] != math.MinInt16 || pcm[len(pcm)-1] != 32737 {
+		t.Errorf("samples %d, %d, %d, want 0, -32768, 32737", pcm[0], pcm[1000], pcm[len(pcm)-1])
+	}
+}
+
+func TestReadWAV(t *testing.T) {
+	pcm := []int16{-1000, 0, 1000, 32767}
+	pcm16 := AppendPCM16(nil, pcm)
+	muLaw := EncodeMuLaw(nil, pcm)
+	aLaw := EncodeALaw(nil, pcm)
+	extensible := fmtChunk(wavFormatExtensible, 1, Rate8k, 16)
+	extensible.data = append(extensible.data, make([]byte, 24)...)
+	binary.LittleEndian.PutUint16(extensible.data[16:18], 22)
+	binary.LittleEndian.PutUint16(extensible.data[24:26], wavFormatPCM)
+
+	tests := []struct {
+		name    string
+		file    []byte
+		want    Frame
+		wantErr error
+	}{
+		{
+			name: "pcm16",
+			file: buildWAV(fmtChunk(wavFormatPCM, 1, Rate16k, 16), wavChunk{"data", pcm16}),
+			want: Frame{Format: Format{SampleRate: Rate16k, Channels: 1}, PCM: pcm},
+		},
+		{
+			name: "pcm8",
+			file: buildWAV(fmtChunk(wavFormatPCM, 1, Rate8k, 8), wavChunk{"data", []byte{0, 128, 255}}),
+			want: Frame{Format: Format{SampleRate: Rate8k, Channels: 1}, PCM: []int16{-32768, 0, 32512}},
+		},
+		{
+			name: "mu-law",
+			file: buildWAV(fmtChunk(wavFormatMuLaw, 1, Rate8k, 8), wavChunk{"data", muLaw}),
+			want: Frame{Format: Format{SampleRate: Rate8k, Channels: 1}, PCM: DecodeMuLaw(nil, muLaw)},
+		},
+		{
+			name: "a-law",
+			file: buildWAV(fmtChunk(wavFormatALaw, 1, Rate8k, 8), wavChunk{"data", aLaw}),
+			want: Frame{Format: Format{SampleRate: Rate8k, Channels: 1}, PCM: DecodeALaw(nil, aLaw)},
+		},
+		{
+			name: "extensible",
+			file: buildWAV(extensible, wavChunk{"data", pcm16}),
+			want: Frame{Format: Format{SampleRate: Rate8k, Channels: 1}, PCM: pcm},
+		},
+		{
+			name: "skips unknown chunks",
+			file: buildWAV(wavChunk{"LIST", []byte("odd")}, fmtChunk(wavFormatPCM, 2, Rate48k, 16),
+				wavChunk{"fact", []byte{1, 2, 3, 4}}, wavChunk{"data", pcm16}),
+			want: Frame{Format: Format{SampleRate: Rate48k, Channels: 2}, PCM: pcm},
+		},
+		{
+			name: "stops at data size",
+			file: append(withDataSize(buildWAV(fmtChunk(wavFormatPCM, 1, Rate16k, 16), wavChunk{"data", pcm16}), len(pcm16), 4), 9, 9),
+			want: Frame{Format: Format{SampleRate: Rate16k, Channels: 1}, PCM: pcm[:2]},
+		},
+		{
+			name: "streamed placeholder size",
+			file: withDataSize(buildWAV(fmtChunk(wavFormatPCM, 1, Rate16k, 16), wavChunk{"data", pcm16}), len(pcm16), math.MaxUint32),
+			want: Frame{Format: Format{SampleRate: Rate16k, Channels: 1}, PCM: pcm},
+		},
+		{
+			name:    "not riff",
+			file:    append([]byte("RIFX\x00\x00\x00\x00WAVE"), pcm16...),
+			wantErr: ErrInvalidWAV,
+		},
+		{
+			name:    "data before fmt",
+			file:    buildWAV(wavChunk{"data", pcm16}, fmtChunk(wavFormatPCM, 1, Rate16k, 16)),
+			wantErr: ErrInvalidWAV,
+		},
+		{
+			name:    "no data chunk",
+			file:    buildWAV(fmtChunk(wavFormatPCM, 1, Rate16k, 16)),
+			wantErr: ErrInvalidWAV,
+		},
+		{
+			name:    "short fmt chunk",
+			file:    buildWAV(wavChunk{"fmt ", make([]byte, 14)}, wavChunk{"data", pcm16}),
+			wantErr: ErrInvalidWAV,
+		},
+		{
+			name:    "float samples",
+			file:    buildWAV(fmtChunk(3, 1, Rate16k, 32), wavChunk{"data", make([]byte, 8)}),
+			wantErr: ErrInvalidWAV,
+		},
+		{
+			name:    "zero channels",
+			file:    buildWAV(fmtChunk(wavFormatPCM, 0, Rate16k, 16), wavChunk{"data", pcm16}),
+			wantErr: ErrInvalidWAV,
+		},
+		{
+			name:    "truncated header",
+			file:    []byte("RIFF"),
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadWAV(bytes.NewReader(tt.file))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadWAV: %v", err)
+			}
+			if got.Format != tt.want.Format {
+				t.Errorf("format %+v, want %+v", got.Format, tt.want.Format)
+			}
+			if !slices.Equal(got.PCM, tt.want.PCM) {
+				t.Errorf("samples %v, want %v", got.PCM, tt.want.PCM)
+			}
+		})
+	}
+}
+
+func TestWAVWriter(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "call.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	format := Format{SampleRate: Rate8k, Channels: 1}
+	w, err := NewWAVWriter(file, format)
+	if err != nil {
+		t.Fatalf("NewWAVWriter: %v", err)
+	}
+	pcm := sine(440, 8000, Rate8k, 1, 4000)
+	for i := 0; i < len(pcm); i += 160 {
+		if err := w.Write(pcm[i:min(len(pcm), i+160)]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if got := w.Duration(); got != 500*time.Millisecond {
+		t.Errorf("Duration = %v, want 500ms", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadWAV(file)
+	if err != nil {
+		t.Fatalf("ReadWAV: %v", err)
+	}
+	if got.Format != format || !slices.Equal(got.PCM, pcm) {
+		t.Errorf("read %d samples at %+v, want %d at %+v", len(got.PCM), got.Format, len(pcm), format)
+	}
+
+	if _, err := NewWAVWriter(file, Format{SampleRate: Rate8k}); err == nil {
+		t.Error("NewWAVWriter accepted a format without channels")
+	}
+}
+
+func BenchmarkReadWAV(b *testing.B) {
+	var buf bytes.Buffer
+	f := Frame{Format: Format{SampleRate: Rate16k, Channels: 1}, PCM: sine(440, 8000, Rate16k, 1, Rate16k)}
+	if err := WriteWAV(&buf, f); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := ReadWAV(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteWAV(b *testing.B) {
+	f := Frame{Format: Format{SampleRate: Rate16k, Channels: 1}, PCM: sine(440, 8000, Rate16k, 1, Rate16k)}
+	var buf bytes.Buffer
+	b.SetBytes(int64(len(f.PCM) * 2))
+	for b.Loop() {
+		buf.Reset()
+		if err := WriteWAV(&buf, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
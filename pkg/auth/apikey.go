@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every API key so leaked keys are easy to spot
+const APIKeyPrefix = "phk_"
+
+const (
+	apiKeyIDLength     = 8
+	apiKeySecretLength = 32
+	base62Alphabet     = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// APIKeyPattern matches API keys, e.g. for secret scanning of logs and repos
+var APIKeyPattern = regexp.MustCompile(`phk_[0-9A-Za-z]{8}_[0-9A-Za-z]{32}`)
+
+var (
+	// ErrAPIKeyNotFound is returned when no key matches
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrAPIKeyRevoked is returned for revoked keys
+	ErrAPIKeyRevoked = errors.New("api key revoked")
+	// ErrAPIKeyExpired is returned for expired keys
+	ErrAPIKeyExpired = errors.New("api key expired")
+)
+
+// APIKey is the stored metadata of an API key. The key itself is never
+// stored, only its SHA-256 hash.
+type APIKey struct {
+	ID       string `json:"id"`
+	UserID   string `json:"user_id"`
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+	// Prefix is the non-secret start of the key, shown in listings
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Valid returns ErrAPIKeyRevoked or ErrAPIKeyExpired when the key may not
+// be used at now
+func (k *APIKey) Valid(now time.Time) error {
+	if k.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+	if k.ExpiresAt != nil && !now.Before(*k.ExpiresAt) {
+		return ErrAPIKeyExpired
+	}
+	return nil
+}
+
+// Claims converts the key into claims so handlers see API key and JWT
+// callers the same way
+func (k *APIKey) Claims() *Claims {
+	claims := &Claims{
+		TenantID: k.TenantID,
+		UserID:   k.UserID,
+		Scopes:   k.Scopes,
+	}
+	claims.ID = k.ID
+	claims.Subject = k.UserID
+	return claims
+}
+
+// GenerateAPIKey returns a new random key and its display prefix. Keys look
+// like phk_<8 id chars>_<32 secret chars>.
+func GenerateAPIKey() (key, prefix string, err error) {
+	id, err := randomBase62(apiKeyIDLength)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	secret, err := randomBase62(apiKeySecretLength)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	prefix = APIKeyPrefix + id
+	return prefix + "_" + secret, prefix, nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key. Keys carry 190 bits of
+// entropy, so a fast hash is sufficient and allows indexed lookups.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether credential looks like an API key
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// RedactAPIKey returns the non-secret prefix of key for logging
+func RedactAPIKey(key string) string {
+	if len(key) <= len(APIKeyPrefix)+apiKeyIDLength {
+		return APIKeyPrefix + "…"
+	}
+	return key[:len(APIKeyPrefix)+apiKeyIDLength] + "_…"
+}
+
+// randomBase62 returns n random characters from base62Alphabet
+func randomBase62(n int) (string, error) {
+	max := big.NewInt(int64(len(base62Alphabet)))
+	var b strings.Builder
+	b.Grow(n)
+	for i := 0; i < n; i++ {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(base62Alphabet[idx.Int64()])
+	}
+	return b.String(), nil
+}
+
+// APIKeyStore persists API keys
+type APIKeyStore interface {
+	// Create stores a key by hash and returns the stored record
+	Create(ctx context.Context, key *APIKey, hash string) (*APIKey, error)
+	// FindByHash returns the key with hash or ErrAPIKeyNotFound
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	// ListByUser returns a user's keys, newest first
+	ListByUser(ctx context.Context, userID string) ([]*APIKey, error)
+	// Revoke marks a key revoked and returns its hash for cache invalidation
+	Revoke(ctx context.Context, id string) (string, error)
+	// TouchLastUsed records use of a key, at most once per interval
+	TouchLastUsed(ctx context.Context, id string, interval time.Duration) error
+}
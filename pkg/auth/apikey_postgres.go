@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresAPIKeyStore stores API keys in the api_keys table
+type PostgresAPIKeyStore struct {
+	db *sql.DB
+}
+
+// NewPostgresAPIKeyStore creates a store over db
+func NewPostgresAPIKeyStore(db *sql.DB) *PostgresAPIKeyStore {
+	return &PostgresAPIKeyStore{db: db}
+}
+
+const apiKeyColumns = `k.id, k.user_id, k.tenant_id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at, k.revoked_at, k.created_at`
+
+// Create implements APIKeyStore
+func (s *PostgresAPIKeyStore) Create(ctx context.Context, key *APIKey, hash string) (*APIKey, error) {
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys AS k (user_id, tenant_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+apiKeyColumns,
+		key.UserID, key.TenantID, key.Name, key.Prefix, hash, pq.Array(key.Scopes), key.ExpiresAt,
+	)
+	created, err := scanAPIKey(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return created, nil
+}
+
+// FindByHash implements APIKeyStore. Keys of deactivated users are not found.
+func (s *PostgresAPIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND u.is_active`, hash)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListByUser implements APIKeyStore
+func (s *PostgresAPIKeyStore) ListByUser(ctx context.Context, userID string) ([]*APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+` FROM api_keys k
+		WHERE k.user_id = $1
+		ORDER BY k.created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke implements APIKeyStore. Revoking an already revoked key keeps the
+// original revocation time.
+func (s *PostgresAPIKeyStore) Revoke(ctx context.Context, id string) (string, error) {
+	var hash string
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+		RETURNING key_hash`, id).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return hash, nil
+}
+
+// TouchLastUsed implements APIKeyStore
+func (s *PostgresAPIKeyStore) TouchLastUsed(ctx context.Context, id string, interval time.Duration) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - $2::interval)`,
+		id, fmt.Sprintf("%d milliseconds", interval.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to update api key last_used_at: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAPIKey reads apiKeyColumns into an APIKey
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var (
+		key                            APIKey
+		expiresAt, lastUsed, revokedAt sql.NullTime
+	)
+	err := row.Scan(&key.ID, &key.UserID, &key.TenantID, &key.Name, &key.Prefix,
+		pq.Array(&key.Scopes), &expiresAt, &lastUsed, &revokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.ExpiresAt = nullTime(expiresAt)
+	key.LastUsedAt = nullTime(lastUsed)
+	key.RevokedAt = nullTime(revokedAt)
+	return &key, nil
+}
+
+// nullTime converts sql.NullTime to a pointer
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
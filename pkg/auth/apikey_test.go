@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeAPIKeyStore is an in-memory APIKeyStore that counts lookups
+type fakeAPIKeyStore struct {
+	mu      sync.Mutex
+	byHash  map[string]*APIKey
+	lookups int
+	touched chan string
+}
+
+func newFakeAPIKeyStore() *fakeAPIKeyStore {
+	return &fakeAPIKeyStore{byHash: make(map[string]*APIKey), touched: make(chan string, 16)}
+}
+
+func (s *fakeAPIKeyStore) Create(ctx context.Context, key *APIKey, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created := *key
+	created.ID = fmt.Sprintf("key-%d", len(s.byHash)+1)
+	created.CreatedAt = time.Now()
+	s.byHash[hash] = &created
+	return &created, nil
+}
+
+func (s *fakeAPIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	key, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+func (s *fakeAPIKeyStore) ListByUser(ctx context.Context, userID string) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []*APIKey
+	for _, key := range s.byHash {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *fakeAPIKeyStore) Revoke(ctx context.Context, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, key := range s.byHash {
+		if key.ID == id {
+			now := time.Now()
+			key.RevokedAt = &now
+			return hash, nil
+		}
+	}
+	return "", ErrAPIKeyNotFound
+}
+
+func (s *fakeAPIKeyStore) TouchLastUsed(ctx context.Context, id string, interval time.Duration) error {
+	s.touched <- id
+	return nil
+}
+
+func (s *fakeAPIKeyStore) lookupCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookups
+}
+
+func newTestVerifier(t *testing.T) (*APIKeyVerifier, *fakeAPIKeyStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store := newFakeAPIKeyStore()
+	return NewAPIKeyVerifier(store, client, testLogger), store, mr
+}
+
+func createKey(t *testing.T, v *APIKeyVerifier, expiresAt *time.Time) (string, *APIKey) {
+	t.Helper()
+	plaintext, key, err := v.Create(context.Background(), APIKey{
+		UserID: "user-1", TenantID: "tenant-1", Name: "ci", Scopes: []string{"calls:read"}, ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return plaintext, key
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !apiKeyFormat.MatchString(key) || !strings.HasPrefix(key, prefix+"_") || len(prefix) != len(APIKeyPrefix)+apiKeyIDLength {
+		t.Errorf("key %q with prefix %q does not match the phk_ format", key, prefix)
+	}
+	if !IsAPIKey(key) || IsAPIKey("eyJhbGciOi") {
+		t.Error("IsAPIKey does not tell keys from JWTs")
+	}
+	if got := RedactAPIKey(key); got != prefix+"_…" || strings.Contains(got, key[len(prefix)+1:]) {
+		t.Errorf("RedactAPIKey = %q, want only the prefix", got)
+	}
+	// FIPS 180-2 test vector
+	if got := HashAPIKey("abc"); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("HashAPIKey = %q, want the hex SHA-256", got)
+	}
+}
+
+func TestAPIKeyVerify(t *testing.T) {
+	v, store, _ := newTestVerifier(t)
+	plaintext, created := createKey(t, v, nil)
+	unknown, _, _ := GenerateAPIKey()
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{name: "valid", key: plaintext},
+		{name: "empty", key: "", wantErr: ErrMissingToken},
+		{name: "wrong prefix", key: "pk_" + plaintext[len(APIKeyPrefix):], wantErr: ErrInvalidToken},
+		{name: "truncated", key: plaintext[:len(plaintext)-1], wantErr: ErrInvalidToken},
+		{name: "trailing data", key: plaintext + "x", wantErr: ErrInvalidToken},
+		{name: "unknown", key: unknown, wantErr: ErrAPIKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.key)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+				}
+				// Errors name the key by prefix only
+				if err != nil && len(tt.key) > 20 && strings.Contains(err.Error(), tt.key[20:]) {
+					t.Errorf("error %q leaks the key", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.TenantID != "tenant-1" || claims.UserID != "user-1" || claims.ID != created.ID || !claims.HasScope("calls:read") {
+				t.Errorf("claims %+v, want the key owner's", claims)
+			}
+		})
+	}
+
+	// Malformed keys never reach the store; the store is looked up by hash
+	if got := store.lookupCount(); got != 2 {
+		t.Errorf("%d store lookups, want 2", got)
+	}
+	select {
+	case id := <-store.touched:
+		if id != created.ID {
+			t.Errorf("touched %s, want %s", id, created.ID)
+		}
+	case <-time.After(time.Second):
+		t.Error("last_used_at not recorded")
+	}
+}
+
+func TestAPIKeyCache(t *testing.T) {
+	v, store, mr := newTestVerifier(t)
+	plaintext, _ := createKey(t, v, nil)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := v.Verify(ctx, plaintext); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+	if got := store.lookupCount(); got != 1 {
+		t.Errorf("%d store lookups for 3 verifies, want 1", got)
+	}
+	cacheKey := v.CachePrefix + HashAPIKey(plaintext)
+	if ttl := mr.TTL(cacheKey); ttl != v.CacheTTL {
+		t.Errorf("cache TTL %s, want %s", ttl, v.CacheTTL)
+	}
+
+	// A broken cache entry falls back to the store
+	mr.Set(cacheKey, "{")
+	if _, err := v.Verify(ctx, plaintext); err != nil {
+		t.Errorf("Verify with a corrupt cache entry: %v", err)
+	}
+	if got := store.lookupCount(); got != 2 {
+		t.Errorf("%d store lookups, want 2", got)
+	}
+
+	// So does an unavailable cache
+	mr.Close()
+	if _, err := v.Verify(ctx, plaintext); err != nil {
+		t.Errorf("Verify with Redis down: %v", err)
+	}
+}
+
+func TestAPIKeyNegativeCache(t *testing.T) {
+	v, store, mr := newTestVerifier(t)
+	unknown, _, _ := GenerateAPIKey()
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := v.Verify(ctx, unknown); !errors.Is(err, ErrAPIKeyNotFound) {
+			t.Fatalf("Verify = %v, want ErrAPIKeyNotFound", err)
+		}
+	}
+	if got := store.lookupCount(); got != 1 {
+		t.Errorf("%d store lookups for a repeated unknown key, want 1", got)
+	}
+	cacheKey := v.CachePrefix + HashAPIKey(unknown)
+	if got, _ := mr.Get(cacheKey); got != apiKeyNotFound {
+		t.Errorf("negative entry %q, want %q", got, apiKeyNotFound)
+	}
+	if ttl := mr.TTL(cacheKey); ttl != v.NegativeCacheTTL {
+		t.Errorf("negative cache TTL %s, want %s", ttl, v.NegativeCacheTTL)
+	}
+
+	// Once the entry expires the store is asked again
+	mr.FastForward(v.NegativeCacheTTL)
+	v.Verify(ctx, unknown)
+	if got := store.lookupCount(); got != 2 {
+		t.Errorf("%d store lookups after expiry, want 2", got)
+	}
+}
+
+func TestAPIKeyRevokeInvalidatesCache(t *testing.T) {
+	v, store, mr := newTestVerifier(t)
+	plaintext, created := createKey(t, v, nil)
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, plaintext); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !mr.Exists(v.CachePrefix + HashAPIKey(plaintext)) {
+		t.Fatal("key not cached")
+	}
+
+	if err := v.Revoke(ctx, created.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if mr.Exists(v.CachePrefix + HashAPIKey(plaintext)) {
+		t.Error("revoked key still cached")
+	}
+	if _, err := v.Verify(ctx, plaintext); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("Verify after revoke = %v, want ErrAPIKeyRevoked", err)
+	}
+	if got := store.lookupCount(); got != 2 {
+		t.Errorf("%d store lookups, want the revocation read from the store", got)
+	}
+
+	if err := v.Revoke(ctx, "key-404"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Revoke of an unknown key = %v", err)
+	}
+}
+
+func TestAPIKeyCacheTTLClampedToExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		wantTTL   time.Duration
+		wantErr   error
+	}{
+		{name: "expires after the cache TTL", expiresIn: time.Hour, wantTTL: 5 * time.Minute},
+		{name: "expires before the cache TTL", expiresIn: 90 * time.Second, wantTTL: 90 * time.Second},
+		{name: "already expired", expiresIn: -time.Second, wantErr: ErrAPIKeyExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _, mr := newTestVerifier(t)
+			v.now = func() time.Time { return now }
+			expiresAt := now.Add(tt.expiresIn)
+			plaintext, _ := createKey(t, v, &expiresAt)
+
+			_, err := v.Verify(context.Background(), plaintext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, want %v", err, tt.wantErr)
+			}
+			cacheKey := v.CachePrefix + HashAPIKey(plaintext)
+			if tt.wantTTL == 0 {
+				if mr.Exists(cacheKey) {
+					t.Error("expired key cached")
+				}
+				return
+			}
+			if ttl := mr.TTL(cacheKey); ttl != tt.wantTTL {
+				t.Errorf("cache TTL %s, want %s", ttl, tt.wantTTL)
+			}
+
+			// The cached record expires with the key, so it is not
+			// served past its expiry
+			mr.FastForward(tt.wantTTL)
+			v.now = func() time.Time { return expiresAt }
+			if _, err := v.Verify(context.Background(), plaintext); tt.expiresIn < v.CacheTTL && !errors.Is(err, ErrAPIKeyExpired) {
+				t.Errorf("Verify at expiry = %v, want ErrAPIKeyExpired", err)
+			}
+		})
+	}
+}
+
+func TestAPIKeyVerifierWithoutCache(t *testing.T) {
+	store := newFakeAPIKeyStore()
+	v := NewAPIKeyVerifier(store, nil, testLogger)
+	plaintext, created := createKey(t, v, nil)
+
+	for range 2 {
+		if _, err := v.Verify(context.Background(), plaintext); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+	if got := store.lookupCount(); got != 2 {
+		t.Errorf("%d store lookups, want one per verify", got)
+	}
+	if err := v.Revoke(context.Background(), created.ID); err != nil {
+		t.Errorf("Revoke: %v", err)
+	}
+}
+
+func TestCredentialsRouting(t *testing.T) {
+	v, _, _ := newTestVerifier(t)
+	plaintext, _ := createKey(t, v, nil)
+	tm := newTestManager(NewHMACKey("hs-1", []byte("secret")))
+	token, err := tm.Issue(Claims{TenantID: "tenant-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		creds      Credentials
+		credential string
+		wantTenant string
+		wantErr    error
+	}{
+		{name: "api key", creds: Credentials{Tokens: tm, APIKeys: v}, credential: plaintext, wantTenant: "tenant-1"},
+		{name: "jwt", creds: Credentials{Tokens: tm, APIKeys: v}, credential: token, wantTenant: "tenant-2"},
+		{name: "empty", creds: Credentials{Tokens: tm, APIKeys: v}, wantErr: ErrMissingToken},
+		{name: "api keys disabled", creds: Credentials{Tokens: tm}, credential: plaintext, wantErr: ErrInvalidToken},
+		{name: "tokens disabled", creds: Credentials{APIKeys: v}, credential: token, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.creds.Verify(context.Background(), tt.credential)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.TenantID != tt.wantTenant {
+				t.Errorf("tenant %s, want %s", claims.TenantID, tt.wantTenant)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// apiKeyFormat matches a whole API key
+var apiKeyFormat = regexp.MustCompile(`^` + APIKeyPattern.String() + `$`)
+
+// apiKeyNotFound marks a negative cache entry
+const apiKeyNotFound = "-"
+
+// APIKeyVerifier creates, verifies and revokes API keys. Lookups are cached
+// in Redis by key hash so most requests never reach Postgres.
+type APIKeyVerifier struct {
+	store  APIKeyStore
+	cache  redis.Cmdable
+	logger *logger.Logger
+
+	// CacheTTL bounds how long a key record is cached, and so how long a
+	// revocation made outside this verifier can take to apply
+	CacheTTL time.Duration
+	// NegativeCacheTTL caches unknown keys so guessing does not load Postgres
+	NegativeCacheTTL time.Duration
+	// LastUsedInterval throttles last_used_at updates per key
+	LastUsedInterval time.Duration
+	// CachePrefix namespaces cache keys
+	CachePrefix string
+
+	touched sync.Map
+	now     func() time.Time
+}
+
+// NewAPIKeyVerifier creates a verifier; cache may be nil to disable caching
+func NewAPIKeyVerifier(store APIKeyStore, cache redis.Cmdable, log *logger.Logger) *APIKeyVerifier {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	return &APIKeyVerifier{
+		store:            store,
+		cache:            cache,
+		logger:           log,
+		CacheTTL:         5 * time.Minute,
+		NegativeCacheTTL: 30 * time.Second,
+		LastUsedInterval: time.Minute,
+		CachePrefix:      "phonic:apikey:",
+		now:              time.Now,
+	}
+}
+
+// Create generates a key for template's user, tenant, name, scopes and
+// expiry. The plaintext key is returned once and cannot be recovered.
+func (v *APIKeyVerifier) Create(ctx context.Context, template APIKey) (string, *APIKey, error) {
+	if template.UserID == "" || template.TenantID == "" {
+		return "", nil, fmt.Errorf("api key requires a user and a tenant")
+	}
+
+	plaintext, prefix, err := GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	template.Prefix = prefix
+
+	created, err := v.store.Create(ctx, &template, HashAPIKey(plaintext))
+	if err != nil {
+		return "", nil, err
+	}
+
+	v.logger.WithContext(ctx).Info("API key created",
+		zap.String("api_key_id", created.ID),
+		zap.String("prefix", created.Prefix),
+		zap.String("user_id", created.UserID),
+		zap.Strings("scopes", created.Scopes),
+	)
+	return plaintext, created, nil
+}
+
+// List returns a user's keys
+func (v *APIKeyVerifier) List(ctx context.Context, userID string) ([]*APIKey, error) {
+	return v.store.ListByUser(ctx, userID)
+}
+
+// Revoke revokes a key and drops it from the cache
+func (v *APIKeyVerifier) Revoke(ctx context.Context, id string) error {
+	hash, err := v.store.Revoke(ctx, id)
+	if err != nil {
+		return err
+	}
+	if v.cache != nil {
+		if err := v.cache.Del(ctx, v.CachePrefix+hash).Err(); err != nil {
+			return fmt.Errorf("api key revoked but cache invalidation failed: %w", err)
+		}
+	}
+
+	v.logger.WithContext(ctx).Info("API key revoked", zap.String("api_key_id", id))
+	return nil
+}
+
+// Verify checks an API key and returns claims for its owner
+func (v *APIKeyVerifier) Verify(ctx context.Context, key string) (*Claims, error) {
+	if key == "" {
+		return nil, ErrMissingToken
+	}
+	if !apiKeyFormat.MatchString(key) {
+		return nil, fmt.Errorf("%w: malformed api key", ErrInvalidToken)
+	}
+
+	record, err := v.lookup(ctx, HashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, fmt.Errorf("%w: %w (%s)", ErrInvalidToken, err, RedactAPIKey(key))
+		}
+		return nil, err
+	}
+	if err := record.Valid(v.now()); err != nil {
+		return nil, fmt.Errorf("%w: %w (%s)", ErrInvalidToken, err, record.Prefix)
+	}
+
+	v.touch(ctx, record.ID)
+	return record.Claims(), nil
+}
+
+// lookup returns the key record for hash, from the cache when possible
+func (v *APIKeyVerifier) lookup(ctx context.Context, hash string) (*APIKey, error) {
+	cacheKey := v.CachePrefix + hash
+
+	if v.cache != nil {
+		cached, err := v.cache.Get(ctx, cacheKey).Result()
+		switch {
+		case err == nil && cached == apiKeyNotFound:
+			return nil, ErrAPIKeyNotFound
+		case err == nil:
+			var record APIKey
+			if err := json.Unmarshal([]byte(cached), &record); err == nil {
+				return &record, nil
+			}
+		case !errors.Is(err, redis.Nil):
+			v.logger.WithContext(ctx).Warn("API key cache unavailable", zap.Error(err))
+		}
+	}
+
+	record, err := v.store.FindByHash(ctx, hash)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		v.cacheSet(ctx, cacheKey, apiKeyNotFound, v.NegativeCacheTTL)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := v.CacheTTL
+	if record.ExpiresAt != nil {
+		if untilExpiry := record.ExpiresAt.Sub(v.now()); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+	if data, err := json.Marshal(record); err == nil && ttl > 0 {
+		v.cacheSet(ctx, cacheKey, string(data), ttl)
+	}
+	return record, nil
+}
+
+// cacheSet writes a cache entry, logging failures
+func (v *APIKeyVerifier) cacheSet(ctx context.Context, key, value string, ttl time.Duration) {
+	if v.cache == nil {
+		return
+	}
+	if err := v.cache.Set(ctx, key, value, ttl).Err(); err != nil {
+		v.logger.WithContext(ctx).Warn("Failed to cache API key", zap.Error(err))
+	}
+}
+
+// touch updates last_used_at in the background, at most once per
+// LastUsedInterval per key from this process
+func (v *APIKeyVerifier) touch(ctx context.Context, id string) {
+	now := v.now()
+	if last, ok := v.touched.Load(id); ok && now.Sub(last.(time.Time)) < v.LastUsedInterval {
+		return
+	}
+	v.touched.Store(id, now)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := v.store.TouchLastUsed(ctx, id, v.LastUsedInterval); err != nil {
+			v.logger.WithContext(ctx).Warn("Failed to record API key use",
+				zap.String("api_key_id", id),
+				zap.Error(err),
+			)
+		}
+	}()
+}
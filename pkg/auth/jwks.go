@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// JWK is a single JSON Web Key (RFC 7517). Only RSA keys are supported.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set document
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the RSA verification keys of the set as a JWKS document
+func (ks *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: []JWK{}}
+	for _, key := range ks.publicKeys() {
+		public := key.verifyKey.(*rsa.PublicKey)
+		doc.Keys = append(doc.Keys, JWK{
+			KeyType:   "RSA",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: AlgRS256,
+			N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+	return doc
+}
+
+// JWKSHandler serves the set's public keys, e.g. at /.well-known/jwks.json
+func (ks *KeySet) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(ks.JWKS())
+	})
+}
+
+// toKey converts an RSA JWK into a verification key
+func (j JWK) toKey() (Key, error) {
+	if j.KeyType != "RSA" {
+		return Key{}, fmt.Errorf("unsupported key type %q", j.KeyType)
+	}
+	if j.Algorithm != "" && j.Algorithm != AlgRS256 {
+		return Key{}, fmt.Errorf("unsupported algorithm %q", j.Algorithm)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(j.N)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to decode modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(j.E)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to decode exponent: %w", err)
+	}
+	public := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	return NewRSAPublicKey(j.KeyID, public), nil
+}
+
+// JWKSSource fetches RS256 verification keys from a remote JWKS endpoint.
+// Keys are refreshed periodically and on demand when a token names an
+// unknown kid, at most once per MinRefreshInterval.
+type JWKSSource struct {
+	url    string
+	client *http.Client
+	logger *logger.Logger
+
+	// MinRefreshInterval throttles on-demand refreshes
+	MinRefreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]Key
+	lastAttempt time.Time
+}
+
+// NewJWKSSource creates a JWKS source; call Refresh or Run to load keys
+func NewJWKSSource(url string, client *http.Client, log *logger.Logger) *JWKSSource {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKSSource{
+		url:                url,
+		client:             client,
+		logger:             log,
+		MinRefreshInterval: 30 * time.Second,
+		keys:               make(map[string]Key),
+	}
+}
+
+// Refresh fetches the JWKS document and replaces the cached keys
+func (s *JWKSSource) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var doc JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]Key, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.toKey()
+		if err != nil {
+			s.logger.WithContext(ctx).Warn("Skipping JWKS key",
+				zap.String("kid", jwk.KeyID),
+				zap.Error(err),
+			)
+			continue
+		}
+		keys[key.ID] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	s.logger.WithContext(ctx).Debug("JWKS refreshed",
+		zap.String("url", s.url),
+		zap.Int("keys", len(keys)),
+	)
+	return nil
+}
+
+// Run refreshes the keys every interval until ctx is cancelled
+func (s *JWKSSource) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.logger.WithContext(ctx).Error("Failed to refresh JWKS", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Lookup returns the key for kid, refreshing once if it is unknown
+func (s *JWKSSource) Lookup(ctx context.Context, kid string) (Key, bool) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	if ok || time.Since(s.lastAttempt) < s.MinRefreshInterval {
+		s.mu.Unlock()
+		return key, ok
+	}
+	// Claim the refresh so a flood of unknown kids cannot hammer the endpoint
+	s.lastAttempt = time.Now()
+	s.mu.Unlock()
+
+	if err := s.Refresh(ctx); err != nil {
+		s.logger.WithContext(ctx).Error("Failed to refresh JWKS", zap.Error(err))
+		return Key{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok = s.keys[kid]
+	return key, ok
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksServer serves the JWKS of whatever key set is current and counts
+// requests
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     *KeySet
+	requests atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys *KeySet) *jwksServer {
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		keys := s.keys
+		s.mu.Unlock()
+		keys.JWKSHandler().ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys *KeySet) {
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	ks := NewKeySet(NewRSAKey("rs-1", testRSAKey), NewHMACKey("hs-1", []byte("secret")))
+	rec := httptest.NewRecorder()
+	ks.JWKSHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	var doc JWKS
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	// HMAC secrets are never published
+	if len(doc.Keys) != 1 || doc.Keys[0].KeyID != "rs-1" || doc.Keys[0].Algorithm != AlgRS256 {
+		t.Fatalf("JWKS %+v, want only rs-1", doc)
+	}
+	key, err := doc.Keys[0].toKey()
+	if err != nil {
+		t.Fatalf("toKey: %v", err)
+	}
+	if !testRSAKey.PublicKey.Equal(key.verifyKey) {
+		t.Error("decoded public key does not match")
+	}
+
+	for _, jwk := range []JWK{
+		{KeyType: "EC", KeyID: "ec-1"},
+		{KeyType: "RSA", KeyID: "rs-2", Algorithm: "RS512"},
+		{KeyType: "RSA", KeyID: "rs-3", N: "not base64!", E: "AQAB"},
+	} {
+		if _, err := jwk.toKey(); err == nil {
+			t.Errorf("toKey(%+v) succeeded", jwk)
+		}
+	}
+}
+
+func TestJWKSSourceLookup(t *testing.T) {
+	server := newJWKSServer(t, NewKeySet(NewRSAKey("rs-1", testRSAKey)))
+	source := NewJWKSSource(server.URL, server.Client(), testLogger)
+	source.MinRefreshInterval = time.Hour
+	ctx := context.Background()
+
+	if err := source.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	server.requests.Store(0)
+
+	tests := []struct {
+		name         string
+		kid          string
+		wantOK       bool
+		wantRequests int32
+	}{
+		{"cached kid", "rs-1", true, 0},
+		{"unknown kid refreshes once", "rs-2", false, 1},
+		// Within MinRefreshInterval unknown kids are not fetched again
+		{"unknown kid is throttled", "rs-3", false, 1},
+		{"repeat is throttled", "rs-2", false, 1},
+		{"cached kid while throttled", "rs-1", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := source.Lookup(ctx, tt.kid); ok != tt.wantOK {
+				t.Errorf("Lookup(%s) ok = %v, want %v", tt.kid, ok, tt.wantOK)
+			}
+			if got := server.requests.Load(); got != tt.wantRequests {
+				t.Errorf("%d JWKS requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+
+	// Once the interval has passed a newly published key is picked up
+	rotated := NewRSAKey("rs-2", testRSAKey)
+	server.setKeys(NewKeySet(rotated))
+	source.mu.Lock()
+	source.lastAttempt = time.Now().Add(-2 * time.Hour)
+	source.mu.Unlock()
+	if key, ok := source.Lookup(ctx, "rs-2"); !ok || key.ID != "rs-2" {
+		t.Errorf("Lookup after interval = %v, %v, want rs-2", key.ID, ok)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Errorf("%d JWKS requests, want 2", got)
+	}
+	// The refresh replaced the set, so the retired key is gone
+	if _, ok := source.Lookup(ctx, "rs-1"); ok {
+		t.Error("retired key still cached")
+	}
+}
+
+func TestJWKSSourceRefreshErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"status", func(w http.ResponseWriter, r *http.Request) { http.Error(w, "down", http.StatusBadGateway) }},
+		{"malformed", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("{")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			source := NewJWKSSource(server.URL, server.Client(), testLogger)
+			if err := source.Refresh(context.Background()); err == nil {
+				t.Error("Refresh succeeded")
+			}
+		})
+	}
+}
+
+func TestVerifyWithJWKS(t *testing.T) {
+	server := newJWKSServer(t, NewKeySet(NewRSAKey("idp-1", testRSAKey)))
+	source := NewJWKSSource(server.URL, server.Client(), testLogger)
+	tm := newTestManager(NewHMACKey("hs-1", []byte("secret")))
+	tm.SetJWKS(source)
+
+	// The first unknown kid triggers the fetch
+	token := sign(t, jwt.SigningMethodRS256, testRSAKey, "idp-1", validClaims())
+	if _, err := tm.Verify(context.Background(), token); err != nil {
+		t.Errorf("Verify of a JWKS-signed token: %v", err)
+	}
+
+	// HS256 tokens never consult the JWKS, so they cannot trigger fetches
+	requests := server.requests.Load()
+	source.mu.Lock()
+	source.lastAttempt = time.Time{}
+	source.mu.Unlock()
+	token = sign(t, jwt.SigningMethodHS256, []byte("guessed"), "idp-2", validClaims())
+	if _, err := tm.Verify(context.Background(), token); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("Verify = %v, want an invalid signature", err)
+	}
+	if got := server.requests.Load(); got != requests {
+		t.Errorf("HS256 token caused %d JWKS requests", got-requests)
+	}
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// Key is a single signing or verification key
+type Key struct {
+	// ID is published in the token's kid header
+	ID string
+	// Algorithm is AlgHS256 or AlgRS256
+	Algorithm string
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey creates an HS256 key. An empty id is replaced by a random
+// one: the kid is published in every token, so it must say nothing about
+// the secret. Instances that share a secret need not share its kid, since
+// tokens with an unknown HS256 kid are checked against every secret.
+func NewHMACKey(id string, secret []byte) Key {
+	if id == "" {
+		id = "hs-" + tokenID()[:8]
+	}
+	return Key{ID: id, Algorithm: AlgHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewHMACVerifyKey creates an HS256 key that only verifies, for secrets
+// that are being rotated out
+func NewHMACVerifyKey(id string, secret []byte) Key {
+	key := NewHMACKey(id, secret)
+	key.signKey = nil
+	return key
+}
+
+// NewRSAKey creates an RS256 signing key
+func NewRSAKey(id string, private *rsa.PrivateKey) Key {
+	key := NewRSAPublicKey(id, &private.PublicKey)
+	key.signKey = private
+	return key
+}
+
+// NewRSAPublicKey creates an RS256 verification-only key
+func NewRSAPublicKey(id string, public *rsa.PublicKey) Key {
+	if id == "" {
+		sum := sha256.Sum256(public.N.Bytes())
+		id = "rs-" + hex.EncodeToString(sum[:4])
+	}
+	return Key{ID: id, Algorithm: AlgRS256, verifyKey: public}
+}
+
+// CanSign reports whether the key holds private material
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// method returns the jwt signing method for the key
+func (k Key) method() jwt.SigningMethod {
+	if k.Algorithm == AlgRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodHS256
+}
+
+// KeySet holds the active keys. Every key verifies tokens; one key signs.
+// Rotation adds a new signing key while older keys keep verifying tokens
+// issued before the rotation until they are removed.
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]Key
+	signing string
+}
+
+// NewKeySet creates a key set; the first key that can sign becomes the
+// signing key
+func NewKeySet(keys ...Key) *KeySet {
+	ks := &KeySet{keys: make(map[string]Key)}
+	for _, key := range keys {
+		ks.Add(key)
+	}
+	return ks
+}
+
+// Add makes key available for verification. It becomes the signing key only
+// if none is set yet.
+func (ks *KeySet) Add(key Key) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+	if ks.signing == "" && key.CanSign() {
+		ks.signing = key.ID
+	}
+}
+
+// Rotate adds key and makes it the signing key. Previous keys stay active
+// for verification.
+func (ks *KeySet) Rotate(key Key) error {
+	if !key.CanSign() {
+		return fmt.Errorf("key %s cannot sign", key.ID)
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+	ks.signing = key.ID
+	return nil
+}
+
+// SetSigning selects an existing key for signing
+func (ks *KeySet) SetSigning(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	key, ok := ks.keys[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("key %s cannot sign", id)
+	}
+	ks.signing = id
+	return nil
+}
+
+// Remove retires a key; tokens signed with it stop verifying
+func (ks *KeySet) Remove(id string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	delete(ks.keys, id)
+	if ks.signing == id {
+		ks.signing = ""
+	}
+}
+
+// Lookup returns the key with the given kid
+func (ks *KeySet) Lookup(id string) (Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[id]
+	return key, ok
+}
+
+// SigningKey returns the current signing key
+func (ks *KeySet) SigningKey() (Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.signing == "" {
+		return Key{}, ErrNoSigningKey
+	}
+	return ks.keys[ks.signing], nil
+}
+
+// IDs returns the active key IDs in sorted order
+func (ks *KeySet) IDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// byAlgorithm returns all verification keys for alg, used for tokens
+// without a kid header
+func (ks *KeySet) byAlgorithm(alg string) []jwt.VerificationKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var keys []jwt.VerificationKey
+	for _, key := range ks.keys {
+		if key.Algorithm == alg {
+			keys = append(keys, key.verifyKey)
+		}
+	}
+	return keys
+}
+
+// publicKeys returns the RSA verification keys for publishing as JWKS
+func (ks *KeySet) publicKeys() []Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	var keys []Key
+	for _, key := range ks.keys {
+		if key.Algorithm == AlgRS256 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
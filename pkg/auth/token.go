@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/httpclient"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// Options configures a TokenManager
+type Options struct {
+	// Issuer is set as iss and required when verifying
+	Issuer string
+	// Audience is set as aud and required when verifying
+	Audience string
+	// TTL is the lifetime of issued tokens
+	TTL time.Duration
+	// Leeway tolerates clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// TokenManager issues and verifies access tokens
+type TokenManager struct {
+	keys    *KeySet
+	jwks    *JWKSSource
+	options Options
+	logger  *logger.Logger
+}
+
+// NewTokenManager creates a token manager over keys
+func NewTokenManager(keys *KeySet, opts Options, log *logger.Logger) *TokenManager {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Hour
+	}
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	return &TokenManager{keys: keys, options: opts, logger: log}
+}
+
+// FromConfig builds a token manager from the security settings. The current
+// secret signs; previous secrets still verify so they can be rotated out.
+// When a JWKS URL is configured its keys are fetched and accepted too.
+func FromConfig(ctx context.Context, cfg config.SecurityConfig, log *logger.Logger) (*TokenManager, error) {
+	if cfg.JWTSecret == "" {
+		return nil, fmt.Errorf("security.jwt_secret is required")
+	}
+
+	keys := NewKeySet(NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret)))
+	for _, secret := range cfg.JWTPreviousSecrets {
+		if secret != "" {
+			keys.Add(NewHMACVerifyKey("", []byte(secret)))
+		}
+	}
+
+	tm := NewTokenManager(keys, Options{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		TTL:      time.Duration(cfg.JWTExpiryHours) * time.Hour,
+		Leeway:   30 * time.Second,
+	}, log)
+
+	if cfg.JWKSURL != "" {
+		jwks := NewJWKSSource(cfg.JWKSURL, httpclient.New(httpclient.DefaultConfig(), log), log)
+		if err := jwks.Refresh(ctx); err != nil {
+			return nil, fmt.Errorf("failed to load JWKS: %w", err)
+		}
+		tm.SetJWKS(jwks)
+	}
+
+	return tm, nil
+}
+
+// SetJWKS adds a remote key source consulted for kids not in the key set
+func (tm *TokenManager) SetJWKS(source *JWKSSource) {
+	tm.jwks = source
+}
+
+// Keys returns the key set, e.g. to rotate keys at runtime
+func (tm *TokenManager) Keys() *KeySet {
+	return tm.keys
+}
+
+// Issue signs claims with the current signing key. Registered claims that
+// are left empty are filled in: iss, aud, sub (from UserID), iat, nbf, exp
+// and a random jti.
+func (tm *TokenManager) Issue(claims Claims) (string, error) {
+	key, err := tm.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if claims.Issuer == "" {
+		claims.Issuer = tm.options.Issuer
+	}
+	if len(claims.Audience) == 0 && tm.options.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{tm.options.Audience}
+	}
+	if claims.Subject == "" {
+		claims.Subject = claims.UserID
+	}
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(now)
+	}
+	if claims.NotBefore == nil {
+		claims.NotBefore = jwt.NewNumericDate(now)
+	}
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(tm.options.TTL))
+	}
+	if claims.ID == "" {
+		claims.ID = tokenID()
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Verify parses and validates a token. It checks the signature against
+// the key named by kid (or every key of the algorithm when kid is absent
+// or names an HS256 key this instance does not know), expiry, issuer,
+// audience and that a tenant ID is present.
+func (tm *TokenManager) Verify(ctx context.Context, tokenString string) (*Claims, error) {
+	if tokenString == "" {
+		return nil, ErrMissingToken
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(tm.options.Leeway),
+	}
+	if tm.options.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(tm.options.Issuer))
+	}
+	if tm.options.Audience != "" {
+		opts = append(opts, jwt.WithAudience(tm.options.Audience))
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return tm.verificationKey(ctx, token)
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if claims.TenantID == "" {
+		return nil, fmt.Errorf("%w: missing tenant_id", ErrInvalidToken)
+	}
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+	return claims, nil
+}
+
+// verificationKey resolves the key for a parsed token header
+func (tm *TokenManager) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	alg := token.Method.Alg()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid != "" {
+		key, ok := tm.keys.Lookup(kid)
+		if !ok && alg == AlgRS256 && tm.jwks != nil {
+			key, ok = tm.jwks.Lookup(ctx, kid)
+		}
+		if ok {
+			// Never let a token pick the algorithm for a key, e.g. HS256
+			// with an RSA public key as the secret
+			if key.Algorithm != alg {
+				return nil, fmt.Errorf("key %s does not accept %s", kid, alg)
+			}
+			return key.verifyKey, nil
+		}
+		// HMAC kids are random unless configured, so another instance's
+		// kid is unknown here; its token is checked against every secret
+		if alg != AlgHS256 {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	keys := tm.keys.byAlgorithm(alg)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
+
+// tokenID generates a random jti
+func tokenID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("20060102150405.000000000")))
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+var testLogger = &logger.Logger{Logger: zap.NewNop()}
+
+var testOptions = Options{Issuer: "phonic", Audience: "phonic-api", TTL: time.Hour}
+
+func newTestManager(keys ...Key) *TokenManager {
+	return NewTokenManager(NewKeySet(keys...), testOptions, testLogger)
+}
+
+// testRSAKey is generated once; RSA key generation is slow
+var testRSAKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// sign signs claims with the given method, key and kid, bypassing Issue
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// validClaims returns claims that pass verification with testOptions
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		TenantID: "tenant-1",
+		UserID:   "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "phonic",
+			Audience:  jwt.ClaimStrings{"phonic-api"},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func TestIssueVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		key  Key
+	}{
+		{"hmac", NewHMACKey("hs-1", []byte("secret"))},
+		{"rsa", NewRSAKey("rs-1", testRSAKey)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := newTestManager(tt.key)
+			token, err := tm.Issue(Claims{TenantID: "tenant-1", UserID: "user-1", Scopes: []string{"calls:write"}})
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+
+			claims, err := tm.Verify(context.Background(), token)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if claims.TenantID != "tenant-1" || claims.UserID != "user-1" || !claims.HasScope("calls:write") {
+				t.Errorf("claims %+v, want the issued ones", claims)
+			}
+			// Issue fills in the registered claims
+			if claims.Issuer != "phonic" || claims.Subject != "user-1" || claims.ID == "" || claims.ExpiresAt == nil {
+				t.Errorf("registered claims %+v not filled in", claims.RegisteredClaims)
+			}
+			if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != time.Hour {
+				t.Errorf("lifetime %s, want the 1h TTL", got)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	secret := []byte("secret")
+	tm := newTestManager(NewHMACKey("hs-1", secret))
+
+	tests := []struct {
+		name    string
+		modify  func(*Claims)
+		wantErr string
+	}{
+		{"expired", func(c *Claims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		}, "expired"},
+		{"no expiry", func(c *Claims) { c.ExpiresAt = nil }, "exp claim is required"},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }, "issuer"},
+		{"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"billing"} }, "audience"},
+		{"missing tenant", func(c *Claims) { c.TenantID = "" }, "missing tenant_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(&claims)
+			token := sign(t, jwt.SigningMethodHS256, secret, "hs-1", claims)
+
+			_, err := tm.Verify(context.Background(), token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify = %v, want ErrInvalidToken", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := tm.Verify(context.Background(), ""); !errors.Is(err, ErrMissingToken) {
+		t.Errorf("Verify(\"\") = %v, want ErrMissingToken", err)
+	}
+}
+
+func TestVerificationKey(t *testing.T) {
+	hmacSecret := []byte("secret")
+	// The PEM a client would fetch from JWKS, misused as an HMAC secret
+	der, err := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	tm := newTestManager(NewHMACKey("hs-1", hmacSecret), NewRSAPublicKey("rs-1", &testRSAKey.PublicKey))
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		key     interface{}
+		kid     string
+		wantErr error
+	}{
+		{name: "hmac by kid", method: jwt.SigningMethodHS256, key: hmacSecret, kid: "hs-1"},
+		{name: "rsa by kid", method: jwt.SigningMethodRS256, key: testRSAKey, kid: "rs-1"},
+		{name: "hmac without kid", method: jwt.SigningMethodHS256, key: hmacSecret},
+		{name: "rsa without kid", method: jwt.SigningMethodRS256, key: testRSAKey},
+		// Another instance's random kid for the same secret
+		{name: "unknown hmac kid", method: jwt.SigningMethodHS256, key: hmacSecret, kid: "hs-other"},
+		{name: "unknown rsa kid", method: jwt.SigningMethodRS256, key: testRSAKey, kid: "rs-other", wantErr: ErrUnknownKey},
+		{name: "hs256 with the rsa public key", method: jwt.SigningMethodHS256, key: publicPEM, kid: "rs-1", wantErr: ErrInvalidToken},
+		{name: "hs256 with the rsa public key and no kid", method: jwt.SigningMethodHS256, key: publicPEM, wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "hs256 with the rsa public key and unknown kid", method: jwt.SigningMethodHS256, key: publicPEM, kid: "rs-other", wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "wrong secret", method: jwt.SigningMethodHS256, key: []byte("guessed"), kid: "hs-1", wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "none", method: jwt.SigningMethodNone, key: jwt.UnsafeAllowNoneSignatureType, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, tt.method, tt.key, tt.kid, validClaims())
+			_, err := tm.Verify(context.Background(), token)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Verify: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := NewHMACKey("hs-old", []byte("old secret"))
+	tm := newTestManager(old)
+	oldToken, err := tm.Issue(Claims{TenantID: "tenant-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tm.Keys().Rotate(NewHMACKey("hs-new", []byte("new secret"))); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	newToken, err := tm.Issue(Claims{TenantID: "tenant-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := headerKid(t, newToken); kid != "hs-new" {
+		t.Errorf("new token signed with %s, want hs-new", kid)
+	}
+
+	// Both verify until the old key is removed
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := tm.Verify(context.Background(), token); err != nil {
+			t.Errorf("%s token after rotation: %v", name, err)
+		}
+	}
+	tm.Keys().Remove("hs-old")
+	if _, err := tm.Verify(context.Background(), oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("old token after removal = %v, want ErrInvalidToken", err)
+	}
+
+	if err := tm.Keys().Rotate(NewHMACVerifyKey("hs-verify", []byte("x"))); err == nil {
+		t.Error("Rotate accepted a verification-only key")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := config.SecurityConfig{
+		JWTSecret:          "current",
+		JWTPreviousSecrets: []string{"previous", ""},
+		JWTIssuer:          "phonic",
+		JWTAudience:        "phonic-api",
+		JWTExpiryHours:     2,
+	}
+	tm, err := FromConfig(context.Background(), cfg, testLogger)
+	if err != nil {
+		t.Fatalf("FromConfig: %v", err)
+	}
+
+	// A token signed with a previous secret by an instance that has not
+	// rotated yet still verifies
+	previous := sign(t, jwt.SigningMethodHS256, []byte("previous"), "hs-elsewhere", validClaims())
+	if _, err := tm.Verify(context.Background(), previous); err != nil {
+		t.Errorf("token signed with the previous secret: %v", err)
+	}
+
+	token, err := tm.Issue(Claims{TenantID: "tenant-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := tm.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got := claims.ExpiresAt.Sub(claims.IssuedAt.Time); got != 2*time.Hour {
+		t.Errorf("lifetime %s, want jwt_expiry_hours", got)
+	}
+
+	// The kid is random unless configured and never derived from the secret
+	kid := headerKid(t, token)
+	if !strings.HasPrefix(kid, "hs-") || kid == NewHMACKey("", []byte("current")).ID {
+		t.Errorf("kid %q, want a random hs- ID", kid)
+	}
+	cfg.JWTKeyID = "primary"
+	tm, err = FromConfig(context.Background(), cfg, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := tm.Issue(Claims{TenantID: "tenant-1"}); headerKid(t, token) != "primary" {
+		t.Errorf("kid %q, want the configured jwt_key_id", headerKid(t, token))
+	}
+
+	if _, err := FromConfig(context.Background(), config.SecurityConfig{}, testLogger); err == nil {
+		t.Error("FromConfig accepted an empty secret")
+	}
+}
+
+func headerKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
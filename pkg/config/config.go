@@ -20,6 +20,7 @@ type Config struct {
 	Logging  LoggingConfig  `mapstructure:"logging" yaml:"logging"`
 	Security SecurityConfig `mapstructure:"security" yaml:"security"`
 	Storage  StorageConfig  `mapstructure:"storage" yaml:"storage"`
+	Tracing  TracingConfig  `mapstructure:"tracing" yaml:"tracing"`
 }
 
 // AppConfig contains general application settings
@@ -47,11 +48,35 @@ type DatabaseConfig struct {
 
 // RedisConfig contains Redis connection settings
 type RedisConfig struct {
-	Host     string `mapstructure:"host" yaml:"host"`
-	Port     int    `mapstructure:"port" yaml:"port"`
-	Password string `mapstructure:"password" yaml:"password"`
-	Database int    `mapstructure:"database" yaml:"database"`
-	PoolSize int    `mapstructure:"pool_size" yaml:"pool_size"`
+	Host        string            `mapstructure:"host" yaml:"host"`
+	Port        int               `mapstructure:"port" yaml:"port"`
+	Password    string            `mapstructure:"password" yaml:"password"`
+	Database    int               `mapstructure:"database" yaml:"database"`
+	PoolSize    int               `mapstructure:"pool_size" yaml:"pool_size"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency" yaml:"idempotency"`
+	Session     SessionConfig     `mapstructure:"session" yaml:"session"`
+}
+
+// IdempotencyConfig contains settings for Idempotency-Key handling
+type IdempotencyConfig struct {
+	// Window is how long a stored response is replayed for its key
+	Window time.Duration `mapstructure:"window" yaml:"window"`
+	// LockTimeout bounds how long a request holds its key, and how long
+	// duplicates wait for it to finish
+	LockTimeout time.Duration `mapstructure:"lock_timeout" yaml:"lock_timeout"`
+	// MaxResponseBytes is the largest response body that is stored
+	MaxResponseBytes int `mapstructure:"max_response_bytes" yaml:"max_response_bytes"`
+}
+
+// SessionConfig contains settings for call sessions stored in Redis
+type SessionConfig struct {
+	// TTL is how long a live session is kept without being written
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl"`
+	// HeartbeatTimeout is how long a live session may go without a
+	// heartbeat before it is failed as abandoned
+	HeartbeatTimeout time.Duration `mapstructure:"heartbeat_timeout" yaml:"heartbeat_timeout"`
+	// EndedTTL is how long an ended or failed session is kept
+	EndedTTL time.Duration `mapstructure:"ended_ttl" yaml:"ended_ttl"`
 }
 
 // MoshiConfig contains Kyutai Moshi server settings
@@ -111,6 +136,15 @@ type LoggingConfig struct {
 type SecurityConfig struct {
 	JWTSecret    string        `mapstructure:"jwt_secret" yaml:"jwt_secret"`
 	JWTExpiryHours int         `mapstructure:"jwt_expiry_hours" yaml:"jwt_expiry_hours"`
+	// JWTKeyID is the kid published for jwt_secret; a random one is used
+	// when empty
+	JWTKeyID     string        `mapstructure:"jwt_key_id" yaml:"jwt_key_id"`
+	// JWTPreviousSecrets are still accepted for verification during key rotation
+	JWTPreviousSecrets []string `mapstructure:"jwt_previous_secrets" yaml:"jwt_previous_secrets"`
+	JWTIssuer    string        `mapstructure:"jwt_issuer" yaml:"jwt_issuer"`
+	JWTAudience  string        `mapstructure:"jwt_audience" yaml:"jwt_audience"`
+	// JWKSURL optionally points at a JWKS document with RS256 verification keys
+	JWKSURL      string        `mapstructure:"jwks_url" yaml:"jwks_url"`
 	RateLimit    RateLimitConfig `mapstructure:"rate_limit" yaml:"rate_limit"`
 	CORS         CORSConfig    `mapstructure:"cors" yaml:"cors"`
 }
@@ -122,11 +156,20 @@ type RateLimitConfig struct {
 	WindowSize        time.Duration `mapstructure:"window_size" yaml:"window_size"`
 }
 
+// Enabled reports whether rate limiting is on; a requests_per_minute of
+// 0 turns it off
+func (c RateLimitConfig) Enabled() bool {
+	return c.RequestsPerMinute > 0
+}
+
 // CORSConfig contains CORS settings
 type CORSConfig struct {
 	AllowedOrigins []string `mapstructure:"allowed_origins" yaml:"allowed_origins"`
 	AllowedMethods []string `mapstructure:"allowed_methods" yaml:"allowed_methods"`
 	AllowedHeaders []string `mapstructure:"allowed_headers" yaml:"allowed_headers"`
+	ExposedHeaders []string `mapstructure:"exposed_headers" yaml:"exposed_headers"`
+	AllowCredentials bool   `mapstructure:"allow_credentials" yaml:"allow_credentials"`
+	MaxAge         time.Duration `mapstructure:"max_age" yaml:"max_age"`
 }
 
 // StorageConfig contains MinIO/S3 settings
@@ -140,6 +183,16 @@ type StorageConfig struct {
 	AudioRetentionDays int `mapstructure:"audio_retention_days" yaml:"audio_retention_days"`
 }
 
+// TracingConfig contains OpenTelemetry tracing settings
+type TracingConfig struct {
+	Enabled     bool          `mapstructure:"enabled" yaml:"enabled"`
+	Exporter    string        `mapstructure:"exporter" yaml:"exporter"`
+	Endpoint    string        `mapstructure:"endpoint" yaml:"endpoint"`
+	Insecure    bool          `mapstructure:"insecure" yaml:"insecure"`
+	SampleRatio float64       `mapstructure:"sample_ratio" yaml:"sample_ratio"`
+	Timeout     time.Duration `mapstructure:"timeout" yaml:"timeout"`
+}
+
 // Load loads configuration from files and environment variables
 func Load(configPath string) (*Config, error) {
 	// Set default configuration file name and paths
@@ -226,6 +279,12 @@ func setDefaults() {
 	viper.SetDefault("redis.port", 6379)
 	viper.SetDefault("redis.database", 0)
 	viper.SetDefault("redis.pool_size", 10)
+	viper.SetDefault("redis.idempotency.window", "24h")
+	viper.SetDefault("redis.idempotency.lock_timeout", "30s")
+	viper.SetDefault("redis.idempotency.max_response_bytes", 1<<20)
+	viper.SetDefault("redis.session.ttl", "4h")
+	viper.SetDefault("redis.session.heartbeat_timeout", "30s")
+	viper.SetDefault("redis.session.ended_ttl", "10m")
 	
 	// Moshi defaults
 	viper.SetDefault("moshi.stt.host", "localhost")
@@ -266,9 +325,14 @@ func setDefaults() {
 	
 	// Security defaults
 	viper.SetDefault("security.jwt_expiry_hours", 24)
+	viper.SetDefault("security.jwt_issuer", "phonic")
+	viper.SetDefault("security.jwt_audience", "phonic-api")
 	viper.SetDefault("security.rate_limit.requests_per_minute", 100)
 	viper.SetDefault("security.rate_limit.burst_size", 50)
 	viper.SetDefault("security.rate_limit.window_size", "1m")
+	viper.SetDefault("security.cors.exposed_headers", []string{"X-Trace-ID", "X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"})
+	viper.SetDefault("security.cors.allow_credentials", true)
+	viper.SetDefault("security.cors.max_age", "10m")
 	
 	// Storage defaults
 	viper.SetDefault("storage.endpoint", "localhost:9000")
@@ -278,6 +342,14 @@ func setDefaults() {
 	viper.SetDefault("storage.region", "us-east-1")
 	viper.SetDefault("storage.use_ssl", false)
 	viper.SetDefault("storage.audio_retention_days", 30)
+	
+	// Tracing defaults
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.exporter", "otlp-grpc")
+	viper.SetDefault("tracing.endpoint", "localhost:4317")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+	viper.SetDefault("tracing.timeout", "10s")
 }
 
 // validateConfig validates the loaded configuration
@@ -329,6 +401,41 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("logging.level must be one of: %v", validLevels)
 	}
 	
+	// Validate CORS; browsers reject credentials with a wildcard origin
+	if config.Security.CORS.AllowCredentials {
+		for _, origin := range config.Security.CORS.AllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("security.cors.allow_credentials cannot be combined with allowed_origins \"*\"")
+			}
+		}
+	}
+	
+	// Validate rate limiting; 0 requests per minute disables it
+	if config.Security.RateLimit.RequestsPerMinute < 0 {
+		return fmt.Errorf("security.rate_limit.requests_per_minute must not be negative")
+	}
+	if config.Security.RateLimit.BurstSize < 0 {
+		return fmt.Errorf("security.rate_limit.burst_size must not be negative")
+	}
+	
+	// Validate tracing
+	if config.Tracing.Enabled {
+		validExporters := []string{"otlp-grpc", "otlp-http", "none"}
+		exporterValid := false
+		for _, exporter := range validExporters {
+			if config.Tracing.Exporter == exporter {
+				exporterValid = true
+				break
+			}
+		}
+		if !exporterValid {
+			return fmt.Errorf("tracing.exporter must be one of: %v", validExporters)
+		}
+		if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+			return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+		}
+	}
+	
 	return nil
 }
 
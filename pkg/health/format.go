@@ -0,0 +1,273 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format identifies how a health response is encoded on the wire
+type Format string
+
+const (
+	// FormatJSON is the native Phonic JSON shape (application/json)
+	FormatJSON Format = "json"
+	// FormatHealthJSON is the IETF health check draft format (application/health+json)
+	FormatHealthJSON Format = "health+json"
+	// FormatText is a kube-apiserver style verbose plaintext listing
+	FormatText Format = "text"
+	// FormatPlain is the terse "ready"/"not ready" body used by probes
+	FormatPlain Format = "plain"
+)
+
+const (
+	contentTypeJSON       = "application/json"
+	contentTypeHealthJSON = "application/health+json"
+	contentTypeText       = "text/plain; charset=utf-8"
+)
+
+// MarshalJSON encodes the check duration as a human-readable string
+func (c CheckResult) MarshalJSON() ([]byte, error) {
+	type alias CheckResult
+	return json.Marshal(struct {
+		alias
+		Duration string `json:"duration"`
+	}{
+		alias:    alias(c),
+		Duration: c.Duration.String(),
+	})
+}
+
+// UnmarshalJSON decodes a check result produced by MarshalJSON
+func (c *CheckResult) UnmarshalJSON(data []byte) error {
+	type alias CheckResult
+	aux := struct {
+		*alias
+		Duration string `json:"duration"`
+	}{alias: (*alias)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	d, err := parseDuration(aux.Duration)
+	if err != nil {
+		return fmt.Errorf("invalid check duration: %w", err)
+	}
+	c.Duration = d
+	return nil
+}
+
+// MarshalJSON encodes the uptime as a human-readable string
+func (h HealthResponse) MarshalJSON() ([]byte, error) {
+	type alias HealthResponse
+	return json.Marshal(struct {
+		alias
+		Uptime string `json:"uptime"`
+	}{
+		alias:  alias(h),
+		Uptime: h.Uptime.String(),
+	})
+}
+
+// UnmarshalJSON decodes a health response produced by MarshalJSON
+func (h *HealthResponse) UnmarshalJSON(data []byte) error {
+	type alias HealthResponse
+	aux := struct {
+		*alias
+		Uptime string `json:"uptime"`
+	}{alias: (*alias)(h)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	d, err := parseDuration(aux.Uptime)
+	if err != nil {
+		return fmt.Errorf("invalid uptime: %w", err)
+	}
+	h.Uptime = d
+	return nil
+}
+
+// parseDuration parses a duration string, treating an empty string as zero
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// negotiateFormat picks a response format from the query string or Accept header.
+// The "format" query parameter wins, then "verbose", then the Accept header.
+func negotiateFormat(r *http.Request, fallback Format) Format {
+	query := r.URL.Query()
+	switch strings.ToLower(query.Get("format")) {
+	case "json":
+		return FormatJSON
+	case "health+json", "health", "ietf":
+		return FormatHealthJSON
+	case "text", "verbose":
+		return FormatText
+	}
+	if _, ok := query["verbose"]; ok {
+		return FormatText
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, contentTypeHealthJSON):
+		return FormatHealthJSON
+	case strings.Contains(accept, contentTypeJSON):
+		return FormatJSON
+	case strings.Contains(accept, "text/plain"):
+		return FormatText
+	}
+	return fallback
+}
+
+// statusCode maps an overall status onto an HTTP status code
+func statusCode(status Status) int {
+	if status == StatusHealthy {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+// writeHealth encodes a health response in the requested format
+func writeHealth(w http.ResponseWriter, format Format, health HealthResponse) error {
+	code := statusCode(health.Status)
+
+	switch format {
+	case FormatHealthJSON:
+		w.Header().Set("Content-Type", contentTypeHealthJSON)
+		w.WriteHeader(code)
+		return json.NewEncoder(w).Encode(toIETF(health))
+	case FormatText:
+		w.Header().Set("Content-Type", contentTypeText)
+		w.WriteHeader(code)
+		_, err := w.Write([]byte(verboseText(health)))
+		return err
+	case FormatPlain:
+		w.Header().Set("Content-Type", contentTypeText)
+		w.WriteHeader(code)
+		body := "ready"
+		if health.Status != StatusHealthy {
+			body = "not ready"
+		}
+		_, err := w.Write([]byte(body))
+		return err
+	default:
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.WriteHeader(code)
+		return json.NewEncoder(w).Encode(health)
+	}
+}
+
+// verboseText renders checks the way kube-apiserver's /readyz?verbose does
+func verboseText(health HealthResponse) string {
+	checks := sortedChecks(health.Checks)
+
+	var b strings.Builder
+	for _, check := range checks {
+		if check.Status == StatusHealthy {
+			fmt.Fprintf(&b, "[+]%s ok (%s)\n", check.Name, check.Duration)
+			continue
+		}
+		fmt.Fprintf(&b, "[-]%s %s (%s)", check.Name, check.Status, check.Duration)
+		if check.Message != "" {
+			fmt.Fprintf(&b, ": %s", check.Message)
+		}
+		b.WriteString("\n")
+	}
+
+	if health.Status == StatusHealthy {
+		b.WriteString("health check passed\n")
+	} else {
+		b.WriteString("health check failed\n")
+	}
+	return b.String()
+}
+
+// sortedChecks returns the checks ordered by name for stable output
+func sortedChecks(checks []CheckResult) []CheckResult {
+	sorted := make([]CheckResult, len(checks))
+	copy(sorted, checks)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+// ietfResponse is the application/health+json document (draft-inadarei-api-health-check)
+type ietfResponse struct {
+	Status      string                 `json:"status"`
+	Version     string                 `json:"version,omitempty"`
+	ReleaseID   string                 `json:"releaseId,omitempty"`
+	ServiceID   string                 `json:"serviceId,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Output      string                 `json:"output,omitempty"`
+	Checks      map[string][]ietfCheck `json:"checks,omitempty"`
+}
+
+// ietfCheck is a single component entry in an IETF health document
+type ietfCheck struct {
+	ComponentID   string            `json:"componentId"`
+	Status        string            `json:"status"`
+	ObservedValue float64           `json:"observedValue"`
+	ObservedUnit  string            `json:"observedUnit"`
+	Time          string            `json:"time"`
+	Output        string            `json:"output,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// ietfStatus maps our status values onto pass/fail/warn
+func ietfStatus(status Status) string {
+	switch status {
+	case StatusHealthy:
+		return "pass"
+	case StatusUnhealthy:
+		return "fail"
+	default:
+		return "warn"
+	}
+}
+
+// toIETF converts a health response to the IETF draft representation
+func toIETF(health HealthResponse) ietfResponse {
+	resp := ietfResponse{
+		Status:      ietfStatus(health.Status),
+		Version:     majorVersion(health.Version),
+		ReleaseID:   health.Version,
+		ServiceID:   health.Service,
+		Description: fmt.Sprintf("%s health (uptime %s)", health.Service, health.Uptime.Round(time.Second)),
+		Checks:      make(map[string][]ietfCheck, len(health.Checks)),
+	}
+
+	var failed []string
+	for _, check := range sortedChecks(health.Checks) {
+		key := check.Name + ":responseTime"
+		resp.Checks[key] = append(resp.Checks[key], ietfCheck{
+			ComponentID:   check.Name,
+			Status:        ietfStatus(check.Status),
+			ObservedValue: float64(check.Duration) / float64(time.Millisecond),
+			ObservedUnit:  "ms",
+			Time:          check.Timestamp.Format(time.RFC3339),
+			Output:        check.Message,
+			Metadata:      check.Metadata,
+		})
+		if check.Status == StatusUnhealthy {
+			failed = append(failed, check.Name)
+		}
+	}
+	if len(failed) > 0 {
+		resp.Output = "failing checks: " + strings.Join(failed, ", ")
+	}
+	return resp
+}
+
+// majorVersion extracts the public API version ("1" from "1.4.2-dev")
+func majorVersion(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, ".-"); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
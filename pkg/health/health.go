@@ -4,15 +4,16 @@ package health
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
 
+	"github.com/ArbajAnsari19/phonic/pkg/httpclient"
 	"github.com/ArbajAnsari19/phonic/pkg/logger"
 )
 
@@ -58,6 +59,10 @@ type Manager struct {
 	checkers    map[string]Checker
 	mu          sync.RWMutex
 	logger      *logger.Logger
+
+	// notReadyReason is non-empty while readiness is forced off,
+	// e.g. while draining calls during shutdown
+	notReadyReason string
 }
 
 // NewManager creates a new health check manager
@@ -85,6 +90,55 @@ func (m *Manager) RemoveChecker(name string) {
 	delete(m.checkers, name)
 }
 
+// SetNotReady forces readiness checks to fail with the given reason
+// regardless of dependency health. Liveness is unaffected.
+func (m *Manager) SetNotReady(reason string) {
+	if reason == "" {
+		reason = "not ready"
+	}
+	m.mu.Lock()
+	m.notReadyReason = reason
+	m.mu.Unlock()
+
+	m.logger.Info("Readiness disabled", zap.String("reason", reason))
+}
+
+// SetReady clears a previous SetNotReady
+func (m *Manager) SetReady() {
+	m.mu.Lock()
+	m.notReadyReason = ""
+	m.mu.Unlock()
+
+	m.logger.Info("Readiness enabled")
+}
+
+// IsReady reports whether readiness has not been forced off
+func (m *Manager) IsReady() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.notReadyReason == ""
+}
+
+// applyReadiness marks a response unhealthy while readiness is forced off
+func (m *Manager) applyReadiness(health HealthResponse) HealthResponse {
+	m.mu.RLock()
+	reason := m.notReadyReason
+	m.mu.RUnlock()
+
+	if reason == "" {
+		return health
+	}
+
+	health.Status = StatusUnhealthy
+	health.Checks = append(health.Checks, CheckResult{
+		Name:      "readiness",
+		Status:    StatusUnhealthy,
+		Message:   reason,
+		Timestamp: time.Now(),
+	})
+	return health
+}
+
 // CheckHealth performs all health checks
 func (m *Manager) CheckHealth(ctx context.Context) HealthResponse {
 	start := time.Now()
@@ -138,6 +192,32 @@ func (m *Manager) CheckHealth(ctx context.Context) HealthResponse {
 		zap.Int("checks_count", len(checks)),
 	)
 
+	return m.response(overallStatus, checks)
+}
+
+// CheckOne runs a single named check. It reports false if no checker is
+// registered under that name.
+func (m *Manager) CheckOne(ctx context.Context, name string) (HealthResponse, bool) {
+	m.mu.RLock()
+	checker, ok := m.checkers[name]
+	m.mu.RUnlock()
+	if !ok {
+		return HealthResponse{}, false
+	}
+
+	result := checker.Check(ctx)
+	result.Name = name
+
+	overallStatus := result.Status
+	if overallStatus == "" {
+		overallStatus = StatusUnknown
+	}
+
+	return m.response(overallStatus, []CheckResult{result}), true
+}
+
+// response builds a HealthResponse with the manager's service details
+func (m *Manager) response(overallStatus Status, checks []CheckResult) HealthResponse {
 	return HealthResponse{
 		Status:    overallStatus,
 		Timestamp: time.Now(),
@@ -148,7 +228,9 @@ func (m *Manager) CheckHealth(ctx context.Context) HealthResponse {
 	}
 }
 
-// HTTPHandler returns an HTTP handler for health checks
+// HTTPHandler returns an HTTP handler for health checks.
+// The response format is negotiated from the Accept header or the
+// "format" query parameter and defaults to JSON.
 func (m *Manager) HTTPHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
@@ -156,40 +238,65 @@ func (m *Manager) HTTPHandler() http.HandlerFunc {
 
 		health := m.CheckHealth(ctx)
 
-		w.Header().Set("Content-Type", "application/json")
-		
-		// Set HTTP status code based on health
-		if health.Status == StatusHealthy {
-			w.WriteHeader(http.StatusOK)
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
-		}
-
-		if err := json.NewEncoder(w).Encode(health); err != nil {
+		if err := writeHealth(w, negotiateFormat(r, FormatJSON), health); err != nil {
 			m.logger.Error("Failed to encode health response", zap.Error(err))
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		}
 	}
 }
 
-// ReadinessHandler returns a simple readiness check handler
+// ReadinessHandler returns a simple readiness check handler.
+// It answers "ready"/"not ready" unless a richer format is requested,
+// e.g. /health/ready?verbose for a per-check listing.
 func (m *Manager) ReadinessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
 
-		health := m.CheckHealth(ctx)
+		health := m.applyReadiness(m.CheckHealth(ctx))
+
+		if err := writeHealth(w, negotiateFormat(r, FormatPlain), health); err != nil {
+			m.logger.Error("Failed to encode readiness response", zap.Error(err))
+		}
+	}
+}
 
-		if health.Status == StatusHealthy {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ready"))
-		} else {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("not ready"))
+// CheckHandler returns a handler that runs a single named check found
+// under prefix, e.g. CheckHandler("/health/ready/") serves /health/ready/redis
+func (m *Manager) CheckHandler(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if name == "" {
+			m.ReadinessHandler()(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		health, ok := m.CheckOne(ctx, name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown health check %q", name), http.StatusNotFound)
+			return
+		}
+
+		if err := writeHealth(w, negotiateFormat(r, FormatText), health); err != nil {
+			m.logger.Error("Failed to encode health check response",
+				zap.String("check", name),
+				zap.Error(err),
+			)
 		}
 	}
 }
 
+// RegisterRoutes wires the standard health endpoints onto mux:
+// /health, /health/ready, /health/ready/{check} and /health/live
+func (m *Manager) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/health", m.HTTPHandler())
+	mux.HandleFunc("/health/ready", m.ReadinessHandler())
+	mux.HandleFunc("/health/ready/", m.CheckHandler("/health/ready/"))
+	mux.HandleFunc("/health/live", m.LivenessHandler())
+}
+
 // LivenessHandler returns a simple liveness check handler
 func (m *Manager) LivenessHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -311,14 +418,20 @@ func (c *RedisChecker) Check(ctx context.Context) CheckResult {
 type MoshiChecker struct {
 	serviceURL string
 	timeout    time.Duration
+	client     *http.Client
 	logger     *logger.Logger
 }
 
 // NewMoshiChecker creates a new Moshi service checker
 func NewMoshiChecker(serviceURL string, timeout time.Duration, log *logger.Logger) *MoshiChecker {
+	// Health checks report the raw state of the service, so no retries
+	// or circuit breaking; the client is shared across checks
+	client := httpclient.New(httpclient.Config{Timeout: timeout}, log)
+
 	return &MoshiChecker{
 		serviceURL: serviceURL,
 		timeout:    timeout,
+		client:     client,
 		logger:     log,
 	}
 }
@@ -327,11 +440,6 @@ func NewMoshiChecker(serviceURL string, timeout time.Duration, log *logger.Logge
 func (c *MoshiChecker) Check(ctx context.Context) CheckResult {
 	start := time.Now()
 	
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: c.timeout,
-	}
-	
 	// Simple HTTP GET to check if service is responding
 	// Note: This is a placeholder - actual Moshi health endpoint may be different
 	healthURL := fmt.Sprintf("http://%s/health", c.serviceURL)
@@ -347,7 +455,7 @@ func (c *MoshiChecker) Check(ctx context.Context) CheckResult {
 		}
 	}
 	
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	duration := time.Since(start)
 	
 	if err != nil {
@@ -0,0 +1,302 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// staticChecker always reports the same result
+type staticChecker CheckResult
+
+func (c staticChecker) Check(context.Context) CheckResult {
+	return CheckResult(c)
+}
+
+func healthy(d time.Duration) staticChecker {
+	return staticChecker{Status: StatusHealthy, Duration: d, Timestamp: time.Now()}
+}
+
+func unhealthy(message string) staticChecker {
+	return staticChecker{Status: StatusUnhealthy, Message: message, Duration: 3 * time.Millisecond, Timestamp: time.Now()}
+}
+
+// newTestMux serves m's routes with the given checkers registered
+func newTestMux(checkers map[string]Checker) (*Manager, *http.ServeMux) {
+	m := NewManager("phonic", "1.4.2-dev", &logger.Logger{Logger: zap.NewNop()})
+	for name, c := range checkers {
+		m.AddChecker(name, c)
+	}
+	mux := http.NewServeMux()
+	m.RegisterRoutes(mux)
+	return m, mux
+}
+
+func serve(mux http.Handler, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   Format
+	}{
+		{"fallback", "/health", "", FormatPlain},
+		{"any", "/health", "*/*", FormatPlain},
+		{"json accept", "/health", "application/json", FormatJSON},
+		{"health+json accept", "/health", "application/health+json, application/json;q=0.9", FormatHealthJSON},
+		{"text accept", "/health", "text/plain", FormatText},
+		{"format query", "/health?format=json", "text/plain", FormatJSON},
+		{"ietf alias", "/health?format=ietf", "", FormatHealthJSON},
+		{"format is case insensitive", "/health?format=Health", "", FormatHealthJSON},
+		{"verbose format", "/health?format=verbose", "application/json", FormatText},
+		{"verbose flag", "/health?verbose", "application/json", FormatText},
+		{"format beats verbose", "/health?verbose&format=json", "", FormatJSON},
+		{"unknown format falls through", "/health?format=xml", "application/json", FormatJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := negotiateFormat(req, FormatPlain); got != tt.want {
+				t.Errorf("negotiateFormat = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	in := HealthResponse{
+		Status:    StatusHealthy,
+		Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Service:   "phonic",
+		Version:   "1.0.0",
+		Uptime:    90 * time.Minute,
+		Checks: []CheckResult{
+			{Name: "redis", Status: StatusHealthy, Duration: 1500 * time.Microsecond, Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"uptime":"1h30m0s"`, `"duration":"1.5ms"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s does not contain %s", data, want)
+		}
+	}
+
+	var out HealthResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if out.Uptime != in.Uptime || out.Service != in.Service || len(out.Checks) != 1 || out.Checks[0].Duration != in.Checks[0].Duration {
+		t.Errorf("round trip gave %+v, want %+v", out, in)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"missing duration", `{"name":"db"}`, false},
+		{"bad duration", `{"duration":"soon"}`, true},
+		{"numeric duration", `{"duration":1500}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c CheckResult
+			if err := json.Unmarshal([]byte(tt.data), &c); (err != nil) != tt.wantErr {
+				t.Errorf("Unmarshal error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthHandlerFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		checkers    map[string]Checker
+		target      string
+		accept      string
+		wantCode    int
+		wantType    string
+		wantContent []string
+	}{
+		{
+			name:        "json by default",
+			checkers:    map[string]Checker{"redis": healthy(time.Millisecond)},
+			target:      "/health",
+			wantCode:    http.StatusOK,
+			wantType:    contentTypeJSON,
+			wantContent: []string{`"status":"healthy"`, `"duration":"1ms"`, `"service":"phonic"`},
+		},
+		{
+			name:        "json unhealthy",
+			checkers:    map[string]Checker{"redis": healthy(time.Millisecond), "postgres": unhealthy("connection refused")},
+			target:      "/health",
+			wantCode:    http.StatusServiceUnavailable,
+			wantType:    contentTypeJSON,
+			wantContent: []string{`"status":"unhealthy"`, `"message":"connection refused"`},
+		},
+		{
+			name:        "verbose text",
+			checkers:    map[string]Checker{"redis": healthy(time.Millisecond), "postgres": unhealthy("connection refused")},
+			target:      "/health?verbose",
+			wantCode:    http.StatusServiceUnavailable,
+			wantType:    contentTypeText,
+			wantContent: []string{"[-]postgres unhealthy (3ms): connection refused\n[+]redis ok (1ms)\nhealth check failed\n"},
+		},
+		{
+			name:        "health+json",
+			checkers:    map[string]Checker{"redis": healthy(time.Millisecond)},
+			target:      "/health",
+			accept:      contentTypeHealthJSON,
+			wantCode:    http.StatusOK,
+			wantType:    contentTypeHealthJSON,
+			wantContent: []string{`"status":"pass"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mux := newTestMux(tt.checkers)
+			rec := serve(mux, tt.target, tt.accept)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type %q, want %q", got, tt.wantType)
+			}
+			for _, want := range tt.wantContent {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body %q does not contain %q", rec.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthJSONDocument(t *testing.T) {
+	_, mux := newTestMux(map[string]Checker{
+		"redis":    healthy(1500 * time.Microsecond),
+		"postgres": unhealthy("connection refused"),
+		"moshi":    staticChecker{Status: StatusUnknown, Timestamp: time.Now()},
+	})
+	rec := serve(mux, "/health?format=health%2Bjson", "")
+
+	var doc ietfResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if doc.Status != "fail" || doc.Version != "1" || doc.ReleaseID != "1.4.2-dev" || doc.ServiceID != "phonic" {
+		t.Errorf("document %+v, want a failing phonic 1 (1.4.2-dev)", doc)
+	}
+	if doc.Output != "failing checks: postgres" {
+		t.Errorf("output %q, want the failing check named", doc.Output)
+	}
+
+	tests := []struct {
+		key    string
+		status string
+		value  float64
+	}{
+		{"redis:responseTime", "pass", 1.5},
+		{"postgres:responseTime", "fail", 3},
+		{"moshi:responseTime", "warn", 0},
+	}
+	for _, tt := range tests {
+		checks := doc.Checks[tt.key]
+		if len(checks) != 1 {
+			t.Errorf("%s has %d entries, want 1", tt.key, len(checks))
+			continue
+		}
+		c := checks[0]
+		if c.Status != tt.status || c.ObservedValue != tt.value || c.ObservedUnit != "ms" {
+			t.Errorf("%s = %s %vms, want %s %vms", tt.key, c.Status, c.ObservedValue, tt.status, tt.value)
+		}
+		if _, err := time.Parse(time.RFC3339, c.Time); err != nil {
+			t.Errorf("%s time %q: %v", tt.key, c.Time, err)
+		}
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	m, mux := newTestMux(map[string]Checker{"redis": healthy(time.Millisecond)})
+
+	rec := serve(mux, "/health/ready", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "ready" {
+		t.Errorf("ready: %d %q, want 200 ready", rec.Code, rec.Body)
+	}
+
+	// Draining forces readiness off while the checks still pass
+	m.SetNotReady("draining")
+	rec = serve(mux, "/health/ready", "")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "not ready" {
+		t.Errorf("draining: %d %q, want 503 not ready", rec.Code, rec.Body)
+	}
+	rec = serve(mux, "/health/ready?verbose", "")
+	if !strings.Contains(rec.Body.String(), "[-]readiness unhealthy (0s): draining\n") {
+		t.Errorf("verbose body %q does not give the reason", rec.Body)
+	}
+
+	// Liveness is unaffected
+	if rec := serve(mux, "/health/live", ""); rec.Code != http.StatusOK {
+		t.Errorf("live: %d, want 200", rec.Code)
+	}
+
+	m.SetReady()
+	if rec := serve(mux, "/health/ready", ""); rec.Code != http.StatusOK {
+		t.Errorf("after SetReady: %d, want 200", rec.Code)
+	}
+}
+
+func TestCheckHandler(t *testing.T) {
+	_, mux := newTestMux(map[string]Checker{
+		"redis":    healthy(time.Millisecond),
+		"postgres": unhealthy("connection refused"),
+	})
+
+	tests := []struct {
+		name     string
+		target   string
+		accept   string
+		wantCode int
+		wantBody string
+	}{
+		{"healthy check", "/health/ready/redis", "", http.StatusOK, "[+]redis ok (1ms)\nhealth check passed\n"},
+		{"failing check", "/health/ready/postgres", "", http.StatusServiceUnavailable, "[-]postgres unhealthy (3ms): connection refused\nhealth check failed\n"},
+		{"trailing slash", "/health/ready/redis/", "", http.StatusOK, "[+]redis ok (1ms)\nhealth check passed\n"},
+		{"json", "/health/ready/redis", "application/json", http.StatusOK, `"name":"redis"`},
+		{"unknown check", "/health/ready/kafka", "", http.StatusNotFound, `unknown health check "kafka"`},
+		{"no check is readiness", "/health/ready/", "", http.StatusServiceUnavailable, "not ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(mux, tt.target, tt.accept)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body %q, want %q", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
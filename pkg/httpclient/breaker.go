@@ -0,0 +1,222 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// ErrCircuitOpen is returned while a host's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerConfig configures per-host circuit breakers
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the circuit; 0 disables circuit breaking
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing
+	OpenTimeout time.Duration
+	// HalfOpenRequests is the number of probes allowed while half-open
+	HalfOpenRequests int
+}
+
+// DefaultBreakerConfig returns conservative breaker settings
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+// breaker is the circuit breaker for a single host
+type breaker struct {
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	inFlight  int
+	successes int
+	// generation counts half-open periods, so a probe's outcome is only
+	// applied to the period that admitted it
+	generation int
+}
+
+// ticket records how a request was admitted
+type ticket struct {
+	probe      bool
+	generation int
+}
+
+// outcome is how a request counts towards its breaker
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	// outcomeIgnored is a request the caller gave up on, which says
+	// nothing about the host's health
+	outcomeIgnored
+)
+
+// BreakerTransport keeps one circuit breaker per host. 5xx responses and
+// transport errors count as failures, unless the request's context was
+// cancelled or timed out.
+type BreakerTransport struct {
+	next   http.RoundTripper
+	config BreakerConfig
+	logger *logger.Logger
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+// NewBreakerTransport wraps next with per-host circuit breaking
+func NewBreakerTransport(next http.RoundTripper, cfg BreakerConfig, log *logger.Logger) *BreakerTransport {
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultBreakerConfig().OpenTimeout
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &BreakerTransport{
+		next:     next,
+		config:   cfg,
+		logger:   log,
+		breakers: make(map[string]*breaker),
+		now:      time.Now,
+	}
+}
+
+// State returns the breaker state for host
+func (t *BreakerTransport) State(host string) BreakerState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[host]
+	if !ok {
+		return BreakerClosed
+	}
+	return b.state
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *BreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	tk, err := t.allow(host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	t.record(req, host, tk, classify(req, resp, err))
+	return resp, err
+}
+
+// classify decides how a finished request counts towards the breaker
+func classify(req *http.Request, resp *http.Response, err error) outcome {
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		return outcomeIgnored
+	case err != nil, resp.StatusCode >= http.StatusInternalServerError:
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
+
+// allow reports whether a request to host may proceed and, if so, how it
+// was admitted
+func (t *BreakerTransport) allow(host string) (ticket, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{state: BreakerClosed}
+		t.breakers[host] = b
+	}
+
+	switch b.state {
+	case BreakerOpen:
+		if t.now().Sub(b.openedAt) < t.config.OpenTimeout {
+			return ticket{}, fmt.Errorf("%w for %s", ErrCircuitOpen, host)
+		}
+		b.state = BreakerHalfOpen
+		b.inFlight = 0
+		b.successes = 0
+		b.generation++
+		fallthrough
+	case BreakerHalfOpen:
+		if b.inFlight >= t.config.HalfOpenRequests {
+			return ticket{}, fmt.Errorf("%w for %s", ErrCircuitOpen, host)
+		}
+		b.inFlight++
+		return ticket{probe: true, generation: b.generation}, nil
+	}
+	return ticket{}, nil
+}
+
+// record updates the breaker for host with the outcome of a request.
+// Probes only count towards the half-open period that admitted them, and
+// requests admitted while closed only count while it is still closed.
+func (t *BreakerTransport) record(req *http.Request, host string, tk ticket, result outcome) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.breakers[host]
+	previous := b.state
+
+	switch {
+	case tk.probe:
+		if b.state != BreakerHalfOpen || tk.generation != b.generation {
+			break
+		}
+		b.inFlight--
+		switch result {
+		case outcomeFailure:
+			b.state = BreakerOpen
+			b.openedAt = t.now()
+		case outcomeSuccess:
+			b.successes++
+			if b.successes >= t.config.HalfOpenRequests {
+				b.state = BreakerClosed
+				b.failures = 0
+			}
+		}
+	case b.state == BreakerClosed:
+		switch result {
+		case outcomeSuccess:
+			b.failures = 0
+		case outcomeFailure:
+			b.failures++
+			if b.failures >= t.config.FailureThreshold {
+				b.state = BreakerOpen
+				b.openedAt = t.now()
+			}
+		}
+	}
+
+	if b.state != previous {
+		t.logger.WithContext(req.Context()).Warn("Circuit breaker state changed",
+			zap.String("host", host),
+			zap.String("from", string(previous)),
+			zap.String("to", string(b.state)),
+			zap.Int("consecutive_failures", b.failures),
+		)
+	}
+}
@@ -0,0 +1,238 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// fakeClock is a settable clock for breaker timeouts
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// flakyServer answers with status, or blocks requests whose path is
+// /block until released or cancelled
+type flakyServer struct {
+	*httptest.Server
+	status  atomic.Int32
+	hits    atomic.Int32
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func newFlakyServer(t *testing.T) *flakyServer {
+	s := &flakyServer{blocked: make(chan struct{}, 8), release: make(chan struct{})}
+	s.status.Store(http.StatusOK)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if r.URL.Path == "/block" {
+			s.blocked <- struct{}{}
+			select {
+			case <-s.release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.WriteHeader(int(s.status.Load()))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestBreaker(cfg BreakerConfig) (*BreakerTransport, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	bt := NewBreakerTransport(http.DefaultTransport, cfg, &logger.Logger{Logger: zap.NewNop()})
+	bt.now = clock.Now
+	return bt, clock
+}
+
+func (s *flakyServer) get(ctx context.Context, rt http.RoundTripper, path string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	cfg := BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute, HalfOpenRequests: 1}
+	tests := []struct {
+		name string
+		// steps are statuses the server answers with; 0 means the breaker
+		// should reject the request without reaching the server
+		steps   []int32
+		advance time.Duration
+		probe   int32
+		want    BreakerState
+	}{
+		{"failures below threshold", []int32{500, 500, 200, 500, 500}, 0, 0, BreakerClosed},
+		{"threshold opens", []int32{500, 503, 502, 0, 0}, 0, 0, BreakerOpen},
+		{"4xx is not a failure", []int32{404, 429, 400, 404}, 0, 0, BreakerClosed},
+		{"successful probe closes", []int32{500, 500, 500}, time.Minute, 200, BreakerClosed},
+		{"failed probe reopens", []int32{500, 500, 500}, time.Minute, 500, BreakerOpen},
+		{"still open before timeout", []int32{500, 500, 500, 0}, 30 * time.Second, 0, BreakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFlakyServer(t)
+			bt, clock := newTestBreaker(cfg)
+			host := mustHost(t, srv.URL)
+
+			for i, status := range tt.steps {
+				hits := srv.hits.Load()
+				if status != 0 {
+					srv.status.Store(status)
+				}
+				code, err := srv.get(context.Background(), bt, "/")
+				if status == 0 {
+					if !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: got %d, %v; want ErrCircuitOpen", i, code, err)
+					}
+					if srv.hits.Load() != hits {
+						t.Fatalf("step %d: rejected request reached the server", i)
+					}
+					continue
+				}
+				if err != nil || code != int(status) {
+					t.Fatalf("step %d: got %d, %v; want %d", i, code, err, status)
+				}
+			}
+
+			clock.Advance(tt.advance)
+			if tt.probe != 0 {
+				srv.status.Store(tt.probe)
+				if _, err := srv.get(context.Background(), bt, "/"); err != nil {
+					t.Fatalf("probe: %v", err)
+				}
+			}
+			if got := bt.State(host); got != tt.want {
+				t.Errorf("state %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerIgnoresCallerCancellation(t *testing.T) {
+	srv := newFlakyServer(t)
+	bt, _ := newTestBreaker(BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-srv.blocked
+			cancel()
+		}()
+		if _, err := srv.get(ctx, bt, "/block"); err == nil {
+			t.Fatal("cancelled request succeeded")
+		}
+		cancel()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := srv.get(ctx, bt, "/block"); err == nil {
+		t.Fatal("timed out request succeeded")
+	}
+	<-srv.blocked
+
+	if got := bt.State(mustHost(t, srv.URL)); got != BreakerClosed {
+		t.Errorf("state %s after caller cancellations, want closed", got)
+	}
+}
+
+func TestBreakerProbeIsTrackedPerRequest(t *testing.T) {
+	srv := newFlakyServer(t)
+	bt, clock := newTestBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+	host := mustHost(t, srv.URL)
+
+	// A slow request admitted while closed
+	slow := make(chan error, 1)
+	go func() {
+		_, err := srv.get(context.Background(), bt, "/block")
+		slow <- err
+	}()
+	<-srv.blocked
+
+	srv.status.Store(http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		srv.get(context.Background(), bt, "/")
+	}
+	if got := bt.State(host); got != BreakerOpen {
+		t.Fatalf("state %s, want open", got)
+	}
+
+	// The probe blocks too, holding the only half-open slot
+	clock.Advance(time.Minute)
+	srv.status.Store(http.StatusOK)
+	probe := make(chan error, 1)
+	go func() {
+		_, err := srv.get(context.Background(), bt, "/block")
+		probe <- err
+	}()
+	<-srv.blocked
+
+	// The slow request finishing must neither free the probe's slot nor
+	// decide the half-open state
+	srv.release <- struct{}{}
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	if got := bt.State(host); got != BreakerHalfOpen {
+		t.Fatalf("state %s after the slow request, want half_open", got)
+	}
+	if _, err := srv.get(context.Background(), bt, "/"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second probe got %v, want ErrCircuitOpen", err)
+	}
+
+	srv.release <- struct{}{}
+	if err := <-probe; err != nil {
+		t.Fatal(err)
+	}
+	if got := bt.State(host); got != BreakerClosed {
+		t.Errorf("state %s after the probe, want closed", got)
+	}
+	bt.mu.Lock()
+	inFlight := bt.breakers[host].inFlight
+	bt.mu.Unlock()
+	if inFlight != 0 {
+		t.Errorf("inFlight = %d, want 0", inFlight)
+	}
+}
+
+func mustHost(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
@@ -0,0 +1,100 @@
+// Package httpclient provides an outbound HTTP client for Phonic AI Calling
+// Agent with tracing, retries and per-host circuit breaking
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// Config configures an outbound HTTP client
+type Config struct {
+	// Timeout bounds a whole request including retries; 0 means none
+	Timeout time.Duration
+	// RetryAttempts is the number of retries after the first attempt
+	RetryAttempts int
+	// RetryBaseDelay is the backoff before the first retry
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay caps the backoff between retries
+	RetryMaxDelay time.Duration
+	// Breaker configures per-host circuit breaking
+	Breaker BreakerConfig
+	// Transport is the underlying transport; nil uses a clone of
+	// http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// DefaultConfig returns settings suitable for service-to-service calls
+func DefaultConfig() Config {
+	return Config{
+		Timeout:        30 * time.Second,
+		RetryAttempts:  3,
+		RetryBaseDelay: 100 * time.Millisecond,
+		RetryMaxDelay:  5 * time.Second,
+		Breaker:        DefaultBreakerConfig(),
+	}
+}
+
+// FromEndpoint derives a client config from a service endpoint
+func FromEndpoint(ep config.ServiceEndpoint) Config {
+	cfg := DefaultConfig()
+	if ep.Timeout > 0 {
+		cfg.Timeout = ep.Timeout
+	}
+	return cfg
+}
+
+// FromMoshiSTT derives a client config from the Moshi STT settings
+func FromMoshiSTT(moshi config.MoshiSTTConfig) Config {
+	cfg := DefaultConfig()
+	if moshi.Timeout > 0 {
+		cfg.Timeout = moshi.Timeout
+	}
+	cfg.RetryAttempts = moshi.RetryAttempts
+	return cfg
+}
+
+// FromMoshiTTS derives a client config from the Moshi TTS settings
+func FromMoshiTTS(moshi config.MoshiTTSConfig) Config {
+	cfg := DefaultConfig()
+	if moshi.Timeout > 0 {
+		cfg.Timeout = moshi.Timeout
+	}
+	cfg.RetryAttempts = moshi.RetryAttempts
+	return cfg
+}
+
+// New builds an http.Client whose transport traces each request, retries
+// transient failures and trips a per-host circuit breaker. Layers run in
+// this order: tracing, retry, circuit breaker, base transport.
+func New(cfg Config, log *logger.Logger) *http.Client {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+
+	base := cfg.Transport
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	var rt http.RoundTripper = base
+	if cfg.Breaker.FailureThreshold > 0 {
+		rt = NewBreakerTransport(rt, cfg.Breaker, log)
+	}
+	if cfg.RetryAttempts > 0 {
+		rt = NewRetryTransport(rt, RetryPolicy{
+			Attempts:  cfg.RetryAttempts,
+			BaseDelay: cfg.RetryBaseDelay,
+			MaxDelay:  cfg.RetryMaxDelay,
+		}, log)
+	}
+	rt = NewTracingTransport(rt, log)
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   cfg.Timeout,
+	}
+}
@@ -0,0 +1,155 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// RetryPolicy configures RetryTransport
+type RetryPolicy struct {
+	// Attempts is the number of retries after the first attempt
+	Attempts int
+	// BaseDelay is the backoff before the first retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff
+	MaxDelay time.Duration
+}
+
+// RetryTransport retries idempotent requests that fail with a transport
+// error or a 429/502/503/504, using exponential backoff with full jitter
+type RetryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+	logger *logger.Logger
+}
+
+// NewRetryTransport wraps next with retries
+func NewRetryTransport(next http.RoundTripper, policy RetryPolicy, log *logger.Logger) *RetryTransport {
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = 100 * time.Millisecond
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 5 * time.Second
+	}
+	return &RetryTransport{next: next, policy: policy, logger: log}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !retryable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(attemptReq)
+		if attempt >= t.policy.Attempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+
+		fields := []zap.Field{
+			zap.String("method", req.Method),
+			zap.String("host", req.URL.Host),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", delay),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			fields = append(fields, zap.Int("status_code", resp.StatusCode))
+			drain(resp)
+		}
+		t.logger.WithContext(ctx).Warn("Retrying HTTP request", fields...)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, honouring Retry-After
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after := retryAfter(resp); after > 0 {
+			return min(after, t.policy.MaxDelay)
+		}
+	}
+
+	ceiling := t.policy.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > t.policy.MaxDelay {
+		ceiling = t.policy.MaxDelay
+	}
+	// Full jitter spreads retries from many clients across the window
+	return time.Duration(rand.Int64N(int64(ceiling)) + 1)
+}
+
+// retryable reports whether req may be sent more than once
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	// Non-idempotent requests are retried only when the caller opts in
+	// with an Idempotency-Key
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetry reports whether a result is a transient failure
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header in seconds or HTTP-date form
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+	return 0
+}
+
+// drain discards and closes a response body so the connection can be reused
+func drain(resp *http.Response) {
+	_, _ = io.CopyN(io.Discard, resp.Body, 64<<10)
+	resp.Body.Close()
+}
@@ -0,0 +1,73 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+)
+
+// TracingTransport starts a client span per request, injects W3C and
+// legacy trace headers plus the request ID, and logs the outcome
+type TracingTransport struct {
+	next   http.RoundTripper
+	logger *logger.Logger
+}
+
+// NewTracingTransport wraps next with tracing
+func NewTracingTransport(next http.RoundTripper, log *logger.Logger) *TracingTransport {
+	return &TracingTransport{next: next, logger: log}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	ctx, span := tracing.Tracer().Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.Redacted()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(ctx)
+	tracing.InjectHTTP(ctx, req.Header)
+	if requestID, ok := ctx.Value(logger.RequestIDKey).(string); ok && requestID != "" {
+		req.Header.Set(tracing.HeaderRequestID, requestID)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	duration := time.Since(start)
+
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("host", req.URL.Host),
+		zap.String("path", req.URL.Path),
+		zap.Duration("duration", duration),
+	}
+
+	log := t.logger.WithContext(ctx)
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+		log.Error("HTTP client request failed", append(fields, zap.Error(err))...)
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(otelcodes.Error, http.StatusText(resp.StatusCode))
+	}
+	log.Info("HTTP client request", append(fields, zap.Int("status_code", resp.StatusCode))...)
+
+	return resp, nil
+}
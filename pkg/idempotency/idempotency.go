@@ -0,0 +1,98 @@
+// Package idempotency stores the first response for an Idempotency-Key in
+// Redis so that retries of the same request replay it instead of running
+// the handler again
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+var (
+	// ErrKeyReused is returned when a key is reused for a different request
+	ErrKeyReused = errors.New("idempotency key reused with a different request")
+	// ErrInProgress is returned when the original request is still running
+	// after waiting for LockTimeout
+	ErrInProgress = errors.New("idempotent request still in progress")
+	// ErrLockLost is the cause of a KeepAlive context cancelled because
+	// the lock expired or was taken over
+	ErrLockLost = errors.New("idempotency lock lost")
+)
+
+// Options configures a Store
+type Options struct {
+	// Window is how long a stored response is replayed
+	Window time.Duration
+	// LockTimeout is how long a lock lasts without being renewed, and how
+	// long duplicates wait for the original request. Lock.KeepAlive renews
+	// it while the request runs.
+	LockTimeout time.Duration
+	// PollInterval is how often waiting duplicates check for the response
+	PollInterval time.Duration
+	// MaxResponseBytes is the largest response body that is stored; larger
+	// responses are not replayed
+	MaxResponseBytes int
+	// Prefix is prepended to Redis keys
+	Prefix string
+}
+
+// FromConfig converts IdempotencyConfig into Options
+func FromConfig(cfg config.IdempotencyConfig) Options {
+	return Options{
+		Window:           cfg.Window,
+		LockTimeout:      cfg.LockTimeout,
+		MaxResponseBytes: cfg.MaxResponseBytes,
+	}
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.Window <= 0 {
+		o.Window = 24 * time.Hour
+	}
+	if o.LockTimeout <= 0 {
+		o.LockTimeout = 30 * time.Second
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 50 * time.Millisecond
+	}
+	if o.MaxResponseBytes <= 0 {
+		o.MaxResponseBytes = 1 << 20
+	}
+	if o.Prefix == "" {
+		o.Prefix = "phonic:idempotency:"
+	}
+	return o
+}
+
+// Record is a stored response. HTTP responses use StatusCode, Header and
+// Body; gRPC responses store the status code in StatusCode, the status
+// message in Message and the marshalled reply in Body and TypeURL.
+type Record struct {
+	Fingerprint string      `json:"fingerprint"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+	TypeURL     string      `json:"type_url,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// Fingerprint hashes the parts that identify a request, e.g. method, path,
+// query and body. Parts are length-prefixed so their boundaries are unambiguous.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	var size [8]byte
+	for _, part := range parts {
+		binary.BigEndian.PutUint64(size[:], uint64(len(part)))
+		h.Write(size[:])
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,256 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Results of acquireScript
+const (
+	stateDone     = 1
+	stateAcquired = 2
+	stateLocked   = 3
+)
+
+// acquireScript returns the stored record or takes the lock.
+// KEYS[1] record key, KEYS[2] lock key; ARGV: lock value, lock ttl (ms).
+// Returns {1, record}, {2, ""} or {3, current lock value}.
+var acquireScript = redis.NewScript(`
+local record = redis.call("GET", KEYS[1])
+if record then
+  return {1, record}
+end
+if redis.call("SET", KEYS[2], ARGV[1], "NX", "PX", ARGV[2]) then
+  return {2, ""}
+end
+return {3, redis.call("GET", KEYS[2]) or ""}
+`)
+
+// completeScript stores the record unless one exists and releases the lock
+// if it is still held by the caller.
+// KEYS[1] record key, KEYS[2] lock key; ARGV: record, window (ms), lock value.
+var completeScript = redis.NewScript(`
+redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2])
+if redis.call("GET", KEYS[2]) == ARGV[3] then
+  redis.call("DEL", KEYS[2])
+end
+return 1
+`)
+
+// releaseScript releases the lock if it is still held by the caller.
+// KEYS[1] lock key; ARGV[1] lock value.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// refreshScript extends the lock if it is still held by the caller.
+// KEYS[1] lock key; ARGV: lock value, lock ttl (ms).
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Store keeps idempotent responses in Redis. Each key has a record, written
+// once when the first request completes, and a lock held while it runs.
+type Store struct {
+	client redis.Scripter
+	opts   Options
+}
+
+// NewStore creates a Redis-backed store
+func NewStore(client redis.Scripter, opts Options) *Store {
+	return &Store{client: client, opts: opts.withDefaults()}
+}
+
+// Options returns the store options with defaults applied
+func (s *Store) Options() Options {
+	return s.opts
+}
+
+// Lock is held by the request that executes the handler for a key
+type Lock struct {
+	store       *Store
+	key         string
+	value       string
+	fingerprint string
+}
+
+// Acquire looks up key. It returns the stored record when the request has
+// already completed, or a Lock when the caller should run the request. When
+// another request holds the key, Acquire waits for its record up to
+// LockTimeout, then returns ErrInProgress. A key whose record or lock was
+// created for a different fingerprint returns ErrKeyReused.
+func (s *Store) Acquire(ctx context.Context, key, fingerprint string) (*Lock, *Record, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	value := fingerprint + " " + hex.EncodeToString(token)
+
+	recordKey, lockKey := s.keys(key)
+	deadline := time.Now().Add(s.opts.LockTimeout)
+
+	for {
+		values, err := acquireScript.Run(ctx, s.client, []string{recordKey, lockKey},
+			value,
+			s.opts.LockTimeout.Milliseconds(),
+		).Slice()
+		if err != nil {
+			return nil, nil, fmt.Errorf("idempotency acquire script failed: %w", err)
+		}
+		if len(values) != 2 {
+			return nil, nil, fmt.Errorf("idempotency acquire script returned %d values", len(values))
+		}
+		state, _ := values[0].(int64)
+		data, _ := values[1].(string)
+
+		switch state {
+		case stateDone:
+			var record Record
+			if err := json.Unmarshal([]byte(data), &record); err != nil {
+				return nil, nil, fmt.Errorf("failed to decode idempotency record: %w", err)
+			}
+			if record.Fingerprint != fingerprint {
+				return nil, nil, ErrKeyReused
+			}
+			return nil, &record, nil
+
+		case stateAcquired:
+			return &Lock{store: s, key: key, value: value, fingerprint: fingerprint}, nil, nil
+
+		case stateLocked:
+			if holder, _, _ := strings.Cut(data, " "); holder != "" && holder != fingerprint {
+				return nil, nil, ErrKeyReused
+			}
+		default:
+			return nil, nil, fmt.Errorf("idempotency acquire script returned state %v", values[0])
+		}
+
+		if time.Now().After(deadline) {
+			return nil, nil, ErrInProgress
+		}
+
+		timer := time.NewTimer(s.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Refresh extends the lock to a full LockTimeout. It returns ErrLockLost
+// when the lock expired or is held by another request.
+func (l *Lock) Refresh(ctx context.Context) error {
+	_, lockKey := l.store.keys(l.key)
+	held, err := refreshScript.Run(ctx, l.store.client, []string{lockKey},
+		l.value,
+		l.store.opts.LockTimeout.Milliseconds(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("idempotency refresh script failed: %w", err)
+	}
+	if held == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// KeepAlive refreshes the lock every third of LockTimeout until stop is
+// called, so a request that runs longer than LockTimeout keeps its key.
+// The returned context is cancelled with cause ErrLockLost when the lock
+// is lost or could not be refreshed before it expired, so the handler
+// stops before a duplicate can take over.
+func (l *Lock) KeepAlive(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(l.store.opts.LockTimeout / 3)
+		defer ticker.Stop()
+
+		expires := time.Now().Add(l.store.opts.LockTimeout)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			now := time.Now()
+			err := l.Refresh(ctx)
+			switch {
+			case err == nil:
+				expires = now.Add(l.store.opts.LockTimeout)
+			case errors.Is(err, ErrLockLost), time.Now().After(expires):
+				cancel(ErrLockLost)
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			cancel(nil)
+		})
+	}
+}
+
+// Complete stores record for the Window and releases the lock
+func (l *Lock) Complete(ctx context.Context, record *Record) error {
+	record.Fingerprint = l.fingerprint
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	recordKey, lockKey := l.store.keys(l.key)
+	if err := completeScript.Run(ctx, l.store.client, []string{recordKey, lockKey},
+		data,
+		l.store.opts.Window.Milliseconds(),
+		l.value,
+	).Err(); err != nil {
+		return fmt.Errorf("idempotency complete script failed: %w", err)
+	}
+	return nil
+}
+
+// Release gives up the lock without storing a response, so the next retry
+// runs the request again
+func (l *Lock) Release(ctx context.Context) error {
+	_, lockKey := l.store.keys(l.key)
+	if err := releaseScript.Run(ctx, l.store.client, []string{lockKey}, l.value).Err(); err != nil {
+		return fmt.Errorf("idempotency release script failed: %w", err)
+	}
+	return nil
+}
+
+// keys returns the record and lock keys for key
+func (s *Store) keys(key string) (string, string) {
+	return s.opts.Prefix + key, s.opts.Prefix + key + ":lock"
+}
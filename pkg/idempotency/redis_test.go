@@ -0,0 +1,140 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestStore(t *testing.T, opts Options) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewStore(client, opts), mr
+}
+
+func TestAcquireCompleteReplay(t *testing.T) {
+	store, _ := newTestStore(t, Options{LockTimeout: time.Second, PollInterval: time.Millisecond})
+	ctx := context.Background()
+
+	lock, record, err := store.Acquire(ctx, "acme:k1", "fp")
+	if err != nil || lock == nil || record != nil {
+		t.Fatalf("Acquire = %v, %v, %v; want a lock", lock, record, err)
+	}
+
+	// A duplicate waits for the original and replays its record
+	replayed := make(chan *Record, 1)
+	go func() {
+		_, record, err := store.Acquire(ctx, "acme:k1", "fp")
+		if err != nil {
+			t.Error(err)
+		}
+		replayed <- record
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := lock.Complete(ctx, &Record{StatusCode: 201, Body: []byte("created")}); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-replayed; r == nil || r.StatusCode != 201 || string(r.Body) != "created" {
+		t.Fatalf("duplicate got %+v, want the stored record", r)
+	}
+
+	if _, _, err := store.Acquire(ctx, "acme:k1", "other"); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("Acquire with another fingerprint = %v, want ErrKeyReused", err)
+	}
+}
+
+func TestKeepAliveRenewsLock(t *testing.T) {
+	const lockTimeout = 60 * time.Millisecond
+	store, mr := newTestStore(t, Options{LockTimeout: lockTimeout, PollInterval: time.Millisecond})
+	ctx := context.Background()
+	_, lockKey := store.keys("acme:k1")
+
+	lock, _, err := store.Acquire(ctx, "acme:k1", "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerCtx, stop := lock.KeepAlive(ctx)
+	defer stop()
+
+	// Run well past LockTimeout, letting Redis time pass as well
+	for i := 0; i < 6; i++ {
+		time.Sleep(lockTimeout / 3)
+		mr.FastForward(lockTimeout / 3)
+	}
+	if !mr.Exists(lockKey) {
+		t.Fatal("lock expired while the request was running")
+	}
+	if handlerCtx.Err() != nil {
+		t.Fatalf("handler context cancelled: %v", context.Cause(handlerCtx))
+	}
+
+	// A duplicate must not take over
+	dupCtx, cancel := context.WithTimeout(ctx, lockTimeout/2)
+	defer cancel()
+	if lock, _, err := store.Acquire(dupCtx, "acme:k1", "fp"); lock != nil || err == nil {
+		t.Fatalf("duplicate Acquire = %v, %v; want it to wait", lock, err)
+	}
+
+	stop()
+	if err := lock.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(lockKey) {
+		t.Error("lock not released")
+	}
+}
+
+func TestKeepAliveCancelsWhenLockLost(t *testing.T) {
+	const lockTimeout = 30 * time.Millisecond
+	store, mr := newTestStore(t, Options{LockTimeout: lockTimeout})
+	ctx := context.Background()
+	_, lockKey := store.keys("acme:k1")
+
+	lock, _, err := store.Acquire(ctx, "acme:k1", "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerCtx, stop := lock.KeepAlive(ctx)
+	defer stop()
+
+	// Another request took the key after the lock expired
+	mr.Set(lockKey, "fp someone-else")
+
+	select {
+	case <-handlerCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("handler context not cancelled after the lock was lost")
+	}
+	if cause := context.Cause(handlerCtx); !errors.Is(cause, ErrLockLost) {
+		t.Errorf("cause %v, want ErrLockLost", cause)
+	}
+	if err := lock.Refresh(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Refresh = %v, want ErrLockLost", err)
+	}
+	if got, _ := mr.Get(lockKey); got != "fp someone-else" {
+		t.Errorf("lock value %q; the other holder's lock was touched", got)
+	}
+}
+
+func TestKeepAliveStop(t *testing.T) {
+	store, _ := newTestStore(t, Options{LockTimeout: time.Second})
+	lock, _, err := store.Acquire(context.Background(), "acme:k1", "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerCtx, stop := lock.KeepAlive(context.Background())
+	stop()
+	stop()
+	if handlerCtx.Err() == nil {
+		t.Error("context still live after stop")
+	}
+	if errors.Is(context.Cause(handlerCtx), ErrLockLost) {
+		t.Error("stop reported the lock as lost")
+	}
+}
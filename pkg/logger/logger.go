@@ -16,6 +16,7 @@ import (
 type Logger struct {
 	*zap.Logger
 	config *config.Config
+	level  *zap.AtomicLevel
 }
 
 // Fields represents structured log fields
@@ -27,10 +28,18 @@ type ContextKey string
 const (
 	// TraceIDKey is the context key for trace IDs
 	TraceIDKey ContextKey = "traceID"
+	// SpanIDKey is the context key for span IDs
+	SpanIDKey ContextKey = "spanID"
 	// RequestIDKey is the context key for request IDs
 	RequestIDKey ContextKey = "requestID"
 	// ServiceKey is the context key for service name
 	ServiceKey ContextKey = "service"
+	// SessionIDKey is the context key for call session IDs
+	SessionIDKey ContextKey = "sessionID"
+	// TenantIDKey is the context key for tenant IDs
+	TenantIDKey ContextKey = "tenantID"
+	// UserIDKey is the context key for authenticated user IDs
+	UserIDKey ContextKey = "userID"
 )
 
 var (
@@ -61,7 +70,8 @@ func New(cfg *config.Config) (*Logger, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid log level: %w", err)
 	}
-	zapConfig.Level = zap.NewAtomicLevelAt(level)
+	atomicLevel := zap.NewAtomicLevelAt(level)
+	zapConfig.Level = atomicLevel
 	
 	// Configure output format
 	switch cfg.Logging.Format {
@@ -106,6 +116,7 @@ func New(cfg *config.Config) (*Logger, error) {
 	logger := &Logger{
 		Logger: zapLogger,
 		config: cfg,
+		level:  &atomicLevel,
 	}
 	
 	return logger, nil
@@ -139,6 +150,10 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 		fields = append(fields, zap.String("trace_id", traceID.(string)))
 	}
 	
+	if spanID := ctx.Value(SpanIDKey); spanID != nil {
+		fields = append(fields, zap.String("span_id", spanID.(string)))
+	}
+	
 	if requestID := ctx.Value(RequestIDKey); requestID != nil {
 		fields = append(fields, zap.String("request_id", requestID.(string)))
 	}
@@ -147,9 +162,22 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 		fields = append(fields, zap.String("service_name", service.(string)))
 	}
 	
+	if sessionID := ctx.Value(SessionIDKey); sessionID != nil {
+		fields = append(fields, zap.String("session_id", sessionID.(string)))
+	}
+	
+	if tenantID := ctx.Value(TenantIDKey); tenantID != nil {
+		fields = append(fields, zap.String("tenant_id", tenantID.(string)))
+	}
+	
+	if userID := ctx.Value(UserIDKey); userID != nil {
+		fields = append(fields, zap.String("user_id", userID.(string)))
+	}
+	
 	return &Logger{
 		Logger: l.Logger.With(fields...),
 		config: l.config,
+		level:  l.level,
 	}
 }
 
@@ -163,6 +191,7 @@ func (l *Logger) WithFields(fields Fields) *Logger {
 	return &Logger{
 		Logger: l.Logger.With(zapFields...),
 		config: l.config,
+		level:  l.level,
 	}
 }
 
@@ -171,6 +200,7 @@ func (l *Logger) WithService(serviceName string) *Logger {
 	return &Logger{
 		Logger: l.Logger.With(zap.String("service_name", serviceName)),
 		config: l.config,
+		level:  l.level,
 	}
 }
 
@@ -190,15 +220,16 @@ func (l *Logger) ErrorWithDuration(msg string, duration time.Duration, err error
 }
 
 // LogHTTPRequest logs HTTP request information
-func (l *Logger) LogHTTPRequest(method, path, userAgent, remoteAddr string, statusCode int, duration time.Duration) {
-	l.Info("HTTP request",
+func (l *Logger) LogHTTPRequest(method, path, userAgent, remoteAddr string, statusCode int, duration time.Duration, extra ...zap.Field) {
+	fields := []zap.Field{
 		zap.String("method", method),
 		zap.String("path", path),
 		zap.String("user_agent", userAgent),
 		zap.String("remote_addr", remoteAddr),
 		zap.Int("status_code", statusCode),
 		zap.Duration("duration", duration),
-	)
+	}
+	l.Info("HTTP request", append(fields, extra...)...)
 }
 
 // LogGRPCRequest logs gRPC request information
@@ -340,23 +371,47 @@ func CreateStartupLogger() *Logger {
 	return &Logger{Logger: zapLogger}
 }
 
-// SetGlobalLevel dynamically sets the global log level
-func SetGlobalLevel(level string) error {
-	if globalLogger == nil {
-		return fmt.Errorf("global logger not initialized")
+// SetLevel dynamically changes the level of this logger and every logger
+// derived from it
+func (l *Logger) SetLevel(level string) error {
+	if l.level == nil {
+		return fmt.Errorf("logger does not support dynamic levels")
 	}
 	
-	_, err := zapcore.ParseLevel(level)
+	parsed, err := zapcore.ParseLevel(level)
 	if err != nil {
 		return fmt.Errorf("invalid log level: %w", err)
 	}
 	
-	// Note: This would require a reconfigurable logger setup
-	// For now, we'll just log the change
-	globalLogger.Info("Log level change requested", 
-		zap.String("new_level", level),
-		zap.String("note", "restart required to apply"),
-	)
+	previous := l.level.Level()
+	l.level.SetLevel(parsed)
+	
+	if previous != parsed {
+		// Log at the new level when it is above info, so the change is
+		// not filtered out by itself; error is as high as it goes
+		l.Log(max(zapcore.InfoLevel, min(parsed, zapcore.ErrorLevel)), "Log level changed",
+			zap.String("previous_level", previous.String()),
+			zap.String("new_level", parsed.String()),
+		)
+	}
 	
 	return nil
 }
+
+// LevelString returns the name of the current log level. It does not
+// shadow zap's Level, which returns the zapcore.Level.
+func (l *Logger) LevelString() string {
+	if l.level == nil {
+		return l.Logger.Level().String()
+	}
+	return l.level.Level().String()
+}
+
+// SetGlobalLevel dynamically sets the global log level
+func SetGlobalLevel(level string) error {
+	if globalLogger == nil {
+		return fmt.Errorf("global logger not initialized")
+	}
+	
+	return globalLogger.SetLevel(level)
+}
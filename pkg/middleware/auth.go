@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// Auth middleware verifies the X-API-Key header, or else the bearer token
+// in the Authorization header, and stores the claims in the request
+// context. Requests without valid credentials get 401 with a
+// WWW-Authenticate challenge.
+func Auth(verifier auth.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			credential := r.Header.Get("X-API-Key")
+			if credential == "" {
+				credential = bearerToken(r.Header.Get("Authorization"))
+			}
+
+			claims, err := verifier.Verify(r.Context(), credential)
+			if err != nil {
+				logger.WithContext(r.Context()).Warn("HTTP authentication failed",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Error(err),
+				)
+				writeAuthError(w, http.StatusUnauthorized, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// RequireScope middleware rejects requests whose claims lack any of scopes
+// with 403. It must run after Auth.
+func RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := auth.RequireScope(r.Context(), scopes...); err != nil {
+				code := http.StatusForbidden
+				if errors.Is(err, auth.ErrMissingToken) {
+					code = http.StatusUnauthorized
+				}
+				writeAuthError(w, code, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GRPCAuthInterceptor verifies the x-api-key or bearer authorization
+// metadata of unary calls and stores the claims in the context
+func GRPCAuthInterceptor(verifier auth.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticateGRPC(ctx, verifier, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCAuthStreamInterceptor verifies credentials when a stream opens
+func GRPCAuthStreamInterceptor(verifier auth.Verifier) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateGRPC(ss.Context(), verifier, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, WrapServerStream(ss, ctx))
+	}
+}
+
+// authenticateGRPC verifies the call's credentials and returns a context
+// with claims
+func authenticateGRPC(ctx context.Context, verifier auth.Verifier, method string) (context.Context, error) {
+	var credential string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		credential = firstValue(md, "x-api-key")
+		if credential == "" {
+			credential = bearerToken(firstValue(md, "authorization"))
+		}
+	}
+
+	claims, err := verifier.Verify(ctx, credential)
+	if err != nil {
+		logger.WithContext(ctx).Warn("gRPC authentication failed",
+			zap.String("method", method),
+			zap.Error(err),
+		)
+		if errors.Is(err, auth.ErrMissingToken) {
+			return nil, status.Error(codes.Unauthenticated, "missing credentials")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+	return auth.WithClaims(ctx, claims), nil
+}
+
+// GRPCRequireScope returns PermissionDenied unless the claims in ctx grant
+// every scope; handlers call it after GRPCAuthInterceptor has run
+func GRPCRequireScope(ctx context.Context, scopes ...string) error {
+	if err := auth.RequireScope(ctx, scopes...); err != nil {
+		if errors.Is(err, auth.ErrMissingToken) {
+			return status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// bearerToken extracts the token from an Authorization header value
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// writeAuthError writes a 401/403 with an RFC 6750 challenge. Details of
+// why a token was rejected are logged, not returned.
+func writeAuthError(w http.ResponseWriter, code int, err error) {
+	challenge := `Bearer realm="phonic"`
+	switch {
+	case errors.Is(err, auth.ErrInsufficientScope):
+		challenge += `, error="insufficient_scope"`
+	case !errors.Is(err, auth.ErrMissingToken):
+		challenge += `, error="invalid_token"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(code), code)
+}
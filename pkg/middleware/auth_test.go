@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// newTestCredentials returns a JWT-only verifier and a token it accepts
+// with the given scopes
+func newTestCredentials(t *testing.T, scopes ...string) (auth.Credentials, string) {
+	t.Helper()
+	tm := auth.NewTokenManager(auth.NewKeySet(auth.NewHMACKey("hs-1", []byte("secret"))),
+		auth.Options{Issuer: "phonic", TTL: time.Minute}, logger.GetGlobal())
+	token, err := tm.Issue(auth.Claims{TenantID: "acme", UserID: "alice", Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.Credentials{Tokens: tm}, token
+}
+
+func TestAuth(t *testing.T) {
+	creds, token := newTestCredentials(t, "calls:read")
+
+	tests := []struct {
+		name          string
+		header        string
+		value         string
+		scopes        []string
+		wantCode      int
+		wantChallenge string
+	}{
+		{name: "bearer token", header: "Authorization", value: "Bearer " + token, wantCode: http.StatusOK},
+		{name: "scheme is case insensitive", header: "Authorization", value: "bearer  " + token, wantCode: http.StatusOK},
+		{name: "granted scope", header: "Authorization", value: "Bearer " + token, scopes: []string{"calls:read"}, wantCode: http.StatusOK},
+		// RFC 6750 3.1: no error code when the request has no credentials
+		{name: "no credentials", wantCode: http.StatusUnauthorized, wantChallenge: `Bearer realm="phonic"`},
+		{name: "other scheme", header: "Authorization", value: "Basic YWxpY2U6c2VjcmV0", wantCode: http.StatusUnauthorized, wantChallenge: `Bearer realm="phonic"`},
+		{name: "invalid token", header: "Authorization", value: "Bearer " + token + "x", wantCode: http.StatusUnauthorized, wantChallenge: `Bearer realm="phonic", error="invalid_token"`},
+		{name: "api keys not accepted", header: "X-API-Key", value: "phk_abc_def", wantCode: http.StatusUnauthorized, wantChallenge: `Bearer realm="phonic", error="invalid_token"`},
+		{name: "missing scope", header: "Authorization", value: "Bearer " + token, scopes: []string{"calls:write"}, wantCode: http.StatusForbidden, wantChallenge: `Bearer realm="phonic", error="insufficient_scope"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims *auth.Claims
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, _ = auth.FromContext(r.Context())
+				if tenant := r.Context().Value(logger.TenantIDKey); tenant != "acme" {
+					t.Errorf("tenant %v not set for logging", tenant)
+				}
+			})
+			if tt.scopes != nil {
+				handler = RequireScope(tt.scopes...)(handler)
+			}
+			handler = Auth(creds)(handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/calls", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate %q, want %q", got, tt.wantChallenge)
+			}
+			if tt.wantCode == http.StatusOK && (claims == nil || claims.UserID != "alice") {
+				t.Errorf("handler got claims %+v, want alice's", claims)
+			}
+		})
+	}
+}
+
+func TestRequireScopeWithoutAuth(t *testing.T) {
+	rec := httptest.NewRecorder()
+	RequireScope("calls:read")(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != `Bearer realm="phonic"` {
+		t.Errorf("%d %q, want 401 with a bare challenge", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestGRPCAuthInterceptor(t *testing.T) {
+	creds, token := newTestCredentials(t, "calls:read")
+	info := &grpc.UnaryServerInfo{FullMethod: "/phonic.calls.Calls/Get"}
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		scopes   []string
+		wantCode codes.Code
+	}{
+		{name: "bearer token", md: metadata.Pairs("authorization", "Bearer "+token), wantCode: codes.OK},
+		{name: "granted scope", md: metadata.Pairs("authorization", "Bearer "+token), scopes: []string{"calls:read"}, wantCode: codes.OK},
+		{name: "no metadata", wantCode: codes.Unauthenticated},
+		{name: "invalid token", md: metadata.Pairs("authorization", "Bearer nope"), wantCode: codes.Unauthenticated},
+		{name: "missing scope", md: metadata.Pairs("authorization", "Bearer "+token), scopes: []string{"calls:write"}, wantCode: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			_, err := GRPCAuthInterceptor(creds)(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, GRPCRequireScope(ctx, tt.scopes...)
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code %s (%v), want %s", code, err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGRPCAuthStreamInterceptor(t *testing.T) {
+	creds, token := newTestCredentials(t)
+	md := metadata.Pairs("authorization", "Bearer "+token)
+	inner := &fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+	err := GRPCAuthStreamInterceptor(creds)(nil, inner, streamInfo, func(srv interface{}, ss grpc.ServerStream) error {
+		if claims, ok := auth.FromContext(ss.Context()); !ok || claims.TenantID != "acme" {
+			t.Errorf("stream context claims %+v, want acme's", claims)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("error %v", err)
+	}
+
+	inner = &fakeServerStream{ctx: context.Background()}
+	err = GRPCAuthStreamInterceptor(creds)(nil, inner, streamInfo, echo)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("error %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,265 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/ratelimit"
+)
+
+// Middleware wraps an http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middleware; the first runs outermost
+func Chain(middleware ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// Skip bypasses mw for requests matching skip
+func Skip(mw Middleware, skip func(r *http.Request) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SkipPaths matches requests for any of paths. A path ending in "/"
+// matches everything below it.
+func SkipPaths(paths ...string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		return matchPrefixes(r.URL.Path, paths)
+	}
+}
+
+// DefaultSkipPaths are the health and metrics endpoints that bypass auth,
+// rate limiting and request logging in the default stacks
+var DefaultSkipPaths = []string{"/health", "/health/", "/metrics"}
+
+// DefaultSkipMethods are the gRPC method prefixes that bypass auth and rate
+// limiting in the default stacks
+var DefaultSkipMethods = []string{"/grpc.health.v1.Health/", "/grpc.reflection."}
+
+// stackOptions holds the settings of the default stacks
+type stackOptions struct {
+	verifier    auth.Verifier
+	noAuth      bool
+	limiter     ratelimit.Limiter
+	keyFunc     KeyFunc
+	grpcKeyFunc GRPCKeyFunc
+	noRateLimit bool
+	limits      *LimitOptions
+	skipPaths   []string
+	skipMethods []string
+}
+
+// StackOption customizes DefaultHTTPStack and DefaultGRPCOptions
+type StackOption func(*stackOptions)
+
+// WithVerifier authenticates with verifier instead of JWTs from
+// cfg.Security, e.g. auth.Credentials to also accept API keys
+func WithVerifier(verifier auth.Verifier) StackOption {
+	return func(o *stackOptions) {
+		o.verifier = verifier
+	}
+}
+
+// WithoutAuth removes the auth layer
+func WithoutAuth() StackOption {
+	return func(o *stackOptions) {
+		o.noAuth = true
+	}
+}
+
+// WithRateLimiter uses limiter instead of an in-memory limiter built from
+// cfg.Security.RateLimit, e.g. a ratelimit.RedisLimiter shared by replicas
+func WithRateLimiter(limiter ratelimit.Limiter) StackOption {
+	return func(o *stackOptions) {
+		o.limiter = limiter
+	}
+}
+
+// WithRateLimitKeys changes how requests are grouped into buckets; the
+// default is per authenticated principal, falling back to the client IP
+func WithRateLimitKeys(keyFunc KeyFunc, grpcKeyFunc GRPCKeyFunc) StackOption {
+	return func(o *stackOptions) {
+		o.keyFunc = keyFunc
+		o.grpcKeyFunc = grpcKeyFunc
+	}
+}
+
+// WithoutRateLimit removes the rate limit layer
+func WithoutRateLimit() StackOption {
+	return func(o *stackOptions) {
+		o.noRateLimit = true
+	}
+}
+
+// WithLimits replaces the body size and deadline limits, which default to
+// LimitOptionsFromConfig(cfg.Services.Gateway)
+func WithLimits(limits LimitOptions) StackOption {
+	return func(o *stackOptions) {
+		o.limits = &limits
+	}
+}
+
+// WithSkipPaths replaces DefaultSkipPaths
+func WithSkipPaths(paths ...string) StackOption {
+	return func(o *stackOptions) {
+		o.skipPaths = paths
+	}
+}
+
+// WithSkipMethods replaces DefaultSkipMethods
+func WithSkipMethods(prefixes ...string) StackOption {
+	return func(o *stackOptions) {
+		o.skipMethods = prefixes
+	}
+}
+
+// resolveStackOptions applies opts and fills in layers built from cfg
+func resolveStackOptions(cfg *config.Config, opts []StackOption) (*stackOptions, error) {
+	o := &stackOptions{
+		keyFunc:     KeyByPrincipal,
+		grpcKeyFunc: GRPCKeyByPrincipal,
+		skipPaths:   DefaultSkipPaths,
+		skipMethods: DefaultSkipMethods,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if !o.noAuth && o.verifier == nil {
+		tokens, err := auth.FromConfig(context.Background(), cfg.Security, logger.GetGlobal())
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure auth: %w", err)
+		}
+		o.verifier = tokens
+	}
+	if !o.noRateLimit && o.limiter == nil {
+		if cfg.Security.RateLimit.Enabled() {
+			o.limiter = ratelimit.NewMemoryLimiter(ratelimit.FromConfig(cfg.Security.RateLimit))
+		} else {
+			o.noRateLimit = true
+		}
+	}
+	if o.limits == nil {
+		limits := LimitOptionsFromConfig(cfg.Services.Gateway)
+		o.limits = &limits
+	}
+	return o, nil
+}
+
+// DefaultHTTPStack builds the standard HTTP middleware from cfg, outermost
+// first: recovery, tracing, CORS, limits, auth, rate limit, logging. CORS
+// runs outside the layers that reject requests so their 401, 413, 429,
+// 503 and 504 responses stay readable by browsers, and it answers
+// preflight requests before they reach auth. Auth, rate limiting and
+// logging are skipped for health and metrics paths.
+func DefaultHTTPStack(cfg *config.Config, opts ...StackOption) (Middleware, error) {
+	o, err := resolveStackOptions(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cors, err := NewCORS(CORSOptionsFromConfig(cfg.Security.CORS))
+	if err != nil {
+		return nil, err
+	}
+
+	skip := SkipPaths(o.skipPaths...)
+	layers := []Middleware{Recovery, HTTPTracing, cors, Limits(*o.limits)}
+	if !o.noAuth {
+		layers = append(layers, Skip(Auth(o.verifier), skip))
+	}
+	if !o.noRateLimit {
+		layers = append(layers, Skip(RateLimit(o.limiter, o.keyFunc), skip))
+	}
+	layers = append(layers, Skip(HTTPLogging, skip))
+
+	return Chain(layers...), nil
+}
+
+// DefaultGRPCOptions builds server options with the standard interceptor
+// order from cfg: recovery, tracing, auth, rate limit, logging, for both
+// unary and streaming calls. Auth and rate limiting are skipped for health
+// and reflection methods.
+func DefaultGRPCOptions(cfg *config.Config, opts ...StackOption) ([]grpc.ServerOption, error) {
+	o, err := resolveStackOptions(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := func(fullMethod string) bool {
+		return matchPrefixes(fullMethod, o.skipMethods)
+	}
+
+	unary := []grpc.UnaryServerInterceptor{GRPCRecoveryInterceptor, GRPCTracingInterceptor}
+	stream := []grpc.StreamServerInterceptor{GRPCRecoveryStreamInterceptor, GRPCTracingStreamInterceptor}
+	if !o.noAuth {
+		unary = append(unary, skipUnary(GRPCAuthInterceptor(o.verifier), skip))
+		stream = append(stream, skipStream(GRPCAuthStreamInterceptor(o.verifier), skip))
+	}
+	if !o.noRateLimit {
+		unary = append(unary, skipUnary(GRPCRateLimitInterceptor(o.limiter, o.grpcKeyFunc), skip))
+		stream = append(stream, skipStream(GRPCRateLimitStreamInterceptor(o.limiter, o.grpcKeyFunc), skip))
+	}
+	unary = append(unary, GRPCLoggingInterceptor)
+	stream = append(stream, GRPCLoggingStreamInterceptor)
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, nil
+}
+
+// skipUnary bypasses interceptor for methods matching skip
+func skipUnary(interceptor grpc.UnaryServerInterceptor, skip func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// skipStream bypasses interceptor for methods matching skip
+func skipStream(interceptor grpc.StreamServerInterceptor, skip func(fullMethod string) bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skip(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		return interceptor(srv, ss, info, handler)
+	}
+}
+
+// matchPrefixes reports whether value equals an entry, or starts with an
+// entry that ends in "/" or "."
+func matchPrefixes(value string, entries []string) bool {
+	for _, entry := range entries {
+		if value == entry {
+			return true
+		}
+		if (strings.HasSuffix(entry, "/") || strings.HasSuffix(entry, ".")) && strings.HasPrefix(value, entry) {
+			return true
+		}
+	}
+	return false
+}
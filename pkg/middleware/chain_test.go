@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/ratelimit"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the middleware's request logs out of test output
+	cfg := &config.Config{
+		App:     config.AppConfig{Environment: "test"},
+		Logging: config.LoggingConfig{Level: "fatal", Format: "json", Output: "stderr"},
+	}
+	if err := logger.InitGlobal(cfg); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// tokenVerifier accepts the single credential "good"
+type tokenVerifier struct{}
+
+func (tokenVerifier) Verify(ctx context.Context, credential string) (*auth.Claims, error) {
+	if credential != "good" {
+		return nil, auth.ErrMissingToken
+	}
+	return &auth.Claims{TenantID: "acme", UserID: "alice"}, nil
+}
+
+func TestDefaultHTTPStackCORS(t *testing.T) {
+	const origin = "https://app.example.com"
+	cfg := &config.Config{
+		Security: config.SecurityConfig{
+			CORS: config.CORSConfig{
+				AllowedOrigins:   []string{origin},
+				AllowedMethods:   []string{"POST"},
+				AllowedHeaders:   []string{"Authorization", "Content-Type"},
+				ExposedHeaders:   []string{"Retry-After"},
+				AllowCredentials: true,
+			},
+		},
+	}
+	stack, err := DefaultHTTPStack(cfg,
+		WithVerifier(tokenVerifier{}),
+		WithRateLimiter(ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0, Burst: 1})),
+		WithLimits(LimitOptions{
+			Default: RouteLimits{MaxBodyBytes: 8, Timeout: 20 * time.Millisecond},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := stack(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Each request is rejected by a different layer; the rate limiter's
+	// single token is spent by the timed out request
+
+	tests := []struct {
+		name  string
+		build func() *http.Request
+		want  int
+	}{
+		{"preflight skips auth", func() *http.Request {
+			r := httptest.NewRequest(http.MethodOptions, "/calls", nil)
+			r.Header.Set("Access-Control-Request-Method", "POST")
+			r.Header.Set("Access-Control-Request-Headers", "authorization")
+			return r
+		}, http.StatusNoContent},
+		{"unauthenticated", func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/calls", nil)
+		}, http.StatusUnauthorized},
+		{"body too large", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/calls", strings.NewReader("far more than eight bytes"))
+			r.Header.Set("Authorization", "Bearer good")
+			return r
+		}, http.StatusRequestEntityTooLarge},
+		{"timed out", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+			r.Header.Set("Authorization", "Bearer good")
+			return r
+		}, http.StatusGatewayTimeout},
+		{"rate limited", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/calls", nil)
+			r.Header.Set("Authorization", "Bearer good")
+			return r
+		}, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.build()
+			r.Header.Set("Origin", origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			checkHeader(t, rec.Header(), "Access-Control-Allow-Origin", origin)
+			checkHeader(t, rec.Header(), "Access-Control-Allow-Credentials", "true")
+		})
+	}
+
+}
+
+func TestDefaultHTTPStackRateLimitConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.RateLimitConfig
+		// want is the status of each request in turn
+		want []int
+	}{
+		{"disabled", config.RateLimitConfig{}, []int{200, 200, 200, 200}},
+		{"disabled with a burst", config.RateLimitConfig{BurstSize: 1}, []int{200, 200, 200, 200}},
+		{"enabled", config.RateLimitConfig{RequestsPerMinute: 1, BurstSize: 2}, []int{200, 200, 429, 429}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Security: config.SecurityConfig{RateLimit: tt.cfg}}
+			stack, err := DefaultHTTPStack(cfg, WithVerifier(tokenVerifier{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := stack(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			for i, want := range tt.want {
+				r := httptest.NewRequest(http.MethodGet, "/calls", nil)
+				r.Header.Set("Authorization", "Bearer good")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, r)
+				if rec.Code != want {
+					t.Errorf("request %d: status %d, want %d", i+1, rec.Code, want)
+				}
+			}
+		})
+	}
+}
+
+// principalVerifier accepts any credential as the subject of tenant acme
+type principalVerifier struct{}
+
+func (principalVerifier) Verify(ctx context.Context, credential string) (*auth.Claims, error) {
+	return &auth.Claims{TenantID: "acme", RegisteredClaims: jwt.RegisteredClaims{Subject: credential}}, nil
+}
+
+func TestDefaultHTTPStackKeysByPrincipal(t *testing.T) {
+	cfg := &config.Config{Security: config.SecurityConfig{
+		RateLimit: config.RateLimitConfig{RequestsPerMinute: 1, BurstSize: 1},
+	}}
+	stack, err := DefaultHTTPStack(cfg, WithVerifier(principalVerifier{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := stack(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Two callers of one tenant have a bucket each
+	for _, tt := range []struct {
+		credential string
+		want       int
+	}{
+		{"alice", http.StatusOK},
+		{"bob", http.StatusOK},
+		{"alice", http.StatusTooManyRequests},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/calls", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.credential)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.credential, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+)
+
+// ClientRetryPolicy controls how GRPCClientRetryInterceptor retries
+// unary calls
+type ClientRetryPolicy struct {
+	// MaxAttempts includes the first call; default 3
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for each
+	// following one up to MaxBackoff; default 100ms and 2s
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Codes are the retryable status codes; default Unavailable
+	Codes []codes.Code
+}
+
+func (p ClientRetryPolicy) withDefaults() ClientRetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 2 * time.Second
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = p.Backoff
+	}
+	if len(p.Codes) == 0 {
+		p.Codes = []codes.Code{codes.Unavailable}
+	}
+	return p
+}
+
+func (p ClientRetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCClientUnaryInterceptor propagates trace, request, session and tenant
+// IDs from the context into outgoing metadata, starts a client span and
+// logs latency and status code. It does not retry; see
+// GRPCClientRetryInterceptor.
+func GRPCClientUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invokeWithRetries(ctx, method, req, reply, cc, invoker, ClientRetryPolicy{MaxAttempts: 1}, opts)
+}
+
+// GRPCClientRetryInterceptor is GRPCClientUnaryInterceptor that also
+// retries calls failing with one of policy's codes. One span and log line
+// cover all attempts, recording how many retries were made.
+func GRPCClientRetryInterceptor(policy ClientRetryPolicy) grpc.UnaryClientInterceptor {
+	policy = policy.withDefaults()
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invokeWithRetries(ctx, method, req, reply, cc, invoker, policy, opts)
+	}
+}
+
+// invokeWithRetries calls invoker up to policy.MaxAttempts times, counting
+// the retries it makes
+func invokeWithRetries(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, policy ClientRetryPolicy, opts []grpc.CallOption) error {
+	start := time.Now()
+
+	ctx, span := startClientSpan(ctx, method, cc.Target())
+	defer span.End()
+
+	var (
+		err     error
+		retries int
+		backoff = policy.Backoff
+	)
+	for attempt := 1; ; attempt++ {
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) || ctx.Err() != nil {
+			break
+		}
+
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("code", status.Code(err).String()),
+		))
+		logger.WithContext(ctx).Warn("gRPC client call failed, retrying",
+			zap.String("grpc_method", method),
+			zap.String("target", cc.Target()),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		retries++
+		if backoff *= 2; backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+
+	endClientSpan(ctx, span, method, cc.Target(), retries, start, err)
+
+	return err
+}
+
+// GRPCClientStreamInterceptor is the streaming counterpart of
+// GRPCClientUnaryInterceptor. The span and log line cover the whole stream
+// and are finished when the stream ends: on EOF or an error, on the single
+// response of a client-streaming call, or when ctx is done, so a stream
+// that is abandoned without being read to the end is still recorded.
+func GRPCClientStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+
+	ctx, span := startClientSpan(ctx, method, cc.Target())
+
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		endClientSpan(ctx, span, method, cc.Target(), 0, start, err)
+		span.End()
+		return nil, err
+	}
+
+	s := &tracedClientStream{
+		ClientStream:  cs,
+		serverStreams: desc.ServerStreams,
+		done:          make(chan struct{}),
+		finish: func(err error) {
+			endClientSpan(ctx, span, method, cc.Target(), 0, start, err)
+			span.End()
+		},
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.end(status.FromContextError(ctx.Err()).Err())
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// tracedClientStream finishes the client span when the stream ends
+type tracedClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	// done is closed once the span has been finished
+	done   chan struct{}
+	finish func(err error)
+}
+
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		// A client-streaming call has a single response
+		s.end(nil)
+	}
+	return err
+}
+
+func (s *tracedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) end(err error) {
+	s.once.Do(func() {
+		s.finish(err)
+		close(s.done)
+	})
+}
+
+// startClientSpan starts a client span and writes propagation metadata
+func startClientSpan(ctx context.Context, fullMethod, target string) (context.Context, trace.Span) {
+	service, method := splitMethod(fullMethod)
+	ctx, span := tracing.Tracer().Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCService(service),
+			semconv.RPCMethod(method),
+			semconv.ServerAddress(target),
+		),
+	)
+
+	return withOutgoingIDs(ctx), span
+}
+
+// withOutgoingIDs replaces any propagation keys in the outgoing metadata
+// with the values carried by ctx
+func withOutgoingIDs(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+
+	tracing.InjectGRPC(ctx, md)
+
+	if requestID, ok := ctx.Value(logger.RequestIDKey).(string); ok && requestID != "" {
+		md.Set(tracing.MetadataRequestID, requestID)
+	}
+	if sessionID, ok := ctx.Value(logger.SessionIDKey).(string); ok && sessionID != "" {
+		md.Set(tracing.MetadataSessionID, sessionID)
+	}
+	if tenantID, ok := ctx.Value(logger.TenantIDKey).(string); ok && tenantID != "" {
+		md.Set(tracing.MetadataTenantID, tenantID)
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// endClientSpan records status on the span and logs the outbound call
+func endClientSpan(ctx context.Context, span trace.Span, method, target string, retries int, start time.Time, err error) {
+	code := status.Code(err)
+	duration := time.Since(start)
+
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if retries > 0 {
+		span.SetAttributes(attribute.Int("rpc.grpc.retries", retries))
+	}
+	if err != nil {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+
+	fields := []zap.Field{
+		zap.String("grpc_method", method),
+		zap.String("target", target),
+		zap.String("code", code.String()),
+		zap.Duration("duration", duration),
+		zap.Int("retries", retries),
+	}
+
+	log := logger.WithContext(ctx)
+	if err != nil {
+		log.Error("gRPC client call failed", append(fields, zap.Error(err))...)
+	} else {
+		log.Info("gRPC client call", fields...)
+	}
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ArbajAnsari19/phonic/pkg/tracing/tracingtest"
+)
+
+func TestGRPCClientRetryInterceptor(t *testing.T) {
+	cc, err := grpc.NewClient("passthrough:///moshi", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	policy := ClientRetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	tests := []struct {
+		name        string
+		interceptor grpc.UnaryClientInterceptor
+		results     []codes.Code
+		wantCalls   int
+		wantCode    codes.Code
+		wantRetries int64
+	}{
+		{"succeeds first time", GRPCClientRetryInterceptor(policy), []codes.Code{codes.OK}, 1, codes.OK, 0},
+		{"succeeds after retries", GRPCClientRetryInterceptor(policy), []codes.Code{codes.Unavailable, codes.Unavailable, codes.OK}, 3, codes.OK, 2},
+		{"gives up", GRPCClientRetryInterceptor(policy), []codes.Code{codes.Unavailable, codes.Unavailable, codes.Unavailable, codes.OK}, 3, codes.Unavailable, 2},
+		{"not retryable", GRPCClientRetryInterceptor(policy), []codes.Code{codes.InvalidArgument, codes.OK}, 1, codes.InvalidArgument, 0},
+		{"no retries by default", GRPCClientUnaryInterceptor, []codes.Code{codes.Unavailable, codes.OK}, 1, codes.Unavailable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, exporter := tracingtest.NewProvider()
+
+			var calls int
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				if len(md.Get("traceparent")) == 0 {
+					t.Error("traceparent not propagated")
+				}
+				code := tt.results[calls]
+				calls++
+				if code == codes.OK {
+					return nil
+				}
+				return status.Error(code, "injected")
+			}
+
+			err := tt.interceptor(context.Background(), "/phonic.tts.TTS/Synthesize", nil, nil, cc, invoker)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("code %v, want %v", got, tt.wantCode)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("invoked %d times, want %d", calls, tt.wantCalls)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			var retries int64
+			for _, kv := range spans[0].Attributes {
+				if kv.Key == attribute.Key("rpc.grpc.retries") {
+					retries = kv.Value.AsInt64()
+				}
+			}
+			if retries != tt.wantRetries {
+				t.Errorf("span records %d retries, want %d", retries, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestGRPCClientRetryStopsOnCancel(t *testing.T) {
+	cc, err := grpc.NewClient("passthrough:///moshi", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		cancel()
+		return status.Error(codes.Unavailable, "injected")
+	}
+
+	interceptor := GRPCClientRetryInterceptor(ClientRetryPolicy{MaxAttempts: 5, Backoff: time.Hour})
+	if err := interceptor(ctx, "/phonic.tts.TTS/Synthesize", nil, nil, cc, invoker); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want the last attempt's error", err)
+	}
+	if calls != 1 {
+		t.Errorf("invoked %d times after cancel, want 1", calls)
+	}
+}
+
+// fakeClientStream is an in-memory grpc.ClientStream whose RecvMsg
+// returns the queued results in order
+type fakeClientStream struct {
+	grpc.ClientStream
+	recv []error
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if len(s.recv) == 0 {
+		return io.EOF
+	}
+	err := s.recv[0]
+	s.recv = s.recv[1:]
+	return err
+}
+
+func (s *fakeClientStream) SendMsg(m interface{}) error { return nil }
+
+func TestGRPCClientStreamInterceptor(t *testing.T) {
+	cc, err := grpc.NewClient("passthrough:///moshi", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	tests := []struct {
+		name string
+		desc grpc.StreamDesc
+		recv []error
+		// reads is how many times the caller reads; cancel abandons the
+		// stream afterwards by cancelling its context
+		reads    int
+		cancel   bool
+		wantCode codes.Code
+	}{
+		{
+			name:     "read to EOF",
+			desc:     grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+			recv:     []error{nil, nil},
+			reads:    3,
+			wantCode: codes.OK,
+		},
+		{
+			name:     "stream fails",
+			desc:     grpc.StreamDesc{ServerStreams: true},
+			recv:     []error{nil, status.Error(codes.Unavailable, "moshi restarted")},
+			reads:    2,
+			wantCode: codes.Unavailable,
+		},
+		{
+			name:     "client streaming response",
+			desc:     grpc.StreamDesc{ClientStreams: true},
+			recv:     []error{nil},
+			reads:    1,
+			wantCode: codes.OK,
+		},
+		{
+			name:     "abandoned on cancel",
+			desc:     grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+			recv:     []error{nil, nil, nil},
+			reads:    1,
+			cancel:   true,
+			wantCode: codes.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, exporter := tracingtest.NewProvider()
+			streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &fakeClientStream{recv: tt.recv}, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cs, err := GRPCClientStreamInterceptor(ctx, &tt.desc, cc, "/phonic.stt.STT/Transcribe", streamer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range tt.reads {
+				if err := cs.RecvMsg(nil); err != nil {
+					break
+				}
+			}
+			if tt.cancel {
+				if n := len(exporter.GetSpans()); n != 0 {
+					t.Fatalf("%d spans ended while the stream is open", n)
+				}
+				cancel()
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for len(exporter.GetSpans()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			// Cancelling after the stream ended does not end the span again
+			cancel()
+			time.Sleep(5 * time.Millisecond)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			var code int64 = -1
+			for _, kv := range spans[0].Attributes {
+				if kv.Key == semconv.RPCGRPCStatusCodeKey {
+					code = kv.Value.AsInt64()
+				}
+			}
+			if codes.Code(code) != tt.wantCode {
+				t.Errorf("span status code %d, want %v", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+// CORSOptions configures the CORS middleware
+type CORSOptions struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"),
+	// wildcard subdomain patterns ("https://*.example.com") or "*"
+	AllowedOrigins []string
+	// AllowedMethods are allowed in preflight requests; GET, HEAD and POST
+	// are always allowed
+	AllowedMethods []string
+	// AllowedHeaders are request headers allowed in preflight requests;
+	// "*" allows any header
+	AllowedHeaders []string
+	// ExposedHeaders are response headers readable by scripts, e.g. X-Trace-ID
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and Authorization; it cannot be
+	// combined with a "*" origin
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORSOptionsFromConfig converts CORSConfig into CORSOptions
+func CORSOptionsFromConfig(cfg config.CORSConfig) CORSOptions {
+	return CORSOptions{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}
+}
+
+// originPattern matches an origin exactly or as a wildcard subdomain
+type originPattern struct {
+	scheme string
+	host   string
+	// suffix is ".example.com" for "*.example.com" patterns
+	suffix string
+}
+
+// matches reports whether origin (already lower-cased) matches the pattern
+func (p originPattern) matches(scheme, host string) bool {
+	if scheme != p.scheme {
+		return false
+	}
+	if p.suffix != "" {
+		return strings.HasSuffix(host, p.suffix) && len(host) > len(p.suffix)
+	}
+	return host == p.host
+}
+
+// cors is a compiled CORS policy
+type cors struct {
+	anyOrigin      bool
+	origins        []originPattern
+	methods        map[string]bool
+	allowedMethods string
+	anyHeader      bool
+	headers        map[string]bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// NewCORS builds CORS middleware. It returns an error for invalid origin
+// patterns and for credentials combined with a "*" origin, which browsers
+// reject and which would let any site make authenticated requests.
+func NewCORS(opts CORSOptions) (func(http.Handler) http.Handler, error) {
+	c := &cors{
+		methods:     map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodPost: true},
+		headers:     make(map[string]bool),
+		credentials: opts.AllowCredentials,
+	}
+
+	for _, origin := range opts.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		if origin == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		c.origins = append(c.origins, pattern)
+	}
+	if c.anyOrigin && c.credentials {
+		return nil, fmt.Errorf("cors: credentials cannot be allowed for origin \"*\"")
+	}
+
+	methods := []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	for _, method := range opts.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" || method == http.MethodOptions || c.methods[method] {
+			continue
+		}
+		c.methods[method] = true
+		methods = append(methods, method)
+	}
+	c.allowedMethods = strings.Join(methods, ", ")
+
+	for _, header := range opts.AllowedHeaders {
+		header = strings.TrimSpace(header)
+		if header == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	c.exposedHeaders = strings.Join(opts.ExposedHeaders, ", ")
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	return c.handler, nil
+}
+
+// CORS middleware handles Cross-Origin Resource Sharing. Credentials are
+// allowed unless a "*" origin is configured. It panics on invalid origin
+// patterns; use NewCORS to handle the error.
+func CORS(allowedOrigins []string, allowedMethods []string, allowedHeaders []string) func(http.Handler) http.Handler {
+	credentials := true
+	for _, origin := range allowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			credentials = false
+		}
+	}
+
+	mw, err := NewCORS(CORSOptions{
+		AllowedOrigins:   allowedOrigins,
+		AllowedMethods:   allowedMethods,
+		AllowedHeaders:   allowedHeaders,
+		AllowCredentials: credentials,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return mw
+}
+
+// parseOriginPattern parses "scheme://host[:port]" with an optional "*."
+// leading label
+func parseOriginPattern(origin string) (originPattern, error) {
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#") {
+		return originPattern{}, fmt.Errorf("cors: invalid origin %q", origin)
+	}
+	if strings.HasPrefix(host, "*.") {
+		if strings.Contains(host[2:], "*") {
+			return originPattern{}, fmt.Errorf("cors: invalid origin pattern %q", origin)
+		}
+		return originPattern{scheme: scheme, suffix: host[1:]}, nil
+	}
+	if strings.Contains(host, "*") {
+		return originPattern{}, fmt.Errorf("cors: only a leading \"*.\" wildcard is supported in %q", origin)
+	}
+	return originPattern{scheme: scheme, host: host}, nil
+}
+
+// handler wraps next with the CORS policy
+func (c *cors) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+
+		// A preflight is an OPTIONS request with Origin and
+		// Access-Control-Request-Method; any other OPTIONS request is
+		// passed through to the handler
+		if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
+			return
+		}
+
+		// Responses differ by Origin, so caches must key on it
+		w.Header().Add("Vary", "Origin")
+		if origin != "" && c.allowOrigin(origin) {
+			c.setOrigin(w.Header(), origin)
+			if c.exposedHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", c.exposedHeaders)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight answers a CORS preflight request. Disallowed requests get a
+// 204 without CORS headers so the browser blocks the actual request.
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := requestedHeaders(r.Header.Get("Access-Control-Request-Headers"))
+	if !c.allowOrigin(origin) || !c.methods[method] || !c.allowHeaders(requested) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", c.allowedMethods)
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setOrigin sets Access-Control-Allow-Origin and, when enabled,
+// Access-Control-Allow-Credentials
+func (c *cors) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowOrigin reports whether origin matches the policy
+func (c *cors) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	for _, pattern := range c.origins {
+		if pattern.matches(u.Scheme, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders reports whether every requested header is allowed
+func (c *cors) allowHeaders(requested []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, header := range requested {
+		if !c.headers[header] {
+			return false
+		}
+	}
+	return true
+}
+
+// requestedHeaders parses Access-Control-Request-Headers into canonical names
+func requestedHeaders(value string) []string {
+	var headers []string
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
+		}
+	}
+	return headers
+}
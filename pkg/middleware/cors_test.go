@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCORSValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CORSOptions
+		wantErr string
+	}{
+		{"exact origin", CORSOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, ""},
+		{"wildcard subdomain", CORSOptions{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, ""},
+		{"any origin", CORSOptions{AllowedOrigins: []string{"*"}}, ""},
+		{"credentials with any origin", CORSOptions{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}, "credentials"},
+		{"missing scheme", CORSOptions{AllowedOrigins: []string{"app.example.com"}}, "invalid origin"},
+		{"path in origin", CORSOptions{AllowedOrigins: []string{"https://app.example.com/"}}, "invalid origin"},
+		{"inner wildcard", CORSOptions{AllowedOrigins: []string{"https://app.*.com"}}, "leading"},
+		{"double wildcard", CORSOptions{AllowedOrigins: []string{"https://*.*.com"}}, "invalid origin pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCORS(tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewCORS: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewCORS error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLegacyCORS(t *testing.T) {
+	// The legacy constructor drops credentials for "*" instead of panicking
+	mw := CORS([]string{"*"}, nil, nil)
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, r)
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q with a \"*\" origin", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("CORS did not panic on an invalid origin")
+		}
+	}()
+	CORS([]string{"not-an-origin"}, nil, nil)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	explicit := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"put", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "content-type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	wildcard := CORSOptions{
+		AllowedOrigins: []string{"*"},
+		AllowedHeaders: []string{"*"},
+	}
+
+	tests := []struct {
+		name        string
+		opts        CORSOptions
+		origin      string
+		method      string
+		headers     string
+		wantOrigin  string
+		wantCreds   string
+		wantHeaders string
+		wantMaxAge  string
+	}{
+		{"exact origin allowed", explicit, "https://app.example.com", "PUT", "authorization, Content-Type", "https://app.example.com", "true", "Authorization, Content-Type", "600"},
+		{"origin is case-insensitive", explicit, "HTTPS://APP.EXAMPLE.COM", "DELETE", "", "HTTPS://APP.EXAMPLE.COM", "true", "", "600"},
+		{"wildcard subdomain allowed", explicit, "https://eu.api.example.org", "GET", "", "https://eu.api.example.org", "true", "", "600"},
+		{"wildcard excludes bare domain", explicit, "https://example.org", "GET", "", "", "", "", ""},
+		{"scheme must match", explicit, "http://app.example.com", "GET", "", "", "", "", ""},
+		{"unknown origin denied", explicit, "https://evil.example", "GET", "", "", "", "", ""},
+		{"method denied", explicit, "https://app.example.com", "PATCH", "", "", "", "", ""},
+		{"header denied", explicit, "https://app.example.com", "PUT", "X-Secret", "", "", "", ""},
+		{"any origin", wildcard, "https://anything.example", "POST", "X-Custom", "*", "", "X-Custom", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := NewCORS(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			called := false
+			handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+			r := httptest.NewRequest(http.MethodOptions, "/calls", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if called {
+				t.Error("preflight reached the handler")
+			}
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("status %d, want 204", rec.Code)
+			}
+			h := rec.Header()
+			checkHeader(t, h, "Access-Control-Allow-Origin", tt.wantOrigin)
+			checkHeader(t, h, "Access-Control-Allow-Credentials", tt.wantCreds)
+			checkHeader(t, h, "Access-Control-Allow-Headers", tt.wantHeaders)
+			checkHeader(t, h, "Access-Control-Max-Age", tt.wantMaxAge)
+			if tt.wantOrigin != "" {
+				if methods := h.Get("Access-Control-Allow-Methods"); !strings.Contains(methods, "GET") || strings.Contains(methods, "OPTIONS") {
+					t.Errorf("Access-Control-Allow-Methods = %q", methods)
+				}
+			}
+			vary := strings.Join(h.Values("Vary"), ", ")
+			for _, want := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+				if !strings.Contains(vary, want) {
+					t.Errorf("Vary %q is missing %s", vary, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	explicit := CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		ExposedHeaders:   []string{"X-Trace-ID", "Retry-After"},
+		AllowCredentials: true,
+	}
+	wildcard := CORSOptions{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Trace-ID"}}
+
+	tests := []struct {
+		name        string
+		opts        CORSOptions
+		method      string
+		origin      string
+		wantOrigin  string
+		wantCreds   string
+		wantExposed string
+	}{
+		{"allowed origin", explicit, http.MethodGet, "https://app.example.com", "https://app.example.com", "true", "X-Trace-ID, Retry-After"},
+		{"denied origin", explicit, http.MethodPost, "https://evil.example", "", "", ""},
+		{"no origin", explicit, http.MethodGet, "", "", "", ""},
+		{"any origin", wildcard, http.MethodGet, "https://evil.example", "*", "", "X-Trace-ID"},
+		{"plain OPTIONS passes through", explicit, http.MethodOptions, "https://app.example.com", "https://app.example.com", "true", "X-Trace-ID, Retry-After"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := NewCORS(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			r := httptest.NewRequest(tt.method, "/calls", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != http.StatusTeapot {
+				t.Errorf("status %d; the handler did not run", rec.Code)
+			}
+			h := rec.Header()
+			checkHeader(t, h, "Access-Control-Allow-Origin", tt.wantOrigin)
+			checkHeader(t, h, "Access-Control-Allow-Credentials", tt.wantCreds)
+			checkHeader(t, h, "Access-Control-Expose-Headers", tt.wantExposed)
+			checkHeader(t, h, "Access-Control-Max-Age", "")
+			if got := h.Values("Vary"); len(got) != 1 || got[0] != "Origin" {
+				t.Errorf("Vary = %v, want [Origin]", got)
+			}
+		})
+	}
+}
+
+func checkHeader(t *testing.T, h http.Header, name, want string) {
+	t.Helper()
+	if got := h.Get(name); got != want {
+		t.Errorf("%s = %q, want %q", name, got, want)
+	}
+}
@@ -0,0 +1,362 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/ArbajAnsari19/phonic/pkg/idempotency"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// IdempotencyKeyHeader carries the client-chosen idempotency key; gRPC
+// clients send it as idempotency-key metadata
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks replayed responses
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds client keys
+const maxIdempotencyKeyLength = 255
+
+// idempotencyTimeout bounds storing the response after the handler returns
+const idempotencyTimeout = 5 * time.Second
+
+// Idempotency middleware honors the Idempotency-Key header on POST, PUT,
+// PATCH and DELETE requests. The first response for a key is stored and
+// replayed for retries within the store's window; concurrent duplicates
+// wait for the original to finish. The key's lock is renewed while the
+// handler runs; if it is lost the handler's context is cancelled so a
+// duplicate never runs alongside it. Keys are scoped per tenant, so it must
+// run after authentication. A key reused for a different method, path,
+// query or body gets 422, and a duplicate that times out waiting gets 409. Server
+// errors and transient statuses are not stored, so they can be retried.
+// Store errors fail open and are logged.
+func Idempotency(store *idempotency.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !idempotentMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := r.Context()
+			log := logger.WithContext(ctx)
+			fingerprint := idempotency.Fingerprint([]byte(r.Method), []byte(r.URL.Path), []byte(r.URL.RawQuery), body)
+
+			lock, record, err := store.Acquire(ctx, idempotencyStoreKey(KeyByTenant(r), key), fingerprint)
+			switch {
+			case errors.Is(err, idempotency.ErrKeyReused):
+				log.Warn("Idempotency key reused with a different request", zap.String("path", r.URL.Path))
+				http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+				return
+			case errors.Is(err, idempotency.ErrInProgress):
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+				return
+			case err != nil && ctx.Err() != nil:
+				return
+			case err != nil:
+				log.Error("Idempotency store unavailable, processing request", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			case record != nil:
+				log.Info("Replaying idempotent response", zap.Int("status_code", record.StatusCode))
+				h := w.Header()
+				for name, values := range record.Header {
+					h[name] = values
+				}
+				h.Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.Body)
+				return
+			}
+
+			iw := &idempotentWriter{
+				ResponseWriter: w,
+				before:         w.Header().Clone(),
+				limit:          store.Options().MaxResponseBytes,
+			}
+			handlerCtx, stop := lock.KeepAlive(ctx)
+			completed := false
+			defer func() {
+				// Runs on panics too, releasing the key for a retry
+				stop()
+				if errors.Is(context.Cause(handlerCtx), idempotency.ErrLockLost) {
+					log.Error("Idempotency lock lost while the request was running")
+					completed = false
+				}
+				storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyTimeout)
+				defer cancel()
+
+				var err error
+				if completed && iw.storable() {
+					err = lock.Complete(storeCtx, iw.record())
+				} else {
+					err = lock.Release(storeCtx)
+				}
+				if err != nil {
+					log.Error("Failed to finish idempotent request", zap.Error(err))
+				}
+			}()
+
+			next.ServeHTTP(iw, r.WithContext(handlerCtx))
+			completed = true
+		})
+	}
+}
+
+// GRPCIdempotencyInterceptor honors idempotency-key metadata for unary
+// calls with the same semantics as Idempotency. Replies are stored as
+// protobuf Any messages; a key reused for a different request gets
+// FAILED_PRECONDITION and a duplicate that times out waiting gets ABORTED.
+func GRPCIdempotencyInterceptor(store *idempotency.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		key := firstValue(md, "idempotency-key")
+		msg, ok := req.(proto.Message)
+		if key == "" || !ok {
+			return handler(ctx, req)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, status.Error(codes.InvalidArgument, "idempotency-key is too long")
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "failed to marshal request")
+		}
+
+		log := logger.WithContext(ctx)
+		fingerprint := idempotency.Fingerprint([]byte(info.FullMethod), body)
+
+		lock, record, err := store.Acquire(ctx, idempotencyStoreKey(GRPCKeyByTenant(ctx), key), fingerprint)
+		switch {
+		case errors.Is(err, idempotency.ErrKeyReused):
+			log.Warn("Idempotency key reused with a different request", zap.String("method", info.FullMethod))
+			return nil, status.Error(codes.FailedPrecondition, "idempotency-key was used for a different request")
+		case errors.Is(err, idempotency.ErrInProgress):
+			return nil, status.Error(codes.Aborted, "a request with this idempotency-key is in progress")
+		case err != nil && ctx.Err() != nil:
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case err != nil:
+			log.Error("Idempotency store unavailable, processing request", zap.Error(err))
+			return handler(ctx, req)
+		case record != nil:
+			log.Info("Replaying idempotent response", zap.String("method", info.FullMethod))
+			grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedHeader, "true"))
+			return replayGRPC(record)
+		}
+
+		// result stays nil on panics and non-replayable errors
+		handlerCtx, stop := lock.KeepAlive(ctx)
+		var result *idempotency.Record
+		defer func() {
+			stop()
+			if errors.Is(context.Cause(handlerCtx), idempotency.ErrLockLost) {
+				log.Error("Idempotency lock lost while the request was running")
+				result = nil
+			}
+			storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), idempotencyTimeout)
+			defer cancel()
+
+			var err error
+			if result != nil {
+				err = lock.Complete(storeCtx, result)
+			} else {
+				err = lock.Release(storeCtx)
+			}
+			if err != nil {
+				log.Error("Failed to finish idempotent request", zap.Error(err))
+			}
+		}()
+
+		resp, err := handler(handlerCtx, req)
+		result = grpcRecord(resp, err)
+		return resp, err
+	}
+}
+
+// idempotentMethod reports whether requests with method are deduplicated
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyStoreKey combines the caller scope with a hash of the client
+// key, keeping Redis key names bounded
+func idempotencyStoreKey(scope, key string) string {
+	return scope + ":" + idempotency.Fingerprint([]byte(key))[:32]
+}
+
+// transientStatus reports whether an HTTP status should not be replayed
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return code >= http.StatusInternalServerError
+}
+
+// grpcRecord converts a unary result into a record, or nil when the result
+// should not be replayed
+func grpcRecord(resp interface{}, err error) *idempotency.Record {
+	if err != nil {
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+			codes.FailedPrecondition, codes.OutOfRange, codes.Unimplemented:
+			return &idempotency.Record{StatusCode: int(st.Code()), Message: st.Message()}
+		}
+		return nil
+	}
+
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return nil
+	}
+	reply, err := anypb.New(msg)
+	if err != nil {
+		return nil
+	}
+	return &idempotency.Record{StatusCode: int(codes.OK), TypeURL: reply.TypeUrl, Body: reply.Value}
+}
+
+// replayGRPC returns the stored reply or status
+func replayGRPC(record *idempotency.Record) (interface{}, error) {
+	if code := codes.Code(record.StatusCode); code != codes.OK {
+		return nil, status.Error(code, record.Message)
+	}
+	reply, err := (&anypb.Any{TypeUrl: record.TypeURL, Value: record.Body}).UnmarshalNew()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to decode stored response")
+	}
+	return reply, nil
+}
+
+// idempotentWriter captures the response for storage while writing it
+// through. Only headers the handler set or changed are kept, so trace,
+// request ID and CORS headers from outer middleware are not replayed.
+type idempotentWriter struct {
+	http.ResponseWriter
+	before      http.Header
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	limit       int
+	overflow    bool
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (iw *idempotentWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+func (iw *idempotentWriter) WriteHeader(code int) {
+	if !iw.wroteHeader && code >= http.StatusOK {
+		iw.wroteHeader = true
+		iw.status = code
+		iw.header = changedHeaders(iw.before, iw.ResponseWriter.Header())
+	}
+	iw.ResponseWriter.WriteHeader(code)
+}
+
+func (iw *idempotentWriter) Write(b []byte) (int, error) {
+	if !iw.wroteHeader {
+		iw.WriteHeader(http.StatusOK)
+	}
+	if !iw.overflow {
+		if iw.body.Len()+len(b) > iw.limit {
+			iw.overflow = true
+			iw.body.Reset()
+		} else {
+			iw.body.Write(b)
+		}
+	}
+	return iw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher. Flushed responses are streams, which are
+// not stored.
+func (iw *idempotentWriter) Flush() {
+	if f, ok := iw.ResponseWriter.(http.Flusher); ok {
+		if !iw.wroteHeader {
+			iw.WriteHeader(http.StatusOK)
+		}
+		iw.overflow = true
+		f.Flush()
+	}
+}
+
+// storable reports whether the captured response should be replayed
+func (iw *idempotentWriter) storable() bool {
+	return !iw.overflow && !transientStatus(iw.statusCode())
+}
+
+// statusCode returns the captured status; handlers that write nothing
+// respond 200
+func (iw *idempotentWriter) statusCode() int {
+	if !iw.wroteHeader {
+		return http.StatusOK
+	}
+	return iw.status
+}
+
+// record returns the captured response
+func (iw *idempotentWriter) record() *idempotency.Record {
+	header := iw.header
+	if !iw.wroteHeader {
+		header = changedHeaders(iw.before, iw.ResponseWriter.Header())
+	}
+	return &idempotency.Record{
+		StatusCode: iw.statusCode(),
+		Header:     header,
+		Body:       iw.body.Bytes(),
+	}
+}
+
+// changedHeaders returns the headers in after that are new or differ from
+// before
+func changedHeaders(before, after http.Header) http.Header {
+	changed := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			changed[name] = slices.Clone(values)
+		}
+	}
+	return changed
+}
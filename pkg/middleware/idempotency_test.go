@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/ArbajAnsari19/phonic/pkg/idempotency"
+)
+
+func newIdempotencyStore(t *testing.T, opts idempotency.Options) *idempotency.Store {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return idempotency.NewStore(client, opts)
+}
+
+func idempotentRequest(target, key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	return r
+}
+
+func TestIdempotencyFingerprint(t *testing.T) {
+	store := newIdempotencyStore(t, idempotency.Options{LockTimeout: time.Second})
+	var calls atomic.Int32
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		want     int
+		replayed bool
+	}{
+		{"first request", "/calls?dry_run=true", "{}", http.StatusCreated, false},
+		{"retry is replayed", "/calls?dry_run=true", "{}", http.StatusCreated, true},
+		{"different query", "/calls?dry_run=false", "{}", http.StatusUnprocessableEntity, false},
+		{"no query", "/calls", "{}", http.StatusUnprocessableEntity, false},
+		{"different body", "/calls?dry_run=true", `{"a":1}`, http.StatusUnprocessableEntity, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, idempotentRequest(tt.target, "key-1", tt.body))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get(IdempotentReplayedHeader) == "true"; got != tt.replayed {
+				t.Errorf("replayed = %v, want %v", got, tt.replayed)
+			}
+		})
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestIdempotencySlowHandlerRunsOnce(t *testing.T) {
+	const lockTimeout = 60 * time.Millisecond
+	store := newIdempotencyStore(t, idempotency.Options{LockTimeout: lockTimeout, PollInterval: 5 * time.Millisecond})
+
+	var calls atomic.Int32
+	started := make(chan struct{})
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		// Outlive the lock timeout several times over
+		select {
+		case <-time.After(4 * lockTimeout):
+		case <-r.Context().Done():
+			t.Error("handler cancelled while holding a renewed lock")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	var wg sync.WaitGroup
+	codes := make([]int, 3)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i > 0 {
+				<-started
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, idempotentRequest("/calls", "key-1", "{}"))
+			codes[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	if codes[0] != http.StatusCreated {
+		t.Errorf("original got %d, want 201", codes[0])
+	}
+	for _, code := range codes[1:] {
+		if code != http.StatusConflict {
+			t.Errorf("duplicate got %d, want 409 while the original runs", code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, idempotentRequest("/calls", "key-1", "{}"))
+	if rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry after completion got %d, want a replayed 201", rec.Code)
+	}
+}
@@ -0,0 +1,478 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+)
+
+// DefaultMaxBodyBytes is the request body limit when none is configured
+const DefaultMaxBodyBytes = 1 << 20
+
+// timeoutGrace is how long a handler may take to return after its deadline
+// before the request is abandoned
+const timeoutGrace = 100 * time.Millisecond
+
+// RouteLimits bounds the request body and handler time of a route. Zero
+// fields inherit the default; negative values disable the limit.
+type RouteLimits struct {
+	MaxBodyBytes int64
+	Timeout      time.Duration
+}
+
+// LimitOptions configures the Limits middleware
+type LimitOptions struct {
+	// Default applies to requests without a matching route
+	Default RouteLimits
+	// Routes maps "[METHOD ]/path" to limits, e.g. "POST /v1/audio/". A
+	// path ending in "/" matches everything below it. The longest path
+	// wins, and an entry with a method wins over one without.
+	Routes map[string]RouteLimits
+}
+
+// LimitOptionsFromConfig uses endpoint.Timeout as the default handler
+// deadline and DefaultMaxBodyBytes as the default body limit
+func LimitOptionsFromConfig(endpoint config.ServiceEndpoint) LimitOptions {
+	return LimitOptions{
+		Default: RouteLimits{
+			MaxBodyBytes: DefaultMaxBodyBytes,
+			Timeout:      endpoint.Timeout,
+		},
+	}
+}
+
+// routeLimit is a compiled Routes entry
+type routeLimit struct {
+	method string
+	path   string
+	limits RouteLimits
+}
+
+// matches reports whether the entry applies to method and path
+func (rl routeLimit) matches(method, path string) bool {
+	if rl.method != "" && rl.method != method {
+		return false
+	}
+	return matchPrefixes(path, []string{rl.path})
+}
+
+// Limits middleware enforces per-route request body limits and handler
+// deadlines. Bodies over the limit get 413, rejected up front when
+// Content-Length is known and otherwise when the handler returns without
+// responding after a read failed with *http.MaxBytesError. Handlers run
+// with a context deadline; one that returns after its deadline without
+// responding gets 504, and one still running shortly after it gets 503.
+// Error bodies carry the trace ID. WebSocket upgrades and event streams
+// have no deadline. The applied limits and outcome are added to the
+// HTTPTracing log line.
+func Limits(opts LimitOptions) func(http.Handler) http.Handler {
+	routes := make([]routeLimit, 0, len(opts.Routes))
+	for pattern, limits := range opts.Routes {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		}
+		routes = append(routes, routeLimit{
+			method: strings.ToUpper(method),
+			path:   strings.TrimSpace(path),
+			limits: limits,
+		})
+	}
+	// Most specific first: longer paths, then entries with a method
+	sort.Slice(routes, func(i, j int) bool {
+		if len(routes[i].path) != len(routes[j].path) {
+			return len(routes[i].path) > len(routes[j].path)
+		}
+		return routes[i].method > routes[j].method
+	})
+
+	resolve := func(r *http.Request) RouteLimits {
+		limits := opts.Default
+		for _, route := range routes {
+			if route.matches(r.Method, r.URL.Path) {
+				if route.limits.MaxBodyBytes != 0 {
+					limits.MaxBodyBytes = route.limits.MaxBodyBytes
+				}
+				if route.limits.Timeout != 0 {
+					limits.Timeout = route.limits.Timeout
+				}
+				break
+			}
+		}
+		if isStreamingRequest(r) {
+			limits.Timeout = 0
+		}
+		return limits
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limits := resolve(r)
+			state := requestLimitsFrom(r.Context())
+			state.set(limits)
+
+			if limits.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+				if r.ContentLength > limits.MaxBodyBytes {
+					state.noteBodyTooLarge()
+					writeLimitError(w, r, http.StatusRequestEntityTooLarge)
+					return
+				}
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes), state: state}
+			}
+
+			if limits.Timeout <= 0 {
+				rw := wrapResponseWriter(w)
+				next.ServeHTTP(rw, r)
+				if !rw.wroteHeader && state.isBodyTooLarge() {
+					writeLimitError(w, r, http.StatusRequestEntityTooLarge)
+				}
+				return
+			}
+
+			serveWithTimeout(w, r, next, limits.Timeout, state)
+		})
+	}
+}
+
+// serveWithTimeout runs next in its own goroutine with a deadline of
+// timeout, answering for it if it overruns. A client disconnect cancels
+// the handler's context too, but is not a timeout: nothing is written and
+// no timeout is logged.
+func serveWithTimeout(w http.ResponseWriter, r *http.Request, next http.Handler, timeout time.Duration, state *requestLimits) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	tw := &timeoutWriter{w: w, header: w.Header().Clone()}
+	done := make(chan struct{})
+	panicked := make(chan *handlerPanic, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- &handlerPanic{value: p, stack: debug.Stack()}
+				return
+			}
+			close(done)
+		}()
+		next.ServeHTTP(tw, r.WithContext(ctx))
+	}()
+
+	select {
+	case p := <-panicked:
+		p.repanic()
+	case <-done:
+	case <-ctx.Done():
+		// Give a handler that watches its context a moment to return
+		grace := time.NewTimer(timeoutGrace)
+		defer grace.Stop()
+		select {
+		case p := <-panicked:
+			p.repanic()
+		case <-done:
+		case <-grace.C:
+			if tw.abandon() {
+				// A hijacked connection belongs to the handler
+				select {
+				case p := <-panicked:
+					p.repanic()
+				case <-done:
+				}
+				return
+			}
+			if errors.Is(ctx.Err(), context.Canceled) {
+				// The client went away; nobody is waiting for a response
+				logger.WithContext(ctx).Debug("HTTP handler still running after the client disconnected",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+				)
+				return
+			}
+			state.noteTimedOut()
+			logger.WithContext(ctx).Warn("HTTP handler timed out",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Duration("timeout", timeout),
+			)
+			if !tw.wroteHeader {
+				writeLimitError(w, r, http.StatusServiceUnavailable)
+			}
+			return
+		}
+	}
+
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.wroteHeader || tw.hijacked {
+		return
+	}
+	switch {
+	case state.isBodyTooLarge():
+		writeLimitError(w, r, http.StatusRequestEntityTooLarge)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		state.noteTimedOut()
+		writeLimitError(w, r, http.StatusGatewayTimeout)
+	case errors.Is(ctx.Err(), context.Canceled):
+		// The client went away while the handler ran; there is no one to
+		// answer
+	default:
+		// The handler responded with an empty 200; keep its headers
+		copyHeader(w.Header(), tw.header)
+	}
+}
+
+// isStreamingRequest reports whether r opens a long-lived stream, such as
+// a WebSocket upgrade or Server-Sent Events
+func isStreamingRequest(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// writeLimitError writes an error response that includes the trace ID
+func writeLimitError(w http.ResponseWriter, r *http.Request, code int) {
+	message := http.StatusText(code)
+	if traceID, ok := r.Context().Value(logger.TraceIDKey).(string); ok && traceID != "" {
+		if w.Header().Get(tracing.HeaderTraceID) == "" {
+			w.Header().Set(tracing.HeaderTraceID, traceID)
+		}
+		message = fmt.Sprintf("%s (trace_id=%s)", message, traceID)
+	}
+	if code == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, message, code)
+}
+
+// copyHeader replaces dst's values with src's
+func copyHeader(dst, src http.Header) {
+	for name := range dst {
+		if _, ok := src[name]; !ok {
+			delete(dst, name)
+		}
+	}
+	for name, values := range src {
+		dst[name] = values
+	}
+}
+
+// limitedBody records when the body limit is exceeded
+type limitedBody struct {
+	io.ReadCloser
+	state *requestLimits
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if err != nil && errors.As(err, &maxErr) {
+		b.state.noteBodyTooLarge()
+	}
+	return n, err
+}
+
+// timeoutWriter lets a handler running in its own goroutine write until
+// it is abandoned. The handler gets its own header map, copied to the real
+// writer when it writes the header, so the timeout response never races
+// with it.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+	timedOut    bool
+	hijacked    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// Flush implements http.Flusher
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		if !tw.wroteHeader {
+			tw.writeHeaderLocked(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker; a hijacked connection is never abandoned
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := tw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking: %w", http.ErrNotSupported)
+	}
+	copyHeader(tw.w.Header(), tw.header)
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		tw.hijacked = true
+	}
+	return conn, buf, err
+}
+
+// writeHeaderLocked sends the handler's header; tw.mu must be held
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	if code >= http.StatusOK {
+		tw.wroteHeader = true
+	}
+	copyHeader(tw.w.Header(), tw.header)
+	tw.w.WriteHeader(code)
+}
+
+// abandon stops further writes and reports whether the connection was
+// hijacked, in which case it is left to the handler
+func (tw *timeoutWriter) abandon() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.hijacked {
+		return true
+	}
+	tw.timedOut = true
+	return false
+}
+
+// handlerPanic carries a panic from a handler goroutine, with its stack,
+// to the request goroutine where Recovery can handle it
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// repanic panics on the calling goroutine; http.ErrAbortHandler is passed
+// on unchanged so net/http still aborts the response silently
+func (p *handlerPanic) repanic() {
+	if p.value == http.ErrAbortHandler {
+		panic(p.value)
+	}
+	panic(p)
+}
+
+func (p *handlerPanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+// requestLimitsKey is the context key for requestLimits
+type requestLimitsKey struct{}
+
+// requestLimits records the limits applied to a request and whether they
+// were hit, for the HTTPTracing log line
+type requestLimits struct {
+	mu           sync.Mutex
+	applied      bool
+	limits       RouteLimits
+	bodyTooLarge bool
+	timedOut     bool
+}
+
+// withRequestLimits adds an empty requestLimits to ctx
+func withRequestLimits(ctx context.Context) (context.Context, *requestLimits) {
+	state := &requestLimits{}
+	return context.WithValue(ctx, requestLimitsKey{}, state), state
+}
+
+// requestLimitsFrom returns the requestLimits in ctx, or a detached one
+// when tracing does not run outside Limits
+func requestLimitsFrom(ctx context.Context) *requestLimits {
+	if state, ok := ctx.Value(requestLimitsKey{}).(*requestLimits); ok {
+		return state
+	}
+	return &requestLimits{}
+}
+
+func (s *requestLimits) set(limits RouteLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied = true
+	s.limits = limits
+}
+
+func (s *requestLimits) noteBodyTooLarge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bodyTooLarge = true
+}
+
+func (s *requestLimits) noteTimedOut() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timedOut = true
+}
+
+func (s *requestLimits) isBodyTooLarge() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodyTooLarge
+}
+
+// fields returns log fields describing the applied limits
+func (s *requestLimits) fields() []zap.Field {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.applied {
+		return nil
+	}
+	var fields []zap.Field
+	if s.limits.MaxBodyBytes > 0 {
+		fields = append(fields, zap.Int64("max_body_bytes", s.limits.MaxBodyBytes))
+	}
+	if s.limits.Timeout > 0 {
+		fields = append(fields, zap.Duration("timeout", s.limits.Timeout))
+	}
+	if s.bodyTooLarge {
+		fields = append(fields, zap.Bool("body_too_large", true))
+	}
+	if s.timedOut {
+		fields = append(fields, zap.Bool("timed_out", true))
+	}
+	return fields
+}
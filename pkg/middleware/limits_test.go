@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsTimeouts(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	tests := []struct {
+		name string
+		// handler misbehaves in the way under test
+		handler http.HandlerFunc
+		// disconnect cancels the request context after this long; 0 never
+		disconnect   time.Duration
+		wantCode     int
+		wantTimedOut bool
+	}{
+		{
+			name:     "fast handler",
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) },
+			wantCode: http.StatusCreated,
+		},
+		{
+			name: "handler returns at its deadline",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			wantCode:     http.StatusGatewayTimeout,
+			wantTimedOut: true,
+		},
+		{
+			name: "handler ignores its deadline",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(timeout + 3*timeoutGrace)
+			},
+			wantCode:     http.StatusServiceUnavailable,
+			wantTimedOut: true,
+		},
+		{
+			name: "client disconnects, handler returns",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			disconnect: timeout / 4,
+		},
+		{
+			name: "client disconnects, handler keeps running",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(timeout + 3*timeoutGrace)
+			},
+			disconnect: timeout / 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Limits(LimitOptions{Default: RouteLimits{Timeout: timeout}})(tt.handler)
+
+			ctx, state := withRequestLimits(context.Background())
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			if tt.disconnect > 0 {
+				time.AfterFunc(tt.disconnect, cancel)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/calls", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if tt.wantCode == 0 {
+				if rec.Body.Len() > 0 || rec.Code != http.StatusOK {
+					t.Errorf("wrote %d %q to a disconnected client", rec.Code, rec.Body.String())
+				}
+			} else if rec.Code != tt.wantCode {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantCode)
+			}
+			state.mu.Lock()
+			timedOut := state.timedOut
+			state.mu.Unlock()
+			if timedOut != tt.wantTimedOut {
+				t.Errorf("timed out = %v, want %v", timedOut, tt.wantTimedOut)
+			}
+		})
+	}
+}
+
+func TestLimitsBodySize(t *testing.T) {
+	handler := Limits(LimitOptions{
+		Default: RouteLimits{MaxBodyBytes: 8},
+		Routes:  map[string]RouteLimits{"POST /v1/audio/": {MaxBodyBytes: 64}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 128)
+		for {
+			if _, err := r.Body.Read(buf); err != nil {
+				break
+			}
+		}
+	}))
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		length bool
+		want   int
+	}{
+		{"within limit", "/calls", "small", true, http.StatusOK},
+		{"over limit with length", "/calls", strings.Repeat("x", 9), true, http.StatusRequestEntityTooLarge},
+		{"over limit while reading", "/calls", strings.Repeat("x", 9), false, http.StatusRequestEntityTooLarge},
+		{"route override", "/v1/audio/upload", strings.Repeat("x", 60), true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if !tt.length {
+				r.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
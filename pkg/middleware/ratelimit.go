@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/ratelimit"
+)
+
+// KeyFunc derives the rate limit key for an HTTP request
+type KeyFunc func(r *http.Request) string
+
+// GRPCKeyFunc derives the rate limit key for a gRPC call
+type GRPCKeyFunc func(ctx context.Context) string
+
+// KeyByIP limits per client IP
+func KeyByIP(r *http.Request) string {
+	return "ip:" + hostOnly(r.RemoteAddr)
+}
+
+// KeyByPrincipal limits per authenticated caller, so every API key and
+// token of one user shares a bucket, falling back to the client IP. It
+// must run after authentication; unverified credentials are never used.
+func KeyByPrincipal(r *http.Request) string {
+	if key, ok := principalBucket(r.Context()); ok {
+		return key
+	}
+	return KeyByIP(r)
+}
+
+// KeyByTenant limits per tenant from the request context, falling back to
+// the client IP. It must run after authentication.
+func KeyByTenant(r *http.Request) string {
+	if tenant, ok := r.Context().Value(logger.TenantIDKey).(string); ok && tenant != "" {
+		return "tenant:" + tenant
+	}
+	return KeyByIP(r)
+}
+
+// GRPCKeyByPeer limits per client address
+func GRPCKeyByPeer(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return "ip:" + hostOnly(p.Addr.String())
+	}
+	return "ip:unknown"
+}
+
+// GRPCKeyByPrincipal limits per authenticated caller, falling back to the
+// peer. It must run after authentication.
+func GRPCKeyByPrincipal(ctx context.Context) string {
+	if key, ok := principalBucket(ctx); ok {
+		return key
+	}
+	return GRPCKeyByPeer(ctx)
+}
+
+// GRPCKeyByTenant limits per tenant from the context, falling back to the peer
+func GRPCKeyByTenant(ctx context.Context) string {
+	if tenant, ok := ctx.Value(logger.TenantIDKey).(string); ok && tenant != "" {
+		return "tenant:" + tenant
+	}
+	return GRPCKeyByPeer(ctx)
+}
+
+// principalBucket returns the bucket key for the verified claims in ctx:
+// the tenant and subject, or the credential ID when there is no subject
+func principalBucket(ctx context.Context) (string, bool) {
+	claims, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	switch {
+	case claims.Subject != "":
+		return "principal:" + claims.TenantID + ":" + claims.Subject, true
+	case claims.ID != "":
+		return "credential:" + claims.TenantID + ":" + claims.ID, true
+	}
+	return "", false
+}
+
+// RateLimit middleware enforces limiter per key. Denied requests get 429
+// with Retry-After; every response carries RateLimit-* headers. Limiter
+// errors (e.g. Redis unavailable) fail open and are logged.
+func RateLimit(limiter ratelimit.Limiter, keyFunc KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			result, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.WithContext(r.Context()).Error("Rate limiter unavailable, allowing request",
+					zap.String("key", key),
+					zap.Error(err),
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w.Header(), result)
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				logger.WithContext(r.Context()).Warn("Rate limit exceeded",
+					zap.String("key", key),
+					zap.String("path", r.URL.Path),
+				)
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GRPCRateLimitInterceptor enforces limiter per key for unary calls,
+// answering RESOURCE_EXHAUSTED with retry-after and ratelimit-* trailers
+func GRPCRateLimitInterceptor(limiter ratelimit.Limiter, keyFunc GRPCKeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkGRPCRateLimit(ctx, limiter, keyFunc, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// GRPCRateLimitStreamInterceptor enforces limiter per key when a stream opens
+func GRPCRateLimitStreamInterceptor(limiter ratelimit.Limiter, keyFunc GRPCKeyFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkGRPCRateLimit(ss.Context(), limiter, keyFunc, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// checkGRPCRateLimit takes a token for the call and returns a status error when denied
+func checkGRPCRateLimit(ctx context.Context, limiter ratelimit.Limiter, keyFunc GRPCKeyFunc, method string) error {
+	key := keyFunc(ctx)
+	result, err := limiter.Allow(ctx, key)
+	if err != nil {
+		logger.WithContext(ctx).Error("Rate limiter unavailable, allowing call",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(result.Limit),
+		"ratelimit-remaining", strconv.Itoa(result.Remaining),
+		"ratelimit-reset", strconv.Itoa(ceilSeconds(result.ResetAfter)),
+	)
+
+	if result.Allowed {
+		_ = grpc.SetHeader(ctx, md)
+		return nil
+	}
+
+	md.Set("retry-after", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	_ = grpc.SetTrailer(ctx, md)
+
+	logger.WithContext(ctx).Warn("Rate limit exceeded",
+		zap.String("key", key),
+		zap.String("method", method),
+	)
+	return status.Error(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry after %s", result.RetryAfter.Round(time.Millisecond)))
+}
+
+// setRateLimitHeaders writes the IETF RateLimit-* headers
+func setRateLimitHeaders(h http.Header, result ratelimit.Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
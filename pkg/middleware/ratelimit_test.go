@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArbajAnsari19/phonic/pkg/auth"
+)
+
+func TestKeyByPrincipal(t *testing.T) {
+	apiKey := (&auth.APIKey{ID: "key-1", TenantID: "acme", UserID: "alice"}).Claims()
+	service := (&auth.APIKey{ID: "key-2", TenantID: "acme"}).Claims()
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		header string
+		want   string
+	}{
+		{"user", apiKey, "", "principal:acme:alice"},
+		{"keys of one user share a bucket", (&auth.APIKey{ID: "key-3", TenantID: "acme", UserID: "alice"}).Claims(), "", "principal:acme:alice"},
+		{"no subject uses the credential", service, "", "credential:acme:key-2"},
+		{"unverified header is ignored", nil, "pk_live_forged", "ip:192.0.2.1"},
+		{"anonymous", nil, "", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/calls", nil)
+			if tt.header != "" {
+				r.Header.Set("X-API-Key", tt.header)
+			}
+			if tt.claims != nil {
+				r = r.WithContext(auth.WithClaims(r.Context(), tt.claims))
+			}
+			if got := KeyByPrincipal(r); got != tt.want {
+				t.Errorf("KeyByPrincipal = %q, want %q", got, tt.want)
+			}
+
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = auth.WithClaims(ctx, tt.claims)
+			}
+			if got := GRPCKeyByPrincipal(ctx); tt.claims != nil && got != tt.want {
+				t.Errorf("GRPCKeyByPrincipal = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// responseWriter wraps http.ResponseWriter to capture the status code,
+// bytes written and time to first byte. It implements http.Flusher,
+// http.Hijacker and io.ReaderFrom by delegating to the wrapped writer, and
+// Unwrap for http.ResponseController.
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	bytes       int64
+	start       time.Time
+	firstByte   time.Duration
+}
+
+// wrapResponseWriter wraps w, reusing w when it is already wrapped so
+// stacked middleware share one set of counters
+func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w, statusCode: http.StatusOK, start: time.Now()}
+}
+
+// Status returns the response status code
+func (rw *responseWriter) Status() int {
+	return rw.statusCode
+}
+
+// BytesWritten returns the number of body bytes written
+func (rw *responseWriter) BytesWritten() int64 {
+	return rw.bytes
+}
+
+// TimeToFirstByte returns the time from wrapping until headers were sent,
+// or zero if nothing was sent
+func (rw *responseWriter) TimeToFirstByte() time.Duration {
+	return rw.firstByte
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	// Informational responses (e.g. 103 Early Hints) are not final
+	if !rw.wroteHeader && code >= http.StatusOK {
+		rw.statusCode = code
+		rw.markWritten()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.markWritten()
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom lets io.Copy use the wrapped writer's sendfile path
+func (rw *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	rw.markWritten()
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{rw.ResponseWriter}, src)
+	}
+	rw.bytes += n
+	return n, err
+}
+
+// Flush implements http.Flusher for streaming responses such as SSE. It is
+// a no-op when the wrapped writer cannot flush.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		rw.markWritten()
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for WebSocket upgrades
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking: %w", http.ErrNotSupported)
+	}
+	conn, buf, err := h.Hijack()
+	if err == nil && !rw.wroteHeader {
+		// The handler now owns the connection and writes its own status
+		// line, normally 101 Switching Protocols
+		rw.statusCode = http.StatusSwitchingProtocols
+		rw.markWritten()
+	}
+	return conn, buf, err
+}
+
+// markWritten records that headers have been sent
+func (rw *responseWriter) markWritten() {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.firstByte = time.Since(rw.start)
+}
+
+// writerOnly hides any ReaderFrom on the wrapped writer to avoid recursion
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// plainWriter is a ResponseWriter with no optional interfaces
+type plainWriter struct {
+	header http.Header
+	body   strings.Builder
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *plainWriter) WriteHeader(int)             {}
+
+func TestResponseWriterCounts(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(w http.ResponseWriter)
+		wantStatus int
+		wantBytes  int64
+	}{
+		{"implicit 200", func(w http.ResponseWriter) { w.Write([]byte("hello")) }, http.StatusOK, 5},
+		{"explicit status", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusCreated, 2},
+		{"early hints", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusAccepted)
+		}, http.StatusAccepted, 0},
+		{"read from", func(w http.ResponseWriter) { io.Copy(w, strings.NewReader("streamed body")) }, http.StatusOK, 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := wrapResponseWriter(httptest.NewRecorder())
+			time.Sleep(time.Millisecond)
+			tt.handler(rw)
+			if rw.Status() != tt.wantStatus {
+				t.Errorf("status %d, want %d", rw.Status(), tt.wantStatus)
+			}
+			if rw.BytesWritten() != tt.wantBytes {
+				t.Errorf("%d bytes, want %d", rw.BytesWritten(), tt.wantBytes)
+			}
+			if rw.TimeToFirstByte() < time.Millisecond {
+				t.Errorf("time to first byte %s, want at least the 1ms before writing", rw.TimeToFirstByte())
+			}
+		})
+	}
+
+	if ttfb := wrapResponseWriter(httptest.NewRecorder()).TimeToFirstByte(); ttfb != 0 {
+		t.Errorf("time to first byte %s before anything was written, want 0", ttfb)
+	}
+}
+
+func TestWrapResponseWriterReuses(t *testing.T) {
+	inner := wrapResponseWriter(httptest.NewRecorder())
+	outer := wrapResponseWriter(inner)
+	if outer != inner {
+		t.Fatal("wrapping a wrapped writer created a second set of counters")
+	}
+	outer.Write([]byte("abc"))
+	if inner.BytesWritten() != 3 {
+		t.Errorf("inner counted %d bytes, want 3", inner.BytesWritten())
+	}
+}
+
+func TestResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := wrapResponseWriter(rec)
+	if err := http.NewResponseController(rw).Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("flush did not reach the recorder")
+	}
+	if rw.TimeToFirstByte() == 0 {
+		t.Error("flushing headers did not record the first byte")
+	}
+
+	// Flushing a writer that cannot flush is a no-op, not a panic
+	wrapResponseWriter(&plainWriter{header: http.Header{}}).Flush()
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	_, _, err := wrapResponseWriter(httptest.NewRecorder()).Hijack()
+	if !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Hijack of a recorder = %v, want ErrNotSupported", err)
+	}
+
+	status := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := wrapResponseWriter(w)
+		defer func() { status <- rw.Status() }()
+
+		// The write deadline is only reachable through Unwrap
+		rc := http.NewResponseController(rw)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Errorf("SetWriteDeadline: %v", err)
+		}
+		conn, buf, err := rc.Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("client got %d, want 101", resp.StatusCode)
+	}
+	if got := <-status; got != http.StatusSwitchingProtocols {
+		t.Errorf("recorded status %d, want 101", got)
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// WrappedServerStream overrides the context of a grpc.ServerStream so
+// stream handlers see values added by interceptors
+type WrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// WrapServerStream returns ss with its context replaced by ctx
+func WrapServerStream(ss grpc.ServerStream, ctx context.Context) *WrappedServerStream {
+	return &WrappedServerStream{ServerStream: ss, ctx: ctx}
+}
+
+// Context returns the enriched stream context
+func (w *WrappedServerStream) Context() context.Context {
+	return w.ctx
+}
+
+// countingStream records message counts and payload sizes in each direction
+type countingStream struct {
+	grpc.ServerStream
+	msgsSent  atomic.Int64
+	msgsRecv  atomic.Int64
+	bytesSent atomic.Int64
+	bytesRecv atomic.Int64
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.msgsSent.Add(1)
+		s.bytesSent.Add(int64(messageSize(m)))
+	}
+	return err
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.msgsRecv.Add(1)
+		s.bytesRecv.Add(int64(messageSize(m)))
+	}
+	return err
+}
+
+// messageSize returns the wire size of protobuf messages and 0 otherwise
+func messageSize(m interface{}) int {
+	if msg, ok := m.(proto.Message); ok {
+		return proto.Size(msg)
+	}
+	return 0
+}
+
+// GRPCTracingStreamInterceptor is the streaming counterpart of
+// GRPCTracingInterceptor. The span covers the whole stream.
+func GRPCTracingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	ctx, span := startGRPCSpan(ss.Context(), info.FullMethod)
+	defer span.End()
+
+	err := handler(srv, WrapServerStream(ss, ctx))
+
+	endGRPCSpan(span, err)
+
+	logger.WithContext(ctx).LogGRPCRequest(info.FullMethod, time.Since(start), err)
+
+	return err
+}
+
+// GRPCLoggingStreamInterceptor logs one line when a stream opens and one
+// summary line when it closes, with per-direction message counts and bytes,
+// instead of logging every audio frame
+func GRPCLoggingStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	log := logger.WithContext(ss.Context())
+
+	log.Info("gRPC stream started",
+		zap.String("method", info.FullMethod),
+		zap.Bool("client_stream", info.IsClientStream),
+		zap.Bool("server_stream", info.IsServerStream),
+	)
+
+	counter := &countingStream{ServerStream: ss}
+	err := handler(srv, counter)
+
+	fields := []zap.Field{
+		zap.String("method", info.FullMethod),
+		zap.Duration("duration", time.Since(start)),
+		zap.Int64("messages_received", counter.msgsRecv.Load()),
+		zap.Int64("messages_sent", counter.msgsSent.Load()),
+		zap.Int64("bytes_received", counter.bytesRecv.Load()),
+		zap.Int64("bytes_sent", counter.bytesSent.Load()),
+	}
+
+	if err != nil && err != io.EOF {
+		fields = append(fields, zap.String("code", status.Code(err).String()), zap.Error(err))
+		log.Error("gRPC stream failed", fields...)
+	} else {
+		log.Info("gRPC stream completed", fields...)
+	}
+
+	return err
+}
+
+// GRPCRecoveryStreamInterceptor turns panics in stream handlers into
+// codes.Internal errors
+func GRPCRecoveryStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx, scope := withRecoveryScope(ss.Context())
+	defer func() {
+		if r := recover(); r != nil {
+			log := logger.WithContext(scope.context(ctx))
+			log.Error("gRPC stream handler panic",
+				zap.Any("panic", r),
+				zap.String("method", info.FullMethod),
+				zap.String("stack", string(debug.Stack())),
+			)
+
+			err = status.Error(codes.Internal, "Internal server error")
+		}
+	}()
+
+	return handler(srv, WrapServerStream(ss, ctx))
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing/tracingtest"
+)
+
+// fakeServerStream is an in-memory grpc.ServerStream. RecvMsg returns
+// the queued messages and then io.EOF; SendMsg records what was sent.
+type fakeServerStream struct {
+	ctx     context.Context
+	recv    []proto.Message
+	sent    []interface{}
+	sendErr error
+}
+
+func (s *fakeServerStream) Context() context.Context     { return s.ctx }
+func (s *fakeServerStream) SetHeader(metadata.MD) error  { return nil }
+func (s *fakeServerStream) SendHeader(metadata.MD) error { return nil }
+func (s *fakeServerStream) SetTrailer(metadata.MD)       {}
+
+func (s *fakeServerStream) SendMsg(m interface{}) error {
+	if s.sendErr != nil {
+		return s.sendErr
+	}
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeServerStream) RecvMsg(m interface{}) error {
+	if len(s.recv) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.recv[0])
+	s.recv = s.recv[1:]
+	return nil
+}
+
+var streamInfo = &grpc.StreamServerInfo{FullMethod: "/phonic.stt.STT/Transcribe", IsClientStream: true, IsServerStream: true}
+
+// echo is a stream handler that sends back every message it receives
+func echo(srv interface{}, ss grpc.ServerStream) error {
+	for {
+		msg := &wrapperspb.BytesValue{}
+		if err := ss.RecvMsg(msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := ss.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func TestWrapServerStream(t *testing.T) {
+	type key struct{}
+	inner := &fakeServerStream{ctx: context.Background()}
+	ctx := context.WithValue(context.Background(), key{}, "enriched")
+
+	ss := WrapServerStream(inner, ctx)
+	if ss.Context().Value(key{}) != "enriched" {
+		t.Error("wrapped stream does not expose the new context")
+	}
+	// Everything else still goes to the underlying stream
+	if err := ss.SendMsg(wrapperspb.String("hi")); err != nil || len(inner.sent) != 1 {
+		t.Errorf("SendMsg = %v with %d sent, want it passed through", err, len(inner.sent))
+	}
+}
+
+func TestGRPCTracingStreamInterceptor(t *testing.T) {
+	_, exporter := tracingtest.NewProvider()
+	md := metadata.Pairs(tracing.MetadataRequestID, "req-7", tracing.MetadataSessionID, "sess-1")
+	inner := &fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+	var handlerCtx context.Context
+	err := GRPCTracingStreamInterceptor(nil, inner, streamInfo, func(srv interface{}, ss grpc.ServerStream) error {
+		handlerCtx = ss.Context()
+		return status.Error(codes.Internal, "model crashed")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("error %v, want the handler's", err)
+	}
+
+	// The handler sees the IDs the interceptor added
+	if id, _ := handlerCtx.Value(logger.TraceIDKey).(string); id == "" {
+		t.Error("no trace ID in the stream context")
+	}
+	for key, want := range map[logger.ContextKey]string{logger.RequestIDKey: "req-7", logger.SessionIDKey: "sess-1"} {
+		if got := handlerCtx.Value(key); got != want {
+			t.Errorf("%s = %v, want %s", key, got, want)
+		}
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "phonic.stt.STT/Transcribe" {
+		t.Fatalf("spans %v, want one for the stream", spans)
+	}
+	if got := spans[0].SpanContext.TraceID().String(); got != handlerCtx.Value(logger.TraceIDKey) {
+		t.Errorf("span trace %s does not match the context's", got)
+	}
+}
+
+func TestGRPCLoggingStreamInterceptorCounts(t *testing.T) {
+	msgs := []proto.Message{wrapperspb.Bytes(make([]byte, 160)), wrapperspb.Bytes(make([]byte, 320)), wrapperspb.Bytes(nil)}
+	var wantBytes int64
+	for _, m := range msgs {
+		wantBytes += int64(proto.Size(m))
+	}
+
+	tests := []struct {
+		name      string
+		sendErr   error
+		wantRecv  int64
+		wantSent  int64
+		wantBytes int64
+		wantErr   bool
+	}{
+		{name: "echo", wantRecv: 3, wantSent: 3, wantBytes: wantBytes},
+		// Failed sends are not counted
+		{name: "send fails", sendErr: errors.New("broken pipe"), wantRecv: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &fakeServerStream{ctx: context.Background(), recv: msgs, sendErr: tt.sendErr}
+			var counter *countingStream
+			err := GRPCLoggingStreamInterceptor(nil, inner, streamInfo, func(srv interface{}, ss grpc.ServerStream) error {
+				counter = ss.(*countingStream)
+				return echo(srv, ss)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if got := counter.msgsRecv.Load(); got != tt.wantRecv {
+				t.Errorf("received %d messages, want %d", got, tt.wantRecv)
+			}
+			if got := counter.msgsSent.Load(); got != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", got, tt.wantSent)
+			}
+			if got := counter.bytesSent.Load(); got != tt.wantBytes {
+				t.Errorf("sent %d bytes, want %d", got, tt.wantBytes)
+			}
+			if got := counter.bytesRecv.Load(); tt.sendErr == nil && got != wantBytes {
+				t.Errorf("received %d bytes, want %d", got, wantBytes)
+			}
+		})
+	}
+}
+
+func TestMessageSize(t *testing.T) {
+	if got := messageSize(wrapperspb.String("hello")); got != 7 {
+		t.Errorf("protobuf message size %d, want 7", got)
+	}
+	if got := messageSize([]byte("hello")); got != 0 {
+		t.Errorf("non-protobuf message size %d, want 0", got)
+	}
+}
+
+func TestGRPCRecoveryStreamInterceptor(t *testing.T) {
+	inner := &fakeServerStream{ctx: context.Background()}
+	err := GRPCRecoveryStreamInterceptor(nil, inner, streamInfo, func(srv interface{}, ss grpc.ServerStream) error {
+		var m map[string]int
+		m["boom"]++
+		return nil
+	})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("error %v, want Internal", err)
+	}
+
+	err = GRPCRecoveryStreamInterceptor(nil, inner, streamInfo, echo)
+	if err != nil {
+		t.Errorf("error %v from a handler that did not panic", err)
+	}
+}
@@ -5,9 +5,16 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -15,6 +22,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
 )
 
 // generateID generates a random ID for tracing
@@ -27,33 +35,66 @@ func generateID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// HTTPTracing middleware adds tracing information to HTTP requests
+// HTTPTracing middleware starts an OpenTelemetry server span for each
+// request. Trace context is read from W3C traceparent/tracestate, falling
+// back to the legacy X-Trace-ID header, and the trace ID is echoed in
+// X-Trace-ID for backward compatibility.
 func HTTPTracing(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		
-		// Generate or extract trace ID
-		traceID := r.Header.Get("X-Trace-ID")
-		if traceID == "" {
-			traceID = generateID()
-		}
+		// Extract the caller's trace context and start a server span
+		ctx := tracing.ExtractHTTP(r.Context(), r.Header)
+		ctx, span := tracing.Tracer().Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.UserAgentOriginal(r.UserAgent()),
+				semconv.ClientAddress(hostOnly(r.RemoteAddr)),
+			),
+		)
+		defer span.End()
+		
+		traceID, spanID := spanIDs(span)
 		
 		// Generate request ID
 		requestID := generateID()
 		
 		// Add to response headers
-		w.Header().Set("X-Trace-ID", traceID)
-		w.Header().Set("X-Request-ID", requestID)
+		w.Header().Set(tracing.HeaderTraceID, traceID)
+		w.Header().Set(tracing.HeaderRequestID, requestID)
 		
 		// Create context with tracing information
-		ctx := context.WithValue(r.Context(), logger.TraceIDKey, traceID)
+		ctx = context.WithValue(ctx, logger.TraceIDKey, traceID)
+		ctx = context.WithValue(ctx, logger.SpanIDKey, spanID)
 		ctx = context.WithValue(ctx, logger.RequestIDKey, requestID)
+		ctx, limits := withRequestLimits(ctx)
+		noteRequestContext(ctx)
 		
-		// Create wrapped response writer to capture status code
-		wrappedWriter := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		// Wrap the response writer to capture status, size and time to
+		// first byte; Flusher and Hijacker stay available for SSE and
+		// WebSocket handlers
+		wrappedWriter := wrapResponseWriter(w)
 		
 		// Process request
-		next.ServeHTTP(wrappedWriter, r.WithContext(ctx))
+		req := r.WithContext(ctx)
+		next.ServeHTTP(wrappedWriter, req)
+		
+		// Record route and status on the span; ServeMux sets Pattern on the
+		// request it routes, which is req here
+		if route := routeFromPattern(req.Pattern); route != "" {
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		statusCode := wrappedWriter.Status()
+		span.SetAttributes(
+			semconv.HTTPResponseStatusCode(statusCode),
+			semconv.HTTPResponseBodySize(int(wrappedWriter.BytesWritten())),
+		)
+		if statusCode >= http.StatusInternalServerError {
+			span.SetStatus(otelcodes.Error, http.StatusText(statusCode))
+		}
 		
 		// Log request
 		duration := time.Since(start)
@@ -62,21 +103,44 @@ func HTTPTracing(next http.Handler) http.Handler {
 			r.URL.Path,
 			r.UserAgent(),
 			r.RemoteAddr,
-			wrappedWriter.statusCode,
+			statusCode,
 			duration,
+			append([]zap.Field{
+				zap.Int64("response_bytes", wrappedWriter.BytesWritten()),
+				zap.Duration("time_to_first_byte", wrappedWriter.TimeToFirstByte()),
+			}, limits.fields()...)...,
 		)
 	})
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
+// spanIDs returns the hex trace and span IDs of span. Without a configured
+// tracer provider the span is invalid, so random IDs are used instead.
+func spanIDs(span trace.Span) (string, string) {
+	sc := span.SpanContext()
+	if sc.IsValid() {
+		return sc.TraceID().String(), sc.SpanID().String()
+	}
+	return generateID() + generateID(), generateID()
 }
 
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
+// hostOnly strips the port from a host:port address
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// routeFromPattern strips the method and host from a ServeMux pattern
+// ("GET /calls/{id}" becomes "/calls/{id}")
+func routeFromPattern(pattern string) string {
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = pattern[i+1:]
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
 }
 
 // HTTPLogging middleware provides detailed HTTP request logging
@@ -98,44 +162,36 @@ func HTTPLogging(next http.Handler) http.Handler {
 		)
 		
 		// Process request
-		next.ServeHTTP(w, r)
+		wrappedWriter := wrapResponseWriter(w)
+		next.ServeHTTP(wrappedWriter, r)
 		
 		// Log completion
 		duration := time.Since(start)
 		log.Info("HTTP request completed",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status_code", wrappedWriter.Status()),
+			zap.Int64("response_bytes", wrappedWriter.BytesWritten()),
+			zap.Duration("time_to_first_byte", wrappedWriter.TimeToFirstByte()),
 			zap.Duration("duration", duration),
 		)
 	})
 }
 
-// GRPCTracing interceptor adds tracing to gRPC requests
+// GRPCTracingInterceptor starts an OpenTelemetry server span for each
+// unary call, reading W3C trace context from metadata with a fallback to
+// the legacy trace-id key
 func GRPCTracingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	start := time.Now()
 	
-	// Extract or generate trace ID
-	var traceID string
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		if ids := md.Get("trace-id"); len(ids) > 0 {
-			traceID = ids[0]
-		}
-	}
-	if traceID == "" {
-		traceID = generateID()
-	}
-	
-	// Generate request ID
-	requestID := generateID()
-	
-	// Add to context
-	ctx = context.WithValue(ctx, logger.TraceIDKey, traceID)
-	ctx = context.WithValue(ctx, logger.RequestIDKey, requestID)
-	
-	// Add to outgoing metadata
-	ctx = metadata.AppendToOutgoingContext(ctx, "trace-id", traceID, "request-id", requestID)
+	ctx, span := startGRPCSpan(ctx, info.FullMethod)
+	defer span.End()
 	
 	// Process request
 	resp, err := handler(ctx, req)
 	
+	endGRPCSpan(span, err)
+	
 	// Log request
 	duration := time.Since(start)
 	logger.WithContext(ctx).LogGRPCRequest(info.FullMethod, duration, err)
@@ -143,6 +199,84 @@ func GRPCTracingInterceptor(ctx context.Context, req interface{}, info *grpc.Una
 	return resp, err
 }
 
+// startGRPCSpan extracts the caller's trace context, starts a server span
+// and stores trace, span and request IDs in the context
+func startGRPCSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = tracing.ExtractGRPC(ctx, md)
+	
+	service, method := splitMethod(fullMethod)
+	ctx, span := tracing.Tracer().Start(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.RPCSystemGRPC,
+			semconv.RPCService(service),
+			semconv.RPCMethod(method),
+		),
+	)
+	
+	traceID, spanID := spanIDs(span)
+	
+	// Keep the caller's request ID so a call chain shares one
+	requestID := firstValue(md, tracing.MetadataRequestID)
+	if requestID == "" {
+		requestID = generateID()
+	}
+	
+	ctx = context.WithValue(ctx, logger.TraceIDKey, traceID)
+	ctx = context.WithValue(ctx, logger.SpanIDKey, spanID)
+	ctx = context.WithValue(ctx, logger.RequestIDKey, requestID)
+	if sessionID := firstValue(md, tracing.MetadataSessionID); sessionID != "" {
+		ctx = context.WithValue(ctx, logger.SessionIDKey, sessionID)
+	}
+	if tenantID := firstValue(md, tracing.MetadataTenantID); tenantID != "" {
+		ctx = context.WithValue(ctx, logger.TenantIDKey, tenantID)
+	}
+	
+	// Add to outgoing metadata
+	ctx = metadata.AppendToOutgoingContext(ctx, tracing.MetadataTraceID, traceID, tracing.MetadataRequestID, requestID)
+	noteRequestContext(ctx)
+	
+	return ctx, span
+}
+
+// firstValue returns the first metadata value for key, or ""
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// endGRPCSpan records the gRPC status code on span
+func endGRPCSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if isServerError(code) {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+}
+
+// isServerError reports whether a gRPC code marks a server-side span as
+// failed under the OpenTelemetry semantic conventions
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented,
+		codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// splitMethod splits "/pkg.Service/Method" into service and method
+func splitMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndexByte(fullMethod, '/'); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
+
 // GRPCLogging interceptor provides detailed gRPC request logging
 func GRPCLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	start := time.Now()
@@ -175,34 +309,51 @@ func GRPCLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.Una
 	return resp, err
 }
 
-// Recovery middleware handles panics gracefully
+// Recovery middleware handles panics gracefully. The panic is logged with
+// its stack trace and, when tracing runs inside recovery, the request's
+// trace and request IDs.
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, scope := withRecoveryScope(r.Context())
 		defer func() {
 			if err := recover(); err != nil {
-				log := logger.WithContext(r.Context())
+				// net/http uses ErrAbortHandler to abort a response silently
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				
+				// Panics from handlers run by Limits carry their own stack
+				stack := debug.Stack()
+				if p, ok := err.(*handlerPanic); ok {
+					err, stack = p.value, p.stack
+				}
+				
+				log := logger.WithContext(scope.context(ctx))
 				log.Error("HTTP handler panic",
 					zap.Any("panic", err),
 					zap.String("method", r.Method),
 					zap.String("path", r.URL.Path),
+					zap.String("stack", string(stack)),
 				)
 				
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
 		
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
 // GRPCRecovery interceptor handles gRPC panics
 func GRPCRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, scope := withRecoveryScope(ctx)
 	defer func() {
 		if r := recover(); r != nil {
-			log := logger.WithContext(ctx)
+			log := logger.WithContext(scope.context(ctx))
 			log.Error("gRPC handler panic",
 				zap.Any("panic", r),
 				zap.String("method", info.FullMethod),
+				zap.String("stack", string(debug.Stack())),
 			)
 			
 			err = status.Error(codes.Internal, "Internal server error")
@@ -212,53 +363,39 @@ func GRPCRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.Un
 	return handler(ctx, req)
 }
 
-// CORS middleware handles Cross-Origin Resource Sharing
-func CORS(allowedOrigins []string, allowedMethods []string, allowedHeaders []string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			
-			// Check if origin is allowed
-			allowed := false
-			for _, allowedOrigin := range allowedOrigins {
-				if allowedOrigin == "*" || allowedOrigin == origin {
-					allowed = true
-					break
-				}
-			}
-			
-			if allowed {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
-			
-			// Set other CORS headers
-			w.Header().Set("Access-Control-Allow-Methods", joinStrings(allowedMethods, ", "))
-			w.Header().Set("Access-Control-Allow-Headers", joinStrings(allowedHeaders, ", "))
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			
-			// Handle preflight requests
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			
-			next.ServeHTTP(w, r)
-		})
-	}
+// recoveryScopeKey is the context key for the recovery scope
+type recoveryScopeKey struct{}
+
+// recoveryScope lets recovery log with the context built by inner
+// middleware. Tracing runs inside recovery, so the trace ID it assigns is
+// otherwise lost by the time the panic reaches the recovery handler.
+type recoveryScope struct {
+	mu  sync.Mutex
+	ctx context.Context
 }
 
-// joinStrings joins a slice of strings with a separator
-func joinStrings(slice []string, separator string) string {
-	if len(slice) == 0 {
-		return ""
-	}
-	if len(slice) == 1 {
-		return slice[0]
+// withRecoveryScope adds a recovery scope to ctx
+func withRecoveryScope(ctx context.Context) (context.Context, *recoveryScope) {
+	scope := &recoveryScope{}
+	return context.WithValue(ctx, recoveryScopeKey{}, scope), scope
+}
+
+// noteRequestContext records ctx as the richest context for the current
+// recovery scope, if any
+func noteRequestContext(ctx context.Context) {
+	if scope, ok := ctx.Value(recoveryScopeKey{}).(*recoveryScope); ok {
+		scope.mu.Lock()
+		scope.ctx = ctx
+		scope.mu.Unlock()
 	}
-	
-	result := slice[0]
-	for i := 1; i < len(slice); i++ {
-		result += separator + slice[i]
+}
+
+// context returns the noted context, or fallback when none was noted
+func (s *recoveryScope) context(fallback context.Context) context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return s.ctx
 	}
-	return result
+	return fallback
 }
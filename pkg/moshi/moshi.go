@@ -0,0 +1,103 @@
+// Package moshi holds helpers shared by the Moshi STT and TTS streaming
+// clients: endpoint URLs, PCM encoding and dialing with retries
+package moshi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+)
+
+// EndpointURL builds the WebSocket URL for a Moshi server. A host that
+// already carries a ws:// or wss:// scheme is used as is.
+func EndpointURL(host string, port int, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if strings.HasPrefix(host, "ws://") || strings.HasPrefix(host, "wss://") {
+		return strings.TrimSuffix(host, "/") + path
+	}
+	u := url.URL{Scheme: "ws", Host: net.JoinHostPort(host, strconv.Itoa(port)), Path: path}
+	return u.String()
+}
+
+// EncodePCM16 encodes samples as little-endian 16-bit PCM
+func EncodePCM16(pcm []int16) []byte {
+	buf := make([]byte, len(pcm)*2)
+	for i, sample := range pcm {
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(sample))
+	}
+	return buf
+}
+
+// DecodePCM16 decodes little-endian 16-bit PCM; a trailing odd byte is
+// ignored
+func DecodePCM16(data []byte) []int16 {
+	pcm := make([]int16, len(data)/2)
+	for i := range pcm {
+		pcm[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return pcm
+}
+
+// Dial connects to a Moshi server, retrying up to attempts times with
+// exponential backoff. The caller's trace context is sent in the upgrade
+// request headers.
+func Dial(ctx context.Context, dialer *websocket.Dialer, endpoint string, header http.Header, attempts int, log *logger.Logger) (*websocket.Conn, error) {
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	h := header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	tracing.InjectHTTP(ctx, h)
+
+	backoff := 100 * time.Millisecond
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		conn, resp, err := dialer.DialContext(ctx, endpoint, h)
+		if err == nil {
+			return conn, nil
+		}
+		if resp != nil {
+			err = fmt.Errorf("%w (status %d)", err, resp.StatusCode)
+		}
+		lastErr = err
+		if ctx.Err() != nil || attempt == attempts {
+			break
+		}
+
+		log.WithContext(ctx).Warn("Moshi connection failed, retrying",
+			zap.String("endpoint", endpoint),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 2*time.Second {
+			backoff = 2 * time.Second
+		}
+	}
+	return nil, fmt.Errorf("failed to connect to %s: %w", endpoint, lastErr)
+}
@@ -0,0 +1,48 @@
+package stt
+
+// The transcription protocol runs over one WebSocket per stream. The
+// client opens with a JSON StartMessage, then sends audio as binary frames
+// of little-endian 16-bit PCM, interleaved when there is more than one
+// channel, and finishes with a JSON EndMessage. The server answers with
+// JSON ServerMessages and closes the connection after the final result
+// that follows EndMessage. Times are seconds from the start of the audio
+// received on the connection.
+
+// Message types
+const (
+	TypeStart   = "start"
+	TypeEnd     = "end"
+	TypePartial = "partial"
+	TypeFinal   = "final"
+	TypeError   = "error"
+)
+
+// StartMessage opens a stream
+type StartMessage struct {
+	Type       string `json:"type"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	Language   string `json:"language,omitempty"`
+}
+
+// EndMessage asks the server to finalize and close the stream
+type EndMessage struct {
+	Type string `json:"type"`
+}
+
+// ServerMessage is a transcript or error sent by the server
+type ServerMessage struct {
+	Type    string        `json:"type"`
+	Text    string        `json:"text,omitempty"`
+	Start   float64       `json:"start,omitempty"`
+	End     float64       `json:"end,omitempty"`
+	Words   []MessageWord `json:"words,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+// MessageWord is a word with its timestamps in a ServerMessage
+type MessageWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
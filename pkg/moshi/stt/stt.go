@@ -0,0 +1,574 @@
+// Package stt is a streaming client for the Moshi speech-to-text server.
+// A Session sends PCM audio in fixed-size chunks and delivers partial and
+// final transcripts with word timestamps, reconnecting transparently when
+// the connection drops.
+package stt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi"
+)
+
+// ErrClosed is returned by Send after Close
+var ErrClosed = errors.New("stt session closed")
+
+// Options configures a Client
+type Options struct {
+	// URL is the WebSocket endpoint, e.g. ws://localhost:8001/transcribe
+	URL        string
+	SampleRate int
+	Channels   int
+	// ChunkSize is the number of frames per audio message
+	ChunkSize int
+	// Language is an optional language hint
+	Language string
+	// RetryAttempts is how many times connecting is retried, and how many
+	// reconnects in a row a session makes without receiving a result
+	RetryAttempts int
+	// Timeout bounds each write and the wait for final results on Close
+	Timeout time.Duration
+	// SendBuffer is the number of chunks queued before Send blocks
+	SendBuffer int
+	// ResultBuffer is the capacity of the Results channel
+	ResultBuffer int
+	Dialer       *websocket.Dialer
+	Header       http.Header
+}
+
+// FromConfig converts MoshiSTTConfig into Options
+func FromConfig(cfg config.MoshiSTTConfig) Options {
+	return Options{
+		URL:           moshi.EndpointURL(cfg.Host, cfg.Port, cfg.WebSocketPath),
+		SampleRate:    cfg.SampleRate,
+		Channels:      cfg.Channels,
+		ChunkSize:     cfg.ChunkSize,
+		RetryAttempts: cfg.RetryAttempts,
+		Timeout:       cfg.Timeout,
+	}
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.SampleRate <= 0 {
+		o.SampleRate = 16000
+	}
+	if o.Channels <= 0 {
+		o.Channels = 1
+	}
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = o.SampleRate / 10
+	}
+	if o.RetryAttempts < 0 {
+		o.RetryAttempts = 0
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.SendBuffer <= 0 {
+		o.SendBuffer = 50
+	}
+	if o.ResultBuffer <= 0 {
+		o.ResultBuffer = 16
+	}
+	return o
+}
+
+// Word is a transcribed word with its position in the audio
+type Word struct {
+	Text  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Result is a partial or final transcript. Partial results for an
+// utterance are replaced by later ones; a final result is not revised.
+type Result struct {
+	Text  string
+	Final bool
+	Words []Word
+	// Start and End are the result's position in the session audio
+	Start time.Duration
+	End   time.Duration
+	// Latency is the time from sending the audio at End to receiving the
+	// result
+	Latency time.Duration
+}
+
+// Client opens transcription sessions against a Moshi STT server
+type Client struct {
+	opts   Options
+	logger *logger.Logger
+}
+
+// NewClient creates an STT client
+func NewClient(opts Options, log *logger.Logger) *Client {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	return &Client{opts: opts.withDefaults(), logger: log}
+}
+
+// chunk is a slice of audio with its position in the session
+type chunk struct {
+	pcm    []int16
+	start  int64
+	frames int64
+	sentAt time.Time
+}
+
+// Session is one transcription stream. Results must be drained, since a
+// full channel stops reading from the server.
+type Session struct {
+	opts   Options
+	logger *logger.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	opened time.Time
+
+	// sendMu serializes Send and Close
+	sendMu  sync.Mutex
+	pending []int16
+	queued  int64
+	closing bool
+
+	chunks  chan chunk
+	results chan Result
+	done    chan struct{}
+	err     error
+
+	// mu guards sent, which holds chunks since the last final result for
+	// replay after a reconnect and for latency, and sentFrames, the
+	// position after the last chunk sent
+	mu         sync.Mutex
+	sent       []chunk
+	sentFrames int64
+
+	// Owned by the run goroutine
+	ended bool
+}
+
+// Open connects and starts a session. The session ends when ctx is
+// cancelled or after Close.
+func (c *Client) Open(ctx context.Context) (*Session, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Session{
+		opts:    c.opts,
+		logger:  c.logger,
+		ctx:     ctx,
+		cancel:  cancel,
+		opened:  time.Now(),
+		chunks:  make(chan chunk, c.opts.SendBuffer),
+		results: make(chan Result, c.opts.ResultBuffer),
+		done:    make(chan struct{}),
+	}
+
+	conn, err := s.connect()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.run(conn)
+	return s, nil
+}
+
+// Results returns the transcripts. The channel is closed when the session
+// ends; Err then reports why.
+func (s *Session) Results() <-chan Result {
+	return s.results
+}
+
+// Err returns the error that ended the session, or nil after a clean Close
+func (s *Session) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Send queues interleaved PCM samples. Audio is sent in ChunkSize frames;
+// Send blocks while SendBuffer chunks are waiting, so a slow server slows
+// the producer instead of growing memory.
+func (s *Session) Send(pcm []int16) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	if s.closing {
+		return ErrClosed
+	}
+
+	chunkSamples := s.opts.ChunkSize * s.opts.Channels
+	s.pending = append(s.pending, pcm...)
+	for len(s.pending) >= chunkSamples {
+		data := make([]int16, chunkSamples)
+		copy(data, s.pending)
+		s.pending = s.pending[chunkSamples:]
+		if err := s.enqueue(data); err != nil {
+			return err
+		}
+	}
+	// Keep the remainder in a fresh slice so the backing array of large
+	// writes is not retained
+	s.pending = append([]int16(nil), s.pending...)
+	return nil
+}
+
+// Close sends any buffered audio, waits for the final results and closes
+// the connection. It returns the error that ended the session, if any.
+// If Results is not drained, Close gives up waiting after Timeout.
+func (s *Session) Close() error {
+	s.sendMu.Lock()
+	if !s.closing {
+		s.closing = true
+		var err error
+		if len(s.pending) > 0 {
+			err = s.enqueue(s.pending)
+			s.pending = nil
+		}
+		if err == nil {
+			close(s.chunks)
+		} else {
+			s.cancel()
+		}
+	}
+	s.sendMu.Unlock()
+
+	<-s.done
+	s.cancel()
+	return s.err
+}
+
+// enqueue queues data, blocking while the send buffer is full
+func (s *Session) enqueue(data []int16) error {
+	frames := int64(len(data) / s.opts.Channels)
+	c := chunk{pcm: data, start: s.queued, frames: frames}
+	select {
+	case s.chunks <- c:
+		s.queued += frames
+		return nil
+	case <-s.done:
+		if s.err != nil {
+			return s.err
+		}
+		return ErrClosed
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// connect dials the server and starts the stream
+func (s *Session) connect() (*websocket.Conn, error) {
+	start := time.Now()
+	conn, err := moshi.Dial(s.ctx, s.opts.Dialer, s.opts.URL, s.opts.Header, s.opts.RetryAttempts+1, s.logger)
+	if err == nil {
+		err = s.write(conn, websocket.TextMessage, StartMessage{
+			Type:       TypeStart,
+			SampleRate: s.opts.SampleRate,
+			Channels:   s.opts.Channels,
+			Language:   s.opts.Language,
+		})
+		if err != nil {
+			conn.Close()
+		}
+	}
+	s.logger.WithContext(s.ctx).LogMoshiInteraction("stt", "connect", time.Since(start), err == nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// run serves connections until the session ends, reconnecting after
+// connection failures
+func (s *Session) run(conn *websocket.Conn) {
+	var err error
+	reconnects := 0
+	for {
+		var progressed, retry bool
+		progressed, retry, err = s.serve(conn)
+		conn.Close()
+		if err == nil || !retry || s.ctx.Err() != nil {
+			break
+		}
+
+		if progressed {
+			reconnects = 0
+		}
+		if reconnects++; reconnects > s.opts.RetryAttempts {
+			err = fmt.Errorf("stt connection lost after %d reconnects: %w", reconnects-1, err)
+			break
+		}
+		s.logger.WithContext(s.ctx).Warn("Moshi STT connection lost, reconnecting",
+			zap.Int("reconnect", reconnects),
+			zap.Error(err),
+		)
+
+		if conn, err = s.connect(); err != nil {
+			break
+		}
+	}
+
+	if err != nil && s.ctx.Err() != nil && errors.Is(err, s.ctx.Err()) {
+		err = s.ctx.Err()
+	}
+	s.err = err
+	s.logger.WithContext(s.ctx).LogMoshiInteraction("stt", "stream", time.Since(s.opened), err == nil, err)
+	close(s.results)
+	close(s.done)
+}
+
+// serve streams audio on conn until the session ends or conn fails. It
+// reports whether any result was received and whether a failure may be
+// retried on a new connection.
+func (s *Session) serve(conn *websocket.Conn) (progressed, retry bool, err error) {
+	// Replay audio the server has not finalized; its timestamps restart
+	// at zero on a new connection
+	s.mu.Lock()
+	replay := append([]chunk(nil), s.sent...)
+	s.mu.Unlock()
+	offset := s.queuedOffset(replay)
+
+	readErr := make(chan error, 1)
+	stop := make(chan struct{})
+	var received bool
+	go func() {
+		readErr <- s.read(conn, offset, &received, stop)
+	}()
+	defer func() {
+		// The reader may be blocked delivering a result nobody drains
+		close(stop)
+		conn.Close()
+		<-readErr
+		progressed = received
+	}()
+
+	for _, c := range replay {
+		if err := s.sendChunk(conn, c); err != nil {
+			return false, true, err
+		}
+	}
+	if s.ended {
+		if err := s.write(conn, websocket.TextMessage, EndMessage{Type: TypeEnd}); err != nil {
+			return false, true, err
+		}
+	}
+
+	chunks := s.chunks
+	if s.ended {
+		chunks = nil
+	}
+	var finalTimeout <-chan time.Time
+	if s.ended {
+		finalTimeout = time.After(s.opts.Timeout)
+	}
+
+	for {
+		select {
+		case c, ok := <-chunks:
+			if !ok {
+				s.ended = true
+				chunks = nil
+				if err := s.write(conn, websocket.TextMessage, EndMessage{Type: TypeEnd}); err != nil {
+					return false, true, err
+				}
+				finalTimeout = time.After(s.opts.Timeout)
+				continue
+			}
+			s.mu.Lock()
+			c.sentAt = time.Now()
+			s.sent = append(s.sent, c)
+			s.sentFrames = c.start + c.frames
+			s.mu.Unlock()
+			if err := s.sendChunk(conn, c); err != nil {
+				return false, true, err
+			}
+
+		case err := <-readErr:
+			readErr <- err
+			var serverErr *serverError
+			switch {
+			case errors.As(err, &serverErr):
+				return false, false, err
+			case err != nil:
+				return false, true, err
+			case s.ended:
+				return false, false, nil
+			default:
+				return false, true, errors.New("stt server closed the stream")
+			}
+
+		case <-finalTimeout:
+			return false, false, errors.New("timed out waiting for final stt results")
+
+		case <-s.ctx.Done():
+			return false, false, s.ctx.Err()
+		}
+	}
+}
+
+// queuedOffset returns the session position where a new connection's
+// audio starts
+func (s *Session) queuedOffset(replay []chunk) int64 {
+	if len(replay) > 0 {
+		return replay[0].start
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sentFrames
+}
+
+// sendChunk writes one audio chunk
+func (s *Session) sendChunk(conn *websocket.Conn, c chunk) error {
+	conn.SetWriteDeadline(time.Now().Add(s.opts.Timeout))
+	if err := conn.WriteMessage(websocket.BinaryMessage, moshi.EncodePCM16(c.pcm)); err != nil {
+		return fmt.Errorf("failed to send audio: %w", err)
+	}
+	return nil
+}
+
+// write sends a JSON control message
+func (s *Session) write(conn *websocket.Conn, messageType int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	conn.SetWriteDeadline(time.Now().Add(s.opts.Timeout))
+	if err := conn.WriteMessage(messageType, data); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// serverError is an error reported by the server, which is not retried
+type serverError struct {
+	message string
+}
+
+func (e *serverError) Error() string {
+	return "stt server error: " + e.message
+}
+
+// read delivers results from conn until it closes or stop is closed.
+// offset is the session frame where the connection's audio starts. A
+// normal close returns nil.
+func (s *Session) read(conn *websocket.Conn, offset int64, received *bool, stop <-chan struct{}) error {
+	base := s.framesToDuration(offset)
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return fmt.Errorf("failed to read stt result: %w", err)
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+
+		var msg ServerMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return fmt.Errorf("failed to decode stt result: %w", err)
+		}
+
+		var result Result
+		switch msg.Type {
+		case TypePartial, TypeFinal:
+			result = s.result(msg, base)
+		case TypeError:
+			return &serverError{message: msg.Message}
+		default:
+			continue
+		}
+		*received = true
+
+		if result.Final {
+			s.logger.WithContext(s.ctx).LogMoshiInteraction("stt", "transcribe", result.Latency, true, nil)
+		}
+
+		select {
+		case s.results <- result:
+		case <-stop:
+			// Undelivered, so its audio stays in the replay buffer
+			return nil
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+		if result.Final {
+			s.finalize(result.End)
+		}
+	}
+}
+
+// result converts msg to a Result in session time
+func (s *Session) result(msg ServerMessage, base time.Duration) Result {
+	result := Result{
+		Text:  msg.Text,
+		Final: msg.Type == TypeFinal,
+		Start: base + seconds(msg.Start),
+		End:   base + seconds(msg.End),
+	}
+	for _, w := range msg.Words {
+		result.Words = append(result.Words, Word{
+			Text:  w.Word,
+			Start: base + seconds(w.Start),
+			End:   base + seconds(w.End),
+		})
+	}
+
+	end := s.durationToFrames(result.End)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.sent {
+		if c.start+c.frames >= end {
+			result.Latency = time.Since(c.sentAt)
+			break
+		}
+	}
+	return result
+}
+
+// finalize drops the audio up to end, covered by a delivered final
+// result, from the replay buffer
+func (s *Session) finalize(end time.Duration) {
+	frames := s.durationToFrames(end)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.sent[:0]
+	for _, c := range s.sent {
+		if c.start+c.frames > frames {
+			kept = append(kept, c)
+		}
+	}
+	s.sent = kept
+}
+
+// framesToDuration converts a frame count to audio time
+func (s *Session) framesToDuration(frames int64) time.Duration {
+	return time.Duration(frames) * time.Second / time.Duration(s.opts.SampleRate)
+}
+
+// durationToFrames converts audio time to a frame count
+func (s *Session) durationToFrames(d time.Duration) int64 {
+	return int64(d) * int64(s.opts.SampleRate) / int64(time.Second)
+}
+
+// seconds converts protocol seconds to a Duration
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
@@ -0,0 +1,241 @@
+package stt_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi/stt"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi/testutil"
+)
+
+const sampleRate = 16000
+
+func newClient(url string, opts stt.Options) *stt.Client {
+	opts.URL = url
+	opts.SampleRate = sampleRate
+	opts.Channels = 1
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = sampleRate / 10
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	return stt.NewClient(opts, &logger.Logger{Logger: zap.NewNop()})
+}
+
+// silence returns d of mono audio
+func silence(d time.Duration) []int16 {
+	return make([]int16, int(d.Seconds()*sampleRate))
+}
+
+// collect drains results until the channel closes
+func collect(s *stt.Session) <-chan []stt.Result {
+	out := make(chan []stt.Result, 1)
+	go func() {
+		var results []stt.Result
+		for r := range s.Results() {
+			results = append(results, r)
+		}
+		out <- results
+	}()
+	return out
+}
+
+func finals(results []stt.Result) []stt.Result {
+	var out []stt.Result
+	for _, r := range results {
+		if r.Final {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// wantFinal describes an expected final result
+type wantFinal struct {
+	text       string
+	start, end time.Duration
+}
+
+func checkFinals(t *testing.T, results []stt.Result, want []wantFinal) {
+	t.Helper()
+	got := finals(results)
+	if len(got) != len(want) {
+		texts := make([]string, len(got))
+		for i, r := range got {
+			texts[i] = r.Text
+		}
+		t.Fatalf("got %d finals %q, want %d", len(got), texts, len(want))
+	}
+	for i, w := range want {
+		r := got[i]
+		if r.Text != w.text || r.Start != w.start || r.End != w.end {
+			t.Errorf("final %d = %q [%v, %v], want %q [%v, %v]", i, r.Text, r.Start, r.End, w.text, w.start, w.end)
+		}
+		if len(r.Words) != len(strings.Fields(w.text)) {
+			t.Errorf("final %d has %d words, want %d", i, len(r.Words), len(strings.Fields(w.text)))
+		}
+		for j := 1; j < len(r.Words); j++ {
+			if r.Words[j].Start != r.Words[j-1].End {
+				t.Errorf("final %d words are not contiguous: %+v", i, r.Words)
+			}
+		}
+	}
+}
+
+func TestSessionFinalsInOrder(t *testing.T) {
+	server := testutil.NewFakeSTT(testutil.FakeSTTOptions{Words: []string{"one", "two", "three", "four", "five", "six"}})
+	defer server.Close()
+
+	session, err := newClient(server.URL(), stt.Options{}).Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := collect(session)
+
+	// Sent in uneven pieces; the session re-chunks them
+	for _, d := range []time.Duration{130 * time.Millisecond, 470 * time.Millisecond, 600 * time.Millisecond} {
+		if err := session.Send(silence(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := <-results
+	checkFinals(t, got, []wantFinal{
+		{"one two three", 0, 600 * time.Millisecond},
+		{"four five six", 600 * time.Millisecond, 1200 * time.Millisecond},
+	})
+	if n := len(got) - len(finals(got)); n != 4 {
+		t.Errorf("got %d partials, want 4", n)
+	}
+	if err := session.Send(silence(time.Millisecond)); err != stt.ErrClosed {
+		t.Errorf("Send after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestSessionReconnectReplaysAudio(t *testing.T) {
+	server := testutil.NewFakeSTT(testutil.FakeSTTOptions{DropAfterChunks: 5})
+	defer server.Close()
+
+	session, err := newClient(server.URL(), stt.Options{RetryAttempts: 2}).Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := collect(session)
+
+	for i := 0; i < 12; i++ {
+		if err := session.Send(silence(100 * time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if n := server.Connections(); n != 2 {
+		t.Errorf("server saw %d connections, want 2", n)
+	}
+	// Nothing was finalized before the drop, so the new connection gets
+	// all of the audio again
+	if frames := server.ReceivedFrames(); frames < 12*sampleRate/10+5*sampleRate/10 {
+		t.Errorf("server received %d frames; audio was not replayed", frames)
+	}
+	checkFinals(t, <-results, []wantFinal{
+		{"hello from phonic", 0, 600 * time.Millisecond},
+		{"hello from phonic", 600 * time.Millisecond, 1200 * time.Millisecond},
+	})
+}
+
+func TestSessionReconnectLimit(t *testing.T) {
+	server := testutil.NewFakeSTT(testutil.FakeSTTOptions{DropAfterChunks: 1})
+	defer server.Close()
+
+	session, err := newClient(server.URL(), stt.Options{RetryAttempts: 0}).Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := collect(session)
+	session.Send(silence(300 * time.Millisecond))
+
+	<-results
+	if err := session.Close(); err == nil || !strings.Contains(err.Error(), "reconnects") {
+		t.Errorf("Close = %v, want the connection loss", err)
+	}
+}
+
+func TestSessionCloseFlushesPendingAudio(t *testing.T) {
+	server := testutil.NewFakeSTT(testutil.FakeSTTOptions{})
+	defer server.Close()
+
+	session, err := newClient(server.URL(), stt.Options{}).Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := collect(session)
+
+	// Eight full chunks and half a chunk left in the buffer
+	if err := session.Send(silence(850 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if frames := server.ReceivedFrames(); frames != 850*sampleRate/1000 {
+		t.Errorf("server received %d frames, want %d", frames, 850*sampleRate/1000)
+	}
+	// The word still open at Close is finalized by the end message
+	checkFinals(t, <-results, []wantFinal{
+		{"hello from phonic", 0, 600 * time.Millisecond},
+		{"hello", 600 * time.Millisecond, 800 * time.Millisecond},
+	})
+}
+
+func TestSessionCloseWithoutDraining(t *testing.T) {
+	server := testutil.NewFakeSTT(testutil.FakeSTTOptions{WordDuration: 20 * time.Millisecond})
+	defer server.Close()
+
+	session, err := newClient(server.URL(), stt.Options{ResultBuffer: 1, Timeout: 100 * time.Millisecond}).Open(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Far more results than the buffer holds, and nobody reads them
+	if err := session.Send(silence(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- session.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close hung on an undrained Results channel")
+	}
+}
+
+func TestSessionContextCancel(t *testing.T) {
+	server := testutil.NewFakeSTT(testutil.FakeSTTOptions{})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	session, err := newClient(server.URL(), stt.Options{}).Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := collect(session)
+	session.Send(silence(300 * time.Millisecond))
+	cancel()
+
+	<-results
+	if err := session.Err(); err != context.Canceled {
+		t.Errorf("Err = %v, want context.Canceled", err)
+	}
+}
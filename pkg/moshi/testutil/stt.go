@@ -0,0 +1,190 @@
+// Package testutil provides in-process fake Moshi servers so clients can be
+// exercised without the real models
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ArbajAnsari19/phonic/pkg/moshi"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi/stt"
+)
+
+// upgrader accepts WebSocket connections from any origin
+var upgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+// FakeSTTOptions configures a FakeSTT
+type FakeSTTOptions struct {
+	// Words are transcribed in order, cycling, one per WordDuration of
+	// received audio
+	Words []string
+	// WordDuration defaults to 200ms
+	WordDuration time.Duration
+	// UtteranceWords is the number of words per final result; default 3
+	UtteranceWords int
+	// DropAfterChunks closes the first connection without a close frame
+	// after that many audio messages, to exercise reconnects
+	DropAfterChunks int
+}
+
+// FakeSTT is a deterministic STT server speaking the stt protocol. Each
+// time WordDuration of audio arrives it sends a partial result with the
+// utterance so far, and a final result every UtteranceWords words and on
+// end.
+type FakeSTT struct {
+	opts   FakeSTTOptions
+	server *httptest.Server
+
+	mu          sync.Mutex
+	connections int
+	frames      int64
+}
+
+// NewFakeSTT starts a fake STT server serving /transcribe
+func NewFakeSTT(opts FakeSTTOptions) *FakeSTT {
+	if len(opts.Words) == 0 {
+		opts.Words = []string{"hello", "from", "phonic"}
+	}
+	if opts.WordDuration <= 0 {
+		opts.WordDuration = 200 * time.Millisecond
+	}
+	if opts.UtteranceWords <= 0 {
+		opts.UtteranceWords = 3
+	}
+
+	f := &FakeSTT{opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/transcribe", f.handle)
+	f.server = httptest.NewServer(mux)
+	return f
+}
+
+// URL returns the WebSocket URL of the transcription endpoint
+func (f *FakeSTT) URL() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http") + "/transcribe"
+}
+
+// Close shuts the server down
+func (f *FakeSTT) Close() {
+	f.server.CloseClientConnections()
+	f.server.Close()
+}
+
+// Connections returns the number of streams opened so far
+func (f *FakeSTT) Connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connections
+}
+
+// ReceivedFrames returns the number of audio frames received on all
+// connections, including replays
+func (f *FakeSTT) ReceivedFrames() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frames
+}
+
+// handle serves one stream
+func (f *FakeSTT) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	f.mu.Lock()
+	f.connections++
+	first := f.connections == 1
+	f.mu.Unlock()
+
+	var start stt.StartMessage
+	if err := conn.ReadJSON(&start); err != nil || start.Type != stt.TypeStart {
+		conn.WriteJSON(stt.ServerMessage{Type: stt.TypeError, Message: "expected start message"})
+		return
+	}
+	if start.SampleRate <= 0 || start.Channels <= 0 {
+		conn.WriteJSON(stt.ServerMessage{Type: stt.TypeError, Message: "invalid audio format"})
+		return
+	}
+
+	wordFrames := int64(f.opts.WordDuration.Seconds() * float64(start.SampleRate))
+	var (
+		frames    int64
+		chunks    int
+		emitted   int
+		utterance []stt.MessageWord
+	)
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if messageType == websocket.TextMessage {
+			var msg stt.EndMessage
+			if json.Unmarshal(data, &msg) == nil && msg.Type == stt.TypeEnd {
+				if len(utterance) > 0 {
+					conn.WriteJSON(transcript(stt.TypeFinal, utterance))
+				}
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			continue
+		}
+
+		chunks++
+		received := int64(len(moshi.DecodePCM16(data)) / start.Channels)
+		frames += received
+		f.mu.Lock()
+		f.frames += received
+		f.mu.Unlock()
+
+		if first && f.opts.DropAfterChunks > 0 && chunks >= f.opts.DropAfterChunks {
+			// Drop the TCP connection without a close handshake
+			conn.UnderlyingConn().Close()
+			return
+		}
+
+		for frames >= int64(emitted+1)*wordFrames {
+			utterance = append(utterance, stt.MessageWord{
+				Word:  f.opts.Words[emitted%len(f.opts.Words)],
+				Start: float64(int64(emitted)*wordFrames) / float64(start.SampleRate),
+				End:   float64(int64(emitted+1)*wordFrames) / float64(start.SampleRate),
+			})
+			emitted++
+
+			kind := stt.TypePartial
+			if len(utterance) >= f.opts.UtteranceWords {
+				kind = stt.TypeFinal
+			}
+			conn.WriteJSON(transcript(kind, utterance))
+			if kind == stt.TypeFinal {
+				utterance = nil
+			}
+		}
+	}
+}
+
+// transcript builds a result message for words
+func transcript(kind string, words []stt.MessageWord) stt.ServerMessage {
+	text := make([]string, len(words))
+	for i, w := range words {
+		text[i] = w.Word
+	}
+	return stt.ServerMessage{
+		Type:  kind,
+		Text:  strings.Join(text, " "),
+		Start: words[0].Start,
+		End:   words[len(words)-1].End,
+		Words: append([]stt.MessageWord(nil), words...),
+	}
+}
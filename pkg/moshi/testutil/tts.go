@@ -0,0 +1,253 @@
+package testutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ArbajAnsari19/phonic/pkg/moshi"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi/tts"
+)
+
+// FakeTTSOptions configures a FakeTTS
+type FakeTTSOptions struct {
+	// WordDuration is the audio length of a word at speed 1; default 100ms
+	WordDuration time.Duration
+	// ChunkDuration is the audio length per binary message; default 20ms
+	ChunkDuration time.Duration
+	// Pace delays each audio message, to leave time for barge-in; default 0
+	Pace time.Duration
+}
+
+// FakeTTS is a deterministic TTS server speaking the tts protocol. Each
+// complete word becomes WordDuration/speed of a square wave whose
+// amplitude is fixed per word index, preceded by a mark. Words are
+// synthesized as soon as they are followed by whitespace or a flush.
+type FakeTTS struct {
+	opts   FakeTTSOptions
+	server *httptest.Server
+
+	mu     sync.Mutex
+	starts []tts.ClientMessage
+	texts  []string
+}
+
+// NewFakeTTS starts a fake TTS server serving /synthesize
+func NewFakeTTS(opts FakeTTSOptions) *FakeTTS {
+	if opts.WordDuration <= 0 {
+		opts.WordDuration = 100 * time.Millisecond
+	}
+	if opts.ChunkDuration <= 0 {
+		opts.ChunkDuration = 20 * time.Millisecond
+	}
+
+	f := &FakeTTS{opts: opts}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/synthesize", f.handle)
+	f.server = httptest.NewServer(mux)
+	return f
+}
+
+// URL returns the WebSocket URL of the synthesis endpoint
+func (f *FakeTTS) URL() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http") + "/synthesize"
+}
+
+// Close shuts the server down
+func (f *FakeTTS) Close() {
+	f.server.CloseClientConnections()
+	f.server.Close()
+}
+
+// Starts returns the start messages received, one per session
+func (f *FakeTTS) Starts() []tts.ClientMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]tts.ClientMessage(nil), f.starts...)
+}
+
+// Spoken returns the words synthesized to completion, in order
+func (f *FakeTTS) Spoken() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+// WordAmplitude is the sample amplitude the fake uses for the nth word of
+// a session
+func WordAmplitude(n int) int16 {
+	return int16(1000 + 100*(n%50))
+}
+
+// fakeTTSStream is the state of one session
+type fakeTTSStream struct {
+	conn       *websocket.Conn
+	sampleRate int
+	wordFrames int
+	buffer     string
+	queue      []string
+	flushes    int
+	ending     bool
+
+	// word is the word being synthesized and frame its progress
+	word     string
+	frame    int
+	words    int
+	position int
+}
+
+// handle serves one session
+func (f *FakeTTS) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var start tts.ClientMessage
+	if err := conn.ReadJSON(&start); err != nil || start.Type != tts.TypeStart {
+		conn.WriteJSON(tts.ServerMessage{Type: tts.TypeError, Message: "expected start message"})
+		return
+	}
+	f.mu.Lock()
+	f.starts = append(f.starts, start)
+	f.mu.Unlock()
+
+	speed := start.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	s := &fakeTTSStream{conn: conn, sampleRate: start.SampleRate}
+	if s.sampleRate <= 0 {
+		s.sampleRate = 24000
+	}
+	s.wordFrames = int(f.opts.WordDuration.Seconds() / speed * float64(s.sampleRate))
+	chunkFrames := int(f.opts.ChunkDuration.Seconds() * float64(s.sampleRate))
+	conn.WriteJSON(tts.ServerMessage{Type: tts.TypeReady, SampleRate: s.sampleRate})
+
+	// Read in the background so cancel interrupts synthesis
+	messages := make(chan tts.ClientMessage)
+	go func() {
+		defer close(messages)
+		for {
+			var msg tts.ClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	for {
+		busy := s.word != "" || len(s.queue) > 0
+		if !busy {
+			if s.flushes > 0 {
+				s.flushes--
+				conn.WriteJSON(tts.ServerMessage{Type: tts.TypeDone})
+				s.position = 0
+				continue
+			}
+			if s.ending {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			msg, ok := <-messages
+			if !ok {
+				return
+			}
+			s.apply(msg)
+			continue
+		}
+
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			s.apply(msg)
+			continue
+		default:
+		}
+
+		if s.word == "" {
+			s.word, s.queue = s.queue[0], s.queue[1:]
+			s.frame = 0
+			conn.WriteJSON(tts.ServerMessage{
+				Type:  tts.TypeMark,
+				Text:  s.word,
+				Start: float64(s.position) / float64(s.sampleRate),
+				End:   float64(s.position+s.wordFrames) / float64(s.sampleRate),
+			})
+		}
+
+		n := min(chunkFrames, s.wordFrames-s.frame)
+		pcm := make([]int16, n)
+		amplitude := WordAmplitude(s.words)
+		for i := range pcm {
+			if (s.frame+i)/20%2 == 0 {
+				pcm[i] = amplitude
+			} else {
+				pcm[i] = -amplitude
+			}
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, moshi.EncodePCM16(pcm)); err != nil {
+			return
+		}
+		s.frame += n
+		s.position += n
+
+		if s.frame >= s.wordFrames {
+			f.mu.Lock()
+			f.texts = append(f.texts, s.word)
+			f.mu.Unlock()
+			s.word = ""
+			s.words++
+		}
+		if f.opts.Pace > 0 {
+			time.Sleep(f.opts.Pace)
+		}
+	}
+}
+
+// apply handles a client message
+func (s *fakeTTSStream) apply(msg tts.ClientMessage) {
+	switch msg.Type {
+	case tts.TypeText:
+		s.buffer += msg.Text
+		s.takeWords(false)
+	case tts.TypeFlush:
+		s.takeWords(true)
+		s.flushes++
+	case tts.TypeEnd:
+		s.takeWords(true)
+		s.ending = true
+	case tts.TypeCancel:
+		s.buffer = ""
+		s.queue = nil
+		s.word = ""
+		s.flushes = 0
+		s.position = 0
+		s.conn.WriteJSON(tts.ServerMessage{Type: tts.TypeCancelled})
+	default:
+		data, _ := json.Marshal(msg)
+		s.conn.WriteJSON(tts.ServerMessage{Type: tts.TypeError, Message: "unexpected message " + string(data)})
+	}
+}
+
+// takeWords moves complete words from the buffer to the queue; all means
+// the trailing partial word is complete too
+func (s *fakeTTSStream) takeWords(all bool) {
+	end := len(s.buffer)
+	if !all {
+		end = strings.LastIndexFunc(s.buffer, unicode.IsSpace) + 1
+	}
+	s.queue = append(s.queue, strings.Fields(s.buffer[:end])...)
+	s.buffer = s.buffer[end:]
+}
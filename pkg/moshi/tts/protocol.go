@@ -0,0 +1,47 @@
+package tts
+
+// The synthesis protocol runs over one WebSocket per session. The client
+// opens with a start message carrying the voice settings, then streams
+// text messages as tokens arrive. A flush message ends an utterance: the
+// server synthesizes any remaining text and answers with done. A cancel
+// message stops the current utterance immediately; the server discards
+// pending text and audio and answers with cancelled. An end message
+// finishes the session after pending synthesis.
+//
+// The server sends audio as binary frames of little-endian 16-bit mono
+// PCM. Before the audio of each word it sends a mark message with the
+// word's position, in seconds from the start of the utterance.
+
+// Message types
+const (
+	TypeStart     = "start"
+	TypeText      = "text"
+	TypeFlush     = "flush"
+	TypeCancel    = "cancel"
+	TypeEnd       = "end"
+	TypeReady     = "ready"
+	TypeMark      = "mark"
+	TypeDone      = "done"
+	TypeCancelled = "cancelled"
+	TypeError     = "error"
+)
+
+// ClientMessage is sent by the client; only start uses the voice settings
+type ClientMessage struct {
+	Type       string  `json:"type"`
+	Text       string  `json:"text,omitempty"`
+	VoiceID    string  `json:"voice_id,omitempty"`
+	Speed      float64 `json:"speed,omitempty"`
+	Quality    string  `json:"quality,omitempty"`
+	SampleRate int     `json:"sample_rate,omitempty"`
+}
+
+// ServerMessage is a control message sent by the server
+type ServerMessage struct {
+	Type       string  `json:"type"`
+	Text       string  `json:"text,omitempty"`
+	Start      float64 `json:"start,omitempty"`
+	End        float64 `json:"end,omitempty"`
+	SampleRate int     `json:"sample_rate,omitempty"`
+	Message    string  `json:"message,omitempty"`
+}
@@ -0,0 +1,472 @@
+// Package tts is a streaming client for the Moshi text-to-speech server.
+// A Session accepts text incrementally, e.g. LLM tokens as they arrive,
+// and yields audio chunks with word timing marks while the sentence is
+// still being written. Cancel stops the current utterance on barge-in.
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi"
+)
+
+// ErrClosed is returned when writing to a closed session
+var ErrClosed = errors.New("tts session closed")
+
+// Options configures a Client
+type Options struct {
+	// URL is the WebSocket endpoint, e.g. ws://localhost:8002/synthesize
+	URL string
+	// VoiceID, Speed and Quality are the defaults for sessions
+	VoiceID string
+	Speed   float64
+	Quality string
+	// SampleRate is the requested output rate
+	SampleRate    int
+	RetryAttempts int
+	// Timeout bounds each write and the wait for pending audio on Close
+	Timeout time.Duration
+	// AudioBuffer is the capacity of the Audio channel
+	AudioBuffer int
+	Dialer      *websocket.Dialer
+	Header      http.Header
+}
+
+// FromConfig converts MoshiTTSConfig into Options
+func FromConfig(cfg config.MoshiTTSConfig) Options {
+	return Options{
+		URL:           moshi.EndpointURL(cfg.Host, cfg.Port, cfg.WebSocketPath),
+		VoiceID:       cfg.VoiceID,
+		Speed:         cfg.Speed,
+		Quality:       cfg.Quality,
+		RetryAttempts: cfg.RetryAttempts,
+		Timeout:       cfg.Timeout,
+	}
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.Speed <= 0 {
+		o.Speed = 1.0
+	}
+	if o.SampleRate <= 0 {
+		o.SampleRate = 24000
+	}
+	if o.RetryAttempts < 0 {
+		o.RetryAttempts = 0
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.AudioBuffer <= 0 {
+		o.AudioBuffer = 32
+	}
+	return o
+}
+
+// Request overrides the client's voice settings for one session; zero
+// fields keep the defaults
+type Request struct {
+	VoiceID string
+	Speed   float64
+	Quality string
+}
+
+// Mark is the position of a word in its utterance's audio
+type Mark struct {
+	Text  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Chunk is a piece of synthesized audio
+type Chunk struct {
+	// Utterance numbers the utterances of a session from zero; it advances
+	// on Flush and Cancel
+	Utterance int
+	// PCM is mono 16-bit audio at SampleRate
+	PCM        []int16
+	SampleRate int
+	// Offset is the position of PCM in the utterance
+	Offset time.Duration
+	// Marks are the words that start within PCM
+	Marks []Mark
+	// Last marks the end of the utterance; its PCM may be empty
+	Last bool
+}
+
+// Client opens synthesis sessions against a Moshi TTS server
+type Client struct {
+	opts   Options
+	logger *logger.Logger
+}
+
+// NewClient creates a TTS client
+func NewClient(opts Options, log *logger.Logger) *Client {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	return &Client{opts: opts.withDefaults(), logger: log}
+}
+
+// Session is one synthesis stream. Audio must be drained, since a full
+// channel stops reading from the server.
+type Session struct {
+	opts   Options
+	logger *logger.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	conn   *websocket.Conn
+	opened time.Time
+
+	// writeMu serializes writes to conn
+	writeMu sync.Mutex
+	closing bool
+
+	// mu guards the utterance state shared with the reader
+	mu         sync.Mutex
+	utterance  int
+	sampleRate int
+	offset     time.Duration
+	marks      []Mark
+	// discarding drops audio of a cancelled utterance until the server
+	// acknowledges the cancel
+	discarding bool
+	// started is when the current utterance's first text was written
+	started    time.Time
+	firstAudio bool
+
+	audio chan Chunk
+	done  chan struct{}
+	err   error
+}
+
+// Open connects and starts a session with req's voice settings. The
+// session ends when ctx is cancelled or after Close.
+func (c *Client) Open(ctx context.Context, req Request) (*Session, error) {
+	start := ClientMessage{
+		Type:       TypeStart,
+		VoiceID:    c.opts.VoiceID,
+		Speed:      c.opts.Speed,
+		Quality:    c.opts.Quality,
+		SampleRate: c.opts.SampleRate,
+	}
+	if req.VoiceID != "" {
+		start.VoiceID = req.VoiceID
+	}
+	if req.Speed > 0 {
+		start.Speed = req.Speed
+	}
+	if req.Quality != "" {
+		start.Quality = req.Quality
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Session{
+		opts:       c.opts,
+		logger:     c.logger,
+		ctx:        ctx,
+		cancel:     cancel,
+		opened:     time.Now(),
+		sampleRate: c.opts.SampleRate,
+		audio:      make(chan Chunk, c.opts.AudioBuffer),
+		done:       make(chan struct{}),
+	}
+
+	dialStart := time.Now()
+	conn, err := moshi.Dial(ctx, c.opts.Dialer, c.opts.URL, c.opts.Header, c.opts.RetryAttempts+1, c.logger)
+	if err == nil {
+		s.conn = conn
+		if err = s.send(start); err != nil {
+			conn.Close()
+		}
+	}
+	c.logger.WithContext(ctx).LogMoshiInteraction("tts", "connect", time.Since(dialStart), err == nil, err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.read()
+	go func() {
+		// Unblock the reader when the session is cancelled
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// Audio returns the synthesized audio. The channel is closed when the
+// session ends; Err then reports why.
+func (s *Session) Audio() <-chan Chunk {
+	return s.audio
+}
+
+// Err returns the error that ended the session, or nil after a clean Close
+func (s *Session) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Utterance returns the number of the utterance whose audio is being
+// received; it advances when an utterance is done or cancelled
+func (s *Session) Utterance() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.utterance
+}
+
+// Write sends text, which may be any fragment such as a single LLM token.
+// Synthesis starts before the utterance is flushed.
+func (s *Session) Write(text string) error {
+	if text == "" {
+		return nil
+	}
+	s.mu.Lock()
+	if s.started.IsZero() {
+		s.started = time.Now()
+		s.firstAudio = false
+	}
+	s.mu.Unlock()
+	return s.send(ClientMessage{Type: TypeText, Text: text})
+}
+
+// Flush ends the current utterance; its last chunk has Last set
+func (s *Session) Flush() error {
+	return s.send(ClientMessage{Type: TypeFlush})
+}
+
+// Cancel stops the current utterance, e.g. when the caller barges in.
+// Pending text and audio are discarded, including chunks already buffered
+// in the Audio channel, so text for the next utterance must be written
+// after Cancel returns. A chunk the reader is delivering at that moment
+// can still arrive; it is numbered below Utterance read right after Cancel.
+func (s *Session) Cancel() error {
+	s.mu.Lock()
+	s.utterance++
+	s.offset = 0
+	s.marks = nil
+	s.discarding = true
+	s.started = time.Time{}
+	s.mu.Unlock()
+
+	if err := s.send(ClientMessage{Type: TypeCancel}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case _, ok := <-s.audio:
+			if !ok {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// Close synthesizes any flushed text, waits for its audio up to Timeout
+// and closes the connection
+func (s *Session) Close() error {
+	if err := s.send(ClientMessage{Type: TypeEnd}); err == nil {
+		select {
+		case <-s.done:
+		case <-time.After(s.opts.Timeout):
+		}
+	}
+
+	s.writeMu.Lock()
+	s.closing = true
+	s.writeMu.Unlock()
+	s.conn.Close()
+
+	<-s.done
+	s.cancel()
+	return s.err
+}
+
+// send writes a control message
+func (s *Session) send(msg ClientMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.closing {
+		return ErrClosed
+	}
+	select {
+	case <-s.done:
+		if s.err != nil {
+			return s.err
+		}
+		return ErrClosed
+	default:
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.opts.Timeout))
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return fmt.Errorf("failed to send %s message: %w", msg.Type, err)
+	}
+	return nil
+}
+
+// read delivers audio until the connection closes
+func (s *Session) read() {
+	err := s.receive()
+
+	s.writeMu.Lock()
+	closing := s.closing
+	s.writeMu.Unlock()
+	if closing || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		err = nil
+	}
+	if s.ctx.Err() != nil {
+		err = s.ctx.Err()
+	}
+
+	s.err = err
+	s.logger.WithContext(s.ctx).LogMoshiInteraction("tts", "stream", time.Since(s.opened), err == nil, err)
+	close(s.audio)
+	close(s.done)
+	s.conn.Close()
+}
+
+// receive reads server messages, returning the error that ended the
+// connection
+func (s *Session) receive() error {
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var chunk *Chunk
+		if messageType == websocket.BinaryMessage {
+			chunk = s.audioChunk(moshi.DecodePCM16(data))
+		} else {
+			var msg ServerMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				return fmt.Errorf("failed to decode tts message: %w", err)
+			}
+			if msg.Type == TypeError {
+				return fmt.Errorf("tts server error: %s", msg.Message)
+			}
+			chunk = s.control(msg)
+		}
+
+		if chunk == nil {
+			continue
+		}
+		select {
+		case s.audio <- *chunk:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// audioChunk wraps pcm for delivery, or returns nil while discarding
+func (s *Session) audioChunk(pcm []int16) *Chunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.discarding || len(pcm) == 0 {
+		return nil
+	}
+
+	duration := time.Duration(len(pcm)) * time.Second / time.Duration(s.sampleRate)
+	chunk := &Chunk{
+		Utterance:  s.utterance,
+		PCM:        pcm,
+		SampleRate: s.sampleRate,
+		Offset:     s.offset,
+	}
+	s.offset += duration
+
+	kept := s.marks[:0]
+	for _, mark := range s.marks {
+		if mark.Start < s.offset {
+			chunk.Marks = append(chunk.Marks, mark)
+		} else {
+			kept = append(kept, mark)
+		}
+	}
+	s.marks = kept
+
+	if !s.firstAudio && !s.started.IsZero() {
+		s.firstAudio = true
+		s.logger.WithContext(s.ctx).LogMoshiInteraction("tts", "first_audio", time.Since(s.started), true, nil)
+	}
+	return chunk
+}
+
+// control applies a server control message, returning the last chunk of
+// an utterance on done
+func (s *Session) control(msg ServerMessage) *Chunk {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch msg.Type {
+	case TypeReady:
+		if msg.SampleRate > 0 {
+			s.sampleRate = msg.SampleRate
+		}
+	case TypeMark:
+		if !s.discarding {
+			s.marks = append(s.marks, Mark{
+				Text:  msg.Text,
+				Start: seconds(msg.Start),
+				End:   seconds(msg.End),
+			})
+		}
+	case TypeCancelled:
+		s.discarding = false
+		s.marks = nil
+		s.offset = 0
+	case TypeDone:
+		if s.discarding {
+			return nil
+		}
+		chunk := &Chunk{
+			Utterance:  s.utterance,
+			SampleRate: s.sampleRate,
+			Offset:     s.offset,
+			Marks:      s.marks,
+			Last:       true,
+		}
+		if !s.started.IsZero() {
+			s.logger.WithContext(s.ctx).LogMoshiInteraction("tts", "synthesize", time.Since(s.started), true, nil)
+		}
+		s.utterance++
+		s.offset = 0
+		s.marks = nil
+		s.started = time.Time{}
+		return chunk
+	}
+	return nil
+}
+
+// seconds converts protocol seconds to a Duration
+func seconds(v float64) time.Duration {
+	return time.Duration(v * float64(time.Second))
+}
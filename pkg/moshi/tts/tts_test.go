@@ -0,0 +1,233 @@
+package tts_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi/testutil"
+	"github.com/ArbajAnsari19/phonic/pkg/moshi/tts"
+)
+
+const wordDuration = 100 * time.Millisecond
+
+func open(t *testing.T, fake *testutil.FakeTTS, req tts.Request) *tts.Session {
+	t.Helper()
+	client := tts.NewClient(tts.Options{
+		URL:     fake.URL(),
+		VoiceID: "default",
+		Timeout: 2 * time.Second,
+	}, &logger.Logger{Logger: zap.NewNop()})
+	s, err := client.Open(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// next returns the next chunk
+func next(t *testing.T, s *tts.Session) tts.Chunk {
+	t.Helper()
+	select {
+	case chunk, ok := <-s.Audio():
+		if !ok {
+			t.Fatalf("audio closed: %v", s.Err())
+		}
+		return chunk
+	case <-time.After(2 * time.Second):
+		t.Fatal("no audio")
+	}
+	return tts.Chunk{}
+}
+
+// utterance collects the chunks of utterance n up to its last chunk,
+// skipping chunks of earlier utterances
+func utterance(t *testing.T, s *tts.Session, n int) []tts.Chunk {
+	t.Helper()
+	var chunks []tts.Chunk
+	for {
+		chunk := next(t, s)
+		switch {
+		case chunk.Utterance < n:
+			continue
+		case chunk.Utterance > n:
+			t.Fatalf("got chunk of utterance %d, want %d", chunk.Utterance, n)
+		}
+		chunks = append(chunks, chunk)
+		if chunk.Last {
+			return chunks
+		}
+	}
+}
+
+// words returns the marked words of chunks
+func words(chunks []tts.Chunk) []string {
+	var out []string
+	for _, chunk := range chunks {
+		for _, mark := range chunk.Marks {
+			out = append(out, mark.Text)
+		}
+	}
+	return out
+}
+
+// duration returns the audio length of chunks, checking they are contiguous
+func duration(t *testing.T, chunks []tts.Chunk) time.Duration {
+	t.Helper()
+	var total time.Duration
+	for _, chunk := range chunks {
+		if chunk.Offset != total {
+			t.Fatalf("chunk at %v, want %v", chunk.Offset, total)
+		}
+		total += time.Duration(len(chunk.PCM)) * time.Second / time.Duration(chunk.SampleRate)
+	}
+	return total
+}
+
+func TestStreamingText(t *testing.T) {
+	fake := testutil.NewFakeTTS(testutil.FakeTTSOptions{WordDuration: wordDuration})
+	defer fake.Close()
+	s := open(t, fake, tts.Request{VoiceID: "alloy", Speed: 1})
+
+	// Complete words are synthesized before the utterance is flushed
+	for _, token := range []string{"hello ", "wor"} {
+		if err := s.Write(token); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	first := next(t, s)
+	if len(first.Marks) != 1 || first.Marks[0].Text != "hello" {
+		t.Fatalf("first chunk marks %+v, want hello", first.Marks)
+	}
+
+	if err := s.Write("ld"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	chunks := append([]tts.Chunk{first}, utterance(t, s, 0)...)
+
+	if got, want := words(chunks), []string{"hello", "world"}; !slices.Equal(got, want) {
+		t.Errorf("marks %v, want %v", got, want)
+	}
+	if got, want := duration(t, chunks), 2*wordDuration; got != want {
+		t.Errorf("audio length %v, want %v", got, want)
+	}
+	for _, chunk := range chunks {
+		if len(chunk.PCM) == 0 {
+			continue
+		}
+		word := int(chunk.Offset / wordDuration)
+		if got, want := chunk.PCM[0], testutil.WordAmplitude(word); got != want && got != -want {
+			t.Errorf("chunk at %v has amplitude %d, want word %d's %d", chunk.Offset, got, word, want)
+		}
+	}
+
+	starts := fake.Starts()
+	if len(starts) != 1 || starts[0].VoiceID != "alloy" || starts[0].SampleRate != 24000 {
+		t.Errorf("start messages %+v, want voice alloy at 24000 Hz", starts)
+	}
+}
+
+func TestCancelDiscardsAudio(t *testing.T) {
+	fake := testutil.NewFakeTTS(testutil.FakeTTSOptions{
+		WordDuration: wordDuration,
+		Pace:         10 * time.Millisecond,
+	})
+	defer fake.Close()
+	s := open(t, fake, tts.Request{})
+
+	if err := s.Write("one two three four five "); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	next(t, s)
+
+	// Barge in while the sentence is being spoken
+	if err := s.Cancel(); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if got := s.Utterance(); got != 1 {
+		t.Fatalf("Utterance after Cancel = %d, want 1", got)
+	}
+
+	if err := s.Write("six"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	chunks := utterance(t, s, 1)
+	if got, want := words(chunks), []string{"six"}; !slices.Equal(got, want) {
+		t.Errorf("marks after cancel %v, want %v", got, want)
+	}
+	if got := duration(t, chunks); got != wordDuration {
+		t.Errorf("audio after cancel is %v long, want %v", got, wordDuration)
+	}
+
+	spoken := fake.Spoken()
+	if slices.Contains(spoken, "five") || spoken[len(spoken)-1] != "six" {
+		t.Errorf("server spoke %v, want the cancelled sentence cut short", spoken)
+	}
+}
+
+func TestUtteranceNumbering(t *testing.T) {
+	fake := testutil.NewFakeTTS(testutil.FakeTTSOptions{WordDuration: wordDuration})
+	defer fake.Close()
+	s := open(t, fake, tts.Request{})
+
+	steps := []struct {
+		name   string
+		text   string
+		cancel bool
+		want   int
+	}{
+		{name: "first", text: "hi", want: 0},
+		{name: "second", text: "there", want: 1},
+		{name: "cancel", cancel: true, want: 2},
+		{name: "after cancel", text: "again", want: 3},
+	}
+	for _, step := range steps {
+		if step.cancel {
+			if err := s.Cancel(); err != nil {
+				t.Fatalf("%s: Cancel: %v", step.name, err)
+			}
+			if got := s.Utterance(); got != step.want+1 {
+				t.Errorf("%s: Utterance = %d, want %d", step.name, got, step.want+1)
+			}
+			continue
+		}
+
+		if got := s.Utterance(); got != step.want {
+			t.Errorf("%s: Utterance before writing = %d, want %d", step.name, got, step.want)
+		}
+		if err := s.Write(step.text); err != nil {
+			t.Fatalf("%s: Write: %v", step.name, err)
+		}
+		if err := s.Flush(); err != nil {
+			t.Fatalf("%s: Flush: %v", step.name, err)
+		}
+		chunks := utterance(t, s, step.want)
+		if got := words(chunks); !slices.Equal(got, []string{step.text}) {
+			t.Errorf("%s: marks %v, want [%s]", step.name, got, step.text)
+		}
+		if chunks[0].Offset != 0 {
+			t.Errorf("%s: utterance starts at %v, want 0", step.name, chunks[0].Offset)
+		}
+		if got := s.Utterance(); got != step.want+1 {
+			t.Errorf("%s: Utterance after done = %d, want %d", step.name, got, step.want+1)
+		}
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, ok := <-s.Audio(); ok {
+		t.Error("audio not closed after Close")
+	}
+}
@@ -0,0 +1,184 @@
+// Package ratelimit provides token-bucket rate limiters for Phonic AI
+// Calling Agent, backed by memory for single instances or Redis for
+// distributed enforcement
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+// Limit describes a token bucket
+type Limit struct {
+	// Rate is the refill rate in tokens per second
+	Rate float64
+	// Burst is the bucket capacity
+	Burst int
+	// IdleTTL is how long an unused bucket is kept before it is dropped
+	IdleTTL time.Duration
+}
+
+// FromConfig converts RateLimitConfig into a Limit. Requests per minute
+// sets the refill rate and burst size the capacity. Idle buckets are kept
+// for the window size, but never for less than it takes an empty bucket
+// to refill; dropping one sooner would hand out a fresh burst early.
+// Check cfg.Enabled() first: without a refill rate the bucket never
+// refills, and with no burst either it denies every request.
+func FromConfig(cfg config.RateLimitConfig) Limit {
+	burst := cfg.BurstSize
+	if burst <= 0 {
+		burst = cfg.RequestsPerMinute
+	}
+	limit := Limit{
+		Rate:    float64(cfg.RequestsPerMinute) / 60,
+		Burst:   burst,
+		IdleTTL: cfg.WindowSize,
+	}
+	if limit.IdleTTL <= 0 {
+		limit.IdleTTL = time.Minute
+	}
+	if refill := limit.refillTime(); refill > limit.IdleTTL {
+		limit.IdleTTL = refill
+	}
+	return limit
+}
+
+// refillTime is how long an empty bucket takes to fill, rounded up to
+// whole seconds
+func (limit Limit) refillTime() time.Duration {
+	if limit.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(float64(limit.Burst)/limit.Rate)) * time.Second
+}
+
+// Result is the outcome of a single Allow call
+type Result struct {
+	Allowed bool
+	// Limit is the bucket capacity
+	Limit int
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// RetryAfter is how long until a token is available when denied
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Limiter decides whether a request identified by key may proceed
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+	// SetLimit replaces the limit at runtime, e.g. on config reload
+	SetLimit(limit Limit)
+	// Limit returns the current limit
+	Limit() Limit
+}
+
+// bucket is the state of one in-memory token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter is an in-process token bucket limiter
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates an in-memory limiter
+func NewMemoryLimiter(limit Limit) *MemoryLimiter {
+	return &MemoryLimiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// SetLimit replaces the limit; existing buckets are clamped to the new burst
+func (l *MemoryLimiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	for _, b := range l.buckets {
+		b.tokens = math.Min(b.tokens, float64(limit.Burst))
+	}
+}
+
+// Limit returns the current limit
+func (l *MemoryLimiter) Limit() Limit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Allow takes one token from the bucket for key
+func (l *MemoryLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	return l.limit.result(allowed, b.tokens), nil
+}
+
+// sweep drops buckets idle for longer than IdleTTL, at most once per TTL
+func (l *MemoryLimiter) sweep(now time.Time) {
+	ttl := l.limit.IdleTTL
+	if ttl <= 0 || now.Sub(l.lastSweep) < ttl {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > ttl {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// result builds a Result from the tokens left after a decision
+func (limit Limit) result(allowed bool, tokens float64) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+	}
+	if limit.Rate <= 0 {
+		return res
+	}
+	if !allowed {
+		res.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	res.ResetAfter = secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate)
+	return res
+}
+
+// secondsToDuration converts fractional seconds to a Duration
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
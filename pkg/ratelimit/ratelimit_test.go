@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+func TestFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.RateLimitConfig
+		wantRate  float64
+		wantBurst int
+		wantTTL   time.Duration
+	}{
+		{"window longer than refill", config.RateLimitConfig{RequestsPerMinute: 60, BurstSize: 10, WindowSize: time.Minute}, 1, 10, time.Minute},
+		{"refill longer than window", config.RateLimitConfig{RequestsPerMinute: 60, BurstSize: 300, WindowSize: time.Minute}, 1, 300, 5 * time.Minute},
+		{"refill rounds up", config.RateLimitConfig{RequestsPerMinute: 7, BurstSize: 10, WindowSize: time.Second}, 7.0 / 60, 10, 86 * time.Second},
+		{"burst defaults to rate", config.RateLimitConfig{RequestsPerMinute: 120, WindowSize: 10 * time.Second}, 2, 120, time.Minute},
+		{"window defaults to a minute", config.RateLimitConfig{RequestsPerMinute: 600, BurstSize: 10}, 10, 10, time.Minute},
+		{"no rate", config.RateLimitConfig{BurstSize: 10, WindowSize: time.Second}, 0, 10, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := FromConfig(tt.cfg)
+			if limit.Rate != tt.wantRate || limit.Burst != tt.wantBurst || limit.IdleTTL != tt.wantTTL {
+				t.Errorf("FromConfig = %+v, want rate %v burst %d ttl %v", limit, tt.wantRate, tt.wantBurst, tt.wantTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript refills and takes from a bucket atomically.
+// KEYS[1] bucket key; ARGV: rate (tokens/s), burst, now (ms), ttl (ms).
+// Returns {allowed, tokens*1000}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+  tokens = burst
+  ts = now
+end
+
+local elapsed = math.max(0, now - ts) / 1000
+tokens = math.min(burst, tokens + elapsed * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], ttl)
+
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+// RedisLimiter is a token bucket limiter shared by every instance through
+// Redis. The refill and take happen in a single Lua script.
+type RedisLimiter struct {
+	client redis.Scripter
+	prefix string
+
+	mu    sync.RWMutex
+	limit Limit
+}
+
+// NewRedisLimiter creates a Redis-backed limiter. Keys are stored as
+// prefix + key, e.g. "phonic:ratelimit:" + "tenant:acme".
+func NewRedisLimiter(client redis.Scripter, prefix string, limit Limit) *RedisLimiter {
+	if prefix == "" {
+		prefix = "phonic:ratelimit:"
+	}
+	return &RedisLimiter{client: client, prefix: prefix, limit: limit}
+}
+
+// SetLimit replaces the limit for all subsequent requests
+func (l *RedisLimiter) SetLimit(limit Limit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// Limit returns the current limit
+func (l *RedisLimiter) Limit() Limit {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.limit
+}
+
+// Allow takes one token from the shared bucket for key
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	limit := l.Limit()
+
+	ttl := limit.IdleTTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	values, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		limit.Rate,
+		limit.Burst,
+		time.Now().UnixMilli(),
+		ttl.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("rate limit script returned %d values", len(values))
+	}
+
+	return limit.result(values[0] == 1, float64(values[1])/1000), nil
+}
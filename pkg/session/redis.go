@@ -0,0 +1,413 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// expireBatch is the most sessions ExpireAbandoned fails in one call
+const expireBatch = 100
+
+// AbandonedReason is the Reason of a session failed for missing heartbeats
+const AbandonedReason = "abandoned"
+
+// errUnchanged aborts an update without writing
+var errUnchanged = errors.New("session unchanged")
+
+// Manager stores sessions in Redis. Each session is a JSON value under its
+// own key; live sessions are also indexed by heartbeat time so abandoned
+// ones can be found. Writes use WATCH/MULTI, so concurrent updates to a
+// session are retried rather than lost, and lifecycle events are published
+// in the same transaction as the change.
+type Manager struct {
+	client redis.UniversalClient
+	opts   Options
+	logger *logger.Logger
+	now    func() time.Time
+}
+
+// NewManager creates a Redis-backed session manager
+func NewManager(client redis.UniversalClient, opts Options, log *logger.Logger) *Manager {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	return &Manager{
+		client: client,
+		opts:   opts.withDefaults(),
+		logger: log,
+		now:    time.Now,
+	}
+}
+
+// Options returns the manager options with defaults applied
+func (m *Manager) Options() Options {
+	return m.opts
+}
+
+// Create stores s as a new pending session, generating its ID and Token
+// when they are empty
+func (m *Manager) Create(ctx context.Context, s *Session) error {
+	if s.TenantID == "" {
+		return fmt.Errorf("session tenant is required")
+	}
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	if s.Token == "" {
+		token := make([]byte, 24)
+		if _, err := rand.Read(token); err != nil {
+			return fmt.Errorf("failed to generate session token: %w", err)
+		}
+		s.Token = hex.EncodeToString(token)
+	}
+	now := m.now().UTC()
+	s.Status = StatusPending
+	s.Reason = ""
+	s.Version = 0
+	s.CreatedAt = now
+	s.HeartbeatAt = now
+	s.StartedAt = nil
+	s.EndedAt = nil
+
+	key := m.key(s.ID)
+	err := m.client.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check session: %w", err)
+		}
+		if n > 0 {
+			return ErrExists
+		}
+		return m.write(ctx, tx, s, EventCreated, "", now)
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+
+	m.logger.WithContext(ctx).Info("Session created",
+		zap.String("session_id", s.ID),
+		zap.String("tenant_id", s.TenantID),
+		zap.String("caller", s.Caller),
+	)
+	return nil
+}
+
+// Get returns the session with id
+func (m *Manager) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := m.client.Get(ctx, m.key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &s, nil
+}
+
+// Transition moves a session to status, recording reason if it is set
+func (m *Manager) Transition(ctx context.Context, id string, status Status, reason string) (*Session, error) {
+	return m.Update(ctx, id, func(s *Session) error {
+		if s.Status == status {
+			return fmt.Errorf("%w: already %s", ErrInvalidTransition, status)
+		}
+		s.Status = status
+		if reason != "" {
+			s.Reason = reason
+		}
+		return nil
+	})
+}
+
+// Update applies fn to the current session and stores the result, running
+// fn again if the session changes concurrently. fn may change the status,
+// which is checked against the state machine; an error from fn aborts the
+// update and is returned.
+func (m *Manager) Update(ctx context.Context, id string, fn func(s *Session) error) (*Session, error) {
+	key := m.key(id)
+	for attempt := range m.opts.MaxRetries {
+		var updated *Session
+		var previous Status
+		err := m.client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if errors.Is(err, redis.Nil) {
+				return ErrNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get session: %w", err)
+			}
+			var s Session
+			if err := json.Unmarshal(data, &s); err != nil {
+				return fmt.Errorf("failed to decode session: %w", err)
+			}
+			updated = &s
+
+			current := s
+			previous = current.Status
+			if err := fn(&s); err != nil {
+				return err
+			}
+			// Identity and bookkeeping are not for fn to change
+			s.ID = current.ID
+			s.CreatedAt = current.CreatedAt
+			s.Version = current.Version
+
+			now := m.now().UTC()
+			eventType := EventType("")
+			if s.Status != current.Status {
+				status := s.Status
+				s.Status = current.Status
+				if err := s.setStatus(status, now); err != nil {
+					return err
+				}
+				eventType = EventStatusChanged
+			}
+			return m.write(ctx, tx, &s, eventType, current.Status, now)
+		}, key)
+
+		if errors.Is(err, redis.TxFailedErr) {
+			// Back off a little so racing writers spread out
+			timer := time.NewTimer(time.Duration(1+mrand.IntN(attempt+1)) * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if updated.Status != previous {
+			m.logger.WithContext(ctx).Info("Session status changed",
+				zap.String("session_id", updated.ID),
+				zap.String("tenant_id", updated.TenantID),
+				zap.String("previous", string(previous)),
+				zap.String("status", string(updated.Status)),
+				zap.String("reason", updated.Reason),
+			)
+		}
+		return updated, nil
+	}
+	return nil, ErrConflict
+}
+
+// Heartbeat records that the session's call is still alive
+func (m *Manager) Heartbeat(ctx context.Context, id string) error {
+	_, err := m.Update(ctx, id, func(s *Session) error {
+		if s.Status.Terminal() {
+			return ErrEnded
+		}
+		s.HeartbeatAt = m.now().UTC()
+		return nil
+	})
+	return err
+}
+
+// Delete removes a session
+func (m *Manager) Delete(ctx context.Context, id string) error {
+	_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, m.key(id))
+		pipe.ZRem(ctx, m.indexKey(), id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// ExpireAbandoned fails live sessions that have missed heartbeats for
+// HeartbeatTimeout and returns how many it failed. It handles a batch of
+// sessions per call, so it is run periodically; several instances may
+// run it at once.
+func (m *Manager) ExpireAbandoned(ctx context.Context) (int, error) {
+	cutoff := m.now().UTC().Add(-m.opts.HeartbeatTimeout)
+	ids, err := m.client.ZRangeByScore(ctx, m.indexKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(cutoff.UnixMilli(), 10),
+		Count: expireBatch,
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list abandoned sessions: %w", err)
+	}
+
+	expired := 0
+	for _, id := range ids {
+		_, err := m.Update(ctx, id, func(s *Session) error {
+			if s.Status.Terminal() || s.HeartbeatAt.After(cutoff) {
+				return errUnchanged
+			}
+			s.Status = StatusFailed
+			s.Reason = AbandonedReason
+			return nil
+		})
+		switch {
+		case err == nil:
+			expired++
+		case errors.Is(err, ErrNotFound):
+			// The session's TTL ran out; drop it from the index
+			if err := m.client.ZRem(ctx, m.indexKey(), id).Err(); err != nil {
+				return expired, fmt.Errorf("failed to unindex session: %w", err)
+			}
+		case errors.Is(err, errUnchanged):
+		default:
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// Run expires abandoned sessions every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := m.ExpireAbandoned(ctx)
+			if err != nil {
+				m.logger.WithContext(ctx).Error("Failed to expire abandoned sessions", zap.Error(err))
+			}
+			if n > 0 {
+				m.logger.WithContext(ctx).Warn("Expired abandoned sessions", zap.Int("sessions", n))
+			}
+		}
+	}
+}
+
+// write stores s within a WATCH transaction, indexing live sessions by
+// heartbeat and publishing an event of eventType unless it is empty
+func (m *Manager) write(ctx context.Context, tx *redis.Tx, s *Session, eventType EventType, previous Status, now time.Time) error {
+	s.Version++
+	s.UpdatedAt = now
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	var event []byte
+	if eventType != "" {
+		event, err = json.Marshal(Event{Type: eventType, Previous: previous, Session: *s, Time: now})
+		if err != nil {
+			return fmt.Errorf("failed to encode session event: %w", err)
+		}
+	}
+
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if s.Status.Terminal() {
+			pipe.Set(ctx, m.key(s.ID), data, m.opts.EndedTTL)
+			pipe.ZRem(ctx, m.indexKey(), s.ID)
+		} else {
+			pipe.Set(ctx, m.key(s.ID), data, m.opts.TTL)
+			pipe.ZAdd(ctx, m.indexKey(), &redis.Z{Score: float64(s.HeartbeatAt.UnixMilli()), Member: s.ID})
+		}
+		if event != nil {
+			pipe.Publish(ctx, m.opts.Channel, event)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	return err
+}
+
+// key returns the Redis key of session id
+func (m *Manager) key(id string) string {
+	return m.opts.Prefix + id
+}
+
+// indexKey returns the key of the sorted set of live sessions, scored by
+// last heartbeat in Unix milliseconds
+func (m *Manager) indexKey() string {
+	return m.opts.Prefix + "heartbeats"
+}
+
+// Subscription receives lifecycle events. Pub/sub delivers each event at
+// most once, and only to subscribers connected when it is published.
+type Subscription struct {
+	pubsub *redis.PubSub
+	events chan Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Subscribe starts receiving lifecycle events
+func (m *Manager) Subscribe(ctx context.Context) (*Subscription, error) {
+	pubsub := m.client.Subscribe(ctx, m.opts.Channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to session events: %w", err)
+	}
+	sub := &Subscription{
+		pubsub: pubsub,
+		events: make(chan Event, 64),
+		done:   make(chan struct{}),
+	}
+	go sub.run(m.logger)
+	return sub, nil
+}
+
+// Events returns the events, closed after Close
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		err = s.pubsub.Close()
+	})
+	return err
+}
+
+// run decodes messages into events until the subscription is closed
+func (s *Subscription) run(log *logger.Logger) {
+	defer close(s.events)
+	messages := s.pubsub.Channel()
+	for {
+		select {
+		case <-s.done:
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Warn("Skipping malformed session event", zap.Error(err))
+				continue
+			}
+			select {
+			case s.events <- event:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// clock is a settable time source for Manager.now
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func newTestManager(t *testing.T, opts Options) (*Manager, *miniredis.Miniredis, *clock) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	m := NewManager(client, opts, &logger.Logger{Logger: zap.NewNop()})
+	c := &clock{now: time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)}
+	m.now = c.Now
+	return m, mr, c
+}
+
+func create(t *testing.T, m *Manager) *Session {
+	t.Helper()
+	s := &Session{TenantID: "acme", Caller: "+15550100"}
+	if err := m.Create(context.Background(), s); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return s
+}
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name string
+		// path leads from pending to the state under test
+		path    []Status
+		to      Status
+		wantErr bool
+	}{
+		{name: "inbound answered", to: StatusActive},
+		{name: "outbound dialled", to: StatusRinging},
+		{name: "outbound answered", path: []Status{StatusRinging}, to: StatusActive},
+		{name: "never answered", path: []Status{StatusRinging}, to: StatusEnded},
+		{name: "hung up", path: []Status{StatusActive, StatusEnding}, to: StatusEnded},
+		{name: "broke", path: []Status{StatusActive}, to: StatusFailed},
+		{name: "pending to ending", to: StatusEnding, wantErr: true},
+		{name: "back to pending", path: []Status{StatusRinging}, to: StatusPending, wantErr: true},
+		{name: "active to ringing", path: []Status{StatusActive}, to: StatusRinging, wantErr: true},
+		{name: "ending to active", path: []Status{StatusActive, StatusEnding}, to: StatusActive, wantErr: true},
+		{name: "ended reopened", path: []Status{StatusEnded}, to: StatusActive, wantErr: true},
+		{name: "failed to ended", path: []Status{StatusFailed}, to: StatusEnded, wantErr: true},
+		{name: "same status", path: []Status{StatusActive}, to: StatusActive, wantErr: true},
+		{name: "unknown status", to: Status("on_hold"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _, _ := newTestManager(t, Options{})
+			ctx := context.Background()
+			s := create(t, m)
+			for _, status := range tt.path {
+				if _, err := m.Transition(ctx, s.ID, status, ""); err != nil {
+					t.Fatalf("Transition to %s: %v", status, err)
+				}
+			}
+			before, err := m.Get(ctx, s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := m.Transition(ctx, s.ID, tt.to, "test")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("Transition = %v, want ErrInvalidTransition", err)
+				}
+				// A rejected transition writes nothing
+				after, err := m.Get(ctx, s.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if after.Status != before.Status || after.Version != before.Version {
+					t.Errorf("session is %s v%d, want %s v%d", after.Status, after.Version, before.Status, before.Version)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Transition: %v", err)
+			}
+			if updated.Status != tt.to || updated.Reason != "test" || updated.Version != before.Version+1 {
+				t.Errorf("session is %s (%q) v%d, want %s (test) v%d",
+					updated.Status, updated.Reason, updated.Version, tt.to, before.Version+1)
+			}
+			if wantStarted := tt.to == StatusActive || before.StartedAt != nil; (updated.StartedAt != nil) != wantStarted {
+				t.Errorf("StartedAt = %v, want set %v", updated.StartedAt, wantStarted)
+			}
+			if (updated.EndedAt != nil) != tt.to.Terminal() {
+				t.Errorf("EndedAt = %v, want set %v", updated.EndedAt, tt.to.Terminal())
+			}
+		})
+	}
+}
+
+func TestUpdateRetriesOnConflict(t *testing.T) {
+	m, _, _ := newTestManager(t, Options{})
+	ctx := context.Background()
+	s := create(t, m)
+
+	// Another writer changes the session between the outer update's read
+	// and its write, so EXEC fails and the update runs again on the new
+	// value
+	calls := 0
+	updated, err := m.Update(ctx, s.ID, func(s *Session) error {
+		calls++
+		if calls == 1 {
+			if _, err := m.Update(ctx, s.ID, func(s *Session) error {
+				s.Metadata = map[string]string{"other": "yes"}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		if s.Metadata == nil {
+			s.Metadata = map[string]string{}
+		}
+		s.Metadata["mine"] = "yes"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("fn ran %d times, want 2", calls)
+	}
+	if updated.Metadata["other"] != "yes" || updated.Metadata["mine"] != "yes" {
+		t.Errorf("metadata %v, want both writers' changes", updated.Metadata)
+	}
+	if updated.Version != 3 {
+		t.Errorf("version %d, want 3", updated.Version)
+	}
+}
+
+func TestUpdateGivesUpAfterMaxRetries(t *testing.T) {
+	m, _, _ := newTestManager(t, Options{MaxRetries: 3})
+	ctx := context.Background()
+	s := create(t, m)
+
+	calls := 0
+	_, err := m.Update(ctx, s.ID, func(s *Session) error {
+		calls++
+		// Conflict on every attempt
+		return m.Heartbeat(ctx, s.ID)
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Update = %v, want ErrConflict", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn ran %d times, want MaxRetries", calls)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	m, _, _ := newTestManager(t, Options{MaxRetries: 100})
+	ctx := context.Background()
+	s := create(t, m)
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Update(ctx, s.ID, func(s *Session) error {
+				if s.Metadata == nil {
+					s.Metadata = map[string]string{}
+				}
+				s.Metadata[strconv.Itoa(i)] = "done"
+				return nil
+			})
+			if err != nil {
+				t.Errorf("writer %d: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := m.Get(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Metadata) != writers || got.Version != writers+1 {
+		t.Errorf("%d writes kept at version %d, want %d at %d", len(got.Metadata), got.Version, writers, writers+1)
+	}
+}
+
+func TestExpireAbandoned(t *testing.T) {
+	m, _, clock := newTestManager(t, Options{HeartbeatTimeout: 30 * time.Second, TTL: time.Hour})
+	ctx := context.Background()
+
+	alive, abandoned, ended := create(t, m), create(t, m), create(t, m)
+	for _, s := range []*Session{alive, abandoned} {
+		if _, err := m.Transition(ctx, s.ID, StatusActive, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Transition(ctx, ended.ID, StatusEnded, "hangup"); err != nil {
+		t.Fatal(err)
+	}
+
+	expire := func(want int) {
+		t.Helper()
+		n, err := m.ExpireAbandoned(ctx)
+		if err != nil {
+			t.Fatalf("ExpireAbandoned: %v", err)
+		}
+		if n != want {
+			t.Errorf("expired %d sessions, want %d", n, want)
+		}
+	}
+	status := func(s *Session) *Session {
+		t.Helper()
+		got, err := m.Get(ctx, s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// Within the timeout nothing expires
+	clock.Advance(20 * time.Second)
+	expire(0)
+	if err := m.Heartbeat(ctx, alive.ID); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	// Only the session that stopped heartbeating is failed
+	clock.Advance(15 * time.Second)
+	expire(1)
+	if got := status(abandoned); got.Status != StatusFailed || got.Reason != AbandonedReason || got.EndedAt == nil {
+		t.Errorf("abandoned session is %s (%q), want failed (%s)", got.Status, got.Reason, AbandonedReason)
+	}
+	if got := status(alive); got.Status != StatusActive {
+		t.Errorf("heartbeating session is %s, want active", got.Status)
+	}
+	if got := status(ended); got.Status != StatusEnded || got.Reason != "hangup" {
+		t.Errorf("ended session is %s (%q), want it untouched", got.Status, got.Reason)
+	}
+	expire(0)
+	if err := m.Heartbeat(ctx, abandoned.ID); !errors.Is(err, ErrEnded) {
+		t.Errorf("Heartbeat on an expired session = %v, want ErrEnded", err)
+	}
+
+	// The other session expires once its heartbeats stop too
+	clock.Advance(31 * time.Second)
+	expire(1)
+	if got := status(alive); got.Status != StatusFailed {
+		t.Errorf("session is %s after missing heartbeats, want failed", got.Status)
+	}
+}
+
+func TestExpireAbandonedDropsExpiredKeys(t *testing.T) {
+	m, mr, clock := newTestManager(t, Options{HeartbeatTimeout: 30 * time.Second, TTL: time.Minute})
+	ctx := context.Background()
+	s := create(t, m)
+
+	// The session's key runs out its TTL, but the index still lists it
+	mr.FastForward(2 * time.Minute)
+	clock.Advance(2 * time.Minute)
+	if _, err := m.Get(ctx, s.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get = %v, want ErrNotFound", err)
+	}
+
+	n, err := m.ExpireAbandoned(ctx)
+	if err != nil || n != 0 {
+		t.Fatalf("ExpireAbandoned = %d, %v; want 0", n, err)
+	}
+	if members, _ := mr.ZMembers(m.indexKey()); len(members) != 0 {
+		t.Errorf("index still holds %v", members)
+	}
+}
@@ -0,0 +1,212 @@
+// Package session tracks call sessions in Redis. A session moves through
+// a fixed set of states, is kept alive by heartbeats, and every change of
+// state is published for other services.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+var (
+	// ErrNotFound is returned for an unknown or expired session
+	ErrNotFound = errors.New("session not found")
+	// ErrExists is returned when creating a session whose ID is taken
+	ErrExists = errors.New("session already exists")
+	// ErrInvalidTransition is returned for a status change the state
+	// machine does not allow
+	ErrInvalidTransition = errors.New("invalid session transition")
+	// ErrEnded is returned when heartbeating a session that has ended
+	ErrEnded = errors.New("session has ended")
+	// ErrConflict is returned when concurrent writers keep changing a
+	// session and an update gives up
+	ErrConflict = errors.New("session changed concurrently")
+)
+
+// Status is the state of a session; the values match call_sessions.status
+type Status string
+
+// Session states
+const (
+	// StatusPending is a session that has been created but not connected
+	StatusPending Status = "pending"
+	// StatusRinging is an outbound call waiting to be answered
+	StatusRinging Status = "ringing"
+	// StatusActive is a connected call
+	StatusActive Status = "active"
+	// StatusEnding is a call being hung up, e.g. while the goodbye plays
+	StatusEnding Status = "ending"
+	// StatusEnded is a call that finished normally
+	StatusEnded Status = "ended"
+	// StatusFailed is a call that could not connect, broke or was abandoned
+	StatusFailed Status = "failed"
+)
+
+// transitions lists the states each state may move to. Inbound calls go
+// from pending straight to active; a call that is never answered ends
+// from pending or ringing.
+var transitions = map[Status][]Status{
+	StatusPending: {StatusRinging, StatusActive, StatusEnded, StatusFailed},
+	StatusRinging: {StatusActive, StatusEnded, StatusFailed},
+	StatusActive:  {StatusEnding, StatusEnded, StatusFailed},
+	StatusEnding:  {StatusEnded, StatusFailed},
+}
+
+// Valid reports whether s is a known state
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok || s.Terminal()
+}
+
+// Terminal reports whether s is a final state
+func (s Status) Terminal() bool {
+	return s == StatusEnded || s == StatusFailed
+}
+
+// CanTransition reports whether a session may move from s to to
+func (s Status) CanTransition(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentConfig is the agent that handles a call
+type AgentConfig struct {
+	Name         string            `json:"name,omitempty"`
+	VoiceID      string            `json:"voice_id,omitempty"`
+	Language     string            `json:"language,omitempty"`
+	SystemPrompt string            `json:"system_prompt,omitempty"`
+	Options      map[string]string `json:"options,omitempty"`
+}
+
+// Session is a call session
+type Session struct {
+	ID string `json:"id"`
+	// Token identifies the session to the caller's media connection; it
+	// is stored as call_sessions.session_token
+	Token    string `json:"token"`
+	TenantID string `json:"tenant_id"`
+	// UserID is the account that owns the call, if any
+	UserID string `json:"user_id,omitempty"`
+	// Caller identifies the other party, e.g. a phone number
+	Caller string      `json:"caller"`
+	Agent  AgentConfig `json:"agent"`
+	Status Status      `json:"status"`
+	// Reason explains why a session ended or failed
+	Reason   string            `json:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Version counts writes to the session
+	Version     int64      `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	HeartbeatAt time.Time  `json:"heartbeat_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+}
+
+// Duration returns how long the call was connected, up to now for a live
+// call, or zero if it never connected
+func (s *Session) Duration() time.Duration {
+	if s.StartedAt == nil {
+		return 0
+	}
+	if s.EndedAt != nil {
+		return s.EndedAt.Sub(*s.StartedAt)
+	}
+	return time.Since(*s.StartedAt)
+}
+
+// setStatus moves the session to status at now, stamping the start and
+// end times
+func (s *Session) setStatus(status Status, now time.Time) error {
+	if !s.Status.CanTransition(status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, s.Status, status)
+	}
+	s.Status = status
+	if status == StatusActive && s.StartedAt == nil {
+		s.StartedAt = &now
+	}
+	if status.Terminal() {
+		s.EndedAt = &now
+	}
+	return nil
+}
+
+// EventType is the kind of lifecycle event
+type EventType string
+
+// Lifecycle events
+const (
+	// EventCreated is published when a session is created
+	EventCreated EventType = "session.created"
+	// EventStatusChanged is published when a session changes state,
+	// including when it is failed for missing heartbeats
+	EventStatusChanged EventType = "session.status_changed"
+)
+
+// Event is a lifecycle event, published as JSON
+type Event struct {
+	Type EventType `json:"type"`
+	// Previous is the state before a status change
+	Previous Status    `json:"previous,omitempty"`
+	Session  Session   `json:"session"`
+	Time     time.Time `json:"time"`
+}
+
+// Options configures a Manager
+type Options struct {
+	// TTL is how long a live session is kept after its last write or
+	// heartbeat, a backstop in case expiry stops running
+	TTL time.Duration
+	// HeartbeatTimeout is how long a live session may go without a
+	// heartbeat before ExpireAbandoned fails it
+	HeartbeatTimeout time.Duration
+	// EndedTTL is how long an ended or failed session is kept, e.g. for it
+	// to be persisted
+	EndedTTL time.Duration
+	// MaxRetries bounds how often an update is retried when the session
+	// changes under it
+	MaxRetries int
+	// Prefix is prepended to Redis keys
+	Prefix string
+	// Channel is the pub/sub channel for lifecycle events
+	Channel string
+}
+
+// FromConfig converts SessionConfig into Options
+func FromConfig(cfg config.SessionConfig) Options {
+	return Options{
+		TTL:              cfg.TTL,
+		HeartbeatTimeout: cfg.HeartbeatTimeout,
+		EndedTTL:         cfg.EndedTTL,
+	}
+}
+
+// withDefaults fills in unset options
+func (o Options) withDefaults() Options {
+	if o.TTL <= 0 {
+		o.TTL = 4 * time.Hour
+	}
+	if o.HeartbeatTimeout <= 0 {
+		o.HeartbeatTimeout = 30 * time.Second
+	}
+	if o.EndedTTL <= 0 {
+		o.EndedTTL = 10 * time.Minute
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 10
+	}
+	if o.Prefix == "" {
+		o.Prefix = "phonic:session:"
+	}
+	if o.Channel == "" {
+		o.Channel = "phonic:session:events"
+	}
+	return o
+}
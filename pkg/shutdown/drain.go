@@ -0,0 +1,309 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// ErrDraining is returned when a new call is started while draining
+var ErrDraining = errors.New("service is draining, not accepting new calls")
+
+// ErrCallActive is returned when a call is started for a session that
+// already has an active call
+var ErrCallActive = errors.New("call already active for session")
+
+// DrainState describes where a Drainer is in its lifecycle
+type DrainState string
+
+const (
+	DrainStateRunning  DrainState = "running"
+	DrainStateDraining DrainState = "draining"
+	DrainStateDrained  DrainState = "drained"
+	DrainStateForced   DrainState = "forced"
+)
+
+// ReadinessController flips service readiness; health.Manager implements it
+type ReadinessController interface {
+	SetNotReady(reason string)
+}
+
+// CallbackFunc tells a caller that was still connected when the drain
+// deadline passed that we will call them back
+type CallbackFunc func(ctx context.Context, sessionID string) error
+
+// DrainConfig configures call draining
+type DrainConfig struct {
+	// MaxDrainTime bounds how long to wait for active calls to finish
+	MaxDrainTime time.Duration
+	// PollInterval is how often the active-call counter is checked
+	PollInterval time.Duration
+	// ProgressInterval is how often drain progress is logged
+	ProgressInterval time.Duration
+	// CallbackTimeout bounds each callback notification
+	CallbackTimeout time.Duration
+}
+
+// DefaultDrainConfig returns sensible defaults for voice calls
+func DefaultDrainConfig() DrainConfig {
+	return DrainConfig{
+		MaxDrainTime:     5 * time.Minute,
+		PollInterval:     500 * time.Millisecond,
+		ProgressInterval: 10 * time.Second,
+		CallbackTimeout:  5 * time.Second,
+	}
+}
+
+// DrainStatus is the JSON document served by StatusHandler
+type DrainStatus struct {
+	State        DrainState `json:"state"`
+	ActiveCalls  int        `json:"active_calls"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	Elapsed      string     `json:"elapsed,omitempty"`
+	MaxDrainTime string     `json:"max_drain_time"`
+	ForcedCalls  int        `json:"forced_calls,omitempty"`
+}
+
+// Drainer tracks active calls and drains them on shutdown: it marks the
+// service not ready, rejects new calls, waits for active calls to finish
+// and force-closes whatever is left at the deadline.
+type Drainer struct {
+	config    DrainConfig
+	readiness ReadinessController
+	callback  CallbackFunc
+	logger    *logger.Logger
+
+	mu        sync.Mutex
+	calls     map[string]context.CancelFunc
+	state     DrainState
+	startedAt time.Time
+	forced    int
+}
+
+// NewDrainer creates a call drainer. readiness may be nil.
+func NewDrainer(cfg DrainConfig, readiness ReadinessController, log *logger.Logger) *Drainer {
+	defaults := DefaultDrainConfig()
+	if cfg.MaxDrainTime <= 0 {
+		cfg.MaxDrainTime = defaults.MaxDrainTime
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaults.PollInterval
+	}
+	if cfg.ProgressInterval <= 0 {
+		cfg.ProgressInterval = defaults.ProgressInterval
+	}
+	if cfg.CallbackTimeout <= 0 {
+		cfg.CallbackTimeout = defaults.CallbackTimeout
+	}
+
+	return &Drainer{
+		config:    cfg,
+		readiness: readiness,
+		logger:    log,
+		calls:     make(map[string]context.CancelFunc),
+		state:     DrainStateRunning,
+	}
+}
+
+// SetCallback registers the notification sent to callers that are still
+// connected when the drain deadline passes
+func (d *Drainer) SetCallback(callback CallbackFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.callback = callback
+}
+
+// StartCall registers an active call. The returned context is cancelled
+// if the call is force-closed; done must be called when the call ends.
+// It returns ErrDraining once draining has begun and ErrCallActive if
+// sessionID already has a call in progress.
+func (d *Drainer) StartCall(ctx context.Context, sessionID string) (context.Context, func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state != DrainStateRunning {
+		return nil, nil, ErrDraining
+	}
+	if _, ok := d.calls[sessionID]; ok {
+		return nil, nil, fmt.Errorf("session %s: %w", sessionID, ErrCallActive)
+	}
+
+	callCtx, cancel := context.WithCancel(ctx)
+	d.calls[sessionID] = cancel
+
+	// Only the first done removes the call, so a repeated done cannot
+	// drop a later call for the same session
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			d.mu.Lock()
+			delete(d.calls, sessionID)
+			d.mu.Unlock()
+			cancel()
+		})
+	}
+	return callCtx, done, nil
+}
+
+// ActiveCalls returns the number of calls in progress
+func (d *Drainer) ActiveCalls() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.calls)
+}
+
+// Status returns a snapshot of the drain progress
+func (d *Drainer) Status() DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := DrainStatus{
+		State:        d.state,
+		ActiveCalls:  len(d.calls),
+		MaxDrainTime: d.config.MaxDrainTime.String(),
+		ForcedCalls:  d.forced,
+	}
+	if !d.startedAt.IsZero() {
+		started := d.startedAt
+		status.StartedAt = &started
+		status.Elapsed = time.Since(started).Round(time.Millisecond).String()
+	}
+	return status
+}
+
+// StatusHandler serves the drain status as JSON
+func (d *Drainer) StatusHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(d.Status()); err != nil {
+			d.logger.Error("Failed to encode drain status", zap.Error(err))
+		}
+	}
+}
+
+// Drain stops accepting calls and waits for active ones to finish. At
+// MaxDrainTime (or when ctx ends) remaining callers are notified through
+// the callback and their calls are force-closed.
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.mu.Lock()
+	if d.state != DrainStateRunning {
+		d.mu.Unlock()
+		return nil
+	}
+	d.state = DrainStateDraining
+	d.startedAt = time.Now()
+	d.mu.Unlock()
+
+	if d.readiness != nil {
+		d.readiness.SetNotReady("draining active calls")
+	}
+
+	d.logger.Info("Call draining started",
+		zap.Int("active_calls", d.ActiveCalls()),
+		zap.Duration("max_drain_time", d.config.MaxDrainTime),
+	)
+
+	deadline := time.NewTimer(d.config.MaxDrainTime)
+	defer deadline.Stop()
+	poll := time.NewTicker(d.config.PollInterval)
+	defer poll.Stop()
+	progress := time.NewTicker(d.config.ProgressInterval)
+	defer progress.Stop()
+
+	for {
+		if d.ActiveCalls() == 0 {
+			d.setState(DrainStateDrained)
+			d.logger.Info("Call draining completed",
+				zap.Duration("duration", time.Since(d.startedAt)),
+			)
+			return nil
+		}
+
+		select {
+		case <-poll.C:
+		case <-progress.C:
+			d.logger.Info("Call draining in progress",
+				zap.Int("active_calls", d.ActiveCalls()),
+				zap.Duration("elapsed", time.Since(d.startedAt)),
+			)
+		case <-deadline.C:
+			return d.forceClose(ctx)
+		case <-ctx.Done():
+			return d.forceClose(ctx)
+		}
+	}
+}
+
+// Hook returns a shutdown hook that drains calls
+func (d *Drainer) Hook() Hook {
+	return d.Drain
+}
+
+// Register adds the drainer to the drain phase of m and widens the phase
+// timeout so callbacks can be sent after MaxDrainTime
+func (d *Drainer) Register(m *Manager) {
+	m.AddPhaseHook(PhaseDrain, "calls", d.Hook())
+	_ = m.SetPhaseTimeout(PhaseDrain, d.config.MaxDrainTime+2*d.config.CallbackTimeout)
+}
+
+// forceClose notifies and cancels every call that is still active
+func (d *Drainer) forceClose(ctx context.Context) error {
+	d.mu.Lock()
+	remaining := make(map[string]context.CancelFunc, len(d.calls))
+	for id, cancel := range d.calls {
+		remaining[id] = cancel
+	}
+	callback := d.callback
+	d.state = DrainStateForced
+	d.forced = len(remaining)
+	d.mu.Unlock()
+
+	d.logger.Warn("Call drain deadline reached, force-closing calls",
+		zap.Int("active_calls", len(remaining)),
+		zap.Duration("elapsed", time.Since(d.startedAt)),
+	)
+
+	if callback != nil {
+		var wg sync.WaitGroup
+		for id := range remaining {
+			wg.Add(1)
+			go func(sessionID string) {
+				defer wg.Done()
+				// The hook context may already be done; callbacks get their own budget
+				cbCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.config.CallbackTimeout)
+				defer cancel()
+				if err := callback(cbCtx, sessionID); err != nil {
+					d.logger.Error("Callback notification failed",
+						zap.String("session_id", sessionID),
+						zap.Error(err),
+					)
+				}
+			}(id)
+		}
+		wg.Wait()
+	}
+
+	for _, cancel := range remaining {
+		cancel()
+	}
+
+	if len(remaining) > 0 {
+		return fmt.Errorf("drain deadline exceeded with %d active calls", len(remaining))
+	}
+	return nil
+}
+
+// setState updates the drain state
+func (d *Drainer) setState(state DrainState) {
+	d.mu.Lock()
+	d.state = state
+	d.mu.Unlock()
+}
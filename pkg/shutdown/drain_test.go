@@ -0,0 +1,221 @@
+package shutdown
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readiness records SetNotReady calls
+type readiness struct {
+	mu      sync.Mutex
+	reasons []string
+}
+
+func (r *readiness) SetNotReady(reason string) {
+	r.mu.Lock()
+	r.reasons = append(r.reasons, reason)
+	r.mu.Unlock()
+}
+
+func (r *readiness) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.reasons)
+}
+
+func newTestDrainer(maxDrain time.Duration, ready ReadinessController) *Drainer {
+	return NewDrainer(DrainConfig{
+		MaxDrainTime:    maxDrain,
+		PollInterval:    time.Millisecond,
+		CallbackTimeout: time.Second,
+	}, ready, testLogger())
+}
+
+func startCall(t *testing.T, d *Drainer, sessionID string) (context.Context, func()) {
+	t.Helper()
+	ctx, done, err := d.StartCall(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("StartCall(%s): %v", sessionID, err)
+	}
+	return ctx, done
+}
+
+func TestStartCallRejectsActiveSession(t *testing.T) {
+	d := newTestDrainer(time.Second, nil)
+	first, done := startCall(t, d, "call-1")
+
+	if _, _, err := d.StartCall(context.Background(), "call-1"); !errors.Is(err, ErrCallActive) {
+		t.Fatalf("second StartCall = %v, want ErrCallActive", err)
+	}
+	if n := d.ActiveCalls(); n != 1 {
+		t.Errorf("ActiveCalls = %d, want 1", n)
+	}
+
+	// Once the first call ends the session can call again, and a stray
+	// second done from the first call leaves the new one registered
+	done()
+	if first.Err() == nil {
+		t.Error("call context not cancelled by done")
+	}
+	second, _ := startCall(t, d, "call-1")
+	done()
+	if n := d.ActiveCalls(); n != 1 || second.Err() != nil {
+		t.Errorf("ActiveCalls = %d (context %v) after a repeated done, want the new call kept", n, second.Err())
+	}
+}
+
+func TestDrainWaitsForCalls(t *testing.T) {
+	ready := &readiness{}
+	d := newTestDrainer(time.Minute, ready)
+	ctx1, done1 := startCall(t, d, "call-1")
+	_, done2 := startCall(t, d, "call-2")
+
+	drained := make(chan error, 1)
+	go func() { drained <- d.Drain(context.Background()) }()
+
+	// Readiness flips and new calls are refused while calls are running
+	deadline := time.Now().Add(time.Second)
+	for d.Status().State != DrainStateDraining {
+		if time.Now().After(deadline) {
+			t.Fatal("drain did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, _, err := d.StartCall(context.Background(), "call-3"); !errors.Is(err, ErrDraining) {
+		t.Errorf("StartCall while draining = %v, want ErrDraining", err)
+	}
+	if got := ready.got(); len(got) != 1 {
+		t.Errorf("SetNotReady called with %v, want once", got)
+	}
+
+	done1()
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned %v with a call still active", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	done2()
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("Drain: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return after the last call ended")
+	}
+
+	status := d.Status()
+	if status.State != DrainStateDrained || status.ActiveCalls != 0 || status.ForcedCalls != 0 || status.StartedAt == nil {
+		t.Errorf("status %+v, want drained", status)
+	}
+	if ctx1.Err() == nil {
+		t.Error("finished call's context not cancelled")
+	}
+
+	// A second drain is a no-op
+	if err := d.Drain(context.Background()); err != nil {
+		t.Errorf("second Drain: %v", err)
+	}
+}
+
+func TestDrainForceCloses(t *testing.T) {
+	tests := []struct {
+		name string
+		// maxDrain is the drain deadline; cancel instead cancels the hook
+		// context
+		maxDrain time.Duration
+		cancel   bool
+	}{
+		{name: "deadline", maxDrain: 30 * time.Millisecond},
+		{name: "context cancelled", maxDrain: time.Minute, cancel: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDrainer(tt.maxDrain, nil)
+
+			var mu sync.Mutex
+			var called []string
+			d.SetCallback(func(ctx context.Context, sessionID string) error {
+				// Callbacks get their own budget even if the hook context
+				// is done
+				if ctx.Err() != nil {
+					t.Errorf("callback for %s got a done context", sessionID)
+				}
+				mu.Lock()
+				called = append(called, sessionID)
+				mu.Unlock()
+				if sessionID == "call-2" {
+					return errors.New("dialer unavailable")
+				}
+				return nil
+			})
+
+			ctx1, _ := startCall(t, d, "call-1")
+			ctx2, _ := startCall(t, d, "call-2")
+			_, finished := startCall(t, d, "call-3")
+			finished()
+
+			hookCtx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+			if err := d.Drain(hookCtx); err == nil {
+				t.Error("Drain returned nil with calls force-closed")
+			}
+
+			slices.Sort(called)
+			if !slices.Equal(called, []string{"call-1", "call-2"}) {
+				t.Errorf("callbacks sent to %v, want call-1 and call-2", called)
+			}
+			if ctx1.Err() == nil || ctx2.Err() == nil {
+				t.Error("force-closed call contexts not cancelled")
+			}
+			if status := d.Status(); status.State != DrainStateForced || status.ForcedCalls != 2 {
+				t.Errorf("status %+v, want 2 forced", status)
+			}
+		})
+	}
+}
+
+func TestDrainStatusHandler(t *testing.T) {
+	d := newTestDrainer(time.Minute, nil)
+	_, done := startCall(t, d, "call-1")
+	defer done()
+
+	rec := httptest.NewRecorder()
+	d.StatusHandler()(rec, httptest.NewRequest(http.MethodGet, "/health/drain", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var status DrainStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != DrainStateRunning || status.ActiveCalls != 1 || status.MaxDrainTime != "1m0s" || status.StartedAt != nil {
+		t.Errorf("status %+v, want one running call", status)
+	}
+}
+
+func TestDrainerRegister(t *testing.T) {
+	m := NewManager(time.Second, testLogger())
+	d := newTestDrainer(50*time.Millisecond, nil)
+	d.Register(m)
+	_, done := startCall(t, d, "call-1")
+	time.AfterFunc(10*time.Millisecond, done)
+
+	report := m.Shutdown()
+	if err := report.Err(); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if d.Status().State != DrainStateDrained {
+		t.Errorf("state %s after shutdown, want drained", d.Status().State)
+	}
+}
@@ -0,0 +1,159 @@
+package shutdown
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/health"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// ReloadFunc applies a freshly loaded configuration to running components
+type ReloadFunc func(ctx context.Context, cfg *config.Config) error
+
+// ReloadConfigHandler reloads configuration with config.Load, applies the
+// new logging level to log and then calls apply (which may be nil).
+// A configuration that fails validation is rejected and nothing changes.
+func ReloadConfigHandler(configPath string, log *logger.Logger, apply ReloadFunc) SignalHandler {
+	return func(ctx context.Context, sig os.Signal) error {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to reload config: %w", err)
+		}
+
+		if err := log.SetLevel(cfg.Logging.Level); err != nil {
+			return fmt.Errorf("failed to apply log level: %w", err)
+		}
+
+		if apply != nil {
+			if err := apply(ctx, cfg); err != nil {
+				return fmt.Errorf("failed to apply config: %w", err)
+			}
+		}
+
+		log.Info("Configuration reloaded",
+			zap.String("environment", cfg.App.Environment),
+			zap.String("log_level", cfg.Logging.Level),
+		)
+		return nil
+	}
+}
+
+// DiagnosticSource contributes a named section to a diagnostics dump
+type DiagnosticSource func(ctx context.Context) interface{}
+
+// DiagnosticsConfig configures DiagnosticsHandler
+type DiagnosticsConfig struct {
+	// Dir receives one dump file per signal; empty means the dump is logged
+	Dir string
+	// Sources are extra sections such as active sessions or health state
+	Sources map[string]DiagnosticSource
+}
+
+// DiagnosticsHandler dumps goroutine stacks, runtime stats and every
+// configured source, either to a file in cfg.Dir or to the log
+func DiagnosticsHandler(cfg DiagnosticsConfig, log *logger.Logger) SignalHandler {
+	return func(ctx context.Context, sig os.Signal) error {
+		sections := make(map[string]interface{}, len(cfg.Sources)+1)
+		sections["runtime"] = runtimeStats()
+		for name, source := range cfg.Sources {
+			sections[name] = source(ctx)
+		}
+
+		var stacks bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&stacks, 2); err != nil {
+			return fmt.Errorf("failed to dump goroutines: %w", err)
+		}
+
+		if cfg.Dir == "" {
+			fields := []zap.Field{zap.String("goroutines", stacks.String())}
+			for _, name := range sortedKeys(sections) {
+				fields = append(fields, zap.Any(name, sections[name]))
+			}
+			log.Info("Diagnostics dump", fields...)
+			return nil
+		}
+
+		path, err := writeDiagnostics(cfg.Dir, sections, stacks.Bytes())
+		if err != nil {
+			return err
+		}
+		log.Info("Diagnostics dump written", zap.String("path", path))
+		return nil
+	}
+}
+
+// HealthDiagnostics reports the current health state
+func HealthDiagnostics(m *health.Manager) DiagnosticSource {
+	return func(ctx context.Context) interface{} {
+		return m.CheckHealth(ctx)
+	}
+}
+
+// DrainerDiagnostics reports active calls and drain progress
+func DrainerDiagnostics(d *Drainer) DiagnosticSource {
+	return func(ctx context.Context) interface{} {
+		return d.Status()
+	}
+}
+
+// runtimeStats returns a small summary of the Go runtime
+func runtimeStats() map[string]interface{} {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return map[string]interface{}{
+		"goroutines":     runtime.NumGoroutine(),
+		"heap_alloc":     mem.HeapAlloc,
+		"heap_objects":   mem.HeapObjects,
+		"num_gc":         mem.NumGC,
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"go_version":     runtime.Version(),
+		"pause_total_ns": mem.PauseTotalNs,
+	}
+}
+
+// writeDiagnostics writes sections as JSON followed by goroutine stacks
+func writeDiagnostics(dir string, sections map[string]interface{}, stacks []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics dir: %w", err)
+	}
+
+	name := fmt.Sprintf("diagnostics-%d-%s.txt", os.Getpid(), time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+
+	data, err := json.MarshalIndent(sections, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode diagnostics: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data)
+	buf.WriteString("\n\n=== goroutines ===\n")
+	buf.Write(stacks)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+	return path, nil
+}
+
+// sortedKeys returns map keys in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
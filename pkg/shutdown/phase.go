@@ -0,0 +1,350 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Standard shutdown phases, in the order they run
+const (
+	// PhaseStopAccepting stops listeners and rejects new calls
+	PhaseStopAccepting = "stop_accepting"
+	// PhaseDrain waits for in-flight calls and requests to finish
+	PhaseDrain = "drain"
+	// PhaseDefault holds hooks registered through AddHook
+	PhaseDefault = "default"
+	// PhaseFlush flushes and closes Postgres, Redis and other clients
+	PhaseFlush = "flush"
+	// PhaseLogger syncs the logger; it always runs last
+	PhaseLogger = "logger"
+)
+
+// ErrPhaseTimeout is recorded for hooks that were still running, or had
+// not started, when their phase timed out
+var ErrPhaseTimeout = errors.New("shutdown phase timed out")
+
+// Phase is a named shutdown stage. Phases run in ascending priority order;
+// the hooks inside a phase run in parallel under the phase timeout.
+type Phase struct {
+	Name     string
+	Priority int
+	Timeout  time.Duration
+	// Sequential runs hooks one at a time in LIFO order instead of in
+	// parallel. It exists for the legacy AddHook behaviour.
+	Sequential bool
+}
+
+// namedHook is a hook together with the name used in logs and reports
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+// HookResult describes the outcome of a single hook
+type HookResult struct {
+	Phase    string        `json:"phase"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+	TimedOut bool          `json:"timed_out"`
+}
+
+// PhaseResult describes the outcome of a phase
+type PhaseResult struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	TimedOut bool          `json:"timed_out"`
+	Hooks    []HookResult  `json:"hooks"`
+}
+
+// Report summarises a completed shutdown
+type Report struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Phases    []PhaseResult `json:"phases"`
+}
+
+// Failed returns every hook that returned an error or timed out
+func (r *Report) Failed() []HookResult {
+	var failed []HookResult
+	for _, phase := range r.Phases {
+		for _, hook := range phase.Hooks {
+			if hook.Err != nil {
+				failed = append(failed, hook)
+			}
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of all failed hooks, or returns nil
+func (r *Report) Err() error {
+	var errs []error
+	for _, hook := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s/%s: %w", hook.Phase, hook.Name, hook.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// String renders a one-line-per-hook summary
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "shutdown completed in %s\n", r.Duration)
+	for _, phase := range r.Phases {
+		fmt.Fprintf(&b, "%s (%s)\n", phase.Name, phase.Duration)
+		for _, hook := range phase.Hooks {
+			status := "ok"
+			if hook.TimedOut {
+				status = "timed out"
+			} else if hook.Err != nil {
+				status = "failed: " + hook.Err.Error()
+			}
+			fmt.Fprintf(&b, "  %s %s (%s)\n", hook.Name, status, hook.Duration)
+		}
+	}
+	return b.String()
+}
+
+// defaultPhases returns the standard phase set. A zero timeout means the
+// manager timeout is used.
+func defaultPhases() map[string]*Phase {
+	phases := []Phase{
+		{Name: PhaseStopAccepting, Priority: 10},
+		{Name: PhaseDrain, Priority: 20},
+		{Name: PhaseDefault, Priority: 30, Sequential: true},
+		{Name: PhaseFlush, Priority: 40},
+		{Name: PhaseLogger, Priority: 100},
+	}
+
+	m := make(map[string]*Phase, len(phases))
+	for i := range phases {
+		m[phases[i].Name] = &phases[i]
+	}
+	return m
+}
+
+// AddPhase registers a phase or updates an existing one
+func (m *Manager) AddPhase(phase Phase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := phase
+	m.phases[phase.Name] = &p
+}
+
+// SetPhaseTimeout overrides the timeout of an existing phase
+func (m *Manager) SetPhaseTimeout(name string, timeout time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	phase, ok := m.phases[name]
+	if !ok {
+		return fmt.Errorf("unknown shutdown phase %q", name)
+	}
+	phase.Timeout = timeout
+	return nil
+}
+
+// AddPhaseHook adds a named hook to a phase. Unknown phases are created
+// with a priority after the flush phase.
+func (m *Manager) AddPhaseHook(phase, name string, hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addPhaseHook(phase, name, hook)
+}
+
+// addPhaseHook is AddPhaseHook with m.mu held
+func (m *Manager) addPhaseHook(phase, name string, hook Hook) {
+	if _, ok := m.phases[phase]; !ok {
+		m.phases[phase] = &Phase{Name: phase, Priority: 50}
+	}
+	m.hooks[phase] = append(m.hooks[phase], namedHook{name: name, hook: hook})
+}
+
+// orderedPhases snapshots phases and their hooks in run order
+func (m *Manager) orderedPhases() ([]Phase, map[string][]namedHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	phases := make([]Phase, 0, len(m.phases))
+	for _, phase := range m.phases {
+		p := *phase
+		if p.Timeout <= 0 {
+			p.Timeout = m.timeout
+		}
+		phases = append(phases, p)
+	}
+	sort.SliceStable(phases, func(i, j int) bool {
+		if phases[i].Priority != phases[j].Priority {
+			return phases[i].Priority < phases[j].Priority
+		}
+		return phases[i].Name < phases[j].Name
+	})
+
+	hooks := make(map[string][]namedHook, len(m.hooks))
+	for name, list := range m.hooks {
+		hooks[name] = append([]namedHook(nil), list...)
+	}
+	return phases, hooks
+}
+
+// runPhase runs the hooks of a single phase under its own timeout
+func (m *Manager) runPhase(phase Phase, hooks []namedHook) PhaseResult {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), phase.Timeout)
+	defer cancel()
+
+	m.logger.Info("Shutdown phase started",
+		zap.String("phase", phase.Name),
+		zap.Int("hooks", len(hooks)),
+		zap.Duration("timeout", phase.Timeout),
+	)
+
+	var results []HookResult
+	if phase.Sequential {
+		results = m.runSequential(ctx, phase.Name, hooks)
+	} else {
+		results = m.runParallel(ctx, phase.Name, hooks)
+	}
+
+	result := PhaseResult{
+		Name:     phase.Name,
+		Duration: time.Since(start),
+		TimedOut: ctx.Err() == context.DeadlineExceeded,
+		Hooks:    results,
+	}
+
+	m.logger.Info("Shutdown phase completed",
+		zap.String("phase", phase.Name),
+		zap.Duration("duration", result.Duration),
+		zap.Bool("timed_out", result.TimedOut),
+	)
+	return result
+}
+
+// runSequential runs hooks one by one in LIFO order. Once the deadline
+// passes it stops waiting for the running hook and skips the rest.
+func (m *Manager) runSequential(ctx context.Context, phase string, hooks []namedHook) []HookResult {
+	results := make([]HookResult, 0, len(hooks))
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if ctx.Err() != nil {
+			results = append(results, m.timedOut(ctx, phase, h, 0))
+			continue
+		}
+
+		start := time.Now()
+		// Buffered so a hook that outlives the deadline can still finish
+		done := make(chan HookResult, 1)
+		go func() {
+			done <- m.runHook(ctx, phase, h)
+		}()
+		select {
+		case result := <-done:
+			results = append(results, result)
+		case <-ctx.Done():
+			results = append(results, m.timedOut(ctx, phase, h, time.Since(start)))
+		}
+	}
+	return results
+}
+
+// timedOut records a hook that did not finish, or did not start, before
+// the phase deadline
+func (m *Manager) timedOut(ctx context.Context, phase string, h namedHook, duration time.Duration) HookResult {
+	m.logger.Error("Shutdown hook timed out",
+		zap.String("phase", phase),
+		zap.String("hook", h.name),
+	)
+	return HookResult{
+		Phase:    phase,
+		Name:     h.name,
+		Duration: duration,
+		Err:      fmt.Errorf("%w: %w", ErrPhaseTimeout, ctx.Err()),
+		TimedOut: true,
+	}
+}
+
+// runParallel runs all hooks concurrently and stops waiting at the deadline
+func (m *Manager) runParallel(ctx context.Context, phase string, hooks []namedHook) []HookResult {
+	start := time.Now()
+	results := make([]HookResult, len(hooks))
+	done := make([]bool, len(hooks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for i, h := range hooks {
+		wg.Add(1)
+		go func(i int, h namedHook) {
+			defer wg.Done()
+			result := m.runHook(ctx, phase, h)
+			mu.Lock()
+			results[i] = result
+			done[i] = true
+			mu.Unlock()
+		}(i, h)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+
+	// Copy under the lock so hooks that finish after the deadline cannot
+	// mutate the slice handed back to the caller
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]HookResult, len(results))
+	copy(out, results)
+	for i, h := range hooks {
+		if !done[i] {
+			out[i] = m.timedOut(ctx, phase, h, time.Since(start))
+		}
+	}
+	return out
+}
+
+// runHook runs a single hook, converting panics into errors
+func (m *Manager) runHook(ctx context.Context, phase string, h namedHook) (result HookResult) {
+	start := time.Now()
+	result = HookResult{Phase: phase, Name: h.name}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("panic: %v", r)
+		}
+		result.Duration = time.Since(start)
+		if result.Err != nil && errors.Is(result.Err, context.DeadlineExceeded) {
+			result.TimedOut = true
+		}
+
+		if result.Err != nil {
+			m.logger.Error("Shutdown hook failed",
+				zap.String("phase", phase),
+				zap.String("hook", h.name),
+				zap.Duration("duration", result.Duration),
+				zap.Error(result.Err),
+			)
+		} else {
+			m.logger.Info("Shutdown hook completed",
+				zap.String("phase", phase),
+				zap.String("hook", h.name),
+				zap.Duration("duration", result.Duration),
+			)
+		}
+	}()
+
+	result.Err = h.hook(ctx)
+	return result
+}
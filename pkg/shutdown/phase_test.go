@@ -0,0 +1,128 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+func testLogger() *logger.Logger {
+	return &logger.Logger{Logger: zap.NewNop()}
+}
+
+func TestSequentialPhaseTimeout(t *testing.T) {
+	m := NewManager(time.Second, testLogger())
+	m.AddPhase(Phase{Name: "seq", Priority: 5, Timeout: 50 * time.Millisecond, Sequential: true})
+
+	var ran []string
+	// Registered first, so it runs last and is skipped
+	m.AddPhaseHook("seq", "skipped", func(ctx context.Context) error {
+		ran = append(ran, "skipped")
+		return nil
+	})
+	release := make(chan struct{})
+	defer close(release)
+	m.AddPhaseHook("seq", "hung", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	m.AddPhaseHook("seq", "first", func(ctx context.Context) error {
+		ran = append(ran, "first")
+		return nil
+	})
+	flushed := false
+	m.AddPhaseHook(PhaseFlush, "flush", func(ctx context.Context) error {
+		flushed = true
+		return nil
+	})
+
+	start := time.Now()
+	report := m.Shutdown()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("shutdown took %v; the hung hook stalled it", elapsed)
+	}
+	if !flushed {
+		t.Error("later phase did not run")
+	}
+	if len(ran) != 1 || ran[0] != "first" {
+		t.Errorf("ran %v, want [first]", ran)
+	}
+
+	phase := report.Phases[0]
+	if phase.Name != "seq" || !phase.TimedOut {
+		t.Fatalf("phase %+v, want seq timed out", phase)
+	}
+	want := []struct {
+		name     string
+		timedOut bool
+	}{{"first", false}, {"hung", true}, {"skipped", true}}
+	if len(phase.Hooks) != len(want) {
+		t.Fatalf("got %d hook results, want %d", len(phase.Hooks), len(want))
+	}
+	for i, w := range want {
+		h := phase.Hooks[i]
+		if h.Name != w.name || h.TimedOut != w.timedOut {
+			t.Errorf("hook %d = %s timed out %v, want %s %v", i, h.Name, h.TimedOut, w.name, w.timedOut)
+		}
+		if w.timedOut && !errors.Is(h.Err, ErrPhaseTimeout) {
+			t.Errorf("hook %s error %v, want ErrPhaseTimeout", h.Name, h.Err)
+		}
+	}
+}
+
+func TestParallelPhaseTimeout(t *testing.T) {
+	m := NewManager(50*time.Millisecond, testLogger())
+	release := make(chan struct{})
+	defer close(release)
+	m.AddPhaseHook(PhaseFlush, "hung", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	m.AddPhaseHook(PhaseFlush, "ok", func(ctx context.Context) error { return nil })
+
+	report := m.Shutdown()
+	hooks := report.Phases[0].Hooks
+	if !errors.Is(hooks[0].Err, ErrPhaseTimeout) || !hooks[0].TimedOut {
+		t.Errorf("hung hook %+v, want timed out", hooks[0])
+	}
+	if hooks[1].Err != nil {
+		t.Errorf("ok hook error %v", hooks[1].Err)
+	}
+}
+
+func TestAddHookNamesAreUnique(t *testing.T) {
+	m := NewManager(time.Second, testLogger())
+	const hooks = 200
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range hooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			m.AddHook(func(ctx context.Context) error { return nil })
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	report := m.Shutdown()
+	names := map[string]bool{}
+	for _, phase := range report.Phases {
+		if phase.Name != PhaseDefault {
+			continue
+		}
+		for _, h := range phase.Hooks {
+			names[h.Name] = true
+		}
+	}
+	if len(names) != hooks {
+		t.Errorf("%d distinct hook names for %d hooks", len(names), hooks)
+	}
+}
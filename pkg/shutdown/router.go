@@ -0,0 +1,155 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+// SignalHandler reacts to a non-terminating signal such as SIGHUP
+type SignalHandler func(ctx context.Context, sig os.Signal) error
+
+// namedHandler is a signal handler together with its name for logs
+type namedHandler struct {
+	name    string
+	handler SignalHandler
+}
+
+// Router dispatches signals to registered handlers. It is meant for
+// operational signals (SIGHUP, SIGUSR1); shutdown signals belong to Manager.
+type Router struct {
+	source  SignalSource
+	logger  *logger.Logger
+	timeout time.Duration
+
+	mu       sync.Mutex
+	handlers map[os.Signal][]namedHandler
+	// sigCh receives signals while Run is running, so Handle can
+	// subscribe it to signals registered later
+	sigCh chan os.Signal
+}
+
+// NewRouter creates a signal router. A nil source uses OS signals.
+func NewRouter(source SignalSource, log *logger.Logger) *Router {
+	if source == nil {
+		source = OSSignals{}
+	}
+	return &Router{
+		source:   source,
+		logger:   log,
+		timeout:  30 * time.Second,
+		handlers: make(map[os.Signal][]namedHandler),
+	}
+}
+
+// SetTimeout bounds how long each handler may run
+func (r *Router) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+}
+
+// Handle registers a named handler for sig. Handlers for the same signal
+// run in registration order. Handlers may be added while Run is running.
+func (r *Router) Handle(sig os.Signal, name string, handler SignalHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sigCh != nil && len(r.handlers[sig]) == 0 {
+		r.source.Notify(r.sigCh, sig)
+	}
+	r.handlers[sig] = append(r.handlers[sig], namedHandler{name: name, handler: handler})
+}
+
+// Run dispatches signals until ctx is cancelled. Pass Manager.Context()
+// to stop routing once shutdown begins. Signals registered through Handle
+// after Run has started are subscribed as they are added. Only one Run
+// may be active at a time.
+func (r *Router) Run(ctx context.Context) {
+	sigCh := make(chan os.Signal, 4)
+	r.mu.Lock()
+	sigs := make([]os.Signal, 0, len(r.handlers))
+	for sig := range r.handlers {
+		sigs = append(sigs, sig)
+	}
+	// Notify with no signals would relay every signal
+	if len(sigs) > 0 {
+		r.source.Notify(sigCh, sigs...)
+	}
+	r.sigCh = sigCh
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.sigCh = nil
+		r.mu.Unlock()
+		r.source.Stop(sigCh)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigCh:
+			r.Dispatch(ctx, sig)
+		}
+	}
+}
+
+// Dispatch runs every handler registered for sig and returns their
+// aggregated error. Handler failures are logged and do not stop later handlers.
+func (r *Router) Dispatch(ctx context.Context, sig os.Signal) error {
+	r.mu.Lock()
+	handlers := append([]namedHandler(nil), r.handlers[sig]...)
+	timeout := r.timeout
+	r.mu.Unlock()
+
+	r.logger.Info("Received signal", zap.String("signal", sig.String()), zap.Int("handlers", len(handlers)))
+
+	var failed []string
+	for _, h := range handlers {
+		start := time.Now()
+		err := r.runHandler(ctx, timeout, sig, h)
+		duration := time.Since(start)
+
+		if err != nil {
+			failed = append(failed, h.name)
+			r.logger.Error("Signal handler failed",
+				zap.String("signal", sig.String()),
+				zap.String("handler", h.name),
+				zap.Duration("duration", duration),
+				zap.Error(err),
+			)
+			continue
+		}
+		r.logger.Info("Signal handler completed",
+			zap.String("signal", sig.String()),
+			zap.String("handler", h.name),
+			zap.Duration("duration", duration),
+		)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("signal %s: handlers failed: %v", sig, failed)
+	}
+	return nil
+}
+
+// runHandler runs a single handler with a timeout, converting panics into errors
+func (r *Router) runHandler(ctx context.Context, timeout time.Duration, sig os.Signal, h namedHandler) (err error) {
+	hctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+
+	return h.handler(hctx, sig)
+}
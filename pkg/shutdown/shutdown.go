@@ -3,8 +3,8 @@ package shutdown
 
 import (
 	"context"
+	"fmt"
 	"os"
-	"os/signal"
 	"sync"
 	"syscall"
 	"time"
@@ -14,75 +14,193 @@ import (
 	"github.com/ArbajAnsari19/phonic/pkg/logger"
 )
 
+// Process exit codes used by WaitForShutdown
+const (
+	// ExitOK means every shutdown hook succeeded
+	ExitOK = 0
+	// ExitHookFailure means at least one shutdown hook failed or timed out
+	ExitHookFailure = 1
+	// ExitForced means a second signal interrupted the graceful shutdown
+	ExitForced = 130
+)
+
+// shutdownSignals are the signals that trigger a graceful shutdown
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
 // Hook represents a cleanup function to run during shutdown
 type Hook func(ctx context.Context) error
 
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithSignalSource replaces the OS signal source, typically with
+// ChannelSignals in tests
+func WithSignalSource(src SignalSource) Option {
+	return func(m *Manager) {
+		m.signals = src
+	}
+}
+
+// WithExitFunc replaces os.Exit for forced and final exits
+func WithExitFunc(exit func(code int)) Option {
+	return func(m *Manager) {
+		m.exit = exit
+	}
+}
+
 // Manager manages graceful shutdown of the service
 type Manager struct {
-	hooks   []Hook
+	phases  map[string]*Phase
+	hooks   map[string][]namedHook
 	timeout time.Duration
 	logger  *logger.Logger
 	mu      sync.Mutex
+
+	signals SignalSource
+	exit    func(code int)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	done   chan struct{}
+	report *Report
 }
 
-// NewManager creates a new shutdown manager
-func NewManager(timeout time.Duration, log *logger.Logger) *Manager {
-	return &Manager{
-		hooks:   make([]Hook, 0),
+// NewManager creates a new shutdown manager. The timeout applies to each
+// phase that does not set its own.
+func NewManager(timeout time.Duration, log *logger.Logger, opts ...Option) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		phases:  defaultPhases(),
+		hooks:   make(map[string][]namedHook),
 		timeout: timeout,
 		logger:  log,
+		signals: OSSignals{},
+		exit:    os.Exit,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
-// AddHook adds a shutdown hook
+// Context returns a context that is cancelled as soon as shutdown begins,
+// so servers and workers can watch it
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Done returns a channel that is closed once shutdown has completed
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+// AddHook adds a shutdown hook to the default phase. Hooks in the default
+// phase run sequentially in reverse order (LIFO).
 func (m *Manager) AddHook(hook Hook) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.hooks = append(m.hooks, hook)
+	m.addPhaseHook(PhaseDefault, fmt.Sprintf("hook-%d", len(m.hooks[PhaseDefault])), hook)
 }
 
-// Listen starts listening for shutdown signals
+// Listen blocks until a shutdown signal arrives and then runs the hooks.
+// Unlike WaitForShutdown it does not exit the process.
 func (m *Manager) Listen() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	_ = m.Run(context.Background())
+}
 
-	sig := <-sigChan
-	m.logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
+// Run blocks until a shutdown signal arrives, ctx is cancelled or
+// Shutdown is called elsewhere, then runs the shutdown hooks and returns
+// their aggregated error. A second signal during shutdown exits the
+// process immediately with ExitForced.
+func (m *Manager) Run(ctx context.Context) error {
+	sigCh := make(chan os.Signal, 2)
+	m.signals.Notify(sigCh, shutdownSignals...)
+	defer m.signals.Stop(sigCh)
+
+	select {
+	case sig := <-sigCh:
+		m.logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
+	case <-ctx.Done():
+		m.logger.Info("Shutdown requested by context", zap.Error(ctx.Err()))
+	case <-m.ctx.Done():
+	}
+	m.cancel()
+
+	stopForce := make(chan struct{})
+	defer close(stopForce)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			m.logger.Warn("Received second signal, forcing exit", zap.String("signal", sig.String()))
+			_ = m.logger.Sync()
+			m.exit(ExitForced)
+		case <-stopForce:
+		}
+	}()
 
-	m.Shutdown()
+	return m.Shutdown().Err()
 }
 
-// Shutdown executes all shutdown hooks with timeout
-func (m *Manager) Shutdown() {
-	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
-	defer cancel()
+// Shutdown runs every phase in priority order and returns a report with
+// per-hook durations and errors. It is idempotent and safe to call
+// concurrently: later callers wait for the first run and share its report.
+func (m *Manager) Shutdown() *Report {
+	m.once.Do(func() {
+		m.cancel()
+		m.report = m.runShutdown()
+		close(m.done)
+	})
+	<-m.done
+	return m.report
+}
 
-	m.logger.Info("Starting graceful shutdown", zap.Duration("timeout", m.timeout))
+// runShutdown executes the phases once
+func (m *Manager) runShutdown() *Report {
+	start := time.Now()
+	phases, hooks := m.orderedPhases()
 
-	m.mu.Lock()
-	hooks := make([]Hook, len(m.hooks))
-	copy(hooks, m.hooks)
-	m.mu.Unlock()
-
-	// Execute hooks in reverse order (LIFO)
-	for i := len(hooks) - 1; i >= 0; i-- {
-		hook := hooks[i]
-		if err := hook(ctx); err != nil {
-			m.logger.Error("Shutdown hook failed", zap.Error(err), zap.Int("hook_index", i))
+	m.logger.Info("Starting graceful shutdown",
+		zap.Duration("timeout", m.timeout),
+		zap.Int("phases", len(phases)),
+	)
+
+	report := &Report{StartedAt: start}
+	for _, phase := range phases {
+		if len(hooks[phase.Name]) == 0 {
+			continue
 		}
+		report.Phases = append(report.Phases, m.runPhase(phase, hooks[phase.Name]))
+	}
+	report.Duration = time.Since(start)
+
+	if failed := report.Failed(); len(failed) > 0 {
+		m.logger.Warn("Graceful shutdown completed with errors",
+			zap.Duration("duration", report.Duration),
+			zap.Int("failed_hooks", len(failed)),
+			zap.Error(report.Err()),
+		)
+	} else {
+		m.logger.Info("Graceful shutdown completed", zap.Duration("duration", report.Duration))
 	}
 
-	m.logger.Info("Graceful shutdown completed")
+	return report
 }
 
-// WaitForShutdown waits for shutdown signal and executes hooks
+// WaitForShutdown waits for a shutdown signal, runs the hooks and exits
+// the process with ExitOK or ExitHookFailure
 func (m *Manager) WaitForShutdown() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-
-	sig := <-sigChan
-	m.logger.Info("Received shutdown signal", zap.String("signal", sig.String()))
+	err := m.Run(context.Background())
+	m.exit(ExitCode(err))
+}
 
-	m.Shutdown()
-	os.Exit(0)
+// ExitCode maps the error returned by Run onto a process exit code
+func ExitCode(err error) int {
+	if err != nil {
+		return ExitHookFailure
+	}
+	return ExitOK
 }
@@ -0,0 +1,302 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitSubscribed waits until something listens on sigs, so a Send is not
+// dropped
+func waitSubscribed(t *testing.T, sigs *ChannelSignals) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sigs.mu.Lock()
+		n := len(sigs.subs)
+		sigs.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("nothing subscribed to signals")
+}
+
+// recorder collects hook names in the order they run
+type recorder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recorder) hook(name string, err error) Hook {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		r.names = append(r.names, name)
+		r.mu.Unlock()
+		return err
+	}
+}
+
+func (r *recorder) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func TestSignalRunsPhasesInOrder(t *testing.T) {
+	sigs := NewChannelSignals()
+	m := NewManager(time.Second, testLogger(), WithSignalSource(sigs))
+
+	var rec recorder
+	m.AddPhaseHook(PhaseLogger, "logger", rec.hook("logger", nil))
+	m.AddPhaseHook(PhaseFlush, "redis", rec.hook("redis", nil))
+	m.AddHook(rec.hook("default-1", nil))
+	m.AddHook(rec.hook("default-2", nil))
+	m.AddPhaseHook(PhaseDrain, "calls", rec.hook("calls", nil))
+	m.AddPhaseHook(PhaseStopAccepting, "http", rec.hook("http", nil))
+
+	errc := make(chan error, 1)
+	go func() { errc <- m.Run(context.Background()) }()
+	waitSubscribed(t, sigs)
+	sigs.Send(syscall.SIGTERM)
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+		if code := ExitCode(err); code != ExitOK {
+			t.Errorf("ExitCode = %d, want %d", code, ExitOK)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the signal")
+	}
+
+	want := []string{"http", "calls", "default-2", "default-1", "redis", "logger"}
+	got := rec.got()
+	if len(got) != len(want) {
+		t.Fatalf("hooks ran %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("hooks ran %v, want %v", got, want)
+		}
+	}
+	if m.Context().Err() == nil {
+		t.Error("manager context not cancelled")
+	}
+	select {
+	case <-m.Done():
+	default:
+		t.Error("Done not closed")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks []error
+		want  int
+	}{
+		{"all succeed", []error{nil, nil}, ExitOK},
+		{"one fails", []error{nil, errors.New("close failed")}, ExitHookFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sigs := NewChannelSignals()
+			exited := make(chan int, 1)
+			m := NewManager(time.Second, testLogger(),
+				WithSignalSource(sigs),
+				WithExitFunc(func(code int) { exited <- code }),
+			)
+			var rec recorder
+			for i, err := range tt.hooks {
+				m.AddPhaseHook(PhaseFlush, string(rune('a'+i)), rec.hook("hook", err))
+			}
+
+			go m.WaitForShutdown()
+			waitSubscribed(t, sigs)
+			sigs.Send(os.Interrupt)
+
+			select {
+			case code := <-exited:
+				if code != tt.want {
+					t.Errorf("exit code %d, want %d", code, tt.want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("WaitForShutdown did not exit")
+			}
+		})
+	}
+}
+
+func TestSecondSignalForcesExit(t *testing.T) {
+	sigs := NewChannelSignals()
+	exited := make(chan int, 1)
+	m := NewManager(5*time.Second, testLogger(),
+		WithSignalSource(sigs),
+		WithExitFunc(func(code int) { exited <- code }),
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m.AddPhaseHook(PhaseDrain, "slow", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- m.Run(context.Background()) }()
+	waitSubscribed(t, sigs)
+	sigs.Send(syscall.SIGTERM)
+	<-started
+	sigs.Send(syscall.SIGTERM)
+
+	select {
+	case code := <-exited:
+		if code != ExitForced {
+			t.Errorf("exit code %d, want %d", code, ExitForced)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second signal did not force an exit")
+	}
+	close(release)
+	<-errc
+}
+
+func TestShutdownIsIdempotent(t *testing.T) {
+	m := NewManager(time.Second, testLogger())
+	var rec recorder
+	m.AddPhaseHook(PhaseFlush, "once", rec.hook("once", nil))
+
+	var wg sync.WaitGroup
+	reports := make([]*Report, 3)
+	for i := range reports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i] = m.Shutdown()
+		}()
+	}
+	wg.Wait()
+
+	if got := rec.got(); len(got) != 1 {
+		t.Errorf("hook ran %d times, want 1", len(got))
+	}
+	for _, r := range reports[1:] {
+		if r != reports[0] {
+			t.Error("callers got different reports")
+		}
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	sigs := NewChannelSignals()
+	r := NewRouter(sigs, testLogger())
+
+	var rec recorder
+	handled := make(chan struct{}, 1)
+	r.Handle(syscall.SIGHUP, "reload", func(ctx context.Context, sig os.Signal) error {
+		rec.hook("reload", nil)(ctx)
+		return nil
+	})
+	r.Handle(syscall.SIGHUP, "panics", func(ctx context.Context, sig os.Signal) error {
+		panic("boom")
+	})
+	r.Handle(syscall.SIGHUP, "after", func(ctx context.Context, sig os.Signal) error {
+		rec.hook("after", nil)(ctx)
+		handled <- struct{}{}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	waitSubscribed(t, sigs)
+	// A signal nobody handles is not delivered
+	sigs.Send(syscall.SIGINT)
+	sigs.Send(syscall.SIGHUP)
+
+	select {
+	case <-handled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SIGHUP not dispatched")
+	}
+	if got := rec.got(); len(got) != 2 || got[0] != "reload" || got[1] != "after" {
+		t.Errorf("handlers ran %v, want [reload after]", got)
+	}
+
+	if err := r.Dispatch(context.Background(), syscall.SIGHUP); err == nil {
+		t.Error("Dispatch did not report the panicking handler")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop when ctx was cancelled")
+	}
+}
+
+func TestRouterSubscribesLateHandlers(t *testing.T) {
+	sigs := NewChannelSignals()
+	r := NewRouter(sigs, testLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	// Run starts with nothing to route, then a handler is added
+	waitFor(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.sigCh != nil
+	})
+	handled := make(chan os.Signal, 1)
+	r.Handle(syscall.SIGHUP, "reload", func(ctx context.Context, sig os.Signal) error {
+		handled <- sig
+		return nil
+	})
+	sigs.Send(syscall.SIGHUP)
+
+	select {
+	case sig := <-handled:
+		if sig != syscall.SIGHUP {
+			t.Errorf("handled %v, want SIGHUP", sig)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("signal registered after Run was not dispatched")
+	}
+
+	cancel()
+	<-done
+	sigs.mu.Lock()
+	defer sigs.mu.Unlock()
+	if len(sigs.subs) != 0 {
+		t.Errorf("%d subscriptions left after Run returned", len(sigs.subs))
+	}
+}
+
+// waitFor polls cond until it holds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
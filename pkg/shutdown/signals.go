@@ -0,0 +1,82 @@
+package shutdown
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// SignalSource delivers OS signals. It mirrors signal.Notify/signal.Stop
+// so tests can inject signals without touching the process.
+type SignalSource interface {
+	Notify(c chan<- os.Signal, sig ...os.Signal)
+	Stop(c chan<- os.Signal)
+}
+
+// OSSignals is the SignalSource backed by os/signal
+type OSSignals struct{}
+
+// Notify relays the given signals to c
+func (OSSignals) Notify(c chan<- os.Signal, sig ...os.Signal) {
+	signal.Notify(c, sig...)
+}
+
+// Stop stops relaying signals to c
+func (OSSignals) Stop(c chan<- os.Signal) {
+	signal.Stop(c)
+}
+
+// ChannelSignals is an in-memory SignalSource for tests. Send delivers a
+// signal to every channel subscribed to it.
+type ChannelSignals struct {
+	mu   sync.Mutex
+	subs map[chan<- os.Signal][]os.Signal
+}
+
+// NewChannelSignals creates an empty in-memory signal source
+func NewChannelSignals() *ChannelSignals {
+	return &ChannelSignals{subs: make(map[chan<- os.Signal][]os.Signal)}
+}
+
+// Notify subscribes c to the given signals
+func (s *ChannelSignals) Notify(c chan<- os.Signal, sig ...os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[c] = append(s.subs[c], sig...)
+}
+
+// Stop unsubscribes c
+func (s *ChannelSignals) Stop(c chan<- os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, c)
+}
+
+// Send delivers sig to every subscribed channel without blocking, like
+// the os/signal package does
+func (s *ChannelSignals) Send(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, sigs := range s.subs {
+		if !wants(sigs, sig) {
+			continue
+		}
+		select {
+		case c <- sig:
+		default:
+		}
+	}
+}
+
+// wants reports whether sig is in sigs; an empty list means all signals
+func wants(sigs []os.Signal, sig os.Signal) bool {
+	if len(sigs) == 0 {
+		return true
+	}
+	for _, s := range sigs {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
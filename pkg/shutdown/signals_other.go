@@ -0,0 +1,14 @@
+//go:build !unix
+
+package shutdown
+
+import "syscall"
+
+// Operational signals routed by Router. Platforms without SIGUSR1 never
+// deliver SignalDiagnostics, so it is inert there.
+var (
+	// SignalReload asks the service to reload configuration
+	SignalReload = syscall.SIGHUP
+	// SignalDiagnostics asks the service to dump diagnostics
+	SignalDiagnostics = syscall.Signal(0x1e)
+)
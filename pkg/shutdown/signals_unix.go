@@ -0,0 +1,13 @@
+//go:build unix
+
+package shutdown
+
+import "syscall"
+
+// Operational signals routed by Router
+var (
+	// SignalReload asks the service to reload configuration (kill -HUP)
+	SignalReload = syscall.SIGHUP
+	// SignalDiagnostics asks the service to dump diagnostics (kill -USR1)
+	SignalDiagnostics = syscall.SIGUSR1
+)
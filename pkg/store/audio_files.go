@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AudioFileType is the role of a recording in a call
+type AudioFileType string
+
+// Audio file types
+const (
+	// AudioInput is the caller's audio
+	AudioInput AudioFileType = "input"
+	// AudioOutput is the agent's audio
+	AudioOutput AudioFileType = "output"
+	// AudioProcessed is derived audio, e.g. a mixed recording
+	AudioProcessed AudioFileType = "processed"
+)
+
+// AudioFormat is the container or codec of a recording
+type AudioFormat string
+
+// Audio formats
+const (
+	FormatWAV  AudioFormat = "wav"
+	FormatMP3  AudioFormat = "mp3"
+	FormatOpus AudioFormat = "opus"
+)
+
+// AudioFile is a row of the audio_files table, describing a recording
+// stored in object storage
+type AudioFile struct {
+	ID        string        `json:"id"`
+	SessionID string        `json:"session_id"`
+	Type      AudioFileType `json:"file_type"`
+	// Path is the object storage path
+	Path            string      `json:"file_path"`
+	SizeBytes       int64       `json:"file_size_bytes,omitempty"`
+	DurationSeconds float64     `json:"duration_seconds,omitempty"`
+	Format          AudioFormat `json:"format,omitempty"`
+	SampleRate      int         `json:"sample_rate,omitempty"`
+	Channels        int         `json:"channels"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+const audioFileColumns = `id, session_id, file_type, file_path, COALESCE(file_size_bytes, 0), COALESCE(duration_seconds, 0),
+	COALESCE(format, ''), COALESCE(sample_rate, 0), COALESCE(channels, 1), created_at`
+
+// CreateAudioFile inserts an audio file record, filling in its ID. Zero
+// size, duration, format and sample rate are stored as unknown.
+func (q *Queries) CreateAudioFile(ctx context.Context, f *AudioFile) (err error) {
+	defer q.observe("INSERT", "audio_files", time.Now(), &err)
+	return q.insertAudioFile(ctx, f)
+}
+
+// insertAudioFile inserts f and reads back the stored row
+func (q *Queries) insertAudioFile(ctx context.Context, f *AudioFile) error {
+	switch f.Type {
+	case AudioInput, AudioOutput, AudioProcessed:
+	default:
+		return fmt.Errorf("invalid audio file type %q", f.Type)
+	}
+	if f.Path == "" {
+		return fmt.Errorf("audio file path is required")
+	}
+	channels := f.Channels
+	if channels <= 0 {
+		channels = 1
+	}
+
+	row := q.db.QueryRowContext(ctx, `
+		INSERT INTO audio_files (session_id, file_type, file_path, file_size_bytes, duration_seconds, format, sample_rate, channels)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+audioFileColumns,
+		f.SessionID, string(f.Type), f.Path,
+		sql.NullInt64{Int64: f.SizeBytes, Valid: f.SizeBytes > 0},
+		sql.NullFloat64{Float64: f.DurationSeconds, Valid: f.DurationSeconds > 0},
+		nullString(string(f.Format)),
+		sql.NullInt64{Int64: int64(f.SampleRate), Valid: f.SampleRate > 0},
+		channels,
+	)
+	created, err := scanAudioFile(row)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	*f = *created
+	return nil
+}
+
+// GetAudioFile returns the audio file record with id
+func (q *Queries) GetAudioFile(ctx context.Context, id string) (f *AudioFile, err error) {
+	defer q.observe("SELECT", "audio_files", time.Now(), &err)
+	f, err = scanAudioFile(q.db.QueryRowContext(ctx, `SELECT `+audioFileColumns+` FROM audio_files WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audio file: %w", err)
+	}
+	return f, nil
+}
+
+// ListAudioFiles returns the audio files of a session, oldest first
+func (q *Queries) ListAudioFiles(ctx context.Context, sessionID string) (files []*AudioFile, err error) {
+	defer q.observe("SELECT", "audio_files", time.Now(), &err)
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT `+audioFileColumns+` FROM audio_files
+		WHERE session_id = $1
+		ORDER BY created_at, id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio files: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f, err := scanAudioFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audio file: %w", err)
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audio files: %w", err)
+	}
+	return files, nil
+}
+
+// DeleteAudioFile deletes an audio file record; the stored object is not
+// touched
+func (q *Queries) DeleteAudioFile(ctx context.Context, id string) (err error) {
+	defer q.observe("DELETE", "audio_files", time.Now(), &err)
+	result, err := q.db.ExecContext(ctx, `DELETE FROM audio_files WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete audio file: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanAudioFile reads audioFileColumns into an AudioFile
+func scanAudioFile(row rowScanner) (*AudioFile, error) {
+	var (
+		f                AudioFile
+		sessionID        sql.NullString
+		fileType, format string
+	)
+	err := row.Scan(&f.ID, &sessionID, &fileType, &f.Path, &f.SizeBytes, &f.DurationSeconds,
+		&format, &f.SampleRate, &f.Channels, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	f.SessionID = sessionID.String
+	f.Type = AudioFileType(fileType)
+	f.Format = AudioFormat(format)
+	return &f, nil
+}
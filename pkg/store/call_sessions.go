@@ -0,0 +1,373 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/ArbajAnsari19/phonic/pkg/session"
+)
+
+// ErrAlreadyFinalized is returned when finalizing a session whose row has
+// already ended or failed, e.g. when two instances handle the same event
+var ErrAlreadyFinalized = errors.New("call session already finalized")
+
+// CallSession is a row of the call_sessions table
+type CallSession struct {
+	ID string `json:"id"`
+	// UserID is empty for calls not linked to a user
+	UserID string         `json:"user_id,omitempty"`
+	Token  string         `json:"session_token"`
+	Status session.Status `json:"status"`
+	// StartedAt is when the call connected, or was created if it never did
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// DurationSeconds is the connected time of a finished call
+	DurationSeconds *int                   `json:"duration_seconds,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// CallSessionFromSession converts a Redis session into a row. The tenant,
+// caller, agent, reason and session metadata go into Metadata.
+func CallSessionFromSession(s *session.Session) *CallSession {
+	cs := &CallSession{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		Token:     s.Token,
+		Status:    s.Status,
+		StartedAt: s.CreatedAt,
+		EndedAt:   s.EndedAt,
+		Metadata: map[string]interface{}{
+			"tenant_id": s.TenantID,
+			"caller":    s.Caller,
+			"agent":     s.Agent,
+		},
+	}
+	if s.StartedAt != nil {
+		cs.StartedAt = *s.StartedAt
+	}
+	if s.Reason != "" {
+		cs.Metadata["reason"] = s.Reason
+	}
+	if len(s.Metadata) > 0 {
+		cs.Metadata["metadata"] = s.Metadata
+	}
+	if s.Status.Terminal() {
+		seconds := int(s.Duration().Round(time.Second) / time.Second)
+		cs.DurationSeconds = &seconds
+	}
+	return cs
+}
+
+// CallSessionFilter selects call sessions for ListCallSessions; zero
+// fields match everything
+type CallSessionFilter struct {
+	UserID string
+	// TenantID matches the tenant_id recorded in Metadata
+	TenantID string
+	Statuses []session.Status
+	// From and To bound started_at, From inclusive and To exclusive
+	From time.Time
+	To   time.Time
+	// Limit is the page size; default 50, at most 500
+	Limit int
+	// Cursor continues from a previous page's NextCursor
+	Cursor string
+}
+
+// CallSessionPage is a page of call sessions, most recently started first
+type CallSessionPage struct {
+	Sessions []*CallSession `json:"sessions"`
+	// NextCursor fetches the next page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const callSessionColumns = `id, user_id, session_token, status, started_at, ended_at, duration_seconds, COALESCE(metadata, '{}'), created_at, updated_at`
+
+// CreateCallSession inserts a call session. The ID is generated when
+// empty, the status defaults to pending and the start time to now.
+func (q *Queries) CreateCallSession(ctx context.Context, cs *CallSession) (err error) {
+	defer q.observe("INSERT", "call_sessions", time.Now(), &err)
+	_, err = q.insertCallSession(ctx, cs, false)
+	return err
+}
+
+// insertCallSession inserts cs and reads back the stored row. With
+// ifAbsent a row with the same ID is left alone and false returned.
+func (q *Queries) insertCallSession(ctx context.Context, cs *CallSession, ifAbsent bool) (bool, error) {
+	metadata, err := encodeMetadata(cs.Metadata)
+	if err != nil {
+		return false, err
+	}
+	var startedAt sql.NullTime
+	if !cs.StartedAt.IsZero() {
+		startedAt = sql.NullTime{Time: cs.StartedAt, Valid: true}
+	}
+	var duration sql.NullInt64
+	if cs.DurationSeconds != nil {
+		duration = sql.NullInt64{Int64: int64(*cs.DurationSeconds), Valid: true}
+	}
+	conflict := ""
+	if ifAbsent {
+		conflict = "ON CONFLICT (id) DO NOTHING"
+	}
+
+	row := q.db.QueryRowContext(ctx, `
+		INSERT INTO call_sessions (id, user_id, session_token, status, started_at, ended_at, duration_seconds, metadata)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, uuid_generate_v4()), $2, $3, COALESCE(NULLIF($4, ''), 'pending'),
+			COALESCE($5, NOW()), $6, $7, $8)
+		`+conflict+`
+		RETURNING `+callSessionColumns,
+		cs.ID, nullString(cs.UserID), cs.Token, string(cs.Status), startedAt, cs.EndedAt, duration, metadata,
+	)
+	created, err := scanCallSession(row)
+	switch {
+	case ifAbsent && errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case isUniqueViolation(err):
+		return false, fmt.Errorf("call session %s: %w", cs.Token, ErrConflict)
+	case err != nil:
+		return false, fmt.Errorf("failed to create call session: %w", err)
+	}
+	*cs = *created
+	return true, nil
+}
+
+// GetCallSession returns the call session with id
+func (q *Queries) GetCallSession(ctx context.Context, id string) (cs *CallSession, err error) {
+	defer q.observe("SELECT", "call_sessions", time.Now(), &err)
+	return q.getCallSession(ctx, `SELECT `+callSessionColumns+` FROM call_sessions WHERE id = $1`, id)
+}
+
+// GetCallSessionByToken returns the call session with a session token
+func (q *Queries) GetCallSessionByToken(ctx context.Context, token string) (cs *CallSession, err error) {
+	defer q.observe("SELECT", "call_sessions", time.Now(), &err)
+	return q.getCallSession(ctx, `SELECT `+callSessionColumns+` FROM call_sessions WHERE session_token = $1`, token)
+}
+
+// getCallSession runs a query for one call session
+func (q *Queries) getCallSession(ctx context.Context, query string, args ...interface{}) (*CallSession, error) {
+	cs, err := scanCallSession(q.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call session: %w", err)
+	}
+	return cs, nil
+}
+
+// UpdateCallSession stores the status, times, duration and metadata of cs;
+// a zero StartedAt keeps the stored start time
+func (q *Queries) UpdateCallSession(ctx context.Context, cs *CallSession) (err error) {
+	defer q.observe("UPDATE", "call_sessions", time.Now(), &err)
+	updated, err := q.updateCallSession(ctx, cs, false)
+	if err == nil && !updated {
+		return ErrNotFound
+	}
+	return err
+}
+
+// updateCallSession writes cs over its row and reads it back. With
+// unlessFinal a row that has already ended or failed is left alone and
+// false returned.
+func (q *Queries) updateCallSession(ctx context.Context, cs *CallSession, unlessFinal bool) (bool, error) {
+	metadata, err := encodeMetadata(cs.Metadata)
+	if err != nil {
+		return false, err
+	}
+	var startedAt sql.NullTime
+	if !cs.StartedAt.IsZero() {
+		startedAt = sql.NullTime{Time: cs.StartedAt, Valid: true}
+	}
+	var duration sql.NullInt64
+	if cs.DurationSeconds != nil {
+		duration = sql.NullInt64{Int64: int64(*cs.DurationSeconds), Valid: true}
+	}
+	guard := ""
+	if unlessFinal {
+		guard = fmt.Sprintf("AND status NOT IN ('%s', '%s')", session.StatusEnded, session.StatusFailed)
+	}
+
+	row := q.db.QueryRowContext(ctx, `
+		UPDATE call_sessions SET status = $2, started_at = COALESCE($3, started_at), ended_at = $4,
+			duration_seconds = $5, metadata = $6
+		WHERE id = $1 `+guard+`
+		RETURNING `+callSessionColumns,
+		cs.ID, string(cs.Status), startedAt, cs.EndedAt, duration, metadata,
+	)
+	updated, err := scanCallSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update call session: %w", err)
+	}
+	*cs = *updated
+	return true, nil
+}
+
+// DeleteCallSession deletes a call session and its audio file records
+func (q *Queries) DeleteCallSession(ctx context.Context, id string) (err error) {
+	defer q.observe("DELETE", "call_sessions", time.Now(), &err)
+	result, err := q.db.ExecContext(ctx, `
+		WITH files AS (DELETE FROM audio_files WHERE session_id = $1)
+		DELETE FROM call_sessions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete call session: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListCallSessions returns a page of call sessions, most recently started
+// first
+func (q *Queries) ListCallSessions(ctx context.Context, filter CallSessionFilter) (page *CallSessionPage, err error) {
+	defer q.observe("SELECT", "call_sessions", time.Now(), &err)
+
+	var conds conditions
+	if filter.UserID != "" {
+		conds.add("user_id = ?", filter.UserID)
+	}
+	if filter.TenantID != "" {
+		conds.add("metadata->>'tenant_id' = ?", filter.TenantID)
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, s := range filter.Statuses {
+			statuses[i] = string(s)
+		}
+		conds.add("status = ANY(?)", pq.Array(statuses))
+	}
+	if !filter.From.IsZero() {
+		conds.add("started_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		conds.add("started_at < ?", filter.To)
+	}
+	if filter.Cursor != "" {
+		t, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conds.add("(started_at, id) < (?, ?)", t, id)
+	}
+	limit := pageSize(filter.Limit)
+	conds.args = append(conds.args, limit+1)
+
+	rows, err := q.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM call_sessions
+		%s
+		ORDER BY started_at DESC, id DESC
+		LIMIT $%d`, callSessionColumns, conds.where(), len(conds.args)), conds.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list call sessions: %w", err)
+	}
+	defer rows.Close()
+
+	page = &CallSessionPage{}
+	for rows.Next() {
+		cs, err := scanCallSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan call session: %w", err)
+		}
+		page.Sessions = append(page.Sessions, cs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list call sessions: %w", err)
+	}
+	if len(page.Sessions) > limit {
+		page.Sessions = page.Sessions[:limit]
+		last := page.Sessions[limit-1]
+		page.NextCursor = encodeCursor(last.StartedAt, last.ID)
+	}
+	return page, nil
+}
+
+// FinalizeSession records a finished call and its audio files in one
+// transaction. The row is created if the call was never stored, or
+// updated if it is still live; ErrAlreadyFinalized is returned when it
+// has already ended or failed, and nothing is written.
+func (s *Store) FinalizeSession(ctx context.Context, cs *CallSession, files []*AudioFile) error {
+	if !cs.Status.Terminal() {
+		return fmt.Errorf("cannot finalize call session in status %s", cs.Status)
+	}
+	start := time.Now()
+	err := s.InTx(ctx, func(tx *Tx) error {
+		inserted, err := tx.insertCallSession(ctx, cs, true)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			updated, err := tx.updateCallSession(ctx, cs, true)
+			if err != nil {
+				return err
+			}
+			if !updated {
+				return ErrAlreadyFinalized
+			}
+		}
+		for _, f := range files {
+			f.SessionID = cs.ID
+			if err := tx.insertAudioFile(ctx, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrAlreadyFinalized) {
+		s.observe("FINALIZE", "call_sessions", start, &err)
+	}
+	return err
+}
+
+// encodeMetadata marshals metadata for the JSONB column; it is passed as
+// a string because pq sends []byte as bytea
+func encodeMetadata(metadata map[string]interface{}) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode call session metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// scanCallSession reads callSessionColumns into a CallSession
+func scanCallSession(row rowScanner) (*CallSession, error) {
+	var (
+		cs                 CallSession
+		userID             sql.NullString
+		startedAt, endedAt sql.NullTime
+		duration           sql.NullInt64
+		metadata           []byte
+		status             string
+	)
+	err := row.Scan(&cs.ID, &userID, &cs.Token, &status, &startedAt, &endedAt, &duration,
+		&metadata, &cs.CreatedAt, &cs.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	cs.UserID = userID.String
+	cs.Status = session.Status(status)
+	cs.StartedAt = startedAt.Time
+	cs.EndedAt = nullTime(endedAt)
+	if duration.Valid {
+		seconds := int(duration.Int64)
+		cs.DurationSeconds = &seconds
+	}
+	if err := json.Unmarshal(metadata, &cs.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode call session metadata: %w", err)
+	}
+	return &cs, nil
+}
@@ -0,0 +1,728 @@
+//go:build integration
+
+package store
+
+// Integration tests run against a real Postgres:
+//
+//	go test -tags integration ./pkg/store/
+//
+// They use the database at PHONIC_TEST_DATABASE_URL when it is set, which
+// must already have the schema, e.g. the docker-compose database. Otherwise
+// they start a throwaway postgres container with docker, initialized from
+// infra/docker/postgres/init.sql as docker-compose.yml does. Each test
+// works under its own tenant, so a shared database can be reused.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+	"github.com/ArbajAnsari19/phonic/pkg/session"
+)
+
+// postgresImage matches the image in docker-compose.yml
+const postgresImage = "postgres:15-alpine"
+
+var testStore *Store
+
+func TestMain(m *testing.M) {
+	os.Exit(runIntegration(m))
+}
+
+func runIntegration(m *testing.M) int {
+	dsn := os.Getenv("PHONIC_TEST_DATABASE_URL")
+	if dsn == "" {
+		var stop func()
+		var err error
+		dsn, stop, err = startPostgres()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to start postgres: %v\n", err)
+			return 1
+		}
+		defer stop()
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	if err := waitForDB(db, time.Minute); err != nil {
+		fmt.Fprintf(os.Stderr, "database not ready: %v\n", err)
+		return 1
+	}
+
+	testStore = New(db, &logger.Logger{Logger: zap.NewNop()})
+	return m.Run()
+}
+
+// startPostgres runs a postgres container with the schema applied and
+// returns its DSN and a function that removes it
+func startPostgres() (string, func(), error) {
+	initSQL, err := filepath.Abs("../../infra/docker/postgres/init.sql")
+	if err != nil {
+		return "", nil, err
+	}
+	out, err := docker("run", "-d", "--rm",
+		"-e", "POSTGRES_DB=phonic",
+		"-e", "POSTGRES_USER=phonic",
+		"-e", "POSTGRES_PASSWORD=phonic_test",
+		"-p", "127.0.0.1::5432",
+		"-v", initSQL+":/docker-entrypoint-initdb.d/init.sql:ro",
+		postgresImage)
+	if err != nil {
+		return "", nil, err
+	}
+	id := strings.TrimSpace(out)
+	stop := func() { docker("rm", "-f", id) }
+
+	out, err = docker("port", id, "5432/tcp")
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	addr, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	return fmt.Sprintf("postgres://phonic:phonic_test@%s/phonic?sslmode=disable", addr), stop, nil
+}
+
+// docker runs a docker command and returns its output
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, exitErr.Stderr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// waitForDB pings db until it answers, e.g. while the container runs
+// its init scripts
+func waitForDB(db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// testTime is a fixed start time, at the microsecond precision Postgres
+// stores
+var testTime = time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)
+
+// newCallSession returns an unsaved call session of tenant
+func newCallSession(tenant string, status session.Status, started time.Time) *CallSession {
+	return &CallSession{
+		ID:        uuid.NewString(),
+		Token:     uuid.NewString(),
+		Status:    status,
+		StartedAt: started,
+		Metadata:  map[string]interface{}{"tenant_id": tenant},
+	}
+}
+
+// finished returns a call session that ended a minute after it started
+func finished(tenant string, status session.Status) *CallSession {
+	cs := newCallSession(tenant, status, testTime)
+	ended := testTime.Add(time.Minute)
+	seconds := 60
+	cs.EndedAt = &ended
+	cs.DurationSeconds = &seconds
+	return cs
+}
+
+func recordings() []*AudioFile {
+	return []*AudioFile{
+		{Type: AudioInput, Path: "calls/in.wav", Format: FormatWAV, SampleRate: 8000, DurationSeconds: 60},
+		{Type: AudioOutput, Path: "calls/out.wav", Format: FormatWAV, SampleRate: 24000, DurationSeconds: 60},
+	}
+}
+
+func TestFinalizeSession(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// live stores the session as active before it is finalized
+		live bool
+	}{
+		{name: "never stored"},
+		{name: "live row", live: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant := uuid.NewString()
+			cs := finished(tenant, session.StatusEnded)
+			if tt.live {
+				live := *cs
+				live.Status, live.EndedAt, live.DurationSeconds = session.StatusActive, nil, nil
+				if err := testStore.CreateCallSession(ctx, &live); err != nil {
+					t.Fatalf("CreateCallSession: %v", err)
+				}
+			}
+
+			files := recordings()
+			if err := testStore.FinalizeSession(ctx, cs, files); err != nil {
+				t.Fatalf("FinalizeSession: %v", err)
+			}
+			stored, err := testStore.GetCallSession(ctx, cs.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != session.StatusEnded || stored.EndedAt == nil || stored.DurationSeconds == nil || *stored.DurationSeconds != 60 {
+				t.Errorf("stored %+v, want an ended 60s call", stored)
+			}
+			for _, f := range files {
+				if f.ID == "" || f.SessionID != cs.ID {
+					t.Errorf("audio file %+v not stored for the session", f)
+				}
+			}
+
+			// A second finalize, e.g. from another instance handling the
+			// same event, changes nothing
+			again := finished(tenant, session.StatusFailed)
+			again.ID, again.Token = cs.ID, cs.Token
+			err = testStore.FinalizeSession(ctx, again, recordings())
+			if !errors.Is(err, ErrAlreadyFinalized) {
+				t.Fatalf("second FinalizeSession = %v, want ErrAlreadyFinalized", err)
+			}
+			stored, err = testStore.GetCallSession(ctx, cs.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != session.StatusEnded {
+				t.Errorf("status %s after second finalize, want ended", stored.Status)
+			}
+			if got, err := testStore.ListAudioFiles(ctx, cs.ID); err != nil || len(got) != 2 {
+				t.Errorf("ListAudioFiles = %d files, %v; want the first 2", len(got), err)
+			}
+		})
+	}
+}
+
+func TestFinalizeSessionRejectsLiveStatus(t *testing.T) {
+	cs := newCallSession(uuid.NewString(), session.StatusActive, testTime)
+	if err := testStore.FinalizeSession(context.Background(), cs, nil); err == nil {
+		t.Fatal("FinalizeSession accepted an active session")
+	}
+	if _, err := testStore.GetCallSession(context.Background(), cs.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCallSession = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFinalizeSessionRollsBack(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		live bool
+	}{
+		{name: "never stored"},
+		{name: "live row", live: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := finished(uuid.NewString(), session.StatusEnded)
+			if tt.live {
+				live := *cs
+				live.Status, live.EndedAt, live.DurationSeconds = session.StatusActive, nil, nil
+				if err := testStore.CreateCallSession(ctx, &live); err != nil {
+					t.Fatalf("CreateCallSession: %v", err)
+				}
+			}
+
+			// The second file's path is too long for audio_files.file_path,
+			// so its insert fails after the session and first file are
+			// written
+			files := recordings()
+			files[1].Path = strings.Repeat("p", 501)
+			if err := testStore.FinalizeSession(ctx, cs, files); err == nil {
+				t.Fatal("FinalizeSession succeeded with an invalid audio file")
+			}
+
+			stored, err := testStore.GetCallSession(ctx, cs.ID)
+			switch {
+			case tt.live && err != nil:
+				t.Fatalf("GetCallSession: %v", err)
+			case tt.live && (stored.Status != session.StatusActive || stored.EndedAt != nil):
+				t.Errorf("stored %s, want the row left active", stored.Status)
+			case !tt.live && !errors.Is(err, ErrNotFound):
+				t.Errorf("GetCallSession = %v, want the insert rolled back", err)
+			}
+			if got, err := testStore.ListAudioFiles(ctx, cs.ID); err != nil || len(got) != 0 {
+				t.Errorf("ListAudioFiles = %d files, %v; want none", len(got), err)
+			}
+
+			// Finalizing works once the files are fixed
+			if err := testStore.FinalizeSession(ctx, cs, recordings()); err != nil {
+				t.Errorf("FinalizeSession after rollback: %v", err)
+			}
+		})
+	}
+}
+
+func TestListCallSessions(t *testing.T) {
+	ctx := context.Background()
+	tenant := uuid.NewString()
+	user := &User{Email: tenant + "@example.com", Name: "Caller"}
+	if err := testStore.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	// Seven calls a minute apart, two of them starting at the same time
+	// so pages must break ties by ID
+	statuses := []session.Status{
+		session.StatusEnded, session.StatusFailed, session.StatusActive, session.StatusEnded,
+		session.StatusEnded, session.StatusPending, session.StatusFailed,
+	}
+	var all []*CallSession
+	for i, status := range statuses {
+		started := testTime.Add(time.Duration(i) * time.Minute)
+		if i == 4 {
+			started = all[3].StartedAt
+		}
+		cs := newCallSession(tenant, status, started)
+		if i < 2 {
+			cs.UserID = user.ID
+		}
+		if err := testStore.CreateCallSession(ctx, cs); err != nil {
+			t.Fatalf("CreateCallSession: %v", err)
+		}
+		all = append(all, cs)
+	}
+	// Another tenant's call is never listed
+	if err := testStore.CreateCallSession(ctx, newCallSession(uuid.NewString(), session.StatusEnded, testTime)); err != nil {
+		t.Fatal(err)
+	}
+
+	// newestFirst orders sessions as the query does
+	newestFirst := func(idx ...int) []string {
+		var ids []*CallSession
+		for _, i := range idx {
+			ids = append(ids, all[i])
+		}
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				a, b := ids[i], ids[j]
+				if b.StartedAt.After(a.StartedAt) || (b.StartedAt.Equal(a.StartedAt) && b.ID > a.ID) {
+					ids[i], ids[j] = b, a
+				}
+			}
+		}
+		out := make([]string, len(ids))
+		for i, cs := range ids {
+			out[i] = cs.ID
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		filter CallSessionFilter
+		want   []string
+	}{
+		{
+			name:   "tenant",
+			filter: CallSessionFilter{TenantID: tenant},
+			want:   newestFirst(0, 1, 2, 3, 4, 5, 6),
+		},
+		{
+			name:   "statuses",
+			filter: CallSessionFilter{TenantID: tenant, Statuses: []session.Status{session.StatusFailed, session.StatusPending}},
+			want:   newestFirst(1, 5, 6),
+		},
+		{
+			name:   "user",
+			filter: CallSessionFilter{UserID: user.ID},
+			want:   newestFirst(0, 1),
+		},
+		{
+			name:   "from inclusive to exclusive",
+			filter: CallSessionFilter{TenantID: tenant, From: all[1].StartedAt, To: all[5].StartedAt},
+			want:   newestFirst(1, 2, 3, 4),
+		},
+		{
+			name:   "status and time",
+			filter: CallSessionFilter{TenantID: tenant, Statuses: []session.Status{session.StatusEnded}, From: all[3].StartedAt},
+			want:   newestFirst(3, 4),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := testStore.ListCallSessions(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListCallSessions: %v", err)
+			}
+			if got := sessionIDs(page.Sessions); !equalIDs(got, tt.want) {
+				t.Errorf("listed %v, want %v", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("NextCursor %q on a single page", page.NextCursor)
+			}
+		})
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		for _, limit := range []int{1, 2, 3, 7} {
+			filter := CallSessionFilter{TenantID: tenant, Limit: limit}
+			var got []string
+			for pages := 0; ; pages++ {
+				if pages > len(all) {
+					t.Fatalf("limit %d: paging did not end", limit)
+				}
+				page, err := testStore.ListCallSessions(ctx, filter)
+				if err != nil {
+					t.Fatalf("limit %d: %v", limit, err)
+				}
+				if len(page.Sessions) > limit {
+					t.Fatalf("limit %d: page of %d", limit, len(page.Sessions))
+				}
+				got = append(got, sessionIDs(page.Sessions)...)
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			if want := newestFirst(0, 1, 2, 3, 4, 5, 6); !equalIDs(got, want) {
+				t.Errorf("limit %d: paged through %v, want %v", limit, got, want)
+			}
+		}
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := testStore.ListCallSessions(ctx, CallSessionFilter{TenantID: tenant, Cursor: "not a cursor"})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ListCallSessions = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+func sessionIDs(sessions []*CallSession) []string {
+	ids := make([]string, len(sessions))
+	for i, cs := range sessions {
+		ids[i] = cs.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	email := uuid.NewString() + "@example.com"
+	user := &User{Email: email, Name: "Ada"}
+	if err := testStore.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.ID == "" || !user.IsActive || user.CreatedAt.IsZero() {
+		t.Fatalf("created %+v, want an active user with an ID", user)
+	}
+	if err := testStore.CreateUser(ctx, &User{Email: email, Name: "Copy"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate email: %v, want ErrConflict", err)
+	}
+
+	byID, err := testStore.GetUser(ctx, user.ID)
+	if err != nil || byID.ID != user.ID || byID.Email != email || byID.Name != "Ada" || !byID.IsActive ||
+		!byID.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("GetUser = %+v, %v; want %+v", byID, err, user)
+	}
+	byEmail, err := testStore.GetUserByEmail(ctx, email)
+	if err != nil || byEmail.ID != user.ID {
+		t.Errorf("GetUserByEmail = %+v, %v", byEmail, err)
+	}
+
+	user.Name, user.IsActive = "Ada L.", false
+	before := user.UpdatedAt
+	if err := testStore.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if !user.UpdatedAt.After(before) {
+		t.Errorf("updated_at %v not after %v", user.UpdatedAt, before)
+	}
+	stored, err := testStore.GetUser(ctx, user.ID)
+	if err != nil || stored.Name != "Ada L." || stored.IsActive {
+		t.Errorf("stored %+v, %v; want the renamed, inactive user", stored, err)
+	}
+
+	other := &User{Email: uuid.NewString() + "@example.com", Name: "Other"}
+	if err := testStore.CreateUser(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	other.Email = email
+	if err := testStore.UpdateUser(ctx, other); !errors.Is(err, ErrConflict) {
+		t.Errorf("update to a taken email: %v, want ErrConflict", err)
+	}
+
+	missing := uuid.NewString()
+	if _, err := testStore.GetUser(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUser(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := testStore.GetUserByEmail(ctx, missing+"@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByEmail(missing) = %v, want ErrNotFound", err)
+	}
+	if err := testStore.UpdateUser(ctx, &User{ID: missing, Email: missing + "@example.com"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateUser(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	ctx := context.Background()
+	var created []*User
+	for i := range 3 {
+		user := &User{Email: fmt.Sprintf("%d-%s@example.com", i, uuid.NewString()), Name: "Lister"}
+		if err := testStore.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		created = append(created, user)
+	}
+	created[1].IsActive = false
+	if err := testStore.UpdateUser(ctx, created[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// The users just created are the newest, so page through them one at
+	// a time
+	var got []string
+	filter := UserFilter{Limit: 1}
+	for i := range created {
+		page, err := testStore.ListUsers(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		// Older users may follow the last of ours
+		if len(page.Users) != 1 || (page.NextCursor == "" && i < len(created)-1) {
+			t.Fatalf("page of %d users, cursor %q", len(page.Users), page.NextCursor)
+		}
+		got = append(got, page.Users[0].ID)
+		filter.Cursor = page.NextCursor
+	}
+	if want := []string{created[2].ID, created[1].ID, created[0].ID}; !equalIDs(got, want) {
+		t.Errorf("paged through %v, want %v", got, want)
+	}
+
+	page, err := testStore.ListUsers(ctx, UserFilter{ActiveOnly: true, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if got, want := userIDs(page.Users), []string{created[2].ID, created[0].ID}; !equalIDs(got, want) {
+		t.Errorf("active users %v, want %v", got, want)
+	}
+
+	if _, err := testStore.ListUsers(ctx, UserFilter{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("ListUsers = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func userIDs(users []*User) []string {
+	ids := make([]string, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	return ids
+}
+
+func TestCallSessionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	cs := newCallSession(uuid.NewString(), session.StatusActive, testTime)
+	cs.Metadata["caller"] = "+15550100"
+	if err := testStore.CreateCallSession(ctx, cs); err != nil {
+		t.Fatalf("CreateCallSession: %v", err)
+	}
+	if err := testStore.CreateCallSession(ctx, newCallSessionWithToken(cs.Token)); !errors.Is(err, ErrConflict) {
+		t.Errorf("duplicate token: %v, want ErrConflict", err)
+	}
+
+	byToken, err := testStore.GetCallSessionByToken(ctx, cs.Token)
+	if err != nil {
+		t.Fatalf("GetCallSessionByToken: %v", err)
+	}
+	if byToken.ID != cs.ID || byToken.Status != session.StatusActive || !byToken.StartedAt.Equal(testTime) ||
+		byToken.Metadata["caller"] != "+15550100" {
+		t.Errorf("GetCallSessionByToken = %+v, want %+v", byToken, cs)
+	}
+	if _, err := testStore.GetCallSessionByToken(ctx, uuid.NewString()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCallSessionByToken(missing) = %v, want ErrNotFound", err)
+	}
+
+	// A zero start time keeps the stored one
+	ended := testTime.Add(90 * time.Second)
+	seconds := 90
+	update := &CallSession{
+		ID:              cs.ID,
+		Status:          session.StatusEnded,
+		EndedAt:         &ended,
+		DurationSeconds: &seconds,
+		Metadata:        map[string]interface{}{"reason": "hangup"},
+	}
+	if err := testStore.UpdateCallSession(ctx, update); err != nil {
+		t.Fatalf("UpdateCallSession: %v", err)
+	}
+	stored, err := testStore.GetCallSession(ctx, cs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != session.StatusEnded || !stored.StartedAt.Equal(testTime) ||
+		stored.EndedAt == nil || !stored.EndedAt.Equal(ended) || stored.DurationSeconds == nil || *stored.DurationSeconds != 90 ||
+		stored.Metadata["reason"] != "hangup" || stored.Token != cs.Token {
+		t.Errorf("stored %+v after update", stored)
+	}
+	if !stored.UpdatedAt.After(cs.UpdatedAt) {
+		t.Errorf("updated_at %v not after %v", stored.UpdatedAt, cs.UpdatedAt)
+	}
+	if err := testStore.UpdateCallSession(ctx, &CallSession{ID: uuid.NewString(), Status: session.StatusEnded}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateCallSession(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+// newCallSessionWithToken returns an unsaved call session using token
+func newCallSessionWithToken(token string) *CallSession {
+	cs := newCallSession(uuid.NewString(), session.StatusPending, testTime)
+	cs.Token = token
+	return cs
+}
+
+func TestAudioFiles(t *testing.T) {
+	ctx := context.Background()
+	cs := finished(uuid.NewString(), session.StatusEnded)
+	if err := testStore.CreateCallSession(ctx, cs); err != nil {
+		t.Fatal(err)
+	}
+
+	full := &AudioFile{
+		SessionID:       cs.ID,
+		Type:            AudioProcessed,
+		Path:            "calls/mixed.opus",
+		SizeBytes:       48000,
+		DurationSeconds: 12.5,
+		Format:          FormatOpus,
+		SampleRate:      48000,
+		Channels:        2,
+	}
+	// Zero size, duration, format and sample rate are stored as unknown
+	// and read back as zero
+	bare := &AudioFile{SessionID: cs.ID, Type: AudioInput, Path: "calls/in.raw"}
+	for _, f := range []*AudioFile{full, bare} {
+		want := *f
+		if err := testStore.CreateAudioFile(ctx, f); err != nil {
+			t.Fatalf("CreateAudioFile: %v", err)
+		}
+		if want.Channels == 0 {
+			want.Channels = 1
+		}
+		want.ID, want.CreatedAt = f.ID, f.CreatedAt
+		if f.ID == "" || *f != want {
+			t.Errorf("created %+v, want %+v", f, want)
+		}
+		got, err := testStore.GetAudioFile(ctx, f.ID)
+		if err != nil {
+			t.Fatalf("GetAudioFile: %v", err)
+		}
+		if !got.CreatedAt.Equal(f.CreatedAt) {
+			t.Errorf("created_at %v, want %v", got.CreatedAt, f.CreatedAt)
+		}
+		got.CreatedAt = f.CreatedAt
+		if *got != *f {
+			t.Errorf("GetAudioFile = %+v, want %+v", got, f)
+		}
+	}
+
+	invalid := []*AudioFile{
+		{SessionID: cs.ID, Type: "stereo", Path: "calls/x.wav"},
+		{SessionID: cs.ID, Type: AudioInput},
+	}
+	for _, f := range invalid {
+		if err := testStore.CreateAudioFile(ctx, f); err == nil {
+			t.Errorf("CreateAudioFile(%+v) succeeded", f)
+		}
+	}
+
+	files, err := testStore.ListAudioFiles(ctx, cs.ID)
+	if err != nil {
+		t.Fatalf("ListAudioFiles: %v", err)
+	}
+	if len(files) != 2 || files[0].ID != full.ID || files[1].ID != bare.ID {
+		t.Errorf("ListAudioFiles = %v, want the two files oldest first", files)
+	}
+	if files, err := testStore.ListAudioFiles(ctx, uuid.NewString()); err != nil || len(files) != 0 {
+		t.Errorf("ListAudioFiles(other session) = %v, %v; want none", files, err)
+	}
+
+	if err := testStore.DeleteAudioFile(ctx, full.ID); err != nil {
+		t.Fatalf("DeleteAudioFile: %v", err)
+	}
+	if _, err := testStore.GetAudioFile(ctx, full.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetAudioFile after delete = %v, want ErrNotFound", err)
+	}
+	if err := testStore.DeleteAudioFile(ctx, full.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteAudioFile = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteCallSession(t *testing.T) {
+	ctx := context.Background()
+	tenant := uuid.NewString()
+	cs := finished(tenant, session.StatusEnded)
+	files := recordings()
+	if err := testStore.FinalizeSession(ctx, cs, files); err != nil {
+		t.Fatalf("FinalizeSession: %v", err)
+	}
+	kept := finished(tenant, session.StatusEnded)
+	keptFiles := recordings()
+	if err := testStore.FinalizeSession(ctx, kept, keptFiles); err != nil {
+		t.Fatal(err)
+	}
+
+	// The audio files reference the session, so they must go in the same
+	// statement
+	if err := testStore.DeleteCallSession(ctx, cs.ID); err != nil {
+		t.Fatalf("DeleteCallSession: %v", err)
+	}
+	if _, err := testStore.GetCallSession(ctx, cs.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetCallSession after delete = %v, want ErrNotFound", err)
+	}
+	for _, f := range files {
+		if _, err := testStore.GetAudioFile(ctx, f.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetAudioFile(%s) after delete = %v, want ErrNotFound", f.Path, err)
+		}
+	}
+
+	// Another session's files are untouched
+	if got, err := testStore.ListAudioFiles(ctx, kept.ID); err != nil || len(got) != len(keptFiles) {
+		t.Errorf("ListAudioFiles(kept) = %d files, %v; want %d", len(got), err, len(keptFiles))
+	}
+
+	if err := testStore.DeleteCallSession(ctx, cs.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteCallSession = %v, want ErrNotFound", err)
+	}
+
+	// A session without files deletes too
+	bare := newCallSession(tenant, session.StatusPending, testTime)
+	if err := testStore.CreateCallSession(ctx, bare); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.DeleteCallSession(ctx, bare.ID); err != nil {
+		t.Errorf("DeleteCallSession without files: %v", err)
+	}
+}
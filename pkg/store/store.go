@@ -0,0 +1,200 @@
+// Package store persists users, call sessions and audio files in Postgres.
+// The schema is created by infra/docker/postgres/init.sql.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+	"github.com/ArbajAnsari19/phonic/pkg/logger"
+)
+
+var (
+	// ErrNotFound is returned when a row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a unique constraint,
+	// e.g. a duplicate email or session token
+	ErrConflict = errors.New("already exists")
+	// ErrInvalidCursor is returned for a malformed page cursor
+	ErrInvalidCursor = errors.New("invalid page cursor")
+)
+
+// Page sizes for list queries
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Queries runs the typed queries, either directly on the database or in a
+// transaction
+type Queries struct {
+	db     querier
+	logger *logger.Logger
+}
+
+// Store is the Postgres repository
+type Store struct {
+	Queries
+	sqlDB *sql.DB
+}
+
+// New creates a store over db
+func New(db *sql.DB, log *logger.Logger) *Store {
+	if log == nil {
+		log = logger.GetGlobal()
+	}
+	return &Store{Queries: Queries{db: db, logger: log}, sqlDB: db}
+}
+
+// Open connects to the configured database and checks the connection
+func Open(ctx context.Context, cfg *config.Config, log *logger.Logger) (*Store, error) {
+	db, err := sql.Open("postgres", cfg.GetDatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return New(db, log), nil
+}
+
+// DB returns the underlying database, e.g. for health checks
+func (s *Store) DB() *sql.DB {
+	return s.sqlDB
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.sqlDB.Close()
+}
+
+// Tx is a transaction; its queries see each other's writes and are
+// committed together
+type Tx struct {
+	Queries
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise
+func (s *Store) InTx(ctx context.Context, fn func(tx *Tx) error) error {
+	sqlTx, err := s.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(&Tx{Queries: Queries{db: sqlTx, logger: s.logger}}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// observe logs a database operation started at start, with the error
+// *errp when it returns; a missing row is not logged as a failure
+func (q *Queries) observe(operation, table string, start time.Time, errp *error) {
+	err := *errp
+	if errors.Is(err, ErrNotFound) {
+		err = nil
+	}
+	q.logger.LogDatabaseOperation(operation, table, time.Since(start), err)
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// pageSize clamps a requested page size
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	return min(limit, maxPageSize)
+}
+
+// encodeCursor returns the cursor of a row in a list ordered by time and
+// ID, newest first
+func encodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+// decodeCursor reverses encodeCursor
+func decodeCursor(cursor string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(data), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return t, id, nil
+}
+
+// conditions builds a WHERE clause with numbered arguments
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add appends a clause in which each ? is replaced by the next argument
+func (c *conditions) add(clause string, args ...interface{}) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		clause = strings.Replace(clause, "?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+// where returns the WHERE clause, or an empty string
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime converts sql.NullTime to a pointer
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
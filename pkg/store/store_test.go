@@ -0,0 +1,63 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		id   string
+	}{
+		{"utc", time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC), "0b7e6f5c-1111-4d2e-9c4a-000000000001"},
+		// Postgres keeps microseconds
+		{"microseconds", time.Date(2025, 3, 4, 10, 0, 0, 123456000, time.UTC), "a"},
+		{"other zone", time.Date(2025, 3, 4, 15, 30, 0, 0, time.FixedZone("IST", 5*3600+1800)), "b"},
+		// Only the first separator splits the cursor
+		{"separator in id", time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC), "x|y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := encodeCursor(tt.t, tt.id)
+			got, id, err := decodeCursor(cursor)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q): %v", cursor, err)
+			}
+			if !got.Equal(tt.t) || id != tt.id {
+				t.Errorf("decodeCursor = %v, %q; want %v, %q", got, id, tt.t, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	cursors := map[string]string{
+		"empty":             "",
+		"not base64":        "not a cursor",
+		"padded base64":     base64.URLEncoding.EncodeToString([]byte("2025-03-04T10:00:00Z|a")),
+		"no separator":      encode("2025-03-04T10:00:00Z"),
+		"empty id":          encode("2025-03-04T10:00:00Z|"),
+		"bad time":          encode("yesterday|a"),
+		"time without zone": encode("2025-03-04T10:00:00|a"),
+	}
+	for name, cursor := range cursors {
+		if _, _, err := decodeCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decodeCursor(%q) = %v, want ErrInvalidCursor", name, cursor, err)
+		}
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	for limit, want := range map[int]int{-1: defaultPageSize, 0: defaultPageSize, 1: 1, 500: 500, 501: maxPageSize} {
+		if got := pageSize(limit); got != want {
+			t.Errorf("pageSize(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// User is a row of the users table
+type User struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserFilter selects users for ListUsers
+type UserFilter struct {
+	// ActiveOnly skips deactivated users
+	ActiveOnly bool
+	// Limit is the page size; default 50, at most 500
+	Limit int
+	// Cursor continues from a previous page's NextCursor
+	Cursor string
+}
+
+// UserPage is a page of users, newest first
+type UserPage struct {
+	Users []*User `json:"users"`
+	// NextCursor fetches the next page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const userColumns = `id, email, name, COALESCE(is_active, TRUE), created_at, updated_at`
+
+// CreateUser inserts an active user, filling in its ID and timestamps
+func (q *Queries) CreateUser(ctx context.Context, user *User) (err error) {
+	defer q.observe("INSERT", "users", time.Now(), &err)
+	err = q.db.QueryRowContext(ctx, `
+		INSERT INTO users (email, name)
+		VALUES ($1, $2)
+		RETURNING `+userColumns,
+		user.Email, user.Name,
+	).Scan(&user.ID, &user.Email, &user.Name, &user.IsActive, &user.CreatedAt, &user.UpdatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("user %s: %w", user.Email, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetUser returns the user with id
+func (q *Queries) GetUser(ctx context.Context, id string) (user *User, err error) {
+	defer q.observe("SELECT", "users", time.Now(), &err)
+	user, err = q.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	return user, err
+}
+
+// GetUserByEmail returns the user with email
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (user *User, err error) {
+	defer q.observe("SELECT", "users", time.Now(), &err)
+	user, err = q.getUser(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+	return user, err
+}
+
+// getUser runs a query for one user
+func (q *Queries) getUser(ctx context.Context, query string, args ...interface{}) (*User, error) {
+	user, err := scanUser(q.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUser stores the user's email, name and active flag
+func (q *Queries) UpdateUser(ctx context.Context, user *User) (err error) {
+	defer q.observe("UPDATE", "users", time.Now(), &err)
+	err = q.db.QueryRowContext(ctx, `
+		UPDATE users SET email = $2, name = $3, is_active = $4
+		WHERE id = $1
+		RETURNING updated_at`,
+		user.ID, user.Email, user.Name, user.IsActive,
+	).Scan(&user.UpdatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case isUniqueViolation(err):
+		return fmt.Errorf("user %s: %w", user.Email, ErrConflict)
+	case err != nil:
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// ListUsers returns a page of users, newest first
+func (q *Queries) ListUsers(ctx context.Context, filter UserFilter) (page *UserPage, err error) {
+	defer q.observe("SELECT", "users", time.Now(), &err)
+
+	var conds conditions
+	if filter.ActiveOnly {
+		conds.add("is_active")
+	}
+	if filter.Cursor != "" {
+		t, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conds.add("(created_at, id) < (?, ?)", t, id)
+	}
+	limit := pageSize(filter.Limit)
+	conds.args = append(conds.args, limit+1)
+
+	rows, err := q.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s FROM users
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d`, userColumns, conds.where(), len(conds.args)), conds.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	page = &UserPage{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		page.Users = append(page.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	if len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+		last := page.Users[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// scanUser reads userColumns into a User
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.IsActive, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
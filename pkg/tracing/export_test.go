@@ -0,0 +1,4 @@
+package tracing
+
+// SetLegacySampler exposes setLegacySampler to the external tests
+var SetLegacySampler = setLegacySampler
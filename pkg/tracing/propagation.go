@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// HeaderTraceID is the legacy HTTP trace header, kept for older clients
+	HeaderTraceID = "X-Trace-ID"
+	// HeaderRequestID carries the per-hop request ID
+	HeaderRequestID = "X-Request-ID"
+	// MetadataTraceID is the legacy gRPC trace metadata key
+	MetadataTraceID = "trace-id"
+	// MetadataRequestID carries the request ID over gRPC
+	MetadataRequestID = "request-id"
+	// MetadataSessionID carries the call session ID over gRPC
+	MetadataSessionID = "session-id"
+	// MetadataTenantID carries the tenant ID over gRPC
+	MetadataTenantID = "tenant-id"
+)
+
+// propagator is used directly rather than through the otel global so
+// traceparent is honoured even before Setup has been called
+var propagator = Propagator()
+
+// MetadataCarrier adapts gRPC metadata to propagation.TextMapCarrier
+type MetadataCarrier metadata.MD
+
+// Get returns the first value for key
+func (c MetadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set replaces the values for key
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys lists the metadata keys
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ExtractHTTP reads traceparent/tracestate from h, falling back to the
+// legacy X-Trace-ID header when no W3C context is present
+func ExtractHTTP(ctx context.Context, h http.Header) context.Context {
+	ctx = propagator.Extract(ctx, propagation.HeaderCarrier(h))
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	return withLegacyTraceID(ctx, h.Get(HeaderTraceID))
+}
+
+// InjectHTTP writes traceparent/tracestate and the legacy X-Trace-ID to h
+func InjectHTTP(ctx context.Context, h http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(h))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		h.Set(HeaderTraceID, sc.TraceID().String())
+	}
+}
+
+// ExtractGRPC reads traceparent/tracestate from md, falling back to the
+// legacy trace-id key when no W3C context is present
+func ExtractGRPC(ctx context.Context, md metadata.MD) context.Context {
+	ctx = propagator.Extract(ctx, MetadataCarrier(md))
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	var legacy string
+	if ids := md.Get(MetadataTraceID); len(ids) > 0 {
+		legacy = ids[0]
+	}
+	return withLegacyTraceID(ctx, legacy)
+}
+
+// InjectGRPC writes traceparent/tracestate and the legacy trace-id to md
+func InjectGRPC(ctx context.Context, md metadata.MD) {
+	propagator.Inject(ctx, MetadataCarrier(md))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		md.Set(MetadataTraceID, sc.TraceID().String())
+	}
+}
+
+// ParseTraceID parses a 32-character W3C trace ID or a legacy
+// 16-character ID, which is left-padded with zeros as W3C recommends
+func ParseTraceID(s string) (trace.TraceID, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) == 16 {
+		s = strings.Repeat("0", 16) + s
+	}
+	id, err := trace.TraceIDFromHex(s)
+	if err != nil {
+		return trace.TraceID{}, false
+	}
+	return id, true
+}
+
+// withLegacyTraceID turns a legacy trace ID into a remote parent so spans
+// join the caller's trace. The legacy ID carries no sampling flag, so the
+// legacy sampler decides, the same way it would for a new trace.
+func withLegacyTraceID(ctx context.Context, legacy string) context.Context {
+	if legacy == "" {
+		return ctx
+	}
+	traceID, ok := ParseTraceID(legacy)
+	if !ok {
+		return ctx
+	}
+
+	var spanID trace.SpanID
+	if _, err := rand.Read(spanID[:]); err != nil {
+		return ctx
+	}
+
+	var flags trace.TraceFlags
+	result := legacySampler.Load().ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       traceID,
+		Name:          "legacy-trace-id",
+	})
+	if result.Decision == sdktrace.RecordAndSample {
+		flags = trace.FlagsSampled
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
@@ -0,0 +1,139 @@
+// Package tracing provides OpenTelemetry tracing setup and W3C Trace Context
+// propagation for Phonic AI Calling Agent
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ArbajAnsari19/phonic/pkg/config"
+)
+
+// InstrumentationName is the tracer name used by Phonic instrumentation
+const InstrumentationName = "github.com/ArbajAnsari19/phonic"
+
+// ShutdownFunc flushes and stops the tracer provider
+type ShutdownFunc func(ctx context.Context) error
+
+// samplerHolder lets an atomic.Pointer hold any Sampler implementation
+type samplerHolder struct{ sdktrace.Sampler }
+
+// legacySampler decides whether a trace continued from a legacy trace ID
+// is sampled, since the legacy header carries no flags. Setup replaces it
+// with the configured root sampler.
+var legacySampler atomic.Pointer[samplerHolder]
+
+func init() {
+	setLegacySampler(sdktrace.AlwaysSample())
+}
+
+// setLegacySampler replaces the sampler used for legacy trace IDs
+func setLegacySampler(s sdktrace.Sampler) {
+	legacySampler.Store(&samplerHolder{s})
+}
+
+// Setup installs the global tracer provider and W3C propagator described
+// by cfg.Tracing. When tracing is disabled spans are still created, so
+// trace IDs appear in logs and flow between services, but none are
+// sampled or exported.
+func Setup(ctx context.Context, cfg *config.Config) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(Propagator())
+
+	if !cfg.Tracing.Enabled || cfg.Tracing.Exporter == "none" {
+		provider, err := NewProvider(cfg, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())))
+		if err != nil {
+			return nil, err
+		}
+		otel.SetTracerProvider(provider)
+		setLegacySampler(sdktrace.NeverSample())
+		return provider.Shutdown, nil
+	}
+
+	exporter, err := newExporter(ctx, cfg.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	provider, err := NewProvider(cfg, sdktrace.WithBatcher(exporter))
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(provider)
+	setLegacySampler(rootSampler(cfg))
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider with the service resource and the
+// configured sampler. Extra options typically add span processors.
+func NewProvider(cfg *config.Config, opts ...sdktrace.TracerProviderOption) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.App.Name),
+		semconv.ServiceVersion(cfg.App.Version),
+		semconv.DeploymentEnvironment(cfg.App.Environment),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(rootSampler(cfg))),
+	}, opts...)
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// rootSampler samples traces that start here by trace ID, so every
+// service with the same ratio makes the same decision
+func rootSampler(cfg *config.Config) sdktrace.Sampler {
+	return sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio)
+}
+
+// Propagator returns the W3C traceparent/tracestate plus baggage propagator
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+}
+
+// Tracer returns the Phonic tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// newExporter builds the OTLP exporter selected by cfg.Exporter
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "otlp-http":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(cfg.Timeout))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if cfg.Timeout > 0 {
+			opts = append(opts, otlptracegrpc.WithTimeout(cfg.Timeout))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+}
@@ -0,0 +1,181 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+	"github.com/ArbajAnsari19/phonic/pkg/tracing/tracingtest"
+)
+
+func TestSpanParenting(t *testing.T) {
+	_, exporter := tracingtest.NewProvider()
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "parent")
+	_, child := tracing.Tracer().Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if c.Name != "child" || p.Name != "parent" {
+		t.Fatalf("spans %s, %s; want child, parent", c.Name, p.Name)
+	}
+	if c.Parent.SpanID() != p.SpanContext.SpanID() {
+		t.Errorf("child parent %s, want %s", c.Parent.SpanID(), p.SpanContext.SpanID())
+	}
+	if c.SpanContext.TraceID() != p.SpanContext.TraceID() {
+		t.Error("child is in a different trace")
+	}
+	if p.Parent.IsValid() {
+		t.Error("root span has a parent")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(t *testing.T, ctx context.Context) func(context.Context) context.Context
+	}{
+		{
+			name: "http",
+			inject: func(t *testing.T, ctx context.Context) func(context.Context) context.Context {
+				h := http.Header{}
+				tracing.InjectHTTP(ctx, h)
+				if h.Get("traceparent") == "" {
+					t.Error("traceparent not injected")
+				}
+				if got, want := h.Get(tracing.HeaderTraceID), trace.SpanContextFromContext(ctx).TraceID().String(); got != want {
+					t.Errorf("%s = %q, want %q", tracing.HeaderTraceID, got, want)
+				}
+				return func(ctx context.Context) context.Context { return tracing.ExtractHTTP(ctx, h) }
+			},
+		},
+		{
+			name: "grpc",
+			inject: func(t *testing.T, ctx context.Context) func(context.Context) context.Context {
+				md := metadata.MD{}
+				tracing.InjectGRPC(ctx, md)
+				if len(md.Get("traceparent")) == 0 {
+					t.Error("traceparent not injected")
+				}
+				if got, want := md.Get(tracing.MetadataTraceID), trace.SpanContextFromContext(ctx).TraceID().String(); len(got) != 1 || got[0] != want {
+					t.Errorf("%s = %v, want %q", tracing.MetadataTraceID, got, want)
+				}
+				return func(ctx context.Context) context.Context { return tracing.ExtractGRPC(ctx, md) }
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, exporter := tracingtest.NewProvider()
+
+			ctx, client := tracing.Tracer().Start(context.Background(), "client")
+			extract := tt.inject(t, ctx)
+			client.End()
+
+			remote := trace.SpanContextFromContext(extract(context.Background()))
+			if !remote.IsRemote() || !remote.IsSampled() {
+				t.Fatalf("extracted %+v, want a sampled remote parent", remote)
+			}
+			_, server := tracing.Tracer().Start(trace.ContextWithRemoteSpanContext(context.Background(), remote), "server")
+			server.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != 2 {
+				t.Fatalf("recorded %d spans, want 2", len(spans))
+			}
+			c, s := spans[0], spans[1]
+			if s.SpanContext.TraceID() != c.SpanContext.TraceID() {
+				t.Error("server span is in a different trace")
+			}
+			if s.Parent.SpanID() != c.SpanContext.SpanID() || !s.Parent.IsRemote() {
+				t.Errorf("server parent %+v, want remote %s", s.Parent, c.SpanContext.SpanID())
+			}
+		})
+	}
+}
+
+func TestUnsampledTraceparentIsNotRecorded(t *testing.T) {
+	_, exporter := tracingtest.NewProvider()
+
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx := tracing.ExtractHTTP(context.Background(), h)
+	if sc := trace.SpanContextFromContext(ctx); !sc.IsValid() || sc.IsSampled() {
+		t.Fatalf("extracted %+v, want a valid unsampled parent", sc)
+	}
+	_, span := tracing.Tracer().Start(ctx, "server")
+	span.End()
+
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Errorf("recorded %d spans for an unsampled parent", n)
+	}
+}
+
+func TestLegacyTraceID(t *testing.T) {
+	defer tracing.SetLegacySampler(sdktrace.AlwaysSample())
+
+	tests := []struct {
+		name    string
+		header  string
+		sampler sdktrace.Sampler
+		traceID string
+		sampled bool
+	}{
+		{"w3c length", "4BF92F3577B34DA6A3CE929D0E0E4736", sdktrace.AlwaysSample(), "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"short id is padded", "a3ce929d0e0e4736", sdktrace.AlwaysSample(), "0000000000000000a3ce929d0e0e4736", true},
+		{"sampler says no", "4bf92f3577b34da6a3ce929d0e0e4736", sdktrace.NeverSample(), "4bf92f3577b34da6a3ce929d0e0e4736", false},
+		{"ratio is by trace id", "4bf92f3577b34da6ffffffffffffffff", sdktrace.TraceIDRatioBased(0.5), "4bf92f3577b34da6ffffffffffffffff", false},
+		{"invalid", "not-a-trace-id", sdktrace.AlwaysSample(), "", false},
+		{"missing", "", sdktrace.AlwaysSample(), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracing.SetLegacySampler(tt.sampler)
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set(tracing.HeaderTraceID, tt.header)
+			}
+
+			sc := trace.SpanContextFromContext(tracing.ExtractHTTP(context.Background(), h))
+			if tt.traceID == "" {
+				if sc.IsValid() {
+					t.Fatalf("extracted %+v from %q, want nothing", sc, tt.header)
+				}
+				return
+			}
+			if got := sc.TraceID().String(); got != tt.traceID {
+				t.Errorf("trace ID %s, want %s", got, tt.traceID)
+			}
+			if !sc.IsRemote() {
+				t.Error("legacy parent is not remote")
+			}
+			if sc.IsSampled() != tt.sampled {
+				t.Errorf("sampled = %v, want %v", sc.IsSampled(), tt.sampled)
+			}
+		})
+	}
+}
+
+func TestTraceparentWinsOverLegacy(t *testing.T) {
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Set(tracing.HeaderTraceID, "a3ce929d0e0e4736")
+
+	sc := trace.SpanContextFromContext(tracing.ExtractHTTP(context.Background(), h))
+	if got := sc.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID %s, want the traceparent's", got)
+	}
+	if got := sc.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("span ID %s, want the traceparent's", got)
+	}
+}
@@ -0,0 +1,23 @@
+// Package tracingtest provides an in-memory tracer provider for tests
+package tracingtest
+
+import (
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ArbajAnsari19/phonic/pkg/tracing"
+)
+
+// NewProvider installs a global tracer provider that records spans
+// synchronously in memory and returns the exporter to inspect them
+func NewProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		sdktrace.WithSyncer(exporter),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(tracing.Propagator())
+	return provider, exporter
+}